@@ -6,6 +6,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	scope_probe "github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 )
@@ -23,10 +24,12 @@ func makeRawReportHandler(rep Reporter) CtxHandlerFunc {
 }
 
 type probeDesc struct {
-	ID       string    `json:"id"`
-	Hostname string    `json:"hostname"`
-	Version  string    `json:"version"`
-	LastSeen time.Time `json:"lastSeen"`
+	ID       string               `json:"id"`
+	Hostname string               `json:"hostname"`
+	Version  string               `json:"version"`
+	LastSeen time.Time            `json:"lastSeen"`
+	Healthy  bool                 `json:"healthy"`
+	Health   []scope_probe.Health `json:"health"`
 }
 
 // Probe handler
@@ -52,11 +55,14 @@ func makeProbeHandler(rep Reporter) CtxHandlerFunc {
 			id, _ := n.Latest.Lookup(report.ControlProbeID)
 			hostname, _ := n.Latest.Lookup(host.HostName)
 			version, dt, _ := n.Latest.LookupEntry(host.ScopeVersion)
+			health := scope_probe.ExtractHealth(n)
 			result = append(result, probeDesc{
 				ID:       id,
 				Hostname: hostname,
 				Version:  version,
 				LastSeen: dt,
+				Healthy:  scope_probe.Healthy(health),
+				Health:   health,
 			})
 		}
 		respondWith(w, http.StatusOK, result)
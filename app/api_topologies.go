@@ -32,9 +32,18 @@ const (
 	servicesID             = "services"
 	hostsID                = "hosts"
 	weaveID                = "weave"
+	calicoID               = "calico"
+	ciliumID               = "cilium"
+	flannelID              = "flannel"
 	ecsTasksID             = "ecs-tasks"
 	ecsServicesID          = "ecs-services"
 	swarmServicesID        = "swarm-services"
+	swarmTasksID           = "swarm-tasks"
+	swarmNodesID           = "swarm-nodes"
+	swarmNetworksID        = "swarm-networks"
+	netDevicesID           = "net-devices"
+
+	pluginTopologyRank = 5
 )
 
 var (
@@ -64,6 +73,37 @@ func namespaceFilters(namespaces []string, noneLabel string) APITopologyOptionGr
 func updateFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
 	topologies = updateKubeFilters(rpt, topologies)
 	topologies = updateSwarmFilters(rpt, topologies)
+	topologies = updateClusterFilters(rpt, topologies)
+	return topologies
+}
+
+// updateClusterFilters adds a filter of the clusters of federating apps to all
+// topologies.
+func updateClusterFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	clusters := map[string]struct{}{}
+	for _, n := range rpt.Host.Nodes {
+		if cluster, ok := n.Latest.Lookup(report.Cluster); ok {
+			clusters[cluster] = struct{}{}
+		}
+	}
+	if len(clusters) == 0 {
+		return topologies
+	}
+	names := []string{}
+	for cluster := range clusters {
+		names = append(names, cluster)
+	}
+	sort.Strings(names)
+	options := APITopologyOptionGroup{ID: "cluster", Default: "", SelectType: "union", NoneLabel: "All Clusters"}
+	for _, cluster := range names {
+		options.Options = append(options.Options, APITopologyOption{
+			Value: cluster, Label: cluster, filter: render.IsCluster(cluster), filterPseudo: false,
+		})
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{options})
+	}
 	return topologies
 }
 
@@ -272,6 +312,28 @@ func MakeRegistry() *Registry {
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          swarmTasksID,
+			parent:      swarmServicesID,
+			renderer:    render.SwarmTaskRenderer,
+			Name:        "tasks",
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          swarmNodesID,
+			parent:      swarmServicesID,
+			renderer:    render.SwarmNodeRenderer,
+			Name:        "nodes",
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          swarmNetworksID,
+			parent:      swarmServicesID,
+			renderer:    render.SwarmNetworkRenderer,
+			Name:        "networks",
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:       hostsID,
 			renderer: render.HostRenderer,
@@ -284,6 +346,34 @@ func MakeRegistry() *Registry {
 			renderer: render.WeaveRenderer,
 			Name:     "Weave Net",
 		},
+		APITopologyDesc{
+			id:          calicoID,
+			parent:      hostsID,
+			renderer:    render.CalicoRenderer,
+			Name:        "Calico",
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          ciliumID,
+			parent:      hostsID,
+			renderer:    render.CiliumRenderer,
+			Name:        "Cilium",
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          flannelID,
+			parent:      hostsID,
+			renderer:    render.FlannelRenderer,
+			Name:        "Flannel",
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          netDevicesID,
+			parent:      hostsID,
+			renderer:    render.NetDeviceRenderer,
+			Name:        "Interfaces",
+			HideIfEmpty: true,
+		},
 	)
 
 	return registry
@@ -441,16 +531,75 @@ func (r *Registry) get(name string) (APITopologyDesc, bool) {
 	return t, ok
 }
 
-func (r *Registry) walk(f func(APITopologyDesc)) {
+// pluginTopologies returns an API topology for each of the report's plugin
+// topologies. They are derived from the report on every request, rather than
+// registered, so they disappear along with the plugins defining them.
+// Plugin topologies are shown under their own name; those which clash with
+// a registered API topology are not shown.
+func (r *Registry) pluginTopologies(rpt report.Report) []APITopologyDesc {
 	r.RLock()
 	defer r.RUnlock()
 	descs := []APITopologyDesc{}
+	for _, name := range rpt.PluginTopologyNames() {
+		if _, ok := r.items[name]; ok || !report.IsPluginTopology(name) {
+			continue
+		}
+		label := name
+		if t := rpt.PluginTopologies[name]; t.LabelPlural != "" {
+			label = strings.Title(t.LabelPlural)
+		}
+		descs = append(descs, APITopologyDesc{
+			id:          name,
+			renderer:    render.TopologySelector(name),
+			Name:        label,
+			Rank:        pluginTopologyRank,
+			HideIfEmpty: true,
+			URL:         apiTopologyURL + url.PathEscape(name),
+		})
+	}
+	return descs
+}
+
+// getForReport returns the registered API topology with the given name, or
+// else the report's plugin topology with that name.
+func (r *Registry) getForReport(name string, rpt report.Report) (APITopologyDesc, bool) {
+	if t, ok := r.get(name); ok {
+		return t, true
+	}
+	for _, t := range r.pluginTopologies(rpt) {
+		if t.id == name {
+			return t, true
+		}
+	}
+	return APITopologyDesc{}, false
+}
+
+// exists returns true if the topology is known, either as one of the
+// built-in topologies or as a plugin topology in the current report.
+func (r *Registry) exists(ctx context.Context, rep Reporter, topologyID string) bool {
+	if _, ok := r.get(topologyID); ok {
+		return true
+	}
+	rpt, err := rep.Report(ctx, time.Now())
+	if err != nil {
+		return false
+	}
+	_, ok := r.getForReport(topologyID, rpt)
+	return ok
+}
+
+// walk calls f with each of the top-level API topologies, including the
+// plugin topologies of the report.
+func (r *Registry) walk(rpt report.Report, f func(APITopologyDesc)) {
+	descs := r.pluginTopologies(rpt)
+	r.RLock()
 	for _, desc := range r.items {
 		if desc.parent != "" {
 			continue
 		}
 		descs = append(descs, desc)
 	}
+	r.RUnlock()
 	sort.Sort(byName(descs))
 	for _, desc := range descs {
 		f(desc)
@@ -473,7 +622,7 @@ func (r *Registry) makeTopologyList(rep Reporter) CtxHandlerFunc {
 func (r *Registry) renderTopologies(rpt report.Report, req *http.Request) []APITopologyDesc {
 	topologies := []APITopologyDesc{}
 	req.ParseForm()
-	r.walk(func(desc APITopologyDesc) {
+	r.walk(rpt, func(desc APITopologyDesc) {
 		renderer, filter, _ := r.RendererForTopology(desc.id, req.Form, rpt)
 		desc.Stats = computeStats(rpt, renderer, filter)
 		for i, sub := range desc.SubTopologies {
@@ -509,7 +658,7 @@ func computeStats(rpt report.Report, renderer render.Renderer, transformer rende
 
 // RendererForTopology ..
 func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt report.Report) (render.Renderer, render.Transformer, error) {
-	topology, ok := r.get(topologyID)
+	topology, ok := r.getForReport(topologyID, rpt)
 	if !ok {
 		return nil, nil, fmt.Errorf("topology not found: %s", topologyID)
 	}
@@ -547,15 +696,15 @@ func (r *Registry) captureRenderer(rep Reporter, f rendererHandler) CtxHandlerFu
 			topologyID = mux.Vars(req)["topology"]
 			timestamp  = deserializeTimestamp(req.URL.Query().Get("timestamp"))
 		)
-		if _, ok := r.get(topologyID); !ok {
-			http.NotFound(w, req)
-			return
-		}
 		rpt, err := rep.Report(ctx, timestamp)
 		if err != nil {
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
+		if _, ok := r.getForReport(topologyID, rpt); !ok {
+			http.NotFound(w, req)
+			return
+		}
 		req.ParseForm()
 		renderer, filter, err := r.RendererForTopology(topologyID, req.Form, rpt)
 		if err != nil {
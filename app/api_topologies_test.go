@@ -158,6 +158,32 @@ func TestRendererForTopologyNoFiltering(t *testing.T) {
 	}
 }
 
+func TestRendererForTopologyClusterFiltering(t *testing.T) {
+	// The reports of federated clusters
+	input := fixture.Report.Copy()
+	for topology, clusters := range map[*report.Topology]map[string]string{
+		&input.Host:      {fixture.ClientHostNodeID: "a", fixture.ServerHostNodeID: "b"},
+		&input.Container: {fixture.ClientContainerNodeID: "a", fixture.ServerContainerNodeID: "b"},
+	} {
+		for id, cluster := range clusters {
+			topology.Nodes[id] = topology.Nodes[id].WithLatests(map[string]string{report.Cluster: cluster})
+		}
+	}
+
+	topologyRegistry := app.MakeRegistry()
+	renderer, filter, err := topologyRegistry.RendererForTopology("containers", url.Values{"cluster": {"a"}}, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+	have := render.Render(input, renderer, filter).Nodes
+	if _, ok := have[fixture.ClientContainerNodeID]; !ok {
+		t.Errorf("Expected the container of cluster a")
+	}
+	if _, ok := have[fixture.ServerContainerNodeID]; ok {
+		t.Errorf("Expected the container of cluster b to be filtered out")
+	}
+}
+
 func getTestContainerLabelFilterTopologySummary(t *testing.T, exclude bool) (detailed.NodeSummaries, error) {
 	ts := topologyServer()
 	defer ts.Close()
@@ -248,3 +274,66 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 		t.Error("Could not find pods topology")
 	}
 }
+
+func TestAPITopologyEscapesPluginTopologies(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, router)
+	app.RegisterTopologyRoutes(router, c, map[string]bool{})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	rpt := report.MakeReport()
+	queues := report.MakeTopology().WithLabel("queue", "queues")
+	queues.AddNode(report.MakeNode("queue1").WithTopology("my queues"))
+	rpt.PluginTopologies["my queues"] = queues
+	buf := &bytes.Buffer{}
+	if err := codec.NewEncoder(buf, &codec.MsgpackHandle{}).Encode(rpt); err != nil {
+		t.Fatalf("Msgpack encoding error: %s", err)
+	}
+	checkRequest(t, ts, "POST", "/api/report", buf.Bytes())
+
+	var topologies []app.APITopologyDesc
+	body := getRawJSON(t, ts, "/api/topology")
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topologies); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	for _, topology := range topologies {
+		if topology.Name == "Queues" {
+			equals(t, "/api/topology/my%20queues", topology.URL)
+			getRawJSON(t, ts, topology.URL)
+			return
+		}
+	}
+	t.Errorf("Could not find the queues topology in %v", topologies)
+}
+
+func TestRendererForPluginTopology(t *testing.T) {
+	registry := app.MakeRegistry()
+	rpt := report.MakeReport()
+	if _, _, err := registry.RendererForTopology("queues", url.Values{}, rpt); err == nil {
+		t.Fatal("expected an error for an unknown topology")
+	}
+
+	queues := report.MakeTopology().WithShape(report.Square).WithLabel("queue", "queues")
+	queues.AddNode(report.MakeNodeWith("queue1", map[string]string{report.Name: "orders"}).WithTopology("queues"))
+	rpt.PluginTopologies["queues"] = queues
+
+	renderer, filter, err := registry.RendererForTopology("queues", url.Values{}, rpt)
+	if err != nil {
+		t.Fatalf("Topology not registered: %v", err)
+	}
+	nodes := render.Render(rpt, renderer, filter).Nodes
+	summaries := detailed.Summaries(detailed.RenderContext{Report: rpt}, nodes)
+	summary, ok := summaries["queue1"]
+	if !ok {
+		t.Fatalf("Node not rendered: %v", summaries)
+	}
+	equals(t, "orders", summary.Label)
+	equals(t, report.Square, summary.Shape)
+
+	// Plugin topologies are not kept once they are gone from the report
+	if _, _, err := registry.RendererForTopology("queues", url.Values{}, report.MakeReport()); err == nil {
+		t.Error("expected an error for a topology gone from the report")
+	}
+}
@@ -33,6 +33,7 @@ func RenderContextForReporter(rep Reporter, r report.Report) detailed.RenderCont
 	rc := detailed.RenderContext{Report: r}
 	if wrep, ok := rep.(WebReporter); ok {
 		rc.MetricsGraphURL = wrep.MetricsGraphURL
+		rc.MetricQueries = wrep.MetricQueries
 	}
 	return rc
 }
@@ -41,9 +42,13 @@ type rendererHandler func(context.Context, render.Renderer, render.Transformer,
 
 // Full topology.
 func handleTopology(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
-	respondWith(w, http.StatusOK, APITopology{
-		Nodes: detailed.Summaries(rc, render.Render(rc.Report, renderer, transformer).Nodes),
-	})
+	topologyID := mux.Vars(r)["topology"]
+	summaries := renderSummaries(rc, topologyID, r.Form, renderer, transformer)
+	if format := r.Form.Get("format"); format != "" && format != "json" {
+		exportTopology(w, r, topologyID, format, summaries)
+		return
+	}
+	respondWith(w, http.StatusOK, APITopology{Nodes: summaries})
 }
 
 // Individual nodes.
@@ -85,13 +90,10 @@ func handleWebsocket(
 		respondWith(w, http.StatusInternalServerError, err)
 		return
 	}
-	loop := websocketLoop
-	if t := r.Form.Get("t"); t != "" {
-		var err error
-		if loop, err = time.ParseDuration(t); err != nil {
-			respondWith(w, http.StatusBadRequest, t)
-			return
-		}
+	loop, err := parseLoop(r)
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, r.Form.Get("t"))
+		return
 	}
 
 	conn, err := xfer.Upgrade(w, r, nil)
@@ -114,6 +116,37 @@ func handleWebsocket(
 		}
 	}(conn)
 
+	watchTopology(ctx, rep, r, loop, quit, func(diff detailed.Diff) error {
+		if err := conn.WriteJSON(diff); err != nil {
+			if !xfer.IsExpectedWSCloseError(err) {
+				log.Errorf("cannot serialize topology diff: %s", err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// parseLoop returns the update interval requested with the "t" parameter,
+// defaulting to websocketLoop.
+func parseLoop(r *http.Request) (time.Duration, error) {
+	if t := r.Form.Get("t"); t != "" {
+		return time.ParseDuration(t)
+	}
+	return websocketLoop, nil
+}
+
+// watchTopology renders the requested topology every loop, or whenever the
+// reporter signals a new report, and passes the diff against the previous
+// rendering to send. It returns when send fails, or quit is closed.
+func watchTopology(
+	ctx context.Context,
+	rep Reporter,
+	r *http.Request,
+	loop time.Duration,
+	quit <-chan struct{},
+	send func(detailed.Diff) error,
+) {
 	var (
 		previousTopo     detailed.NodeSummaries
 		tick             = time.Tick(loop)
@@ -145,14 +178,11 @@ func handleWebsocket(
 			log.Errorf("Error generating report: %v", err)
 			return
 		}
-		newTopo := detailed.Summaries(RenderContextForReporter(rep, re), render.Render(re, renderer, filter).Nodes)
+		newTopo := renderSummaries(RenderContextForReporter(rep, re), topologyID, r.Form, renderer, filter)
 		diff := detailed.TopoDiff(previousTopo, newTopo)
 		previousTopo = newTopo
 
-		if err := conn.WriteJSON(diff); err != nil {
-			if !xfer.IsExpectedWSCloseError(err) {
-				log.Errorf("cannot serialize topology diff: %s", err)
-			}
+		if err := send(diff); err != nil {
 			return
 		}
 
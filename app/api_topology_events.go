@@ -0,0 +1,157 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+const (
+	// How long a long-poll request waits for a new report. Needs to be
+	// less than the app's HTTP write timeout.
+	longPollTimeout = 30 * time.Second
+)
+
+// APITopologyPoll is returned by the /api/topology/{name}/poll handler.
+type APITopologyPoll struct {
+	ReportID string        `json:"reportID"`
+	Diff     detailed.Diff `json:"diff"`
+}
+
+// Server-Sent Events for the full topology. The events carry the same diffs
+// as the websocket. The app's HTTP write timeout eventually closes the
+// stream; EventSource clients reconnect, and receive a reset diff.
+func handleEvents(
+	ctx context.Context,
+	rep Reporter,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if err := r.ParseForm(); err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	loop, err := parseLoop(r)
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, r.Form.Get("t"))
+		return
+	}
+	if !topologyRegistry.exists(ctx, rep, mux.Vars(r)["topology"]) {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWith(w, http.StatusInternalServerError, fmt.Errorf("Streaming unsupported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	quit := make(chan struct{})
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed := cn.CloseNotify()
+		go func() {
+			<-closed
+			close(quit)
+		}()
+	}
+
+	watchTopology(ctx, rep, r, loop, quit, func(diff detailed.Diff) error {
+		if _, err := fmt.Fprint(w, "data: "); err != nil {
+			return err
+		}
+		if err := codec.NewEncoder(w, &codec.JsonHandle{}).Encode(diff); err != nil {
+			log.Errorf("cannot serialize topology diff: %s", err)
+			return err
+		}
+		if _, err := fmt.Fprint(w, "\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
+
+// Long-poll for the full topology. The client passes the ID of the last
+// report it has seen as "since"; the request returns as soon as there is a
+// newer report, or after the timeout. The diff is against the topology
+// rendered from the "since" report, if that is still cached, and a reset
+// otherwise.
+func handlePoll(
+	ctx context.Context,
+	rep Reporter,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if err := r.ParseForm(); err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	timeout := longPollTimeout
+	if t := r.Form.Get("timeout"); t != "" {
+		var err error
+		if timeout, err = time.ParseDuration(t); err != nil || timeout < 0 || timeout > longPollTimeout {
+			respondWith(w, http.StatusBadRequest, t)
+			return
+		}
+	}
+
+	topologyID := mux.Vars(r)["topology"]
+	if !topologyRegistry.exists(ctx, rep, topologyID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var (
+		since    = r.Form.Get("since")
+		tick     = time.NewTicker(websocketLoop)
+		deadline = time.After(timeout)
+		wait     = make(chan struct{}, 1)
+	)
+	defer tick.Stop()
+
+	rep.WaitOn(ctx, wait)
+	defer rep.UnWait(ctx, wait)
+
+	for {
+		re, err := rep.Report(ctx, time.Now())
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		if re.ID != since {
+			renderer, filter, err := topologyRegistry.RendererForTopology(topologyID, r.Form, re)
+			if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			rc := RenderContextForReporter(rep, re)
+			previousTopo, _ := cachedSummaries(rc, since, topologyID, r.Form)
+			newTopo := renderSummaries(rc, topologyID, r.Form, renderer, filter)
+			respondWith(w, http.StatusOK, APITopologyPoll{
+				ReportID: re.ID,
+				Diff:     detailed.TopoDiff(previousTopo, newTopo),
+			})
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-tick.C:
+		case <-deadline:
+			respondWith(w, http.StatusOK, APITopologyPoll{ReportID: since})
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// Formats topologies can be exported in, besides JSON
+var exportFormats = map[string]struct {
+	contentType string
+	separator   rune
+}{
+	"csv": {"text/csv; charset=utf-8", ','},
+	"tsv": {"text/tab-separated-values; charset=utf-8", '\t'},
+}
+
+const (
+	idColumn           = "id"
+	labelColumn        = "label"
+	labelMinorColumn   = "labelMinor"
+	parentColumnPrefix = "parents."
+)
+
+// exportTopology writes the node summaries of a topology as a table, a row
+// per node, in one of the export formats. The columns are the metadata,
+// latest metric values and parents of nodes, or those listed in the columns
+// request parameter, and are headed by their labels.
+func exportTopology(w http.ResponseWriter, r *http.Request, topologyID, format string, summaries detailed.NodeSummaries) {
+	f, ok := exportFormats[format]
+	if !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q", format))
+		return
+	}
+
+	var columns []string
+	if s := r.Form.Get("columns"); s != "" {
+		columns = strings.Split(s, ",")
+	} else {
+		columns = exportColumns(summaries)
+	}
+
+	nodes := make([]detailed.NodeSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		nodes = append(nodes, summary)
+	}
+	sort.Sort(byLabel(nodes))
+
+	labels := exportLabels(summaries)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = exportCell(column)
+		if label, ok := labels[column]; ok {
+			header[i] = exportCell(label)
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = f.separator
+	writer.Write(header)
+	for _, node := range nodes {
+		values := exportValues(node)
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = exportCell(values[column])
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", topologyID+"."+format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// exportCell neutralises values which spreadsheets would take for formulas,
+// by quoting them, unless they are numbers.
+func exportCell(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+// exportColumns returns all the columns of a set of nodes: their IDs and
+// labels, then their metadata and metrics by priority, then their parents.
+func exportColumns(summaries detailed.NodeSummaries) []string {
+	var (
+		priorities = map[string]float64{}
+		parents    = map[string]struct{}{}
+	)
+	for _, summary := range summaries {
+		for _, row := range summary.Metadata {
+			priorities[row.ID] = row.Priority
+		}
+		for _, row := range summary.Metrics {
+			priorities[row.ID] = row.Priority
+		}
+		for _, parent := range summary.Parents {
+			parents[parentColumnPrefix+parent.TopologyID] = struct{}{}
+		}
+	}
+
+	rows := make([]string, 0, len(priorities))
+	for id := range priorities {
+		rows = append(rows, id)
+	}
+	sort.Sort(byPriority{rows, priorities})
+	parentColumns := make([]string, 0, len(parents))
+	for id := range parents {
+		parentColumns = append(parentColumns, id)
+	}
+	sort.Strings(parentColumns)
+
+	columns := []string{idColumn, labelColumn, labelMinorColumn}
+	columns = append(columns, rows...)
+	return append(columns, parentColumns...)
+}
+
+// exportLabels returns the labels of the columns of a set of nodes: those of
+// their metadata and metrics, and the names of the topologies of their
+// parents.
+func exportLabels(summaries detailed.NodeSummaries) map[string]string {
+	labels := map[string]string{
+		idColumn:         "ID",
+		labelColumn:      "Label",
+		labelMinorColumn: "Details",
+	}
+	for _, summary := range summaries {
+		for _, row := range summary.Metadata {
+			labels[row.ID] = row.Label
+		}
+		for _, row := range summary.Metrics {
+			labels[row.ID] = row.Label
+		}
+		for _, parent := range summary.Parents {
+			label := parent.TopologyID
+			if topology, ok := topologyRegistry.get(parent.TopologyID); ok {
+				label = topology.Name
+			}
+			labels[parentColumnPrefix+parent.TopologyID] = label
+		}
+	}
+	return labels
+}
+
+// exportValues returns the values of the columns of a node.
+func exportValues(summary detailed.NodeSummary) map[string]string {
+	values := map[string]string{
+		idColumn:         summary.ID,
+		labelColumn:      summary.Label,
+		labelMinorColumn: summary.LabelMinor,
+	}
+	for _, row := range summary.Metadata {
+		values[row.ID] = row.Value
+	}
+	for _, row := range summary.Metrics {
+		if !row.ValueEmpty {
+			values[row.ID] = strconv.FormatFloat(row.Value, 'f', -1, 64)
+		}
+	}
+	for _, parent := range summary.Parents {
+		column := parentColumnPrefix + parent.TopologyID
+		if values[column] != "" {
+			values[column] += ", "
+		}
+		values[column] += parent.Label
+	}
+	return values
+}
+
+type byLabel []detailed.NodeSummary
+
+func (s byLabel) Len() int      { return len(s) }
+func (s byLabel) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLabel) Less(i, j int) bool {
+	if s[i].Label != s[j].Label {
+		return s[i].Label < s[j].Label
+	}
+	return s[i].ID < s[j].ID
+}
+
+type byPriority struct {
+	ids        []string
+	priorities map[string]float64
+}
+
+func (s byPriority) Len() int      { return len(s.ids) }
+func (s byPriority) Swap(i, j int) { s.ids[i], s.ids[j] = s.ids[j], s.ids[i] }
+func (s byPriority) Less(i, j int) bool {
+	pi, pj := s.priorities[s.ids[i]], s.priorities[s.ids[j]]
+	if pi != pj {
+		return pi < pj
+	}
+	return s.ids[i] < s.ids[j]
+}
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestExportCell(t *testing.T) {
+	for value, want := range map[string]string{
+		"":                         "",
+		"nginx":                    "nginx",
+		"-12.5":                    "-12.5",
+		"+1":                       "+1",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+cmd|' /C calc'!A0":       "'+cmd|' /C calc'!A0",
+		"-2+3":                     "'-2+3",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+	} {
+		if have := exportCell(value); have != want {
+			t.Errorf("%q: expected %q, got %q", value, want, have)
+		}
+	}
+}
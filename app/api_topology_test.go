@@ -1,8 +1,13 @@
 package app_test
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/websocket"
@@ -117,6 +122,46 @@ func TestAPITopologyHosts(t *testing.T) {
 	}
 }
 
+func TestAPITopologyExport(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	readTable := func(path string, separator rune) [][]string {
+		res, body := checkGet(t, ts, path)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, res.StatusCode)
+		}
+		reader := csv.NewReader(bytes.NewReader(body))
+		reader.Comma = separator
+		rows, err := reader.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	rows := readTable("/api/topology/hosts?format=csv", ',')
+	equals(t, []string{"ID", "Label", "Details"}, rows[0][:3])
+	equals(t, len(expected.RenderedHosts)+1, len(rows))
+
+	rows = readTable("/api/topology/containers?format=tsv&system=all&columns=label,parents.hosts,nonexistent", '\t')
+	equals(t, []string{"Label", "Hosts", "nonexistent"}, rows[0])
+	withHosts := 0
+	for _, row := range rows[1:] {
+		if row[1] != "" {
+			withHosts++
+		}
+		if row[2] != "" {
+			t.Errorf("Expected no value of an unknown column, got %v", row)
+		}
+	}
+	if withHosts == 0 {
+		t.Errorf("Expected the hosts of containers, got %v", rows)
+	}
+
+	is400(t, ts, "/api/topology/hosts?format=xlsx")
+}
+
 // Basic websocket test
 func TestAPITopologyWebsocket(t *testing.T) {
 	ts := topologyServer()
@@ -152,4 +197,57 @@ func TestAPITopologyWebsocket(t *testing.T) {
 	equals(t, 0, len(d.Remove))
 }
 
+func TestAPITopologyEvents(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	is404(t, ts, "/api/topology/foobar/events")
+
+	res, err := http.Get(ts.URL + "/api/topology/processes/events")
+	ok(t, err)
+	defer res.Body.Close()
+	equals(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	ok(t, err)
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("Unexpected event: %q", line)
+	}
+	var d detailed.Diff
+	decoder := codec.NewDecoderBytes([]byte(strings.TrimPrefix(line, "data: ")), &codec.JsonHandle{})
+	if err := decoder.Decode(&d); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, 6, len(d.Add))
+	equals(t, 0, len(d.Update))
+	equals(t, 0, len(d.Remove))
+}
+
+func TestAPITopologyPoll(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	is404(t, ts, "/api/topology/foobar/poll")
+	is400(t, ts, "/api/topology/processes/poll?timeout=-1s")
+	is400(t, ts, "/api/topology/processes/poll?timeout=1h")
+
+	var first app.APITopologyPoll
+	body := getRawJSON(t, ts, "/api/topology/processes/poll")
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&first); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, fixture.Report.ID, first.ReportID)
+	equals(t, true, first.Diff.Reset)
+	equals(t, 6, len(first.Diff.Add))
+
+	// The report never changes, so polling again times out with no changes
+	var second app.APITopologyPoll
+	body = getRawJSON(t, ts, "/api/topology/processes/poll?timeout=10ms&since="+url.QueryEscape(first.ReportID))
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&second); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, first.ReportID, second.ReportID)
+	equals(t, 0, len(second.Diff.Add))
+}
+
 func newu64(value uint64) *uint64 { return &value }
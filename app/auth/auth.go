@@ -0,0 +1,289 @@
+// Package auth authenticates the users of the app's UI and API, and its
+// probes, for apps which aren't behind an authenticating proxy.
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// Routes of the OIDC login
+const (
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+)
+
+const (
+	sessionCookie = "scope_session"
+	stateCookie   = "scope_oidc_state"
+	sessionIssuer = "scope"
+)
+
+// Config configures the ways to authenticate. Unset ones are disabled.
+type Config struct {
+	HtpasswdFile string // Users logging in with HTTP basic auth
+	TokensFile   string // Bearer tokens of API clients and probes
+	OIDC         OIDCConfig
+
+	SessionKey []byte        // Signs the sessions of OIDC logins. Random if empty
+	SessionTTL time.Duration // How long OIDC logins last
+
+	// NoProbes is set by apps which pull their reports, rather than
+	// accepting them from probes, which need a token to authenticate.
+	NoProbes bool
+}
+
+// Enabled returns whether any way to authenticate is configured.
+func (c Config) Enabled() bool {
+	return c.HtpasswdFile != "" || c.TokensFile != "" || c.OIDC.Issuer != ""
+}
+
+// Authenticator is a middleware rejecting unauthenticated requests.
+//
+// Users authenticate with HTTP basic auth, or by logging in with an OIDC
+// provider, which starts a session kept in a cookie. API clients use bearer
+// tokens, and so do probes, which send them as their --probe.token.
+type Authenticator struct {
+	users         htpasswd
+	tokens        tokens
+	oidc          *oidcProvider
+	sessionKey    []byte
+	sessionTTL    time.Duration
+	secureCookies bool
+}
+
+// New makes a new Authenticator.
+func New(config Config) (*Authenticator, error) {
+	a := &Authenticator{
+		sessionKey: config.SessionKey,
+		sessionTTL: config.SessionTTL,
+		// The app is served over HTTPS, even if by a proxy in front of it
+		secureCookies: strings.HasPrefix(config.OIDC.RedirectURL, "https://"),
+	}
+	var err error
+	if config.HtpasswdFile != "" {
+		if a.users, err = loadHtpasswd(config.HtpasswdFile); err != nil {
+			return nil, err
+		}
+	}
+	if config.TokensFile != "" {
+		if a.tokens, err = loadTokens(config.TokensFile); err != nil {
+			return nil, err
+		}
+	}
+	if a.tokens == nil && !config.NoProbes {
+		return nil, fmt.Errorf("probes can only authenticate with a token, but no tokens file is configured")
+	}
+	if config.OIDC.Issuer != "" {
+		if a.oidc, err = newOIDCProvider(config.OIDC, &http.Client{Timeout: 10 * time.Second}); err != nil {
+			return nil, err
+		}
+	}
+	if len(a.sessionKey) == 0 {
+		a.sessionKey = make([]byte, 32)
+		if _, err := rand.Read(a.sessionKey); err != nil {
+			return nil, err
+		}
+	}
+	if a.sessionTTL == 0 {
+		a.sessionTTL = 12 * time.Hour
+	}
+	return a, nil
+}
+
+// RegisterRoutes registers the OIDC login routes.
+func (a *Authenticator) RegisterRoutes(router *mux.Router) {
+	if a.oidc != nil {
+		router.Methods("GET").Path(LoginPath).HandlerFunc(a.login)
+		router.Methods("GET").Path(CallbackPath).HandlerFunc(a.callback)
+	}
+	router.Methods("POST").Path(LogoutPath).HandlerFunc(a.logout)
+}
+
+// Wrap implements middleware.Interface
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, ok := a.authenticate(r); ok {
+			if c.probe && !xfer.ProbeRoutes.MatchString(r.URL.Path) {
+				http.Error(w, "probe tokens can only be used by probes", http.StatusForbidden)
+				return
+			}
+			// Pages of other sites can make browsers send their cookies and
+			// basic auth credentials, but not their own origin.
+			if c.ambient && (r.Method != "GET" && r.Method != "HEAD" || isWebsocket(r)) && !xfer.SameOrigin(r) {
+				http.Error(w, "cross-origin request refused", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.oidc != nil {
+			if r.URL.Path == LoginPath || r.URL.Path == CallbackPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if isPageRequest(r) {
+				http.Redirect(w, r, LoginPath+"?"+url.Values{"return": {r.URL.RequestURI()}}.Encode(), http.StatusFound)
+				return
+			}
+		}
+		if a.users != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Weave Scope"`)
+		}
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+	})
+}
+
+// client is who a request is authenticated as.
+type client struct {
+	user    string
+	ambient bool // Authenticated with credentials browsers send by themselves
+	probe   bool // Authenticated with a probe token
+}
+
+// authenticate returns the client of a request, if it's authenticated.
+func (a *Authenticator) authenticate(r *http.Request) (client, bool) {
+	if user, password, ok := r.BasicAuth(); ok && a.users != nil {
+		return client{user: user, ambient: true}, a.users.authenticate(user, password)
+	}
+	authorization := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authorization, "Bearer "):
+		t, ok := a.tokens.authenticate(strings.TrimPrefix(authorization, "Bearer "))
+		return client{user: t.user, probe: t.probe}, ok
+	case strings.HasPrefix(authorization, "Scope-Probe token="):
+		t, ok := a.tokens.authenticate(strings.TrimPrefix(authorization, "Scope-Probe token="))
+		return client{user: t.user, probe: t.probe}, ok
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		user, ok := a.session(cookie.Value)
+		return client{user: user, ambient: true}, ok
+	}
+	return client{}, false
+}
+
+// isPageRequest returns whether a request is a browser loading a page, which
+// can be redirected to log in, rather than an API call.
+func isPageRequest(r *http.Request) bool {
+	return r.Method == "GET" && r.Header.Get("Upgrade") == "" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func isWebsocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// cookie makes a cookie only sent to the app over HTTPS, when it is served
+// over HTTPS, and not sent along requests made by other sites, except when
+// following links to the app, as the OIDC provider redirects to it.
+func (a *Authenticator) cookie(r *http.Request, name, value, path string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   a.secureCookies || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (a *Authenticator) login(w http.ResponseWriter, r *http.Request) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The state carries the page to return to, and is kept in a cookie to
+	// check it comes back from the login we started.
+	state := hex.EncodeToString(nonce) + ":" + base64.RawURLEncoding.EncodeToString([]byte(returnPath(r.URL.Query().Get("return"))))
+	http.SetCookie(w, a.cookie(r, stateCookie, state, CallbackPath, int((10*time.Minute).Seconds())))
+	http.Redirect(w, r, a.oidc.oauth2.AuthCodeURL(state), http.StatusFound)
+}
+
+func (a *Authenticator) callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(stateCookie)
+	state := r.URL.Query().Get("state")
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, a.cookie(r, stateCookie, "", CallbackPath, -1))
+	if e := r.URL.Query().Get("error"); e != "" {
+		http.Error(w, fmt.Sprintf("login failed: %s", e), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := a.oidc.exchange(r.URL.Query().Get("code"))
+	if err != nil {
+		log.Warningf("OIDC login failed: %v", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	session, err := a.newSession(user, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infof("%s logged in", user)
+	http.SetCookie(w, a.cookie(r, sessionCookie, session, "/", int(a.sessionTTL.Seconds())))
+
+	returnTo := "/"
+	if i := strings.Index(state, ":"); i >= 0 {
+		if path, err := base64.RawURLEncoding.DecodeString(state[i+1:]); err == nil {
+			returnTo = returnPath(string(path))
+		}
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+func (a *Authenticator) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, a.cookie(r, sessionCookie, "", "/", -1))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// returnPath only allows returning to pages of the app after logging in.
+func returnPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// newSession returns a session of a user, signed with the session key.
+func (a *Authenticator) newSession(user string, now time.Time) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Issuer:    sessionIssuer,
+		Subject:   user,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(a.sessionTTL).Unix(),
+	}).SignedString(a.sessionKey)
+}
+
+// session returns the user of a session, if it's valid.
+func (a *Authenticator) session(value string) (string, bool) {
+	claims := jwt.StandardClaims{}
+	_, err := new(jwt.Parser).ParseWithClaims(value, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return a.sessionKey, nil
+	})
+	if err != nil || claims.Issuer != sessionIssuer || claims.ExpiresAt == 0 || claims.Subject == "" {
+		return "", false
+	}
+	return claims.Subject, true
+}
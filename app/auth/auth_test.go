@@ -0,0 +1,422 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app/auth"
+)
+
+const clientID = "scope"
+
+// mockOIDCProvider issues ID tokens for the codes it is given: the code is
+// the email of the user, and "other-client" issues tokens for another client.
+// Emails starting with "unverified" aren't verified, and carol is in the ops
+// group.
+func mockOIDCProvider(t *testing.T) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code, audience := r.FormValue("code"), clientID
+		if code == "other-client" {
+			audience = "other"
+		}
+		claims := jwt.MapClaims{
+			"iss":            server.URL,
+			"aud":            []string{audience},
+			"sub":            "1234",
+			"email":          code,
+			"email_verified": !strings.HasPrefix(code, "unverified"),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		if code == "carol@other.example.org" {
+			claims["groups"] = []string{"dev", "ops"}
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key1"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func tempFile(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func newApp(t *testing.T, config auth.Config) *httptest.Server {
+	authenticator, err := auth.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	authenticator.RegisterRoutes(router)
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return httptest.NewServer(authenticator.Wrap(router))
+}
+
+func noRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// oidcLogin logs in with the code of a user, and returns the status of the
+// callback.
+func oidcLogin(t *testing.T, app *httptest.Server, client *http.Client, code string) int {
+	resp, err := client.Get(app.URL + auth.LoginPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	state := location.Query().Get("state")
+
+	resp, err = client.Get(app.URL + auth.CallbackPath + "?" + url.Values{"code": {code}, "state": {state}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestNewRequiresTokensAndAllowList(t *testing.T) {
+	htpasswd := tempFile(t, "myName:$apr1$r31.....$HqJZimcKQFAMYayBlzkrA/\n")
+	defer os.Remove(htpasswd)
+	if _, err := auth.New(auth.Config{HtpasswdFile: htpasswd}); err == nil {
+		t.Errorf("Expected probes to need a tokens file")
+	}
+	if _, err := auth.New(auth.Config{HtpasswdFile: htpasswd, NoProbes: true}); err != nil {
+		t.Errorf("Expected apps without probes not to need a tokens file: %v", err)
+	}
+
+	provider := mockOIDCProvider(t)
+	defer provider.Close()
+	if _, err := auth.New(auth.Config{OIDC: auth.OIDCConfig{Issuer: provider.URL, ClientID: clientID}, NoProbes: true}); err == nil {
+		t.Errorf("Expected OIDC to need an allow-list")
+	}
+}
+
+func TestBasicAuthAndTokens(t *testing.T) {
+	htpasswd := tempFile(t, "myName:$apr1$r31.....$HqJZimcKQFAMYayBlzkrA/\n")
+	defer os.Remove(htpasswd)
+	tokens := tempFile(t, "# API clients\ns3cr3t grafana\nprob3 probes probe\n")
+	defer os.Remove(tokens)
+	app := newApp(t, auth.Config{HtpasswdFile: htpasswd, TokensFile: tokens})
+	defer app.Close()
+
+	for _, c := range []struct {
+		name          string
+		authorization func(*http.Request)
+		want          int
+	}{
+		{"none", func(*http.Request) {}, http.StatusUnauthorized},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("myName", "myPassword") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("myName", "s3cr3t") }, http.StatusUnauthorized},
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cr3t") }, http.StatusOK},
+		{"wrong bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cr3") }, http.StatusUnauthorized},
+		{"probe", func(r *http.Request) { r.Header.Set("Authorization", "Scope-Probe token=prob3") }, http.StatusForbidden},
+		{"probe without token", func(r *http.Request) { r.Header.Set("Authorization", "Scope-Probe token=") }, http.StatusUnauthorized},
+	} {
+		req, _ := http.NewRequest("GET", app.URL+"/api/topology", nil)
+		c.authorization(req)
+		// Reading is allowed across origins, for the browser to drop the response
+		req.Header.Set("Origin", "http://evil.example.com")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a basic auth challenge", c.name)
+		}
+	}
+
+	// Browsers send basic auth credentials along the requests of other sites
+	for _, c := range []struct {
+		name, origin string
+		bearer       bool
+		want         int
+	}{
+		{"same origin", app.URL, false, http.StatusOK},
+		{"no origin", "", false, http.StatusOK},
+		{"other origin", "http://evil.example.com", false, http.StatusForbidden},
+		{"other origin with token", "http://evil.example.com", true, http.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", app.URL+"/api/control/x", nil)
+		if c.bearer {
+			req.Header.Set("Authorization", "Bearer s3cr3t")
+		} else {
+			req.SetBasicAuth("myName", "myPassword")
+		}
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("POST from %s: expected %d, got %d", c.name, c.want, resp.StatusCode)
+		}
+	}
+
+	// Probe tokens only give access to the routes used by probes
+	for _, c := range []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/api/report", http.StatusOK},
+		{"GET", "/api/control/ws", http.StatusOK},
+		{"GET", "/api/pipe/pipe1/probe", http.StatusOK},
+		{"GET", "/api/pipe/pipe1", http.StatusForbidden},
+		{"POST", "/api/control/x", http.StatusForbidden},
+		{"GET", "/api/topology", http.StatusForbidden},
+		{"GET", "/", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest(c.method, app.URL+c.path, nil)
+		req.Header.Set("Authorization", "Bearer prob3")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("%s %s with a probe token: expected %d, got %d", c.method, c.path, c.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := mockOIDCProvider(t)
+	defer provider.Close()
+	app := newApp(t, auth.Config{
+		OIDC: auth.OIDCConfig{
+			Issuer:         provider.URL,
+			ClientID:       clientID,
+			ClientSecret:   "secret",
+			RedirectURL:    "http://scope.example.com" + auth.CallbackPath,
+			AllowedDomains: []string{"example.com"},
+		},
+		SessionTTL: time.Hour,
+		NoProbes:   true,
+	})
+	defer app.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: noRedirects}
+	do := func(method, path, accept, origin string) *http.Response {
+		req, _ := http.NewRequest(method, app.URL+path, nil)
+		req.Header.Set("Accept", accept)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	get := func(path, accept string) *http.Response {
+		return do("GET", path, accept, "")
+	}
+
+	// API calls are rejected, pages redirect to the login
+	if resp := get("/api/topology", "application/json"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected API calls to be rejected, got %d", resp.StatusCode)
+	}
+	resp := get("/some/page?x=1", "text/html")
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(resp.Header.Get("Location"), auth.LoginPath) {
+		t.Fatalf("Expected a redirect to the login, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// The login redirects to the provider
+	resp = get(resp.Header.Get("Location"), "text/html")
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), provider.URL+"/authorize") {
+		t.Fatalf("Expected a redirect to the provider, got %s", location)
+	}
+	state := location.Query().Get("state")
+	if location.Query().Get("client_id") != clientID || state == "" {
+		t.Fatalf("Unexpected authorization request %s", location)
+	}
+
+	// Which redirects back with a code, or an attacker with another state
+	if resp := get(auth.CallbackPath+"?"+url.Values{"code": {"mallory@example.com"}, "state": {"forged"}}.Encode(), "text/html"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a forged state to be rejected, got %d", resp.StatusCode)
+	}
+	resp = get(auth.CallbackPath+"?"+url.Values{"code": {"alice@example.com"}, "state": {state}}.Encode(), "text/html")
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/some/page?x=1" {
+		t.Fatalf("Expected a redirect to the original page, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// The session authenticates API calls, but only from pages of the app
+	// for those changing anything
+	if resp := get("/api/topology", "application/json"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the session to be authenticated, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/control/x", "application/json", app.URL); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected controls from the app to be allowed, got %d", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/control/x", "application/json", "http://evil.example.com"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected controls from other sites to be refused, got %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest("GET", app.URL+"/api/topology/x/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Origin", "http://evil.example.com")
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected websockets from other sites to be refused, got %v %v", resp, err)
+	}
+
+	// Until logging out, which can't be done by following a link
+	get(auth.LogoutPath, "text/html")
+	if resp := get("/api/topology", "application/json"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected GET not to log out, got %d", resp.StatusCode)
+	}
+	do("POST", auth.LogoutPath, "text/html", app.URL)
+	if resp := get("/api/topology", "application/json"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected to be logged out, got %d", resp.StatusCode)
+	}
+}
+
+func TestOIDCLoginOtherClient(t *testing.T) {
+	provider := mockOIDCProvider(t)
+	defer provider.Close()
+	app := newApp(t, auth.Config{
+		OIDC: auth.OIDCConfig{
+			Issuer:        provider.URL,
+			ClientID:      clientID,
+			RedirectURL:   "http://scope.example.com" + auth.CallbackPath,
+			AllowedGroups: []string{"ops"},
+		},
+		NoProbes: true,
+	})
+	defer app.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: noRedirects}
+
+	// ID tokens issued for other clients can't be used to log in
+	if status := oidcLogin(t, app, client, "other-client"); status != http.StatusUnauthorized {
+		t.Errorf("Expected the login to fail, got %d", status)
+	}
+}
+
+func TestOIDCAllowList(t *testing.T) {
+	provider := mockOIDCProvider(t)
+	defer provider.Close()
+	app := newApp(t, auth.Config{
+		OIDC: auth.OIDCConfig{
+			Issuer:         provider.URL,
+			ClientID:       clientID,
+			RedirectURL:    "http://scope.example.com" + auth.CallbackPath,
+			AllowedEmails:  []string{"bob@example.net", "unverified@example.net"},
+			AllowedDomains: []string{"example.com"},
+			AllowedGroups:  []string{"ops"},
+		},
+		NoProbes: true,
+	})
+	defer app.Close()
+
+	for code, want := range map[string]int{
+		"alice@example.com":       http.StatusFound,
+		"Bob@example.net":         http.StatusFound,
+		"carol@other.example.org": http.StatusFound,
+		"mallory@example.net":     http.StatusUnauthorized,
+		"unverified@example.net":  http.StatusUnauthorized,
+		"unverified@example.com":  http.StatusUnauthorized,
+	} {
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{Jar: jar, CheckRedirect: noRedirects}
+		if status := oidcLogin(t, app, client, code); status != want {
+			t.Errorf("%s: expected %d, got %d", code, want, status)
+		}
+	}
+}
+
+func TestCookies(t *testing.T) {
+	provider := mockOIDCProvider(t)
+	defer provider.Close()
+	app := newApp(t, auth.Config{
+		OIDC: auth.OIDCConfig{
+			Issuer:         provider.URL,
+			ClientID:       clientID,
+			RedirectURL:    "https://scope.example.com" + auth.CallbackPath,
+			AllowedDomains: []string{"example.com"},
+		},
+		NoProbes: true,
+	})
+	defer app.Close()
+
+	// The app is behind a proxy serving it over HTTPS
+	client := &http.Client{CheckRedirect: noRedirects}
+	resp, err := client.Get(app.URL + auth.LoginPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || !cookies[0].Secure || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected a secure, lax cookie, got %v", cookies)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	shaPrefix  = "{SHA}"
+	apr1Prefix = "$apr1$"
+	apr1Chars  = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// htpasswd maps users to the hashes of their passwords.
+type htpasswd map[string]string
+
+// loadHtpasswd reads a file of user:hash lines, as written by the htpasswd
+// tool with -m (the default MD5 based format) or -s (SHA1).
+func loadHtpasswd(filename string) (htpasswd, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := htpasswd{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s: invalid line %q", filename, line)
+		}
+		user, hash := parts[0], parts[1]
+		if !strings.HasPrefix(hash, shaPrefix) && !strings.HasPrefix(hash, apr1Prefix) {
+			return nil, fmt.Errorf("%s: unsupported password hash of %s, use htpasswd -m or -s", filename, user)
+		}
+		users[user] = hash
+	}
+	return users, scanner.Err()
+}
+
+func (h htpasswd) authenticate(user, password string) bool {
+	hash, ok := h[user]
+	if !ok {
+		return false
+	}
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, shaPrefix):
+		sum := sha1.Sum([]byte(password))
+		computed = shaPrefix + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, apr1Prefix):
+		salt := strings.SplitN(strings.TrimPrefix(hash, apr1Prefix), "$", 2)[0]
+		computed = apr1(password, salt)
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// apr1 is Apache's variant of the MD5 based crypt(3), with its own magic.
+func apr1(password, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw, s := []byte(password), []byte(salt)
+
+	alternate := md5.New()
+	alternate.Write(pw)
+	alternate.Write(s)
+	alternate.Write(pw)
+	sum := alternate.Sum(nil)
+
+	d := md5.New()
+	d.Write(pw)
+	d.Write([]byte(apr1Prefix))
+	d.Write(s)
+	for i := len(pw); i > 0; i -= 16 {
+		n := i
+		if n > 16 {
+			n = 16
+		}
+		d.Write(sum[:n])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			d.Write([]byte{0})
+		} else {
+			d.Write(pw[:1])
+		}
+	}
+	sum = d.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		d := md5.New()
+		if i&1 != 0 {
+			d.Write(pw)
+		} else {
+			d.Write(sum)
+		}
+		if i%3 != 0 {
+			d.Write(s)
+		}
+		if i%7 != 0 {
+			d.Write(pw)
+		}
+		if i&1 != 0 {
+			d.Write(sum)
+		} else {
+			d.Write(pw)
+		}
+		sum = d.Sum(nil)
+	}
+
+	var buf []byte
+	encode := func(v uint, n int) {
+		for ; n > 0; n-- {
+			buf = append(buf, apr1Chars[v&0x3f])
+			v >>= 6
+		}
+	}
+	encode(uint(sum[0])<<16|uint(sum[6])<<8|uint(sum[12]), 4)
+	encode(uint(sum[1])<<16|uint(sum[7])<<8|uint(sum[13]), 4)
+	encode(uint(sum[2])<<16|uint(sum[8])<<8|uint(sum[14]), 4)
+	encode(uint(sum[3])<<16|uint(sum[9])<<8|uint(sum[15]), 4)
+	encode(uint(sum[4])<<16|uint(sum[10])<<8|uint(sum[5]), 4)
+	encode(uint(sum[11]), 2)
+	return apr1Prefix + salt + "$" + string(buf)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestHtpasswd(t *testing.T) {
+	f, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	// Written by htpasswd -m and -s
+	f.WriteString("# users\nmyName:$apr1$r31.....$HqJZimcKQFAMYayBlzkrA/\nalice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	f.Close()
+
+	users, err := loadHtpasswd(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		user, password string
+		want           bool
+	}{
+		{"myName", "myPassword", true},
+		{"myName", "myPassword2", false},
+		{"alice", "secret", true},
+		{"alice", "myPassword", false},
+		{"bob", "secret", false},
+	} {
+		if have := users.authenticate(c.user, c.password); have != c.want {
+			t.Errorf("%s:%s: expected %v, got %v", c.user, c.password, c.want, have)
+		}
+	}
+}
+
+func TestHtpasswdUnsupportedHash(t *testing.T) {
+	f, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("bob:$2y$05$BnGlX6iQ0Ak4OGO9KoJ7ouwx9X7ZtTtVvmr/b0gNMT4NV0ffADQn2\n")
+	f.Close()
+
+	if _, err := loadHtpasswd(f.Name()); err == nil {
+		t.Errorf("Expected an error for a bcrypt hash")
+	}
+}
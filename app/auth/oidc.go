@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+// OIDCConfig configures logging in with an OpenID Connect provider.
+type OIDCConfig struct {
+	Issuer       string // URL of the provider, e.g. https://accounts.google.com
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // External URL of the app's /auth/callback
+	UserClaim    string // Claim of the ID token naming the user, e.g. email
+
+	// Users allowed to log in: those with one of these verified emails, or
+	// emails of these domains, or in one of these groups (the groups claim).
+	// At least one must be set, for not every user of the provider to get in.
+	AllowedEmails  []string
+	AllowedDomains []string
+	AllowedGroups  []string
+}
+
+type oidcProvider struct {
+	config  OIDCConfig
+	oauth2  oauth2.Config
+	jwksURL string
+	client  *http.Client
+
+	mtx  sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+// newOIDCProvider discovers the endpoints of a provider.
+func newOIDCProvider(config OIDCConfig, client *http.Client) (*oidcProvider, error) {
+	if len(config.AllowedEmails) == 0 && len(config.AllowedDomains) == 0 && len(config.AllowedGroups) == 0 {
+		return nil, fmt.Errorf("no users are allowed to log in with OIDC: allow some emails, domains or groups")
+	}
+	resp, err := client.Get(strings.TrimSuffix(config.Issuer, "/") + discoveryPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovering OIDC provider %s: %s", config.Issuer, resp.Status)
+	}
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("OIDC provider %s claims to be %s", config.Issuer, discovery.Issuer)
+	}
+	if config.UserClaim == "" {
+		config.UserClaim = "email"
+	}
+	return &oidcProvider{
+		config: config,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+			Scopes: []string{"openid", "email", "profile"},
+		},
+		jwksURL: discovery.JWKSURI,
+		client:  client,
+		keys:    map[string]*rsa.PublicKey{},
+	}, nil
+}
+
+// exchange trades an authorization code for the verified user of its ID
+// token.
+func (p *oidcProvider) exchange(code string) (string, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, p.client)
+	token, err := p.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", fmt.Errorf("no ID token in the token response")
+	}
+	return p.verify(rawIDToken, time.Now())
+}
+
+// verify checks the signature, issuer, audience and expiry of an ID token,
+// and returns its user.
+func (p *oidcProvider) verify(rawIDToken string, now time.Time) (string, error) {
+	token, err := new(jwt.Parser).Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil {
+		return "", err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyIssuer(p.config.Issuer, true) {
+		return "", fmt.Errorf("ID token issued by %v", claims["iss"])
+	}
+	if !claims.VerifyExpiresAt(now.Unix(), true) {
+		return "", fmt.Errorf("ID token expired")
+	}
+	if !hasAudience(claims, p.config.ClientID) {
+		return "", fmt.Errorf("ID token not issued for %s", p.config.ClientID)
+	}
+	user, _ := claims[p.config.UserClaim].(string)
+	if user == "" {
+		return "", fmt.Errorf("no %s claim in ID token", p.config.UserClaim)
+	}
+	if p.config.UserClaim == "email" && !emailVerified(claims) {
+		return "", fmt.Errorf("email %s not verified", user)
+	}
+	if !p.allowed(claims) {
+		return "", fmt.Errorf("%s is not allowed to log in", user)
+	}
+	return user, nil
+}
+
+// allowed checks the user of an ID token is on the allow-list. Emails are
+// only trusted once the provider verified them.
+func (p *oidcProvider) allowed(claims jwt.MapClaims) bool {
+	if email, _ := claims["email"].(string); email != "" && emailVerified(claims) {
+		domain := email[strings.LastIndex(email, "@")+1:]
+		if contains(p.config.AllowedEmails, email) || contains(p.config.AllowedDomains, domain) {
+			return true
+		}
+	}
+	groups, _ := claims["groups"].([]interface{})
+	for _, group := range groups {
+		if group, ok := group.(string); ok && contains(p.config.AllowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailVerified checks the email_verified claim, which some providers send
+// as a string.
+func emailVerified(claims jwt.MapClaims) bool {
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true"
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAudience checks the aud claim, which may be a string or a list.
+func hasAudience(claims jwt.MapClaims, clientID string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns a signing key of the provider, fetching them again when the
+// provider rotated its keys.
+func (p *oidcProvider) key(kid string) (*rsa.PublicKey, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	keys, err := p.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *oidcProvider) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	resp, err := p.client.Get(p.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching OIDC keys: %s", resp.Status)
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// token is a bearer token of an API client or probe. Probe tokens can only
+// be used on the routes of the API used by probes, so that a leaked probe
+// token doesn't give access to the UI and controls.
+type token struct {
+	secret, user string
+	probe        bool
+}
+
+type tokens []token
+
+// loadTokens reads a file of "token name" lines, which end with "probe" for
+// probe tokens. The name identifies the client in the logs.
+func loadTokens(filename string) (tokens, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := tokens{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		probe := len(fields) == 3 && fields[2] == "probe"
+		if len(fields) != 2 && !probe {
+			return nil, fmt.Errorf("%s: expected a token, a name and optionally \"probe\" per line", filename)
+		}
+		result = append(result, token{secret: fields[0], user: fields[1], probe: probe})
+	}
+	return result, scanner.Err()
+}
+
+// authenticate returns the token of a secret. All tokens are compared, in
+// constant time, to not leak how much of a token matched.
+func (ts tokens) authenticate(secret string) (token, bool) {
+	result, found := token{}, false
+	for _, t := range ts {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(t.secret)) == 1 {
+			result, found = t, true
+		}
+	}
+	return result, found
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// ClientCertificateName returns the common name of the verified client
+// certificate of a request, if any.
+func ClientCertificateName(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	return name, name != ""
+}
+
+// ClientCertificateAuth is a middleware rejecting the requests of probes
+// which didn't present a verified client certificate. Users of the UI are
+// not required to present one.
+type ClientCertificateAuth struct {
+	// Also reject probes whose ID isn't the common name of their
+	// certificate, so that probes can't impersonate each other.
+	VerifyProbeID bool
+}
+
+// Wrap implements middleware.Interface
+func (a ClientCertificateAuth) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !xfer.ProbeRoutes.MatchString(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name, ok := ClientCertificateName(r)
+		if !ok {
+			respondWith(w, http.StatusUnauthorized, "client certificate required")
+			return
+		}
+		if a.VerifyProbeID && r.Header.Get(xfer.ScopeProbeIDHeader) != name {
+			respondWith(w, http.StatusForbidden, "probe ID doesn't match client certificate")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
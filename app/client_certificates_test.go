@@ -0,0 +1,47 @@
+package app_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestClientCertificateAuth(t *testing.T) {
+	handler := app.ClientCertificateAuth{VerifyProbeID: true}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	withCertificate := func(r *http.Request, name string) *http.Request {
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: name}}}},
+		}
+		return r
+	}
+	withProbeID := func(r *http.Request, probeID string) *http.Request {
+		r.Header.Set(xfer.ScopeProbeIDHeader, probeID)
+		return r
+	}
+
+	for _, c := range []struct {
+		name    string
+		request *http.Request
+		want    int
+	}{
+		{"UI without certificate", httptest.NewRequest("GET", "/api/topology", nil), http.StatusNoContent},
+		{"probe without certificate", httptest.NewRequest("POST", "/api/report", nil), http.StatusUnauthorized},
+		{"probe with certificate", withProbeID(withCertificate(httptest.NewRequest("POST", "/api/report", nil), "probe1"), "probe1"), http.StatusNoContent},
+		{"probe with another ID", withProbeID(withCertificate(httptest.NewRequest("GET", "/api/control/ws", nil), "probe1"), "probe2"), http.StatusForbidden},
+		{"probe pipe without certificate", httptest.NewRequest("GET", "/api/pipe/pipe1/probe", nil), http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, c.request)
+		if w.Code != c.want {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.want, w.Code)
+		}
+	}
+}
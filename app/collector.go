@@ -1,6 +1,8 @@
 package app
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,6 +14,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -41,6 +44,7 @@ type Reporter interface {
 type WebReporter struct {
 	Reporter
 	MetricsGraphURL string
+	MetricQueries   *detailed.MetricQueries
 }
 
 // Adder is something that can accept reports. It's a convenient interface for
@@ -48,11 +52,28 @@ type WebReporter struct {
 // arguments:
 // - context.Context: the request context
 // - report.Report: the deserialised report
-// - []byte: the serialised report (as gzip'd msgpack)
+// - []byte: the serialised report (as gzip'd msgpack), or nil
+//
+// The serialised report is nil if the report was not received serialised, as
+// when applying a delta to a streamed report. Adders which need it serialise
+// the report with ReportBytes.
 type Adder interface {
 	Add(context.Context, report.Report, []byte) error
 }
 
+// ReportBytes returns the serialised report passed to an Adder, serialising
+// the report if it is nil.
+func ReportBytes(rpt report.Report, buf []byte) ([]byte, error) {
+	if buf != nil {
+		return buf, nil
+	}
+	var serialised bytes.Buffer
+	if err := rpt.WriteBinary(&serialised, gzip.DefaultCompression); err != nil {
+		return nil, err
+	}
+	return serialised.Bytes(), nil
+}
+
 // A Collector is a Reporter and an Adder
 type Collector interface {
 	Reporter
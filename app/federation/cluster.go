@@ -0,0 +1,86 @@
+// Package federation implements an app which shows the reports of several
+// upstream Scope apps, one per cluster, and routes controls and pipes back to
+// them.
+package federation
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// pipeIDSeparator separates the cluster from the upstream pipe ID in the IDs
+// of federated pipes, so it can't be part of cluster names.
+const pipeIDSeparator = ":"
+
+// Cluster is an upstream Scope app.
+type Cluster struct {
+	Name string
+	URL  url.URL // The user of the URL, if any, is sent as a bearer token
+}
+
+// ParseCluster parses a name=URL cluster, e.g.
+// eu-west=https://token@scope.eu-west.example.com
+func ParseCluster(s string) (Cluster, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return Cluster{}, fmt.Errorf("invalid cluster %q, expected name=URL", s)
+	}
+	if strings.ContainsAny(parts[0], pipeIDSeparator+report.ScopeDelim) {
+		return Cluster{}, fmt.Errorf("invalid cluster name %q, can't contain %q or %q", parts[0], pipeIDSeparator, report.ScopeDelim)
+	}
+	u, err := url.Parse(parts[1])
+	if err != nil {
+		return Cluster{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Cluster{}, fmt.Errorf("invalid URL of cluster %s: %s", parts[0], parts[1])
+	}
+	return Cluster{Name: parts[0], URL: *u}, nil
+}
+
+// url returns the URL of an escaped path of the upstream app.
+func (c Cluster) url(path string) string {
+	u := url.URL{Scheme: c.URL.Scheme, Host: c.URL.Host}
+	return u.String() + strings.TrimSuffix(c.URL.EscapedPath(), "/") + path
+}
+
+// wsURL returns the websocket URL of an escaped path of the upstream app.
+func (c Cluster) wsURL(path string) string {
+	return strings.Replace(c.url(path), "http", "ws", 1)
+}
+
+func (c Cluster) authorize(headers http.Header) {
+	if c.URL.User != nil {
+		headers.Set("Authorization", "Bearer "+c.URL.User.Username())
+	}
+}
+
+// request makes an authorized request to an escaped path of the upstream
+// app.
+func (c Cluster) request(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.url(path), body)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(req.Header)
+	return req, nil
+}
+
+// federatedPipeID is the ID of a pipe of an upstream app in the federating
+// app.
+func federatedPipeID(cluster, pipeID string) string {
+	return cluster + pipeIDSeparator + pipeID
+}
+
+func parsePipeID(id string) (cluster, pipeID string, ok bool) {
+	parts := strings.SplitN(id, pipeIDSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,89 @@
+package federation
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// controlRouter forwards control requests to the apps of the clusters of
+// their probes.
+type controlRouter struct {
+	reporter *Reporter
+}
+
+// NewControlRouter makes a new ControlRouter forwarding control requests to
+// the clusters reporting their probes.
+func NewControlRouter(reporter *Reporter) app.ControlRouter {
+	return controlRouter{reporter: reporter}
+}
+
+func (cr controlRouter) Handle(_ context.Context, probeID string, req xfer.Request) (xfer.Response, error) {
+	cluster, ok := cr.reporter.clusterOfProbe(probeID)
+	if !ok {
+		return xfer.Response{}, fmt.Errorf("probe %s not found in any cluster", probeID)
+	}
+
+	// Pipes are identified by their clusters here, but not upstream
+	args := map[string]string{}
+	for k, v := range req.ControlArgs {
+		args[k] = v
+	}
+	if pipeID, ok := args["pipeID"]; ok {
+		if _, upstreamID, ok := parsePipeID(pipeID); ok {
+			args["pipeID"] = upstreamID
+		}
+	}
+	var body bytes.Buffer
+	if err := codec.NewEncoder(&body, &codec.JsonHandle{}).Encode(args); err != nil {
+		return xfer.Response{}, err
+	}
+
+	path := fmt.Sprintf("/api/control/%s/%s/%s", url.PathEscape(probeID), url.PathEscape(req.NodeID), url.PathEscape(req.Control))
+	httpReq, err := cluster.request("POST", path, &body)
+	if err != nil {
+		return xfer.Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := cr.reporter.config.Client.Do(httpReq)
+	if err != nil {
+		return xfer.Response{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var res xfer.Response
+		if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&res); err != nil {
+			return xfer.Response{}, err
+		}
+		if res.Pipe != "" {
+			res.Pipe = federatedPipeID(cluster.Name, res.Pipe)
+		}
+		return res, nil
+	case http.StatusBadRequest:
+		// The upstream app responds with the error of the control
+		var message string
+		if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&message); err != nil {
+			return xfer.Response{}, err
+		}
+		return xfer.Response{Error: message}, nil
+	default:
+		return xfer.Response{}, fmt.Errorf("control %s of cluster %s: %s", req.Control, cluster.Name, resp.Status)
+	}
+}
+
+func (controlRouter) Register(context.Context, string, xfer.ControlHandlerFunc) (int64, error) {
+	return 0, fmt.Errorf("federating apps don't accept probe connections, probes must connect to the app of their cluster")
+}
+
+func (controlRouter) Deregister(context.Context, string, int64) error {
+	return fmt.Errorf("federating apps don't accept probe connections")
+}
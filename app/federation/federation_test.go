@@ -0,0 +1,211 @@
+package federation_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/federation"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// upstreamApp is the app of a cluster with a single host and probe, which
+// opens a pipe echoing what it's sent on its "exec" control. Its host
+// connects from the same private address as the hosts of other clusters to a
+// public one.
+func upstreamApp(t *testing.T, host, probeID string) *httptest.Server {
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNodeWith(report.MakeHostNodeID(host), map[string]string{
+		report.ControlProbeID: probeID,
+	}))
+	rpt.Endpoint.AddNode(report.MakeNode(report.MakeEndpointNodeID(host, "", "10.0.0.1", "43210")).
+		WithAdjacent(report.MakeEndpointNodeID(host, "", "8.8.8.8", "53")))
+	rpt.Endpoint.AddNode(report.MakeNode(report.MakeEndpointNodeID(host, "", "8.8.8.8", "53")))
+	rpt.Container.AddNode(report.MakeNode(report.MakeContainerNodeID(host)).
+		WithSets(report.MakeSets().Add(report.DockerContainerIPsWithScopes, report.MakeStringSet(report.MakeAddressNodeID(host, "10.0.0.1")))))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/report", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		codec.NewEncoder(w, &codec.JsonHandle{}).Encode(rpt)
+	})
+	mux.HandleFunc("/api/control/", func(w http.ResponseWriter, r *http.Request) {
+		var args map[string]string
+		codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&args)
+		switch r.RequestURI {
+		case "/api/control/" + probeID + "/" + url.PathEscape(report.MakeHostNodeID(host)) + "/exec":
+			codec.NewEncoder(w, &codec.JsonHandle{}).Encode(xfer.Response{Pipe: "pipe1"})
+		case "/api/control/" + probeID + "/" + url.PathEscape(report.MakeHostNodeID(host)) + "/resize":
+			if args["pipeID"] != "pipe1" {
+				w.WriteHeader(http.StatusBadRequest)
+				codec.NewEncoder(w, &codec.JsonHandle{}).Encode("unknown pipe " + args["pipeID"])
+				return
+			}
+			codec.NewEncoder(w, &codec.JsonHandle{}).Encode(xfer.Response{})
+		default:
+			t.Errorf("Unexpected control %s", r.RequestURI)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/api/pipe/pipe1/check", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/pipe/pipe1", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := xfer.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for {
+			_, buf, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(2, buf); err != nil {
+				return
+			}
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func cluster(t *testing.T, name string, server *httptest.Server) federation.Cluster {
+	u, _ := url.Parse(server.URL)
+	cluster, err := federation.ParseCluster(name + "=" + u.Scheme + "://token@" + u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cluster
+}
+
+func TestFederation(t *testing.T) {
+	a, b := upstreamApp(t, "host-a", "probe-a"), upstreamApp(t, "host-b", "probe-b")
+	defer a.Close()
+	defer b.Close()
+
+	reporter := federation.NewReporter(federation.Config{
+		Clusters: []federation.Cluster{cluster(t, "a", a), cluster(t, "b", b)},
+		Client:   http.DefaultClient,
+		Interval: 10 * time.Millisecond,
+		Window:   time.Minute,
+	})
+	defer reporter.Stop()
+	ctx := context.Background()
+
+	// The reports of both clusters are merged, and their nodes stamped
+	var rpt report.Report
+	for deadline := time.Now().Add(5 * time.Second); len(rpt.Host.Nodes) < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the reports of both clusters, got %v", rpt.Host.Nodes)
+		}
+		var err error
+		if rpt, err = reporter.Report(ctx, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for host, want := range map[string]string{"host-a": "a", "host-b": "b"} {
+		if have, _ := rpt.Host.Nodes[report.MakeHostNodeID(host)].Latest.Lookup(report.Cluster); have != want {
+			t.Errorf("Expected %s in cluster %s, got %q", host, want, have)
+		}
+	}
+
+	// Private addresses are scoped by their cluster, public ones are shared
+	public := report.MakeEndpointNodeID("", "", "8.8.8.8", "53")
+	if want, have := 3, len(rpt.Endpoint.Nodes); want != have {
+		t.Errorf("Expected %d endpoints, got %v", want, rpt.Endpoint.Nodes)
+	}
+	for host, cluster := range map[string]string{"host-a": "a", "host-b": "b"} {
+		private := report.MakeScopedEndpointNodeID(cluster+"/", "10.0.0.1", "43210")
+		if n, ok := rpt.Endpoint.Nodes[private]; !ok || !n.Adjacency.Contains(public) {
+			t.Errorf("Expected %s connecting to %s, got %v", private, public, rpt.Endpoint.Nodes)
+		}
+		want := report.MakeStringSet(report.MakeScopedAddressNodeID(cluster+"/", "10.0.0.1"))
+		if have, _ := rpt.Container.Nodes[report.MakeContainerNodeID(host)].Sets.Lookup(report.DockerContainerIPsWithScopes); !want.Equal(have) {
+			t.Errorf("Expected the addresses of %s to be %v, got %v", host, want, have)
+		}
+	}
+
+	// Controls are sent to the cluster of their probe, and pipes are
+	// identified by their cluster
+	controlRouter := federation.NewControlRouter(reporter)
+	res, err := controlRouter.Handle(ctx, "probe-b", xfer.Request{NodeID: report.MakeHostNodeID("host-b"), Control: "exec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pipe != "b:pipe1" {
+		t.Errorf("Expected pipe b:pipe1, got %q", res.Pipe)
+	}
+	res, err = controlRouter.Handle(ctx, "probe-b", xfer.Request{
+		NodeID:      report.MakeHostNodeID("host-b"),
+		Control:     "resize",
+		ControlArgs: map[string]string{"pipeID": "b:pipe1"},
+	})
+	if err != nil || res.Error != "" {
+		t.Errorf("Expected the pipe ID to be translated, got %v, %v", res, err)
+	}
+	if _, err := controlRouter.Handle(ctx, "probe-c", xfer.Request{Control: "exec"}); err == nil {
+		t.Errorf("Expected an error for an unknown probe")
+	}
+
+	// Pipes are bridged to their cluster
+	pipeRouter := federation.NewPipeRouter(reporter)
+	defer pipeRouter.Stop()
+	if exists, err := pipeRouter.Exists(ctx, "b:pipe1"); err != nil || !exists {
+		t.Errorf("Expected pipe to exist, got %v, %v", exists, err)
+	}
+	pipe, ui, err := pipeRouter.Get(ctx, "b:pipe1", app.UIEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ui.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := ui.Read(buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Expected the echo of hello, got %q, %v", buf, err)
+	}
+	if err := pipeRouter.Release(ctx, "b:pipe1", app.UIEnd); err != nil {
+		t.Error(err)
+	}
+	if !pipe.Closed() {
+		t.Errorf("Expected the pipe to be closed")
+	}
+}
+
+func TestFederationRefusesLargeReports(t *testing.T) {
+	a := upstreamApp(t, "host-a", "probe-a")
+	defer a.Close()
+
+	reporter := federation.NewReporter(federation.Config{
+		Clusters:       []federation.Cluster{cluster(t, "a", a)},
+		Client:         http.DefaultClient,
+		Interval:       10 * time.Millisecond,
+		Window:         time.Minute,
+		MaxReportBytes: 64,
+	})
+	defer reporter.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	if has, err := reporter.HasReports(context.Background(), time.Now()); err != nil || has {
+		t.Errorf("Expected the report of the cluster to be refused, got %v, %v", has, err)
+	}
+}
+
+func TestParseCluster(t *testing.T) {
+	for _, invalid := range []string{"a", "=http://scope", "a:b=http://scope", "a=scope:4040"} {
+		if _, err := federation.ParseCluster(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
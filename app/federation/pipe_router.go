@@ -0,0 +1,155 @@
+package federation
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// pipeRouter bridges the pipes of the UI to the pipes of the apps of their
+// clusters.
+type pipeRouter struct {
+	reporter *Reporter
+	dialer   *websocket.Dialer
+
+	mtx   sync.Mutex
+	pipes map[string]xfer.Pipe
+}
+
+// NewPipeRouter makes a new PipeRouter bridging pipes to the clusters they
+// were opened in.
+func NewPipeRouter(reporter *Reporter) app.PipeRouter {
+	dialer := &websocket.Dialer{}
+	if transport, ok := reporter.config.Client.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+	return &pipeRouter{
+		reporter: reporter,
+		dialer:   dialer,
+		pipes:    map[string]xfer.Pipe{},
+	}
+}
+
+func (pr *pipeRouter) cluster(id string) (Cluster, string, error) {
+	name, upstreamID, ok := parsePipeID(id)
+	if !ok {
+		return Cluster{}, "", fmt.Errorf("pipe %s not opened by a cluster", id)
+	}
+	cluster, ok := pr.reporter.cluster(name)
+	if !ok {
+		return Cluster{}, "", fmt.Errorf("unknown cluster %s of pipe %s", name, id)
+	}
+	return cluster, upstreamID, nil
+}
+
+func (pr *pipeRouter) Exists(_ context.Context, id string) (bool, error) {
+	cluster, upstreamID, err := pr.cluster(id)
+	if err != nil {
+		return false, nil
+	}
+	req, err := cluster.request("GET", "/api/pipe/"+url.PathEscape(upstreamID)+"/check", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := pr.reporter.config.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("checking pipe %s: %s", id, resp.Status)
+	}
+}
+
+// Get connects to the pipe in the app of its cluster, and copies it to the
+// UI end of a local pipe.
+func (pr *pipeRouter) Get(_ context.Context, id string, e app.End) (xfer.Pipe, io.ReadWriter, error) {
+	if e != app.UIEnd {
+		return nil, nil, fmt.Errorf("federating apps don't accept probe connections")
+	}
+	cluster, upstreamID, err := pr.cluster(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	headers := http.Header{}
+	cluster.authorize(headers)
+	conn, _, err := xfer.DialWS(pr.dialer, cluster.wsURL("/api/pipe/"+url.PathEscape(upstreamID)), headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pipe := xfer.NewPipe()
+	ui, upstream := pipe.Ends()
+	pr.mtx.Lock()
+	if old, ok := pr.pipes[id]; ok {
+		go old.Close()
+	}
+	pr.pipes[id] = pipe
+	pr.mtx.Unlock()
+
+	go func() {
+		if err := pipe.CopyToWebsocket(upstream, conn); err != nil && !xfer.IsExpectedWSCloseError(err) {
+			log.Errorf("Error copying pipe %s of cluster %s: %v", upstreamID, cluster.Name, err)
+		}
+		conn.Close()
+		pipe.Close()
+	}()
+	return pipe, ui, nil
+}
+
+// Release closes the connection to the upstream pipe once the UI is done
+// with it.
+func (pr *pipeRouter) Release(_ context.Context, id string, _ app.End) error {
+	pr.mtx.Lock()
+	pipe, ok := pr.pipes[id]
+	delete(pr.pipes, id)
+	pr.mtx.Unlock()
+	if !ok {
+		return fmt.Errorf("pipe %s not found", id)
+	}
+	return pipe.Close()
+}
+
+func (pr *pipeRouter) Delete(_ context.Context, id string) error {
+	cluster, upstreamID, err := pr.cluster(id)
+	if err != nil {
+		return err
+	}
+	req, err := cluster.request("DELETE", "/api/pipe/"+url.PathEscape(upstreamID), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := pr.reporter.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("deleting pipe %s: %s", id, resp.Status)
+	}
+	return nil
+}
+
+func (pr *pipeRouter) Stop() {
+	pr.mtx.Lock()
+	pipes := pr.pipes
+	pr.pipes = map[string]xfer.Pipe{}
+	pr.mtx.Unlock()
+	for _, pipe := range pipes {
+		pipe.Close()
+	}
+}
@@ -0,0 +1,266 @@
+package federation
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/backoff"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+// defaultMaxReportBytes is the default largest report of a cluster to decode.
+const defaultMaxReportBytes = 100 * 1024 * 1024
+
+var clusterMetadataTemplates = report.MetadataTemplates{
+	report.Cluster: {ID: report.Cluster, Label: "Cluster", From: report.FromLatest, Priority: 0.5},
+}
+
+// Config configures a federating Reporter.
+type Config struct {
+	Clusters []Cluster
+	Client   *http.Client
+	Interval time.Duration // How often to pull the reports of the clusters
+	Window   time.Duration // How long to show the last report of a cluster which can't be reached
+
+	MaxReportBytes int64 // Largest report to accept from a cluster. Defaults to 100MB
+}
+
+// Reporter is an app.Collector which pulls the reports of several upstream
+// apps, stamps their nodes with their cluster, and merges them.
+//
+// The IDs of endpoints, and the addresses of containers, are scoped by their
+// cluster (see report.ClusterScope), as clusters may use the same private
+// ranges. Public addresses aren't, so that connections to those of other
+// clusters are resolved like those within a cluster. Other nodes keep their
+// IDs: those of hosts, and of the processes of hosts, are made of host names,
+// so host names must be unique across clusters, or the hosts are merged.
+type Reporter struct {
+	config Config
+	merger app.Merger
+
+	mtx     sync.Mutex
+	reports map[string]clusterReport
+	cached  *report.Report
+	expiry  time.Time // When the oldest report of the cached one goes stale
+	waiters map[chan struct{}]struct{}
+
+	backoffs []backoff.Interface
+}
+
+type clusterReport struct {
+	report report.Report
+	probes map[string]struct{} // IDs of the probes of the cluster
+	at     time.Time
+}
+
+// NewReporter makes a new Reporter, and starts pulling reports.
+func NewReporter(config Config) *Reporter {
+	if config.MaxReportBytes == 0 {
+		config.MaxReportBytes = defaultMaxReportBytes
+	}
+	r := &Reporter{
+		config:  config,
+		merger:  app.NewSmartMerger(),
+		reports: map[string]clusterReport{},
+		waiters: map[chan struct{}]struct{}{},
+	}
+	for _, cluster := range config.Clusters {
+		cluster := cluster
+		b := backoff.New(func() (bool, error) {
+			return false, r.pull(cluster)
+		}, fmt.Sprintf("pulling report of cluster %s", cluster.Name))
+		b.SetInitialBackoff(config.Interval)
+		r.backoffs = append(r.backoffs, b)
+		go b.Start()
+	}
+	return r
+}
+
+// Stop pulling reports.
+func (r *Reporter) Stop() {
+	for _, b := range r.backoffs {
+		b.Stop()
+	}
+}
+
+func (r *Reporter) pull(cluster Cluster) error {
+	req, err := cluster.request("GET", "/api/report", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	rpt := report.MakeReport()
+	body := http.MaxBytesReader(nil, resp.Body, r.config.MaxReportBytes)
+	if err := rpt.ReadBinary(body, false, &codec.JsonHandle{}); err != nil {
+		return fmt.Errorf("%s: %v", req.URL, err)
+	}
+	r.add(cluster.Name, rpt, mtime.Now())
+	return nil
+}
+
+// add replaces the report of a cluster.
+func (r *Reporter) add(cluster string, rpt report.Report, now time.Time) {
+	rpt, probes := stamp(cluster, rpt, now)
+
+	r.mtx.Lock()
+	r.reports[cluster] = clusterReport{report: rpt, probes: probes, at: now}
+	r.cached = nil
+	for waiter := range r.waiters {
+		// Non-block write to channel
+		select {
+		case waiter <- struct{}{}:
+		default:
+		}
+	}
+	r.mtx.Unlock()
+}
+
+// stamp adds the cluster to all the nodes of a report, scopes their addresses
+// by the cluster, and returns the IDs of the probes of the cluster.
+func stamp(cluster string, rpt report.Report, now time.Time) (report.Report, map[string]struct{}) {
+	probes := map[string]struct{}{}
+	rpt = rpt.Copy()
+	rpt.WalkNamedTopologies(func(name string, t *report.Topology) {
+		nodes := make(report.Nodes, len(t.Nodes))
+		for id, n := range t.Nodes {
+			if probeID, ok := n.Latest.Lookup(report.ControlProbeID); ok {
+				probes[probeID] = struct{}{}
+			}
+			if name == report.Endpoint {
+				id = scopeEndpoint(cluster, id)
+				n.ID = id
+				adjacency := report.MakeIDList()
+				for _, adjacent := range n.Adjacency {
+					adjacency = adjacency.Add(scopeEndpoint(cluster, adjacent))
+				}
+				n.Adjacency = adjacency
+			}
+			if addresses, ok := n.Sets.Lookup(report.DockerContainerIPsWithScopes); ok {
+				scoped := make([]string, 0, len(addresses))
+				for _, address := range addresses {
+					scoped = append(scoped, scopeAddress(cluster, address))
+				}
+				n.Sets = n.Sets.Delete(report.DockerContainerIPsWithScopes).Add(report.DockerContainerIPsWithScopes, report.MakeStringSet(scoped...))
+			}
+			nodes[id] = n.WithLatest(report.Cluster, now, cluster)
+		}
+		t.Nodes = nodes
+		t.MetadataTemplates = t.MetadataTemplates.Merge(clusterMetadataTemplates)
+	})
+	return rpt, probes
+}
+
+func scopeEndpoint(cluster, id string) string {
+	scope, address, port, ok := report.ParseEndpointNodeID(id)
+	if !ok {
+		return id
+	}
+	return report.MakeScopedEndpointNodeID(report.ClusterScope(cluster, scope, address), address, port)
+}
+
+func scopeAddress(cluster, id string) string {
+	scope, address, ok := report.ParseAddressNodeID(id)
+	if !ok {
+		return id
+	}
+	return report.MakeScopedAddressNodeID(report.ClusterScope(cluster, scope, address), address)
+}
+
+// Report implements app.Reporter. The reports of clusters which couldn't be
+// reached within the window are left out.
+func (r *Reporter) Report(_ context.Context, _ time.Time) (report.Report, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	now := mtime.Now()
+	if r.cached != nil && now.Before(r.expiry) {
+		return *r.cached, nil
+	}
+	reports := r.freshReports(now)
+	merged := r.merger.Merge(reports)
+	r.cached = &merged
+	r.expiry = now.Add(r.config.Window)
+	for _, cr := range r.reports {
+		if expiry := cr.at.Add(r.config.Window); expiry.After(now) && expiry.Before(r.expiry) {
+			r.expiry = expiry
+		}
+	}
+	return merged, nil
+}
+
+func (r *Reporter) freshReports(now time.Time) []report.Report {
+	reports := []report.Report{}
+	for _, cr := range r.reports {
+		if now.Sub(cr.at) < r.config.Window {
+			reports = append(reports, cr.report)
+		}
+	}
+	return reports
+}
+
+// HasReports implements app.Reporter.
+func (r *Reporter) HasReports(_ context.Context, _ time.Time) (bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.freshReports(mtime.Now())) > 0, nil
+}
+
+// HasHistoricReports implements app.Reporter. Upstream apps are only asked
+// for their current reports.
+func (r *Reporter) HasHistoricReports() bool {
+	return false
+}
+
+// WaitOn implements app.Reporter.
+func (r *Reporter) WaitOn(_ context.Context, waiter chan struct{}) {
+	r.mtx.Lock()
+	r.waiters[waiter] = struct{}{}
+	r.mtx.Unlock()
+}
+
+// UnWait implements app.Reporter.
+func (r *Reporter) UnWait(_ context.Context, waiter chan struct{}) {
+	r.mtx.Lock()
+	delete(r.waiters, waiter)
+	r.mtx.Unlock()
+}
+
+// Add implements app.Adder. Probes report to the apps of their clusters.
+func (r *Reporter) Add(context.Context, report.Report, []byte) error {
+	return fmt.Errorf("federating apps don't accept reports, probes must report to the app of their cluster")
+}
+
+// cluster returns the cluster of a name.
+func (r *Reporter) cluster(name string) (Cluster, bool) {
+	for _, c := range r.config.Clusters {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Cluster{}, false
+}
+
+// clusterOfProbe returns the cluster reporting a probe.
+func (r *Reporter) clusterOfProbe(probeID string) (Cluster, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for name, cr := range r.reports {
+		if _, ok := cr.probes[probeID]; ok {
+			return r.cluster(name)
+		}
+	}
+	return Cluster{}, false
+}
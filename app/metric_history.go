@@ -0,0 +1,440 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/backoff"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+const defaultMetricHistoryRange = time.Hour
+
+// MetricHistoryResolution is a resolution the history of metrics is kept at:
+// samples are downsampled into buckets of Step, for Retention.
+type MetricHistoryResolution struct {
+	Step      time.Duration
+	Retention time.Duration
+}
+
+func (r MetricHistoryResolution) String() string {
+	return fmt.Sprintf("%v:%v", r.Step, r.Retention)
+}
+
+// ParseMetricHistoryResolutions parses comma-separated step:retention
+// resolutions, e.g. 15s:1h,2m:6h
+func ParseMetricHistoryResolutions(s string) ([]MetricHistoryResolution, error) {
+	resolutions := []MetricHistoryResolution{}
+	if s == "" {
+		return resolutions, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		durations := strings.SplitN(part, ":", 2)
+		if len(durations) != 2 {
+			return nil, fmt.Errorf("invalid resolution %q, expected step:retention", part)
+		}
+		step, err := time.ParseDuration(durations[0])
+		if err != nil {
+			return nil, err
+		}
+		retention, err := time.ParseDuration(durations[1])
+		if err != nil {
+			return nil, err
+		}
+		if step <= 0 || retention < step {
+			return nil, fmt.Errorf("invalid resolution %q, the retention must be at least the step", part)
+		}
+		resolutions = append(resolutions, MetricHistoryResolution{Step: step, Retention: retention})
+	}
+	sort.Sort(byStep(resolutions))
+	return resolutions, nil
+}
+
+type byStep []MetricHistoryResolution
+
+func (s byStep) Len() int           { return len(s) }
+func (s byStep) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byStep) Less(i, j int) bool { return s[i].Step < s[j].Step }
+
+// MetricHistory keeps the history of the metrics of the nodes of reports for
+// longer than the reports themselves. Samples are downsampled into buckets
+// in ring buffers, one per resolution, so the memory used per metric is
+// bounded. Metrics of nodes which haven't been reported for the longest
+// retention are forgotten, and so are the least recently reported ones
+// beyond the maximum number of series kept.
+type MetricHistory struct {
+	resolutions []MetricHistoryResolution
+	maxSeries   int
+
+	mtx    sync.Mutex
+	series map[seriesKey]*series
+
+	backoff backoff.Interface
+}
+
+type seriesKey struct {
+	nodeID, metricID string
+}
+
+// series is the history of a metric of a node.
+type series struct {
+	last  time.Time // Timestamp of the last sample recorded
+	rings []ring    // One per resolution
+}
+
+// ring is a ring buffer of consecutive buckets.
+type ring struct {
+	step        time.Duration
+	buckets     []bucket
+	newest      int       // Index of the newest bucket
+	newestStart time.Time // Start of the newest bucket, zero if empty
+}
+
+type bucket struct {
+	min, max, sum float64
+	count         int
+}
+
+// MetricHistorySample is the minimum, maximum and average of the samples of
+// a metric in a step.
+type MetricHistorySample struct {
+	Timestamp time.Time `json:"date"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Avg       float64   `json:"avg"`
+}
+
+// NewMetricHistory makes a new MetricHistory, keeping the history of at most
+// maxSeries metrics of nodes. It records the reports of the reporter every
+// step of the finest resolution, if there's a reporter.
+func NewMetricHistory(reporter Reporter, resolutions []MetricHistoryResolution, maxSeries int) *MetricHistory {
+	h := &MetricHistory{
+		resolutions: resolutions,
+		maxSeries:   maxSeries,
+		series:      map[seriesKey]*series{},
+	}
+	if reporter != nil && len(resolutions) > 0 {
+		h.backoff = backoff.New(func() (bool, error) {
+			rpt, err := reporter.Report(context.Background(), mtime.Now())
+			if err != nil {
+				return false, err
+			}
+			h.Record(rpt)
+			return false, nil
+		}, "recording metric history")
+		h.backoff.SetInitialBackoff(resolutions[0].Step)
+		go h.backoff.Start()
+	}
+	return h
+}
+
+// Stop recording reports.
+func (h *MetricHistory) Stop() {
+	if h.backoff != nil {
+		h.backoff.Stop()
+	}
+}
+
+// Record adds the samples of the metrics of all the nodes of a report which
+// are newer than the ones already recorded.
+func (h *MetricHistory) Record(rpt report.Report) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	rpt.WalkTopologies(func(t *report.Topology) {
+		for nodeID, n := range t.Nodes {
+			for metricID, metric := range n.Metrics {
+				key := seriesKey{nodeID: nodeID, metricID: metricID}
+				s, ok := h.series[key]
+				if !ok {
+					s = h.newSeries()
+					h.series[key] = s
+				}
+				for _, sample := range metric.Samples {
+					if sample.Timestamp.After(s.last) {
+						s.add(sample)
+					}
+				}
+			}
+		}
+	})
+
+	// Forget the metrics of nodes which are gone
+	if len(h.resolutions) == 0 {
+		return
+	}
+	horizon := mtime.Now().Add(-h.resolutions[len(h.resolutions)-1].Retention)
+	for key, s := range h.series {
+		if s.last.Before(horizon) {
+			delete(h.series, key)
+		}
+	}
+	h.evict()
+}
+
+// evict forgets the least recently reported series beyond the maximum.
+func (h *MetricHistory) evict() {
+	if len(h.series) <= h.maxSeries {
+		return
+	}
+	keys := make([]seriesKey, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Sort(byLastSample{keys, h.series})
+	for _, key := range keys[:len(keys)-h.maxSeries] {
+		delete(h.series, key)
+	}
+}
+
+// byLastSample sorts series oldest first.
+type byLastSample struct {
+	keys   []seriesKey
+	series map[seriesKey]*series
+}
+
+func (s byLastSample) Len() int      { return len(s.keys) }
+func (s byLastSample) Swap(i, j int) { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s byLastSample) Less(i, j int) bool {
+	return s.series[s.keys[i]].last.Before(s.series[s.keys[j]].last)
+}
+
+func (h *MetricHistory) newSeries() *series {
+	s := &series{}
+	for _, r := range h.resolutions {
+		s.rings = append(s.rings, ring{
+			step:    r.Step,
+			buckets: make([]bucket, int(r.Retention/r.Step)),
+		})
+	}
+	return s
+}
+
+func (s *series) add(sample report.Sample) {
+	for i := range s.rings {
+		s.rings[i].add(sample)
+	}
+	s.last = sample.Timestamp
+}
+
+func (r *ring) add(sample report.Sample) {
+	start := sample.Timestamp.Truncate(r.step)
+	if r.newestStart.IsZero() {
+		r.newestStart = start
+	} else if start.After(r.newestStart) {
+		n := int(start.Sub(r.newestStart) / r.step)
+		if n > len(r.buckets) {
+			n = len(r.buckets)
+		}
+		for i := 0; i < n; i++ {
+			r.newest = (r.newest + 1) % len(r.buckets)
+			r.buckets[r.newest] = bucket{}
+		}
+		r.newestStart = start
+	}
+	age := int(r.newestStart.Sub(start) / r.step)
+	if age >= len(r.buckets) {
+		return
+	}
+	r.buckets[(r.newest-age+len(r.buckets))%len(r.buckets)].add(sample.Value)
+}
+
+func (b *bucket) add(v float64) {
+	if b.count == 0 || v < b.min {
+		b.min = v
+	}
+	if b.count == 0 || v > b.max {
+		b.max = v
+	}
+	b.sum += v
+	b.count++
+}
+
+func (b *bucket) merge(o bucket) {
+	if o.count == 0 {
+		return
+	}
+	if b.count == 0 || o.min < b.min {
+		b.min = o.min
+	}
+	if b.count == 0 || o.max > b.max {
+		b.max = o.max
+	}
+	b.sum += o.sum
+	b.count += o.count
+}
+
+// samples returns the buckets since from, merged into steps, oldest first.
+func (r *ring) samples(from time.Time, step time.Duration) []MetricHistorySample {
+	result := []MetricHistorySample{}
+	if r.newestStart.IsZero() {
+		return result
+	}
+	var (
+		current      bucket
+		currentStart time.Time
+	)
+	flush := func() {
+		if current.count > 0 {
+			result = append(result, MetricHistorySample{
+				Timestamp: currentStart,
+				Min:       current.min,
+				Max:       current.max,
+				Avg:       current.sum / float64(current.count),
+			})
+		}
+		current = bucket{}
+	}
+	for age := len(r.buckets) - 1; age >= 0; age-- {
+		b := r.buckets[(r.newest-age+len(r.buckets))%len(r.buckets)]
+		start := r.newestStart.Add(-time.Duration(age) * r.step)
+		if b.count == 0 || start.Before(from) {
+			continue
+		}
+		if start := start.Truncate(step); !start.Equal(currentStart) {
+			flush()
+			currentStart = start
+		}
+		current.merge(b)
+	}
+	flush()
+	return result
+}
+
+// resolution returns the index of the finest resolution keeping a range.
+func (h *MetricHistory) resolution(rng time.Duration) int {
+	for i, r := range h.resolutions {
+		if r.Retention >= rng {
+			return i
+		}
+	}
+	return len(h.resolutions) - 1
+}
+
+// Step returns the step of the history over a range: the requested one, or
+// the step of the resolution keeping the range if that's coarser.
+func (h *MetricHistory) Step(rng, step time.Duration) time.Duration {
+	if len(h.resolutions) == 0 {
+		return step
+	}
+	if min := h.resolutions[h.resolution(rng)].Step; step < min {
+		return min
+	}
+	return step
+}
+
+// Samples returns the history of a metric of a node over a range, in steps
+// no finer than the resolution keeping it.
+func (h *MetricHistory) Samples(nodeID, metricID string, rng, step time.Duration) []MetricHistorySample {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	s, ok := h.series[seriesKey{nodeID: nodeID, metricID: metricID}]
+	if !ok {
+		return []MetricHistorySample{}
+	}
+	return s.rings[h.resolution(rng)].samples(mtime.Now().Add(-rng), h.Step(rng, step))
+}
+
+func (h *MetricHistory) has(nodeID, metricID string) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	_, ok := h.series[seriesKey{nodeID: nodeID, metricID: metricID}]
+	return ok
+}
+
+// source returns the ID of the node whose history is that of a metric of a
+// rendered node: the node itself, or its only child with a history of the
+// metric, whose metrics were propagated to it.
+func (h *MetricHistory) source(n report.Node, metricID string) string {
+	if h.has(n.ID, metricID) {
+		return n.ID
+	}
+	sources := []string{}
+	n.Children.ForEach(func(child report.Node) {
+		if h.has(child.ID, metricID) {
+			sources = append(sources, child.ID)
+		}
+	})
+	if len(sources) != 1 {
+		return n.ID
+	}
+	return sources[0]
+}
+
+// APINodeMetrics is returned by the /api/topology/{name}/{id}/metrics
+// handler.
+type APINodeMetrics struct {
+	ID      string                `json:"id"`
+	Range   string                `json:"range"`
+	Step    string                `json:"step"`
+	Metrics []APINodeMetricSeries `json:"metrics"`
+}
+
+// APINodeMetricSeries is the history of a metric of a node.
+type APINodeMetricSeries struct {
+	ID      string                `json:"id"`
+	Label   string                `json:"label"`
+	Samples []MetricHistorySample `json:"samples"`
+}
+
+// RegisterMetricHistoryRoutes registers the route of the history of the
+// metrics of nodes.
+func RegisterMetricHistoryRoutes(router *mux.Router, r Reporter, h *MetricHistory) {
+	router.Methods("GET").
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/metrics")).HandlerFunc(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, h.handleNodeMetrics)))).
+		Name("api_topology_topology_id_metrics")
+}
+
+func (h *MetricHistory) handleNodeMetrics(ctx context.Context, renderer render.Renderer, _ render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	var (
+		nodeID = mux.Vars(r)["id"]
+		rng    = defaultMetricHistoryRange
+		step   time.Duration
+		err    error
+	)
+	if s := r.Form.Get("range"); s != "" {
+		if rng, err = time.ParseDuration(s); err != nil || rng <= 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Sprintf("invalid range %q", s))
+			return
+		}
+	}
+	if s := r.Form.Get("step"); s != "" {
+		if step, err = time.ParseDuration(s); err != nil || step <= 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Sprintf("invalid step %q", s))
+			return
+		}
+	}
+
+	node, ok := renderer.Render(rc.Report).Nodes[nodeID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	// Only the metrics shown in the details of the node
+	summary, _ := detailed.MakeNodeSummary(detailed.RenderContext{Report: rc.Report}, node)
+	result := APINodeMetrics{
+		ID:      nodeID,
+		Range:   rng.String(),
+		Step:    h.Step(rng, step).String(),
+		Metrics: []APINodeMetricSeries{},
+	}
+	for _, metric := range summary.Metrics {
+		result.Metrics = append(result.Metrics, APINodeMetricSeries{
+			ID:      metric.ID,
+			Label:   metric.Label,
+			Samples: h.Samples(h.source(node, metric.ID), metric.ID, rng, step),
+		})
+	}
+	respondWith(w, http.StatusOK, result)
+}
@@ -0,0 +1,162 @@
+package app_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+var historyHostID = report.MakeHostNodeID("host")
+
+// cpuReport reports the CPU usage of a host, a sample per second from start.
+func cpuReport(start time.Time, values ...float64) report.Report {
+	samples := []report.Sample{}
+	for i, v := range values {
+		samples = append(samples, report.Sample{Timestamp: start.Add(time.Duration(i) * time.Second), Value: v})
+	}
+	rpt := report.MakeReport()
+	rpt.Host = rpt.Host.WithMetricTemplates(host.MetricTemplates)
+	rpt.Host.AddNode(report.MakeNode(historyHostID).WithTopology(report.Host).WithMetrics(report.Metrics{
+		host.CPUUsage: report.MakeMetric(samples),
+	}))
+	return rpt
+}
+
+func checkSamples(t *testing.T, have []app.MetricHistorySample, want []app.MetricHistorySample) {
+	if len(have) != len(want) {
+		t.Fatalf("Expected %d samples, got %v", len(want), have)
+	}
+	for i := range want {
+		if !have[i].Timestamp.Equal(want[i].Timestamp) || have[i].Min != want[i].Min || have[i].Max != want[i].Max || have[i].Avg != want[i].Avg {
+			t.Errorf("Expected sample %d to be %v, got %v", i, want[i], have[i])
+		}
+	}
+}
+
+func TestMetricHistory(t *testing.T) {
+	resolutions, err := app.ParseMetricHistoryResolutions("1m:10m,10s:1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := app.NewMetricHistory(nil, resolutions, 100)
+	start := time.Unix(1000*60, 0).UTC()
+	mtime.NowForce(start.Add(time.Minute))
+	defer mtime.NowReset()
+
+	// Samples already recorded are skipped
+	h.Record(cpuReport(start, 1, 2, 3))
+	h.Record(cpuReport(start, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12))
+	h.Record(cpuReport(start.Add(30*time.Second), 20, 40))
+
+	checkSamples(t, h.Samples(historyHostID, host.CPUUsage, time.Minute, 0), []app.MetricHistorySample{
+		{Timestamp: start, Min: 1, Max: 10, Avg: 5.5},
+		{Timestamp: start.Add(10 * time.Second), Min: 11, Max: 12, Avg: 11.5},
+		{Timestamp: start.Add(30 * time.Second), Min: 20, Max: 40, Avg: 30},
+	})
+	checkSamples(t, h.Samples(historyHostID, host.CPUUsage, time.Minute, 20*time.Second), []app.MetricHistorySample{
+		{Timestamp: start, Min: 1, Max: 12, Avg: 6.5},
+		{Timestamp: start.Add(20 * time.Second), Min: 20, Max: 40, Avg: 30},
+	})
+	if step := h.Step(5*time.Minute, time.Second); step != time.Minute {
+		t.Errorf("Expected the step of the coarser resolution, got %v", step)
+	}
+	checkSamples(t, h.Samples(historyHostID, host.CPUUsage, 5*time.Minute, 0), []app.MetricHistorySample{
+		{Timestamp: start, Min: 1, Max: 40, Avg: 138.0 / 14},
+	})
+
+	// Old buckets are overwritten, and the metrics of nodes which are gone
+	// forgotten
+	mtime.NowForce(start.Add(2 * time.Minute))
+	h.Record(cpuReport(start.Add(2*time.Minute), 50))
+	checkSamples(t, h.Samples(historyHostID, host.CPUUsage, time.Minute, 0), []app.MetricHistorySample{
+		{Timestamp: start.Add(2 * time.Minute), Min: 50, Max: 50, Avg: 50},
+	})
+	mtime.NowForce(start.Add(20 * time.Minute))
+	h.Record(report.MakeReport())
+	checkSamples(t, h.Samples(historyHostID, host.CPUUsage, 10*time.Minute, 0), []app.MetricHistorySample{})
+}
+
+func TestMetricHistoryMaxSeries(t *testing.T) {
+	resolutions, err := app.ParseMetricHistoryResolutions("10s:1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := app.NewMetricHistory(nil, resolutions, 2)
+	start := time.Unix(1000*60, 0).UTC()
+	mtime.NowForce(start)
+	defer mtime.NowReset()
+
+	// The least recently reported series are forgotten first
+	hosts := []string{"host1", "host2", "host3"}
+	for i, hostname := range hosts {
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID(hostname)).WithMetrics(report.Metrics{
+			host.CPUUsage: report.MakeMetric([]report.Sample{{Timestamp: start.Add(time.Duration(i) * time.Second), Value: 1}}),
+		}))
+		h.Record(rpt)
+	}
+	for i, hostname := range hosts {
+		have := len(h.Samples(report.MakeHostNodeID(hostname), host.CPUUsage, time.Minute, 0))
+		if want := map[bool]int{true: 0, false: 1}[i == 0]; have != want {
+			t.Errorf("Expected %d samples of %s, got %d", want, hostname, have)
+		}
+	}
+}
+
+func TestParseMetricHistoryResolutions(t *testing.T) {
+	for _, invalid := range []string{"1m", "1m:", "1h:1m", "0s:1m", "1m:1h,"} {
+		if _, err := app.ParseMetricHistoryResolutions(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestAPITopologyNodeMetrics(t *testing.T) {
+	start := time.Unix(1000*60, 0).UTC()
+	mtime.NowForce(start.Add(time.Minute))
+	defer mtime.NowReset()
+	rpt := cpuReport(start, 1, 2, 3)
+	resolutions, _ := app.ParseMetricHistoryResolutions("10s:1h")
+	h := app.NewMetricHistory(nil, resolutions, 100)
+	h.Record(rpt)
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt), nil)
+	app.RegisterMetricHistoryRoutes(router, app.StaticCollector(rpt), h)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	path := "/api/topology/hosts/" + url.QueryEscape(historyHostID) + "/metrics"
+	body := getRawJSON(t, ts, path+"?range=5m&step=1s")
+	var metrics app.APINodeMetrics
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, historyHostID, metrics.ID)
+	equals(t, "5m0s", metrics.Range)
+	equals(t, "10s", metrics.Step)
+	var cpu *app.APINodeMetricSeries
+	for i, m := range metrics.Metrics {
+		if m.ID == host.CPUUsage {
+			cpu = &metrics.Metrics[i]
+		}
+	}
+	if cpu == nil {
+		t.Fatalf("Expected the history of the CPU usage, got %v", metrics.Metrics)
+	}
+	equals(t, "CPU", cpu.Label)
+	checkSamples(t, cpu.Samples, []app.MetricHistorySample{{Timestamp: start, Min: 1, Max: 3, Avg: 2}})
+
+	is400(t, ts, path+"?range=forever")
+	is400(t, ts, path+"?step=-1s")
+	is404(t, ts, "/api/topology/hosts/"+url.QueryEscape(report.MakeHostNodeID("other"))+"/metrics")
+}
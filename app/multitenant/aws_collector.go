@@ -428,6 +428,10 @@ func (c *awsCollector) Add(ctx context.Context, rep report.Report, buf []byte) e
 		return err
 	}
 
+	buf, err = app.ReportBytes(rep, buf)
+	if err != nil {
+		return err
+	}
 	reportSize, err := c.s3.StoreReportBytes(ctx, reportKey, buf)
 	if err != nil {
 		return err
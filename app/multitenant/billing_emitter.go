@@ -56,6 +56,12 @@ func (e *BillingEmitter) Add(ctx context.Context, rep report.Report, buf []byte)
 	}
 	rowKey, colKey := calculateDynamoKeys(userID, now)
 
+	// Serialise the report once, for the hash and the upstream collector
+	buf, err = app.ReportBytes(rep, buf)
+	if err != nil {
+		return err
+	}
+
 	interval := e.reportInterval(rep)
 	hasher := sha256.New()
 	hasher.Write(buf)
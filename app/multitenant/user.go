@@ -31,6 +31,20 @@ func UserIDHeader(headerName string) UserIDer {
 	}
 }
 
+// UserIDClientCertificate is a UserIDer which identifies users by the common
+// name of the verified client certificate of the request.
+func UserIDClientCertificate(ctx context.Context) (string, error) {
+	request, ok := ctx.Value(app.RequestCtxKey).(*http.Request)
+	if !ok || request == nil {
+		return "", ErrUserIDNotFound
+	}
+	userID, ok := app.ClientCertificateName(request)
+	if !ok {
+		return "", ErrUserIDNotFound
+	}
+	return userID, nil
+}
+
 // NoopUserIDer always returns the empty user ID.
 func NoopUserIDer(context.Context) (string, error) {
 	return "", nil
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/bluele/gcache"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+var (
+	summariesCacheRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "summaries_cache_requests_total",
+		Help:      "Total count of rendered topologies requested from the summaries cache.",
+	})
+
+	summariesCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "summaries_cache_hits_total",
+		Help:      "Total count of rendered topologies found in the summaries cache.",
+	})
+
+	// sharedSummaries is shared by all clients of the app, so that rendering
+	// cost doesn't grow with the number of users watching the same view.
+	sharedSummaries = newSummariesCache(100)
+)
+
+func init() {
+	prometheus.MustRegister(summariesCacheRequests)
+	prometheus.MustRegister(summariesCacheHits)
+}
+
+// ignoredRenderOptions are request parameters which don't affect rendering,
+// and so are left out of cache keys.
+var ignoredRenderOptions = []string{"t", "timestamp", "since", "timeout", "format", "columns"}
+
+// renderSummaries renders the node summaries of a topology. The result is
+// shared with concurrent and subsequent requests for the same report,
+// topology and options.
+func renderSummaries(rc detailed.RenderContext, topologyID string, values url.Values, renderer render.Renderer, transformer render.Transformer) detailed.NodeSummaries {
+	key := summariesKey(rc.Report.ID, topologyID, values, rc.MetricsGraphURL)
+	return sharedSummaries.get(key, func() detailed.NodeSummaries {
+		return detailed.Summaries(rc, render.Render(rc.Report, renderer, transformer).Nodes)
+	})
+}
+
+// cachedSummaries returns the node summaries of a topology for an earlier
+// report, if they are still cached.
+func cachedSummaries(rc detailed.RenderContext, reportID, topologyID string, values url.Values) (detailed.NodeSummaries, bool) {
+	return sharedSummaries.lookup(summariesKey(reportID, topologyID, values, rc.MetricsGraphURL))
+}
+
+func summariesKey(reportID, topologyID string, values url.Values, metricsGraphURL string) string {
+	options := url.Values{}
+	for k, v := range values {
+		options[k] = v
+	}
+	for _, k := range ignoredRenderOptions {
+		options.Del(k)
+	}
+	// url.Values.Encode() sorts by key, so equal options give equal keys
+	return fmt.Sprintf("%s-%s-%s-%s", reportID, topologyID, options.Encode(), metricsGraphURL)
+}
+
+// summariesCache holds promises of rendered node summaries. As with
+// render.Memoise, using promises means concurrent identical requests only
+// render once.
+type summariesCache struct {
+	sync.Mutex
+	cache gcache.Cache
+}
+
+func newSummariesCache(size int) *summariesCache {
+	return &summariesCache{cache: gcache.New(size).LRU().Build()}
+}
+
+func (c *summariesCache) get(key string, f func() detailed.NodeSummaries) detailed.NodeSummaries {
+	summariesCacheRequests.Inc()
+	c.Lock()
+	v, err := c.cache.Get(key)
+	if err == nil {
+		c.Unlock()
+		summariesCacheHits.Inc()
+		if val, ok := v.(*summariesPromise).get(); ok {
+			return val
+		}
+		// The render failed; try again ourselves
+		return c.get(key, f)
+	}
+	promise := &summariesPromise{done: make(chan struct{})}
+	c.cache.Set(key, promise)
+	c.Unlock()
+
+	// If f panics, forget the promise and release anyone waiting on it
+	defer func() {
+		if !promise.ok {
+			c.Lock()
+			if v, err := c.cache.Get(key); err == nil && v == promise {
+				c.cache.Remove(key)
+			}
+			c.Unlock()
+			close(promise.done)
+		}
+	}()
+	promise.set(f())
+	return promise.val
+}
+
+func (c *summariesCache) lookup(key string) (detailed.NodeSummaries, bool) {
+	c.Lock()
+	v, err := c.cache.Get(key)
+	c.Unlock()
+	if err != nil {
+		return nil, false
+	}
+	return v.(*summariesPromise).get()
+}
+
+type summariesPromise struct {
+	val  detailed.NodeSummaries
+	ok   bool
+	done chan struct{}
+}
+
+func (p *summariesPromise) set(val detailed.NodeSummaries) {
+	p.val, p.ok = val, true
+	close(p.done)
+}
+
+// get waits for the promise, and reports whether it was set
+func (p *summariesPromise) get() (detailed.NodeSummaries, bool) {
+	<-p.done
+	return p.val, p.ok
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+func TestSummariesCacheCoalesces(t *testing.T) {
+	var (
+		c       = newSummariesCache(10)
+		renders int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	render := func() detailed.NodeSummaries {
+		atomic.AddInt32(&renders, 1)
+		<-release
+		return detailed.NodeSummaries{"foo": {ID: "foo"}}
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if have := c.get("key", render); len(have) != 1 {
+				t.Errorf("Unexpected summaries: %v", have)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if have := atomic.LoadInt32(&renders); have != 1 {
+		t.Errorf("want 1 render, have %d", have)
+	}
+
+	c.get("other", render)
+	if have := atomic.LoadInt32(&renders); have != 2 {
+		t.Errorf("want 2 renders, have %d", have)
+	}
+}
+
+func TestSummariesCacheRecoversFromPanics(t *testing.T) {
+	var (
+		c       = newSummariesCache(10)
+		started = make(chan struct{})
+		release = make(chan struct{})
+		result  = make(chan detailed.NodeSummaries)
+	)
+	go func() {
+		defer func() { recover() }()
+		c.get("key", func() detailed.NodeSummaries {
+			close(started)
+			<-release
+			panic("render failed")
+		})
+	}()
+	<-started
+	go func() {
+		result <- c.get("key", func() detailed.NodeSummaries {
+			return detailed.NodeSummaries{"foo": {ID: "foo"}}
+		})
+	}()
+	close(release)
+
+	select {
+	case have := <-result:
+		if len(have) != 1 {
+			t.Errorf("Unexpected summaries: %v", have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Request blocked on a panicked render")
+	}
+	if _, ok := c.lookup("key"); !ok {
+		t.Error("Expected the retried render to be cached")
+	}
+}
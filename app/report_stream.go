@@ -0,0 +1,110 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// handleReportStream accepts a long-lived websocket from a probe, over which
+// it receives a stream of reports. Each report is acknowledged once it has
+// been added; the probe waits for the ack before sending the next one.
+func handleReportStream(a Adder) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		probeID := r.Header.Get(xfer.ScopeProbeIDHeader)
+		if probeID == "" {
+			respondWith(w, http.StatusBadRequest, xfer.ScopeProbeIDHeader)
+			return
+		}
+
+		conn, err := xfer.Upgrade(w, r, nil)
+		if err != nil {
+			log.Errorf("Error upgrading report stream websocket: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		stream := reportStream{adder: a}
+		for {
+			var header xfer.ReportStreamHeader
+			if err := conn.ReadJSON(&header); err != nil {
+				if !xfer.IsExpectedWSCloseError(err) {
+					log.Errorf("Error reading report stream from probe %s: %v", probeID, err)
+				}
+				return
+			}
+			messageType, buf, err := conn.ReadMessage()
+			if err != nil {
+				if !xfer.IsExpectedWSCloseError(err) {
+					log.Errorf("Error reading report stream from probe %s: %v", probeID, err)
+				}
+				return
+			}
+
+			ack := stream.add(ctx, header, messageType, buf)
+			if ack.Error != "" {
+				log.Errorf("Error adding streamed report from probe %s: %s", probeID, ack.Error)
+			}
+			if err := conn.WriteJSON(ack); err != nil {
+				log.Errorf("Error acknowledging report from probe %s: %v", probeID, err)
+				return
+			}
+		}
+	}
+}
+
+// reportStream holds the state of a single report stream: the last report
+// with a sequence number, against which deltas are applied.
+type reportStream struct {
+	adder   Adder
+	lastSeq uint64
+	last    report.Report
+}
+
+func (s *reportStream) add(ctx context.Context, header xfer.ReportStreamHeader, messageType int, buf []byte) xfer.ReportStreamAck {
+	ack := xfer.ReportStreamAck{Seq: header.Seq}
+	if messageType != websocket.BinaryMessage {
+		ack.Error = fmt.Sprintf("Unexpected message type: %d", messageType)
+		return ack
+	}
+
+	var rpt report.Report
+	if header.Base == 0 {
+		if err := rpt.ReadBinary(bytes.NewReader(buf), true, &codec.MsgpackHandle{}); err != nil {
+			ack.Error = err.Error()
+			return ack
+		}
+	} else {
+		if s.lastSeq == 0 || header.Base != s.lastSeq {
+			ack.Error = fmt.Sprintf("Missing base report %d for delta (have %d)", header.Base, s.lastSeq)
+			ack.Resync = true
+			return ack
+		}
+		var delta report.Delta
+		if err := delta.ReadBinary(bytes.NewReader(buf)); err != nil {
+			ack.Error = err.Error()
+			ack.Resync = true
+			return ack
+		}
+		rpt = delta.Apply(s.last)
+
+		// Adders which need the full report serialise it themselves
+		buf = nil
+	}
+
+	if header.Seq != 0 {
+		s.lastSeq, s.last = header.Seq, rpt
+	}
+	if err := s.adder.Add(ctx, rpt, buf); err != nil {
+		ack.Error = err.Error()
+	}
+	return ack
+}
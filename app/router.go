@@ -72,7 +72,7 @@ func matchURL(r *http.Request, pattern string) (map[string]string, bool) {
 
 	vars := map[string]string{}
 	for i, part := range parts {
-		unescaped, err := url.QueryUnescape(part)
+		unescaped, err := url.PathUnescape(part)
 		if err != nil {
 			return nil, false
 		}
@@ -105,6 +105,14 @@ func RegisterTopologyRoutes(router *mux.Router, r Reporter, capabilities map[str
 		HandleFunc("/api/topology/{topology}/ws",
 			requestContextDecorator(captureReporter(r, handleWebsocket))). // NB not gzip!
 		Name("api_topology_topology_ws")
+	get.
+		HandleFunc("/api/topology/{topology}/events",
+			requestContextDecorator(captureReporter(r, handleEvents))). // NB not gzip!
+		Name("api_topology_topology_events")
+	get.
+		HandleFunc("/api/topology/{topology}/poll",
+			gzipHandler(requestContextDecorator(captureReporter(r, handlePoll)))).
+		Name("api_topology_topology_poll")
 	get.
 		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}")).HandlerFunc(
 		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleNode)))).
@@ -115,8 +123,14 @@ func RegisterTopologyRoutes(router *mux.Router, r Reporter, capabilities map[str
 		gzipHandler(requestContextDecorator(makeProbeHandler(r))))
 }
 
-// RegisterReportPostHandler registers the handler for report submission
+// RegisterReportPostHandler registers the handlers for report submission,
+// both as individual POSTs and as a websocket stream.
 func RegisterReportPostHandler(a Adder, router *mux.Router) {
+	router.
+		Methods("GET").
+		Path("/api/report/ws").
+		HandlerFunc(requestContextDecorator(handleReportStream(a)))
+
 	post := router.Methods("POST").Subrouter()
 	post.HandleFunc("/api/report", requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		var (
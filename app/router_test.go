@@ -2,19 +2,24 @@ package app_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -37,6 +42,7 @@ func TestURLMatcher(t *testing.T) {
 	test("/{a}/b/c", "/b/b/b", false, v{})
 	test("/a/b/{c}", "/a/b/b", true, v{"c": "b"})
 	test("/a/b/{c}", "/a/b/b%2Fb", true, v{"c": "b/b"})
+	test("/a/b/{c}", "/a/b/b+c%20d", true, v{"c": "b+c d"})
 }
 
 func TestReportPostHandler(t *testing.T) {
@@ -94,3 +100,104 @@ func TestReportPostHandler(t *testing.T) {
 		return buf.Bytes(), err
 	})
 }
+
+func TestReportStreamHandler(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	headers := http.Header{}
+	headers.Set(xfer.ScopeProbeIDHeader, "probe")
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/report/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, headers)
+	if err != nil {
+		t.Fatalf("Error dialing report stream: %v", err)
+	}
+	defer conn.Close()
+
+	buf := &bytes.Buffer{}
+	fixture.Report.WriteBinary(buf, gzip.DefaultCompression)
+	if err := conn.WriteJSON(xfer.ReportStreamHeader{Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	var ack xfer.ReportStreamAck
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.Seq != 1 || ack.Error != "" {
+		t.Fatalf("Unexpected ack: %v", ack)
+	}
+
+	report, err := c.Report(context.Background(), time.Now())
+	if err != nil {
+		t.Error(err)
+	}
+	if want, have := fixture.Report.Endpoint.Nodes, report.Endpoint.Nodes; len(have) == 0 || len(want) != len(have) {
+		t.Fatal(test.Diff(have, want))
+	}
+}
+
+func TestReportStreamDeltas(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	headers := http.Header{}
+	headers.Set(xfer.ScopeProbeIDHeader, "probe")
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/report/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, headers)
+	if err != nil {
+		t.Fatalf("Error dialing report stream: %v", err)
+	}
+	defer conn.Close()
+
+	send := func(header xfer.ReportStreamHeader, buf *bytes.Buffer) xfer.ReportStreamAck {
+		if err := conn.WriteJSON(header); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		var ack xfer.ReportStreamAck
+		if err := conn.ReadJSON(&ack); err != nil {
+			t.Fatal(err)
+		}
+		return ack
+	}
+
+	base := report.MakeReport()
+	base.Host.AddNode(report.MakeNodeWith("host1", map[string]string{"name": "host1"}))
+	full := &bytes.Buffer{}
+	base.WriteBinary(full, gzip.DefaultCompression)
+	if ack := send(xfer.ReportStreamHeader{Seq: 1}, full); ack.Error != "" {
+		t.Fatalf("Unexpected ack: %v", ack)
+	}
+
+	rpt := base.Copy()
+	rpt.Host.AddNode(report.MakeNodeWith("host2", map[string]string{"name": "host2"}))
+	delta := &bytes.Buffer{}
+	report.MakeDelta(base, rpt).WriteBinary(delta, gzip.DefaultCompression)
+	if ack := send(xfer.ReportStreamHeader{Seq: 2, Base: 1}, delta); ack.Error != "" {
+		t.Fatalf("Unexpected ack: %v", ack)
+	}
+
+	// A delta against a report the app never saw needs a resync
+	if ack := send(xfer.ReportStreamHeader{Seq: 4, Base: 3}, delta); !ack.Resync {
+		t.Fatalf("Expected resync, got: %v", ack)
+	}
+
+	have, err := c.Report(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Host.Nodes["host2"]; !ok || len(have.Host.Nodes) != 2 {
+		t.Fatalf("Expected both hosts, got: %v", have.Host.Nodes)
+	}
+}
@@ -1,5 +1,7 @@
 package xfer
 
+import "regexp"
+
 const (
 	// AppPort is the default port that the app will use for its HTTP server.
 	// The app publishes the API and user interface, and receives reports from
@@ -14,10 +16,22 @@ const (
 	ScopeProbeVersionHeader = "X-Scope-Probe-Version"
 )
 
+// ProbeRoutes are the routes of the app's API used by probes: to publish
+// reports, and to open control and pipe websockets.
+var ProbeRoutes = regexp.MustCompile(`^/api/(report|report/ws|control/ws|pipe/[^/]+/probe)$`)
+
 // HistoricReportsCapability indicates whether reports older than the
 // current time (-app.window) can be retrieved.
 const HistoricReportsCapability = "historic_reports"
 
+// ReportStreamCapability indicates whether the app accepts reports over a
+// long-lived websocket on /api/report/ws, as well as by POSTing them.
+const ReportStreamCapability = "report_stream"
+
+// ReportDeltasCapability indicates whether the app accepts report deltas
+// over a report stream.
+const ReportDeltasCapability = "report_deltas"
+
 // Details are some generic details that can be fetched from /api
 type Details struct {
 	ID           string          `json:"id"`
@@ -0,0 +1,25 @@
+package xfer
+
+// ReportStreamHeader is the Probe -> App message which precedes each report
+// sent over a report stream. The report itself follows in a separate binary
+// message, as gzip'd msgpack.
+//
+// If Base is non-zero, the message is a report.Delta against the report with
+// sequence number Base, which must be the last one the app received over
+// this stream with a non-zero sequence number.
+type ReportStreamHeader struct {
+	Seq  uint64 `json:"seq"`
+	Base uint64 `json:"base,omitempty"`
+}
+
+// ReportStreamAck is the App -> Probe message acknowledging a report
+// received over a report stream. The probe doesn't send another report until
+// it has received the ack for the previous one, which provides backpressure.
+//
+// Resync is set when the app couldn't apply a delta, for instance because it
+// missed the base report; the probe should send a full report next.
+type ReportStreamAck struct {
+	Seq    uint64 `json:"seq"`
+	Error  string `json:"error,omitempty"`
+	Resync bool   `json:"resync,omitempty"`
+}
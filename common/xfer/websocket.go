@@ -3,6 +3,8 @@ package xfer
 import (
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -46,10 +48,24 @@ type pingingWebsocket struct {
 	conn      *websocket.Conn
 }
 
+// Origins are only checked for browsers authenticating with credentials they
+// send by themselves (see SameOrigin); pages of other sites can't send those
+// of other clients.
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// SameOrigin returns whether a request is made by a page of the site it is
+// made to, or by a client which isn't a browser and sends no Origin.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
 // Upgrade upgrades the HTTP server connection to the WebSocket protocol.
 func Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (Websocket, error) {
 	wsConn, err := upgrader.Upgrade(w, r, responseHeader)
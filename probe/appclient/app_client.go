@@ -23,8 +23,15 @@ const (
 	httpClientTimeout = 12 * time.Second // a bit less than default app.window
 	initialBackoff    = 1 * time.Second
 	maxBackoff        = 60 * time.Second
+	reportStreamID    = "report-stream"
 )
 
+// streamAckTimeout is how long to wait for the app to acknowledge a report
+// sent on the report stream, before reconnecting. Pongs keep the read
+// deadline of the stream alive, so an app which stops acking would otherwise
+// hang the publish loop.
+var streamAckTimeout = httpClientTimeout
+
 // AppClient is a client to an app, dealing with report publishing, controls and pipes.
 type AppClient interface {
 	Details() (xfer.Details, error)
@@ -32,22 +39,35 @@ type AppClient interface {
 	PipeConnection(string, xfer.Pipe)
 	PipeClose(string) error
 	Publish(io.Reader, bool) error
+	PublishStatus() PublishStatus
 	Target() url.URL
 	ReTarget(url.URL)
 	Stop()
 }
 
+// PublishStatus describes how publishing reports to an app is going.
+type PublishStatus struct {
+	App         string
+	Target      string
+	LastSuccess time.Time
+	LastError   time.Time
+	Error       string
+	Latency     time.Duration // of the most recent publish
+	Failures    uint64
+}
+
 // appClient is a client to an app, dealing with report publishing, controls and pipes.
 type appClient struct {
 	ProbeConfig
 
-	quit     chan struct{}
-	mtx      sync.Mutex
-	client   *http.Client
-	wsDialer websocket.Dialer
-	appID    string
-	hostname string
-	target   url.URL
+	quit         chan struct{}
+	mtx          sync.Mutex
+	client       *http.Client
+	wsDialer     websocket.Dialer
+	appID        string
+	capabilities map[string]bool
+	hostname     string
+	target       url.URL
 
 	// Track all the background goroutines, ensure they all stop
 	backgroundWait sync.WaitGroup
@@ -59,6 +79,13 @@ type appClient struct {
 	publishLoop sync.Once
 	readers     chan io.Reader
 
+	// Outcome of the most recent publishes, guarded by mtx
+	publishStatus PublishStatus
+
+	// For streaming reports; only used by the publish loop
+	stream      xfer.Websocket
+	streamAcked uint64 // sequence number of the last report acknowledged
+
 	// For controls
 	control xfer.ControlHandler
 }
@@ -195,7 +222,10 @@ func (c *appClient) Details() (xfer.Details, error) {
 	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&result); err != nil {
 		return result, err
 	}
+	c.mtx.Lock()
 	c.appID = result.ID
+	c.capabilities = result.Capabilities
+	c.mtx.Unlock()
 	return result, nil
 }
 
@@ -306,6 +336,118 @@ func (c *appClient) publish(r io.Reader) error {
 	return nil
 }
 
+// streamReports returns true if reports should be sent over a report stream
+// rather than POSTed individually. This is only done with apps which have
+// advertised the capability, so older apps keep working.
+func (c *appClient) streamReports() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.ReportStream && c.capabilities[xfer.ReportStreamCapability]
+}
+
+// streamDeltas returns true if report deltas can be sent over the report
+// stream.
+func (c *appClient) streamDeltas() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.ReportDeltas && c.capabilities[xfer.ReportDeltasCapability]
+}
+
+func (c *appClient) publishStream(r io.Reader) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if c.stream == nil {
+		headers := http.Header{}
+		c.ProbeConfig.authorizeHeaders(headers)
+		conn, _, err := xfer.DialWS(&c.wsDialer, c.wsURL("/api/report/ws"), headers)
+		if err != nil {
+			return err
+		}
+		// Will return false if we are exiting
+		if !c.registerConn(reportStreamID, conn) {
+			return nil
+		}
+		c.stream = conn
+		c.streamAcked = 0
+	}
+
+	// Send a delta if the app has acknowledged the report it is against,
+	// otherwise the full report.
+	full := buf
+	header := xfer.ReportStreamHeader{}
+	if payload, ok := r.(payloadReader); ok {
+		header.Seq = payload.seq
+		if payload.baseSeq != 0 && payload.baseSeq == c.streamAcked && c.streamDeltas() {
+			if delta, err := payload.serialisedDelta(); err != nil {
+				log.Warnf("Error computing report delta for %s: %v", c.hostname, err)
+			} else {
+				header.Base = payload.baseSeq
+				buf = delta
+			}
+		}
+	}
+
+	ack, err := c.sendOnStream(header, buf)
+	if err == nil && ack.Resync && header.Base != 0 {
+		// The app lost the base of the delta, so needs the full report now
+		log.Warnf("App %s requested a report resync: %s", c.hostname, ack.Error)
+		header.Base = 0
+		ack, err = c.sendOnStream(header, full)
+	}
+	if err != nil {
+		c.closeConn(reportStreamID)
+		c.stream = nil
+		return err
+	}
+	if ack.Error != "" {
+		c.streamAcked = 0
+		return fmt.Errorf("App rejected report: %s", ack.Error)
+	}
+	if header.Seq != 0 {
+		c.streamAcked = header.Seq
+	}
+	return nil
+}
+
+// sendOnStream sends a report on the report stream, and waits for the app
+// to acknowledge it.
+func (c *appClient) sendOnStream(header xfer.ReportStreamHeader, buf []byte) (xfer.ReportStreamAck, error) {
+	var ack xfer.ReportStreamAck
+	if err := c.stream.WriteJSON(header); err != nil {
+		return ack, err
+	}
+	if err := c.stream.WriteMessage(websocket.BinaryMessage, buf); err != nil {
+		return ack, err
+	}
+	type result struct {
+		ack xfer.ReportStreamAck
+		err error
+	}
+	acked := make(chan result, 1)
+	go func(stream xfer.Websocket) {
+		var r result
+		r.err = stream.ReadJSON(&r.ack)
+		acked <- r
+	}(c.stream)
+	select {
+	case r := <-acked:
+		if r.err != nil {
+			return ack, r.err
+		}
+		ack = r.ack
+	case <-time.After(streamAckTimeout):
+		// The caller closes the stream, which ends the read
+		return ack, fmt.Errorf("Timed out waiting for report stream ack after %v", streamAckTimeout)
+	}
+	if ack.Seq != header.Seq {
+		return ack, fmt.Errorf("Unexpected report stream ack: want %d, have %d", header.Seq, ack.Seq)
+	}
+	return ack, nil
+}
+
 func (c *appClient) startPublishing() {
 	go func() {
 		log.Infof("Publish loop for %s starting", c.hostname)
@@ -315,11 +457,48 @@ func (c *appClient) startPublishing() {
 			if r == nil {
 				return true, nil
 			}
-			return false, c.publish(r)
+			var (
+				t   = time.Now()
+				err error
+			)
+			if c.streamReports() {
+				err = c.publishStream(r)
+			} else {
+				err = c.publish(r)
+			}
+			c.recordPublish(t, err)
+			return false, err
 		})
 	}()
 }
 
+func (c *appClient) recordPublish(start time.Time, err error) {
+	now := time.Now()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.publishStatus.Latency = now.Sub(start)
+	if err != nil {
+		c.publishStatus.LastError = now
+		c.publishStatus.Error = err.Error()
+		c.publishStatus.Failures++
+	} else {
+		c.publishStatus.LastSuccess = now
+	}
+}
+
+// PublishStatus implements AppClient
+func (c *appClient) PublishStatus() PublishStatus {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	status := c.publishStatus
+	status.App = c.hostname
+	if c.appID != "" {
+		status.App = c.appID
+	}
+	status.Target = c.target.String()
+	return status
+}
+
 // Publish implements Publisher
 func (c *appClient) Publish(r io.Reader, shortcut bool) error {
 	// Lazily start the background publishing loop.
@@ -2,12 +2,15 @@ package appclient
 
 import (
 	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -225,3 +228,201 @@ func TestStop(t *testing.T) {
 	// Let the server go so that the test can end
 	close(stopHanging)
 }
+
+func TestAppClientPublishStream(t *testing.T) {
+	var (
+		rpt      = report.MakeReport()
+		received = make(chan xfer.ReportStreamHeader, 10)
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			codec.NewEncoder(w, &codec.JsonHandle{}).Encode(xfer.Details{
+				ID: "app",
+				Capabilities: map[string]bool{
+					xfer.ReportStreamCapability: true,
+					xfer.ReportDeltasCapability: true,
+				},
+			})
+			return
+		}
+		if r.URL.Path != "/api/report/ws" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		conn, err := xfer.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for {
+			var header xfer.ReportStreamHeader
+			if err := conn.ReadJSON(&header); err != nil {
+				return
+			}
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			received <- header
+			// Pretend to have lost the base of the third report
+			ack := xfer.ReportStreamAck{Seq: header.Seq, Resync: header.Seq == 3 && header.Base != 0}
+			if err := conn.WriteJSON(ack); err != nil {
+				return
+			}
+		}
+	})
+
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc := ProbeConfig{
+		Token:        "",
+		ProbeID:      "probe",
+		ReportStream: true,
+		ReportDeltas: true,
+	}
+	p, err := NewAppClient(pc, u.Host, *u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	if _, err := p.Details(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first report is sent in full, the following ones as deltas, but
+	// for the one whose delta the app asked to resync, which is sent again
+	// in full
+	rp := NewReportPublisher(p, false)
+	for _, wants := range [][]xfer.ReportStreamHeader{
+		{{Seq: 1}},
+		{{Seq: 2, Base: 1}},
+		{{Seq: 3, Base: 2}, {Seq: 3}},
+		{{Seq: 4, Base: 3}},
+	} {
+		if err := rp.Publish(rpt); err != nil {
+			t.Error(err)
+		}
+		for _, want := range wants {
+			select {
+			case have := <-received:
+				if want != have {
+					t.Errorf("want %v, have %v", want, have)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timeout")
+			}
+		}
+	}
+}
+
+func TestAppClientPublishStreamAckTimeout(t *testing.T) {
+	oldTimeout := streamAckTimeout
+	defer func() { streamAckTimeout = oldTimeout }()
+	streamAckTimeout = 100 * time.Millisecond
+
+	var (
+		connections = make(chan int, 10)
+		mtx         sync.Mutex
+		count       = 0
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			codec.NewEncoder(w, &codec.JsonHandle{}).Encode(xfer.Details{
+				ID:           "app",
+				Capabilities: map[string]bool{xfer.ReportStreamCapability: true},
+			})
+			return
+		}
+		conn, err := xfer.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		mtx.Lock()
+		count++
+		n := count
+		mtx.Unlock()
+		connections <- n
+		// The first connection never acks
+		for {
+			var header xfer.ReportStreamHeader
+			if err := conn.ReadJSON(&header); err != nil {
+				return
+			}
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			if n > 1 {
+				if err := conn.WriteJSON(xfer.ReportStreamAck{Seq: header.Seq}); err != nil {
+					return
+				}
+			}
+		}
+	})
+	s := httptest.NewServer(handler)
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewAppClient(ProbeConfig{ProbeID: "probe", ReportStream: true}, u.Host, *u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if _, err := p.Details(); err != nil {
+		t.Fatal(err)
+	}
+
+	rp := NewReportPublisher(p, false)
+	for i := 0; i < 2; i++ {
+		if err := rp.Publish(report.MakeReport()); err != nil {
+			t.Error(err)
+		}
+	}
+	for want := 1; want <= 2; want++ {
+		select {
+		case have := <-connections:
+			if have != want {
+				t.Errorf("want connection %d, have %d", want, have)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for connection %d", want)
+		}
+	}
+}
+
+func TestProbeConfigTLS(t *testing.T) {
+	cert := tls.Certificate{Certificate: [][]byte{[]byte("cert")}}
+	rootCAs := x509.NewCertPool()
+	pc := ProbeConfig{
+		TLSCertificates: []tls.Certificate{cert},
+		TLSRootCAs:      rootCAs,
+	}
+	config := pc.getHTTPTransport("app").TLSClientConfig
+	if config.RootCAs != rootCAs {
+		t.Errorf("Expected the configured root CAs")
+	}
+	if len(config.Certificates) != 1 || !reflect.DeepEqual(config.Certificates[0], cert) {
+		t.Errorf("Expected the client certificate, got %v", config.Certificates)
+	}
+	if config.ServerName != "app" {
+		t.Errorf("Expected server name app, got %q", config.ServerName)
+	}
+
+	// Well-known CAs are used by default
+	if config := (ProbeConfig{}).getHTTPTransport("app").TLSClientConfig; config.RootCAs != certPool {
+		t.Errorf("Expected the default root CAs")
+	}
+}
@@ -1,7 +1,6 @@
 package appclient
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -52,6 +51,7 @@ type MultiAppClient interface {
 	PipeClose(appID, pipeID string) error
 	Stop()
 	Publish(io.Reader, bool) error
+	PublishStatuses() []PublishStatus
 }
 
 // NewMultiAppClient creates a new MultiAppClient.
@@ -183,7 +183,7 @@ func (c *multiClient) Publish(r io.Reader, shortcut bool) error {
 
 	errs := []string{}
 	for _, c := range c.clients {
-		if err := c.Publish(bytes.NewReader(buf), shortcut); err != nil {
+		if err := c.Publish(rewind(r, buf), shortcut); err != nil {
 			errs = append(errs, err.Error())
 		}
 	}
@@ -193,6 +193,17 @@ func (c *multiClient) Publish(r io.Reader, shortcut bool) error {
 	return nil
 }
 
+// PublishStatuses returns how publishing to each of the apps is going.
+func (c *multiClient) PublishStatuses() []PublishStatus {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	result := make([]PublishStatus, 0, len(c.clients))
+	for _, c := range c.clients {
+		result = append(result, c.PublishStatus())
+	}
+	return result
+}
+
 type semaphore chan struct{}
 
 func newSemaphore(n int) semaphore {
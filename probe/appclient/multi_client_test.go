@@ -42,6 +42,10 @@ func (c *mockClient) Publish(io.Reader, bool) error {
 	return nil
 }
 
+func (c *mockClient) PublishStatus() appclient.PublishStatus {
+	return appclient.PublishStatus{App: c.id}
+}
+
 func (c *mockClient) PipeConnection(_ string, _ xfer.Pipe) {}
 func (c *mockClient) PipeClose(_ string) error             { return nil }
 
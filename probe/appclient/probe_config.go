@@ -35,6 +35,13 @@ type ProbeConfig struct {
 	ProbeVersion string
 	ProbeID      string
 	Insecure     bool
+	ReportStream bool // Stream reports over a websocket, if the app supports it
+	ReportDeltas bool // Send report deltas over the stream, if the app supports it
+
+	// Certificates presented to apps verifying the identity of probes
+	TLSCertificates []tls.Certificate
+	// CAs of the certificates of apps, instead of the well-known ones
+	TLSRootCAs *x509.CertPool
 }
 
 func (pc ProbeConfig) authorizeHeaders(headers http.Header) {
@@ -60,10 +67,15 @@ func (pc ProbeConfig) getHTTPTransport(hostname string) *http.Transport {
 	if pc.Insecure {
 		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	} else {
+		rootCAs := certPool
+		if pc.TLSRootCAs != nil {
+			rootCAs = pc.TLSRootCAs
+		}
 		transport.TLSClientConfig = &tls.Config{
-			RootCAs:    certPool,
+			RootCAs:    rootCAs,
 			ServerName: hostname,
 		}
 	}
+	transport.TLSClientConfig.Certificates = pc.TLSCertificates
 	return transport
 }
@@ -3,14 +3,26 @@ package appclient
 import (
 	"bytes"
 	"compress/gzip"
+	"io"
+	"sync"
+
 	"github.com/weaveworks/scope/report"
 )
 
+// Every fullReportInterval publishes, the ReportPublisher doesn't offer a
+// delta, forcing a full resync with apps which receive deltas.
+const fullReportInterval = 20
+
 // A ReportPublisher uses a buffer pool to serialise reports, which it
 // then passes to a publisher
 type ReportPublisher struct {
 	publisher  Publisher
 	noControls bool
+
+	seq       uint64
+	base      report.Report
+	baseSeq   uint64
+	sinceFull int
 }
 
 // NewReportPublisher creates a new report publisher
@@ -30,5 +42,70 @@ func (p *ReportPublisher) Publish(r report.Report) error {
 	}
 	buf := &bytes.Buffer{}
 	r.WriteBinary(buf, gzip.DefaultCompression)
-	return p.publisher.Publish(buf, r.Shortcut)
+
+	// Shortcut reports are partial, so they are neither sequenced nor
+	// used as the base for deltas.
+	if r.Shortcut {
+		return p.publisher.Publish(buf, r.Shortcut)
+	}
+
+	p.seq++
+	payload := &reportPayload{
+		full: buf.Bytes(),
+		seq:  p.seq,
+		rpt:  r,
+	}
+	if p.baseSeq != 0 && p.sinceFull < fullReportInterval {
+		payload.base, payload.baseSeq = p.base, p.baseSeq
+		p.sinceFull++
+	} else {
+		p.sinceFull = 0
+	}
+	p.base, p.baseSeq = r, p.seq
+	return p.publisher.Publish(payload.reader(), r.Shortcut)
+}
+
+// reportPayload is a serialised report, along with what is needed to
+// compute its delta against the previously published report. The delta is
+// only computed on demand, by publishers which can send it.
+type reportPayload struct {
+	full    []byte
+	seq     uint64
+	rpt     report.Report
+	base    report.Report
+	baseSeq uint64 // zero if there is no delta on offer
+
+	deltaOnce sync.Once
+	delta     []byte
+	deltaErr  error
+}
+
+// payloadReader reads the full serialised report from a reportPayload.
+type payloadReader struct {
+	*bytes.Reader
+	*reportPayload
+}
+
+func (p *reportPayload) reader() io.Reader {
+	return payloadReader{bytes.NewReader(p.full), p}
+}
+
+// serialisedDelta returns the delta against the report with sequence number
+// baseSeq, as gzip'd msgpack.
+func (p *reportPayload) serialisedDelta() ([]byte, error) {
+	p.deltaOnce.Do(func() {
+		buf := &bytes.Buffer{}
+		p.deltaErr = report.MakeDelta(p.base, p.rpt).WriteBinary(buf, gzip.DefaultCompression)
+		p.delta = buf.Bytes()
+	})
+	return p.delta, p.deltaErr
+}
+
+// rewind returns a fresh reader over buf, the contents of r. If r came from
+// a ReportPublisher, the new reader still offers its delta.
+func rewind(r io.Reader, buf []byte) io.Reader {
+	if pr, ok := r.(payloadReader); ok {
+		return pr.reportPayload.reader()
+	}
+	return bytes.NewReader(buf)
 }
@@ -1,7 +1,10 @@
 package awsecs
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"strings"
 	"sync"
 	"time"
@@ -9,12 +12,22 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/bluele/gcache"
 )
 
-const servicePrefix = "ecs-svc" // Task StartedBy field begins with this if it was started by a service
+const (
+	servicePrefix = "ecs-svc" // Task StartedBy field begins with this if it was started by a service
+
+	// ECS only keeps the last 100 events of a service, we only report the most recent ones
+	maxServiceEvents = 5
+
+	// Launch types of tasks
+	LaunchTypeEC2     = "EC2"
+	LaunchTypeFargate = "FARGATE"
+)
 
 // EcsClient is a wrapper around an AWS client that makes all the needed calls and just exposes the final results.
 // We create an interface so we can mock for testing.
@@ -27,10 +40,12 @@ type EcsClient interface {
 
 // actual implementation
 type ecsClientImpl struct {
-	client       *ecs.ECS
-	cluster      string
-	taskCache    gcache.Cache // Keys are task ARNs.
-	serviceCache gcache.Cache // Keys are service names.
+	client              *ecs.ECS
+	cluster             string
+	taskCache           gcache.Cache // Keys are task ARNs.
+	serviceCache        gcache.Cache // Keys are service names.
+	taskDefinitionCache gcache.Cache // Keys are task definition ARNs.
+	instanceCache       gcache.Cache // Keys are container instance ARNs.
 }
 
 // EcsTask describes the parts of ECS tasks we care about.
@@ -46,6 +61,34 @@ type EcsTask struct {
 	// which we know it is because otherwise we wouldn't be looking at it.
 	StartedAt time.Time
 	StartedBy string // tag or deployment id
+
+	// Tasks are placed before they start, and never move.
+	ContainerInstanceARN string
+	LaunchType           string
+}
+
+// EcsTaskDefinition describes the parts of ECS task definitions we care about.
+// Task definitions are immutable, so we cache them like tasks.
+// Exported for test.
+type EcsTaskDefinition struct {
+	TaskDefinitionARN string
+	Family            string
+	Revision          int64
+	// Summed over the containers of the task definition
+	CPU    int64 // CPU units
+	Memory int64 // MiB
+}
+
+// EcsContainerInstance describes the parts of ECS container instances we care about.
+// Container instances are mutable, so we describe the referenced ones each report.
+// Exported for test.
+type EcsContainerInstance struct {
+	ContainerInstanceARN string
+	EC2InstanceID        string
+	Status               string
+	AgentConnected       bool
+	RunningTasksCount    int64
+	PendingTasksCount    int64
 }
 
 // EcsService describes the parts of ECS services we care about.
@@ -61,13 +104,39 @@ type EcsService struct {
 	PendingCount      int64
 	RunningCount      int64
 	TaskDefinitionARN string
+	Deployments       []EcsDeployment
+	Events            []EcsServiceEvent // most recent first
+}
+
+// EcsDeployment describes a deployment of an ECS service.
+// Exported for test.
+type EcsDeployment struct {
+	ID                string
+	Status            string // PRIMARY, ACTIVE or INACTIVE
+	TaskDefinitionARN string
+	DesiredCount      int64
+	PendingCount      int64
+	RunningCount      int64
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// EcsServiceEvent is an event of an ECS service, e.g. a task being started
+// or failing to be placed.
+// Exported for test.
+type EcsServiceEvent struct {
+	ID        string
+	CreatedAt time.Time
+	Message   string
 }
 
 // EcsInfo is exported for test
 type EcsInfo struct {
-	Tasks          map[string]EcsTask
-	Services       map[string]EcsService
-	TaskServiceMap map[string]string
+	Tasks              map[string]EcsTask
+	Services           map[string]EcsService
+	TaskServiceMap     map[string]string
+	TaskDefinitions    map[string]EcsTaskDefinition    // Keys are task definition ARNs.
+	ContainerInstances map[string]EcsContainerInstance // Keys are container instance ARNs.
 }
 
 func newClient(cluster string, cacheSize int, cacheExpiry time.Duration, clusterRegion string) (EcsClient, error) {
@@ -82,10 +151,12 @@ func newClient(cluster string, cacheSize int, cacheExpiry time.Duration, cluster
 	}
 
 	return &ecsClientImpl{
-		client:       ecs.New(sess, &aws.Config{Region: aws.String(clusterRegion)}),
-		cluster:      cluster,
-		taskCache:    gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
-		serviceCache: gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
+		client:              ecs.New(sess, &aws.Config{Region: aws.String(clusterRegion)}),
+		cluster:             cluster,
+		taskCache:           gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
+		serviceCache:        gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
+		taskDefinitionCache: gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
+		instanceCache:       gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
 	}, nil
 }
 
@@ -112,20 +183,83 @@ func int64OrZero(i *int64) int64 {
 	return *i
 }
 
-func newECSTask(task *ecs.Task) EcsTask {
+func boolOrFalse(b *bool) bool {
+	if b == nil {
+		return false
+	}
+	return *b
+}
+
+func newECSTask(task *ecs.Task, launchType string) EcsTask {
 	return EcsTask{
-		TaskARN:           stringOrBlank(task.TaskArn),
-		CreatedAt:         timeOrZero(task.CreatedAt),
-		TaskDefinitionARN: stringOrBlank(task.TaskDefinitionArn),
-		StartedAt:         timeOrZero(task.StartedAt),
-		StartedBy:         stringOrBlank(task.StartedBy),
+		TaskARN:              stringOrBlank(task.TaskArn),
+		CreatedAt:            timeOrZero(task.CreatedAt),
+		TaskDefinitionARN:    stringOrBlank(task.TaskDefinitionArn),
+		StartedAt:            timeOrZero(task.StartedAt),
+		StartedBy:            stringOrBlank(task.StartedBy),
+		ContainerInstanceARN: stringOrBlank(task.ContainerInstanceArn),
+		LaunchType:           launchType,
+	}
+}
+
+func newECSTaskDefinition(taskDefinition *ecs.TaskDefinition) EcsTaskDefinition {
+	result := EcsTaskDefinition{
+		TaskDefinitionARN: stringOrBlank(taskDefinition.TaskDefinitionArn),
+		Family:            stringOrBlank(taskDefinition.Family),
+		Revision:          int64OrZero(taskDefinition.Revision),
+	}
+	for _, container := range taskDefinition.ContainerDefinitions {
+		if container != nil {
+			result.CPU += int64OrZero(container.Cpu)
+			result.Memory += int64OrZero(container.Memory)
+		}
+	}
+	return result
+}
+
+func newECSContainerInstance(instance *ecs.ContainerInstance) EcsContainerInstance {
+	return EcsContainerInstance{
+		ContainerInstanceARN: stringOrBlank(instance.ContainerInstanceArn),
+		EC2InstanceID:        stringOrBlank(instance.Ec2InstanceId),
+		Status:               stringOrBlank(instance.Status),
+		AgentConnected:       boolOrFalse(instance.AgentConnected),
+		RunningTasksCount:    int64OrZero(instance.RunningTasksCount),
+		PendingTasksCount:    int64OrZero(instance.PendingTasksCount),
 	}
 }
 
 func newECSService(service *ecs.Service) EcsService {
-	deploymentIDs := make([]string, len(service.Deployments))
-	for i, deployment := range service.Deployments {
-		deploymentIDs[i] = stringOrBlank(deployment.Id)
+	deploymentIDs := make([]string, 0, len(service.Deployments))
+	deployments := make([]EcsDeployment, 0, len(service.Deployments))
+	for _, deployment := range service.Deployments {
+		if deployment == nil {
+			continue
+		}
+		deploymentIDs = append(deploymentIDs, stringOrBlank(deployment.Id))
+		deployments = append(deployments, EcsDeployment{
+			ID:                stringOrBlank(deployment.Id),
+			Status:            stringOrBlank(deployment.Status),
+			TaskDefinitionARN: stringOrBlank(deployment.TaskDefinition),
+			DesiredCount:      int64OrZero(deployment.DesiredCount),
+			PendingCount:      int64OrZero(deployment.PendingCount),
+			RunningCount:      int64OrZero(deployment.RunningCount),
+			CreatedAt:         timeOrZero(deployment.CreatedAt),
+			UpdatedAt:         timeOrZero(deployment.UpdatedAt),
+		})
+	}
+	events := []EcsServiceEvent{}
+	for _, event := range service.Events {
+		if len(events) == maxServiceEvents {
+			break
+		}
+		if event == nil {
+			continue
+		}
+		events = append(events, EcsServiceEvent{
+			ID:        stringOrBlank(event.Id),
+			CreatedAt: timeOrZero(event.CreatedAt),
+			Message:   stringOrBlank(event.Message),
+		})
 	}
 	return EcsService{
 		ServiceName:       stringOrBlank(service.ServiceName),
@@ -134,6 +268,8 @@ func newECSService(service *ecs.Service) EcsService {
 		PendingCount:      int64OrZero(service.PendingCount),
 		RunningCount:      int64OrZero(service.RunningCount),
 		TaskDefinitionARN: stringOrBlank(service.TaskDefinition),
+		Deployments:       deployments,
+		Events:            events,
 	}
 }
 
@@ -158,6 +294,14 @@ func (c ecsClientImpl) getCachedService(serviceName string) (EcsService, bool) {
 	return EcsService{}, false
 }
 
+// Fetches a task definition from the cache, returning (task definition, ok) as per map[]
+func (c ecsClientImpl) getCachedTaskDefinition(taskDefinitionARN string) (EcsTaskDefinition, bool) {
+	if taskDefinitionRaw, err := c.taskDefinitionCache.Get(taskDefinitionARN); err == nil {
+		return taskDefinitionRaw.(EcsTaskDefinition), true
+	}
+	return EcsTaskDefinition{}, false
+}
+
 // Returns a list of service names.
 // Cannot fail as it will attempt to deliver partial results, though that may end up being no results.
 func (c ecsClientImpl) listServices() []string {
@@ -252,7 +396,7 @@ func (c ecsClientImpl) getTasks(taskARNs []string) {
 
 	// You'd think there's a limit on how many tasks can be described here,
 	// but the docs don't mention anything.
-	resp, err := c.client.DescribeTasks(&ecs.DescribeTasksInput{
+	resp, launchTypes, err := c.describeTasks(&ecs.DescribeTasksInput{
 		Cluster: &c.cluster,
 		Tasks:   taskPtrs,
 	})
@@ -267,11 +411,44 @@ func (c ecsClientImpl) getTasks(taskARNs []string) {
 
 	for _, task := range resp.Tasks {
 		if task != nil && task.TaskArn != nil {
-			c.taskCache.Set(*task.TaskArn, newECSTask(task))
+			c.taskCache.Set(*task.TaskArn, newECSTask(task, launchTypes[*task.TaskArn]))
 		}
 	}
 }
 
+// describeTasks describes tasks, along with their launch types by task ARN.
+// The version of the API we use predates the launchType field, so it is
+// decoded from the response before the API unmarshals it. Tasks without a
+// launch type are left out.
+func (c ecsClientImpl) describeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, map[string]string, error) {
+	req, resp := c.client.DescribeTasksRequest(input)
+	launchTypes := map[string]string{}
+	req.Handlers.Unmarshal.PushFront(func(r *request.Request) {
+		body, err := ioutil.ReadAll(r.HTTPResponse.Body)
+		r.HTTPResponse.Body.Close()
+		r.HTTPResponse.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		var output struct {
+			Tasks []struct {
+				TaskArn    string `json:"taskArn"`
+				LaunchType string `json:"launchType"`
+			} `json:"tasks"`
+		}
+		if err := json.Unmarshal(body, &output); err != nil {
+			return
+		}
+		for _, task := range output.Tasks {
+			if task.LaunchType != "" {
+				launchTypes[task.TaskArn] = task.LaunchType
+			}
+		}
+	})
+	err := req.Send()
+	return resp, launchTypes, err
+}
+
 // Try to match a list of task ARNs to service names using cached info.
 // Returns (task to service map, unmatched tasks). Ignores tasks whose startedby values
 // don't appear to point to a service.
@@ -325,6 +502,91 @@ func (c ecsClientImpl) ensureTasksAreCached(taskARNs []string) {
 	}
 }
 
+// Task definitions can only be described one at a time, but they're immutable
+// so we only ever describe each of them once.
+func (c ecsClientImpl) ensureTaskDefinitionsAreCached(taskARNs []string) {
+	toDescribe := map[string]struct{}{}
+	for _, taskARN := range taskARNs {
+		task, ok := c.getCachedTask(taskARN)
+		if !ok || task.TaskDefinitionARN == "" {
+			continue
+		}
+		if _, err := c.taskDefinitionCache.Get(task.TaskDefinitionARN); err != nil {
+			toDescribe[task.TaskDefinitionARN] = struct{}{}
+		}
+	}
+
+	group := sync.WaitGroup{}
+	for taskDefinitionARN := range toDescribe {
+		group.Add(1)
+		go func(arn string) {
+			defer group.Done()
+			resp, err := c.client.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+				TaskDefinition: &arn,
+			})
+			if err != nil {
+				log.Warnf("Failed to describe ECS task definition %s, ECS task report may be incomplete: %v", arn, err)
+				return
+			}
+			if resp.TaskDefinition != nil {
+				c.taskDefinitionCache.Set(arn, newECSTaskDefinition(resp.TaskDefinition))
+			}
+		}(taskDefinitionARN)
+	}
+	group.Wait()
+}
+
+// Returns the container instances the given tasks are placed on, describing
+// those which aren't cached.
+// Cannot fail as it will attempt to deliver partial results.
+func (c ecsClientImpl) describeContainerInstances(taskARNs []string) map[string]EcsContainerInstance {
+	const maxContainerInstances = 100 // How many container instances we can put in one Describe command
+	results := map[string]EcsContainerInstance{}
+
+	seen := map[string]bool{}
+	arns := []*string{}
+	for _, taskARN := range taskARNs {
+		task, ok := c.getCachedTask(taskARN)
+		if !ok || task.ContainerInstanceARN == "" || seen[task.ContainerInstanceARN] {
+			continue
+		}
+		seen[task.ContainerInstanceARN] = true
+		if instanceRaw, err := c.instanceCache.Get(task.ContainerInstanceARN); err == nil {
+			results[task.ContainerInstanceARN] = instanceRaw.(EcsContainerInstance)
+			continue
+		}
+		arn := task.ContainerInstanceARN
+		arns = append(arns, &arn)
+	}
+
+	for len(arns) > 0 {
+		batch := arns
+		if len(batch) > maxContainerInstances {
+			batch = batch[:maxContainerInstances]
+		}
+		arns = arns[len(batch):]
+
+		resp, err := c.client.DescribeContainerInstances(&ecs.DescribeContainerInstancesInput{
+			Cluster:            &c.cluster,
+			ContainerInstances: batch,
+		})
+		if err != nil {
+			log.Warnf("Failed to describe ECS container instances, ECS task report may be incomplete: %v", err)
+			continue
+		}
+		for _, failure := range resp.Failures {
+			log.Warnf("Failed to describe ECS container instance %s, ECS task report may be incomplete: %s", stringOrBlank(failure.Arn), stringOrBlank(failure.Reason))
+		}
+		for _, instance := range resp.ContainerInstances {
+			if instance != nil && instance.ContainerInstanceArn != nil {
+				results[*instance.ContainerInstanceArn] = newECSContainerInstance(instance)
+				c.instanceCache.Set(*instance.ContainerInstanceArn, results[*instance.ContainerInstanceArn])
+			}
+		}
+	}
+	return results
+}
+
 func (c ecsClientImpl) refreshServices(taskServiceMap map[string]string) map[string]bool {
 	servicesRefreshed := map[string]bool{}
 	toDescribe := []string{}
@@ -375,7 +637,20 @@ func (c ecsClientImpl) makeECSInfo(taskARNs []string, taskServiceMap map[string]
 		}
 	}
 
-	return EcsInfo{Services: services, Tasks: tasks, TaskServiceMap: taskServiceMap}
+	taskDefinitions := map[string]EcsTaskDefinition{}
+	for _, task := range tasks {
+		if taskDefinition, ok := c.getCachedTaskDefinition(task.TaskDefinitionARN); ok {
+			taskDefinitions[task.TaskDefinitionARN] = taskDefinition
+		}
+	}
+
+	return EcsInfo{
+		Services:           services,
+		Tasks:              tasks,
+		TaskServiceMap:     taskServiceMap,
+		TaskDefinitions:    taskDefinitions,
+		ContainerInstances: c.describeContainerInstances(taskARNs),
+	}
 }
 
 // Implements EcsClient.GetInfo
@@ -386,6 +661,7 @@ func (c ecsClientImpl) GetInfo(taskARNs []string) EcsInfo {
 	// First, we ensure we have all the tasks we need, and fetch the ones we don't.
 	// We also mark the tasks as being used here to prevent eviction.
 	c.ensureTasksAreCached(taskARNs)
+	c.ensureTaskDefinitionsAreCached(taskARNs)
 
 	// We're going to do this matching process potentially several times, but that's ok - it's quite cheap.
 	// First, we want to see how far we get with existing data, and identify the set of services
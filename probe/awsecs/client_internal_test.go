@@ -0,0 +1,58 @@
+package awsecs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/bluele/gcache"
+)
+
+func TestGetTasksLaunchTypes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".DescribeTasks") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		fmt.Fprint(w, `{"failures":[],"tasks":[
+			{"taskArn":"fargate","launchType":"FARGATE"},
+			{"taskArn":"ec2","launchType":"EC2","containerInstanceArn":"instance"},
+			{"taskArn":"unknown"}
+		]}`)
+	}))
+	defer ts.Close()
+
+	c := ecsClientImpl{
+		client: ecs.New(session.New(), &aws.Config{
+			Endpoint:    aws.String(ts.URL),
+			Region:      aws.String("us-east-1"),
+			Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+			DisableSSL:  aws.Bool(true),
+		}),
+		cluster:   "cluster",
+		taskCache: gcache.New(10).LRU().Expiration(time.Minute).Build(),
+	}
+	c.getTasks([]string{"fargate", "ec2", "unknown"})
+
+	for arn, want := range map[string]string{"fargate": LaunchTypeFargate, "ec2": LaunchTypeEC2, "unknown": ""} {
+		task, ok := c.getCachedTask(arn)
+		if !ok {
+			t.Errorf("Task %s not cached", arn)
+			continue
+		}
+		if task.LaunchType != want {
+			t.Errorf("Expected launch type %q for task %s, got %q", want, arn, task.LaunchType)
+		}
+	}
+	if task, _ := c.getCachedTask("ec2"); task.ContainerInstanceARN != "instance" {
+		t.Errorf("Unexpected container instance: %q", task.ContainerInstanceARN)
+	}
+}
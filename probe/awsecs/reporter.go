@@ -2,6 +2,7 @@ package awsecs
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -20,22 +21,159 @@ const (
 	ServiceRunningCount = report.ECSServiceRunningCount
 	ScaleUp             = report.ECSScaleUp
 	ScaleDown           = report.ECSScaleDown
+
+	TaskDefinition                  = report.ECSTaskDefinition
+	TaskCPU                         = report.ECSTaskCPU
+	TaskMemory                      = report.ECSTaskMemory
+	LaunchType                      = report.ECSLaunchType
+	ContainerInstanceEC2ID          = report.ECSContainerInstanceEC2ID
+	ContainerInstanceStatus         = report.ECSContainerInstanceStatus
+	ContainerInstanceAgentConnected = report.ECSContainerInstanceAgentConnected
+	ContainerInstanceRunningTasks   = report.ECSContainerInstanceRunningTasks
+	ContainerInstancePendingTasks   = report.ECSContainerInstancePendingTasks
+	ServicePendingCount             = report.ECSServicePendingCount
+
+	ContainerInstanceTableID = "ecs_container_instance_table"
+	DeploymentsTablePrefix   = "ecs_deployments_table_"
+	EventsTablePrefix        = "ecs_events_table_"
+
+	DeploymentStatus         = "ecs_deployment_status"
+	DeploymentTaskDefinition = "ecs_deployment_task_definition"
+	DeploymentDesiredCount   = "ecs_deployment_desired_count"
+	DeploymentPendingCount   = "ecs_deployment_pending_count"
+	DeploymentRunningCount   = "ecs_deployment_running_count"
+	DeploymentUpdatedAt      = "ecs_deployment_updated_at"
+	EventCreatedAt           = "ecs_event_created_at"
+	EventMessage             = "ecs_event_message"
 )
 
 var (
 	taskMetadata = report.MetadataTemplates{
-		Cluster:    {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 0},
-		CreatedAt:  {ID: CreatedAt, Label: "Created At", From: report.FromLatest, Priority: 1, Datatype: report.DateTime},
-		TaskFamily: {ID: TaskFamily, Label: "Family", From: report.FromLatest, Priority: 2},
+		Cluster:        {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 0},
+		CreatedAt:      {ID: CreatedAt, Label: "Created At", From: report.FromLatest, Priority: 1, Datatype: report.DateTime},
+		TaskFamily:     {ID: TaskFamily, Label: "Family", From: report.FromLatest, Priority: 2},
+		TaskDefinition: {ID: TaskDefinition, Label: "Task Definition", From: report.FromLatest, Priority: 3},
+		LaunchType:     {ID: LaunchType, Label: "Launch Type", From: report.FromLatest, Priority: 4},
+		TaskCPU:        {ID: TaskCPU, Label: "CPU Units", From: report.FromLatest, Priority: 5, Datatype: report.Number},
+		TaskMemory:     {ID: TaskMemory, Label: "Memory (MiB)", From: report.FromLatest, Priority: 6, Datatype: report.Number},
 	}
 	serviceMetadata = report.MetadataTemplates{
 		Cluster:             {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 0},
 		CreatedAt:           {ID: CreatedAt, Label: "Created At", From: report.FromLatest, Priority: 1, Datatype: report.DateTime},
 		ServiceDesiredCount: {ID: ServiceDesiredCount, Label: "Desired Tasks", From: report.FromLatest, Priority: 2, Datatype: report.Number},
 		ServiceRunningCount: {ID: ServiceRunningCount, Label: "Running Tasks", From: report.FromLatest, Priority: 3, Datatype: report.Number},
+		ServicePendingCount: {ID: ServicePendingCount, Label: "Pending Tasks", From: report.FromLatest, Priority: 4, Datatype: report.Number},
+		TaskDefinition:      {ID: TaskDefinition, Label: "Task Definition", From: report.FromLatest, Priority: 5},
+	}
+
+	taskTableTemplates = report.TableTemplates{
+		ContainerInstanceTableID: {
+			ID:    ContainerInstanceTableID,
+			Label: "Container Instance",
+			Type:  report.PropertyListType,
+			FixedRows: map[string]string{
+				ContainerInstanceEC2ID:          "EC2 Instance",
+				ContainerInstanceStatus:         "Status",
+				ContainerInstanceAgentConnected: "Agent Connected",
+				ContainerInstanceRunningTasks:   "Running Tasks",
+				ContainerInstancePendingTasks:   "Pending Tasks",
+			},
+		},
+	}
+	serviceTableTemplates = report.TableTemplates{
+		DeploymentsTablePrefix: {
+			ID:     DeploymentsTablePrefix,
+			Label:  "Deployments",
+			Type:   report.MulticolumnTableType,
+			Prefix: DeploymentsTablePrefix,
+			Columns: []report.Column{
+				{ID: DeploymentStatus, Label: "Status"},
+				{ID: DeploymentTaskDefinition, Label: "Task Definition"},
+				{ID: DeploymentDesiredCount, Label: "Desired", DataType: report.Number},
+				{ID: DeploymentPendingCount, Label: "Pending", DataType: report.Number},
+				{ID: DeploymentRunningCount, Label: "Running", DataType: report.Number},
+				{ID: DeploymentUpdatedAt, Label: "Updated At", DataType: report.DateTime},
+			},
+		},
+		EventsTablePrefix: {
+			ID:     EventsTablePrefix,
+			Label:  "Events",
+			Type:   report.MulticolumnTableType,
+			Prefix: EventsTablePrefix,
+			Columns: []report.Column{
+				{ID: EventCreatedAt, Label: "Time", DataType: report.DateTime},
+				{ID: EventMessage, Label: "Message"},
+			},
+		},
 	}
 )
 
+// taskDefinitionName turns the ARN of a task definition into the family:revision
+// form used by the ECS console.
+func taskDefinitionName(arn string) string {
+	if i := strings.LastIndex(arn, "/"); i >= 0 {
+		return arn[i+1:]
+	}
+	return arn
+}
+
+// Rows of multicolumn tables are sorted by ID, and ECS lists deployments and
+// events in a meaningful order, so we number them.
+func rowID(i int) string {
+	return fmt.Sprintf("%03d", i)
+}
+
+func serviceTables(node report.Node, service EcsService) report.Node {
+	deployments := make([]report.Row, 0, len(service.Deployments))
+	for i, deployment := range service.Deployments {
+		deployments = append(deployments, report.Row{
+			ID: rowID(i),
+			Entries: map[string]string{
+				DeploymentStatus:         deployment.Status,
+				DeploymentTaskDefinition: taskDefinitionName(deployment.TaskDefinitionARN),
+				DeploymentDesiredCount:   fmt.Sprintf("%d", deployment.DesiredCount),
+				DeploymentPendingCount:   fmt.Sprintf("%d", deployment.PendingCount),
+				DeploymentRunningCount:   fmt.Sprintf("%d", deployment.RunningCount),
+				DeploymentUpdatedAt:      deployment.UpdatedAt.Format(time.RFC3339Nano),
+			},
+		})
+	}
+	events := make([]report.Row, 0, len(service.Events))
+	for i, event := range service.Events {
+		events = append(events, report.Row{
+			ID: rowID(i),
+			Entries: map[string]string{
+				EventCreatedAt: event.CreatedAt.Format(time.RFC3339Nano),
+				EventMessage:   event.Message,
+			},
+		})
+	}
+	return node.
+		AddPrefixMulticolumnTable(DeploymentsTablePrefix, deployments).
+		AddPrefixMulticolumnTable(EventsTablePrefix, events)
+}
+
+func taskDetails(task EcsTask, info EcsInfo) map[string]string {
+	details := map[string]string{
+		TaskDefinition: taskDefinitionName(task.TaskDefinitionARN),
+	}
+	if task.LaunchType != "" {
+		details[LaunchType] = task.LaunchType
+	}
+	if taskDefinition, ok := info.TaskDefinitions[task.TaskDefinitionARN]; ok {
+		details[TaskCPU] = fmt.Sprintf("%d", taskDefinition.CPU)
+		details[TaskMemory] = fmt.Sprintf("%d", taskDefinition.Memory)
+	}
+	if instance, ok := info.ContainerInstances[task.ContainerInstanceARN]; ok {
+		details[ContainerInstanceEC2ID] = instance.EC2InstanceID
+		details[ContainerInstanceStatus] = instance.Status
+		details[ContainerInstanceAgentConnected] = fmt.Sprintf("%t", instance.AgentConnected)
+		details[ContainerInstanceRunningTasks] = fmt.Sprintf("%d", instance.RunningTasksCount)
+		details[ContainerInstancePendingTasks] = fmt.Sprintf("%d", instance.PendingTasksCount)
+	}
+	return details
+}
+
 // TaskLabelInfo is used in return value of GetLabelInfo. Exported for test.
 type TaskLabelInfo struct {
 	ContainerIDs []string
@@ -150,17 +288,20 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 		// Create all the services first
 		for serviceName, service := range ecsInfo.Services {
 			serviceID := report.MakeECSServiceNodeID(cluster, serviceName)
-			rpt.ECSService.AddNode(report.MakeNodeWith(serviceID, map[string]string{
+			node := report.MakeNodeWith(serviceID, map[string]string{
 				Cluster:               cluster,
 				ServiceDesiredCount:   fmt.Sprintf("%d", service.DesiredCount),
 				ServiceRunningCount:   fmt.Sprintf("%d", service.RunningCount),
+				ServicePendingCount:   fmt.Sprintf("%d", service.PendingCount),
+				TaskDefinition:        taskDefinitionName(service.TaskDefinitionARN),
 				report.ControlProbeID: r.probeID,
 			}).WithLatestControls(map[string]report.NodeControlData{
 				ScaleUp: {Dead: false},
 				// We've decided for now to disable ScaleDown when only 1 task is desired,
 				// since scaling down to 0 would cause the service to disappear (#2085)
 				ScaleDown: {Dead: service.DesiredCount <= 1},
-			}))
+			})
+			rpt.ECSService.AddNode(serviceTables(node, service))
 		}
 		log.Debugf("Created %v ECS service nodes", len(ecsInfo.Services))
 
@@ -177,7 +318,7 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 				TaskFamily: info.Family,
 				Cluster:    cluster,
 				CreatedAt:  task.CreatedAt.Format(time.RFC3339Nano),
-			})
+			}).WithLatests(taskDetails(task, ecsInfo))
 			rpt.ECSTask.AddNode(node)
 
 			// parents sets to merge into all matching container nodes
@@ -206,9 +347,13 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 // Report needed for Reporter
 func (Reporter) Report() (report.Report, error) {
 	result := report.MakeReport()
-	taskTopology := report.MakeTopology().WithMetadataTemplates(taskMetadata)
+	taskTopology := report.MakeTopology().
+		WithMetadataTemplates(taskMetadata).
+		WithTableTemplates(taskTableTemplates)
 	result.ECSTask = result.ECSTask.Merge(taskTopology)
-	serviceTopology := report.MakeTopology().WithMetadataTemplates(serviceMetadata)
+	serviceTopology := report.MakeTopology().
+		WithMetadataTemplates(serviceMetadata).
+		WithTableTemplates(serviceTableTemplates)
 	serviceTopology.Controls.AddControls([]report.Control{
 		{
 			ID:    ScaleDown,
@@ -16,7 +16,9 @@ var (
 	testFamily            = "test-family"
 	testTaskARN           = "arn:aws:ecs:us-east-1:123456789012:task/12345678-9abc-def0-1234-56789abcdef0"
 	testTaskCreatedAt     = time.Unix(1483228800, 0)
-	testTaskDefinitionARN = "arn:aws:ecs:us-east-1:123456789012:task-definition/deadbeef-dead-beef-dead-beefdeadbeef"
+	testTaskDefinitionARN = "arn:aws:ecs:us-east-1:123456789012:task-definition/test-family:3"
+	testInstanceARN       = "arn:aws:ecs:us-east-1:123456789012:container-instance/12345678-9abc-def0-1234-56789abcdef1"
+	testEC2InstanceID     = "i-0123456789abcdef0"
 	testTaskStartedAt     = time.Unix(1483228805, 0)
 	testDeploymentID      = "ecs-svc/1121123211234321"
 	testServiceName       = "test-service"
@@ -100,11 +102,13 @@ func TestTagReport(t *testing.T) {
 		awsecs.EcsInfo{
 			Tasks: map[string]awsecs.EcsTask{
 				testTaskARN: {
-					TaskARN:           testTaskARN,
-					CreatedAt:         testTaskCreatedAt,
-					TaskDefinitionARN: testTaskDefinitionARN,
-					StartedAt:         testTaskStartedAt,
-					StartedBy:         testDeploymentID,
+					TaskARN:              testTaskARN,
+					CreatedAt:            testTaskCreatedAt,
+					TaskDefinitionARN:    testTaskDefinitionARN,
+					StartedAt:            testTaskStartedAt,
+					StartedBy:            testDeploymentID,
+					ContainerInstanceARN: testInstanceARN,
+					LaunchType:           awsecs.LaunchTypeEC2,
 				},
 			},
 			Services: map[string]awsecs.EcsService{
@@ -115,11 +119,42 @@ func TestTagReport(t *testing.T) {
 					PendingCount:      0,
 					RunningCount:      1,
 					TaskDefinitionARN: testTaskDefinitionARN,
+					Deployments: []awsecs.EcsDeployment{
+						{
+							ID:                testDeploymentID,
+							Status:            "PRIMARY",
+							TaskDefinitionARN: testTaskDefinitionARN,
+							DesiredCount:      1,
+							RunningCount:      1,
+						},
+					},
+					Events: []awsecs.EcsServiceEvent{
+						{ID: "2", Message: "(service test-service) has reached a steady state."},
+						{ID: "1", Message: "(service test-service) has started 1 tasks."},
+					},
 				},
 			},
 			TaskServiceMap: map[string]string{
 				testTaskARN: testServiceName,
 			},
+			TaskDefinitions: map[string]awsecs.EcsTaskDefinition{
+				testTaskDefinitionARN: {
+					TaskDefinitionARN: testTaskDefinitionARN,
+					Family:            testFamily,
+					Revision:          3,
+					CPU:               256,
+					Memory:            512,
+				},
+			},
+			ContainerInstances: map[string]awsecs.EcsContainerInstance{
+				testInstanceARN: {
+					ContainerInstanceARN: testInstanceARN,
+					EC2InstanceID:        testEC2InstanceID,
+					Status:               "ACTIVE",
+					AgentConnected:       true,
+					RunningTasksCount:    4,
+				},
+			},
 		},
 	)
 
@@ -142,6 +177,13 @@ func TestTagReport(t *testing.T) {
 		awsecs.TaskFamily: testFamily,
 		awsecs.Cluster:    testCluster,
 		awsecs.CreatedAt:  testTaskCreatedAt.Format(time.RFC3339Nano),
+
+		awsecs.TaskDefinition:                "test-family:3",
+		awsecs.LaunchType:                    awsecs.LaunchTypeEC2,
+		awsecs.TaskCPU:                       "256",
+		awsecs.TaskMemory:                    "512",
+		awsecs.ContainerInstanceEC2ID:        testEC2InstanceID,
+		awsecs.ContainerInstanceRunningTasks: "4",
 	}
 	for key, expectedValue := range taskExpected {
 		value, ok := task.Latest.Lookup(key)
@@ -163,6 +205,7 @@ func TestTagReport(t *testing.T) {
 		awsecs.Cluster:             testCluster,
 		awsecs.ServiceDesiredCount: "1",
 		awsecs.ServiceRunningCount: "1",
+		awsecs.TaskDefinition:      "test-family:3",
 	}
 	for key, expectedValue := range serviceExpected {
 		value, ok := service.Latest.Lookup(key)
@@ -175,6 +218,17 @@ func TestTagReport(t *testing.T) {
 		}
 	}
 
+	// Check the deployments and events of the service are tabulated, in order
+	serviceTopology := rpt.ECSService
+	deployments := service.ExtractMulticolumnTable(serviceTopology.TableTemplates[awsecs.DeploymentsTablePrefix])
+	if len(deployments) != 1 || deployments[0].Entries[awsecs.DeploymentStatus] != "PRIMARY" {
+		t.Errorf("Result service did not contain expected deployments: %v", deployments)
+	}
+	events := service.ExtractMulticolumnTable(serviceTopology.TableTemplates[awsecs.EventsTablePrefix])
+	if len(events) != 2 || events[0].Entries[awsecs.EventMessage] != "(service test-service) has reached a steady state." {
+		t.Errorf("Result service did not contain expected events: %v", events)
+	}
+
 	// Check container node is present and contains expected parents
 	container, ok := rpt.Container.Nodes[report.MakeContainerNodeID(testContainer)]
 	if !ok {
@@ -10,6 +10,7 @@ import (
 	"github.com/armon/go-radix"
 	docker_client "github.com/fsouza/go-dockerclient"
 
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 )
@@ -44,6 +45,7 @@ type Registry interface {
 	GetContainer(string) (Container, bool)
 	GetContainerByPrefix(string) (Container, bool)
 	GetContainerImage(string) (docker_client.APIImages, bool)
+	Health() probe.Health
 }
 
 // ContainerUpdateWatcher is the type of functions that get called when containers are updated.
@@ -60,6 +62,7 @@ type registry struct {
 	handlerRegistry        *controls.HandlerRegistry
 	noCommandLineArguments bool
 	noEnvironmentVariables bool
+	health                 *probe.HealthRecorder
 
 	watchers        []ContainerUpdateWatcher
 	containers      *radix.Tree
@@ -130,6 +133,7 @@ func NewRegistry(options RegistryOptions) (Registry, error) {
 		hostID:          options.HostID,
 		handlerRegistry: options.HandlerRegistry,
 		quit:            make(chan chan struct{}),
+		health:          probe.NewHealthRecorder("events"),
 		noCommandLineArguments: options.NoCommandLineArguments,
 		noEnvironmentVariables: options.NoEnvironmentVariables,
 	}
@@ -180,7 +184,7 @@ func (r *registry) listenForEvents() bool {
 	// after listing but before listening for events.
 	events := make(chan *docker_client.APIEvents)
 	if err := r.client.AddEventListener(events); err != nil {
-		log.Errorf("docker registry: %s", err)
+		r.fail(err)
 		return true
 	}
 	defer func() {
@@ -190,39 +194,42 @@ func (r *registry) listenForEvents() bool {
 	}()
 
 	if err := r.updateContainers(); err != nil {
-		log.Errorf("docker registry: %s", err)
+		r.fail(err)
 		return true
 	}
 
 	if err := r.updateImages(); err != nil {
-		log.Errorf("docker registry: %s", err)
+		r.fail(err)
 		return true
 	}
 
 	if err := r.updateNetworks(); err != nil {
-		log.Errorf("docker registry: %s", err)
+		r.fail(err)
 		return true
 	}
 
+	r.health.Record(nil)
+
 	otherUpdates := time.Tick(r.interval)
 	for {
 		select {
 		case event, ok := <-events:
 			if !ok {
-				log.Errorf("docker registry: event listener unexpectedly disconnected")
+				r.fail(fmt.Errorf("event listener unexpectedly disconnected"))
 				return true
 			}
 			r.handleEvent(event)
 
 		case <-otherUpdates:
 			if err := r.updateImages(); err != nil {
-				log.Errorf("docker registry: %s", err)
+				r.fail(err)
 				return true
 			}
 			if err := r.updateNetworks(); err != nil {
-				log.Errorf("docker registry: %s", err)
+				r.fail(err)
 				return true
 			}
+			r.health.Record(nil)
 
 		case ch := <-r.quit:
 			r.Lock()
@@ -240,6 +247,18 @@ func (r *registry) listenForEvents() bool {
 	}
 }
 
+// fail logs an error which interrupted listening for events, and records it
+// in the health of the registry.
+func (r *registry) fail(err error) {
+	log.Errorf("docker registry: %s", err)
+	r.health.Record(err)
+}
+
+// Health returns the health of listening for Docker events.
+func (r *registry) Health() probe.Health {
+	return r.health.Health()
+}
+
 func (r *registry) reset() {
 	r.Lock()
 	defer r.Unlock()
@@ -9,6 +9,7 @@ import (
 
 	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -44,7 +45,7 @@ var (
 	ContainerMetricTemplates = report.MetricTemplates{
 		CPUTotalUsage: {ID: CPUTotalUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage:   {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
-	}
+	}.Merge(process.AggregateMetricTemplates) // summed up from the processes of containers
 
 	ContainerImageMetadataTemplates = report.MetadataTemplates{
 		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 2},
@@ -165,6 +166,11 @@ func NewReporter(registry Registry, hostID string, probeID string, probe *probe.
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "Docker" }
 
+// Health implements probe.HealthChecker.
+func (r *Reporter) Health() []probe.Health {
+	return []probe.Health{r.registry.Health()}
+}
+
 // ContainerUpdated should be called whenever a container is updated.
 func (r *Reporter) ContainerUpdated(n report.Node) {
 	// Publish a 'short cut' report container just this container
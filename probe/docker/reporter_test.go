@@ -5,6 +5,7 @@ import (
 
 	client "github.com/fsouza/go-dockerclient"
 
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
@@ -48,6 +49,8 @@ func (r *mockRegistry) GetContainer(_ string) (docker.Container, bool) { return
 
 func (r *mockRegistry) GetContainerByPrefix(_ string) (docker.Container, bool) { return nil, false }
 
+func (r *mockRegistry) Health() probe.Health { return probe.Health{Component: "events"} }
+
 func (r *mockRegistry) GetContainerImage(id string) (client.APIImages, bool) {
 	image, ok := r.images[id]
 	return image, ok
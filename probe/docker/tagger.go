@@ -57,15 +57,8 @@ func (t *Tagger) Tag(r report.Report) (report.Report, error) {
 		if !ok {
 			continue
 		}
-		stackNamespace, ok := container.Latest.Lookup(LabelPrefix + "com.docker.stack.namespace")
-		if !ok {
-			stackNamespace = DefaultNamespace
-		} else {
-			prefix := stackNamespace + "_"
-			if strings.HasPrefix(serviceName, prefix) {
-				serviceName = serviceName[len(prefix):]
-			}
-		}
+		stackNamespace, _ := container.Latest.Lookup(LabelPrefix + "com.docker.stack.namespace")
+		serviceName, stackNamespace = StackServiceName(serviceName, stackNamespace)
 
 		nodeID := report.MakeSwarmServiceNodeID(serviceID)
 		node := report.MakeNodeWith(nodeID, map[string]string{
@@ -74,12 +67,26 @@ func (t *Tagger) Tag(r report.Report) (report.Report, error) {
 		})
 		r.SwarmService.AddNode(node)
 
-		r.Container.Nodes[containerID] = container.WithParents(container.Parents.Add(report.SwarmService, report.MakeStringSet(nodeID)))
+		parents := container.Parents.Add(report.SwarmService, report.MakeStringSet(nodeID))
+		if taskID, ok := container.Latest.Lookup(LabelPrefix + "com.docker.swarm.task.id"); ok {
+			parents = parents.Add(report.SwarmTask, report.MakeStringSet(report.MakeSwarmTaskNodeID(taskID)))
+		}
+		r.Container.Nodes[containerID] = container.WithParents(parents)
 	}
 
 	return r, nil
 }
 
+// StackServiceName returns the name of a Swarm service within its stack,
+// and the name of the stack, given the full name of the service and the
+// (possibly empty) namespace label of the stack.
+func StackServiceName(serviceName, stackNamespace string) (string, string) {
+	if stackNamespace == "" {
+		return serviceName, DefaultNamespace
+	}
+	return strings.TrimPrefix(serviceName, stackNamespace+"_"), stackNamespace
+}
+
 func (t *Tagger) tag(tree process.Tree, topology *report.Topology) {
 	for _, node := range topology.Nodes {
 		pidStr, ok := node.Latest.Lookup(process.PID)
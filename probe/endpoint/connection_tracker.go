@@ -1,10 +1,12 @@
 package endpoint
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
@@ -33,12 +35,14 @@ type connectionTracker struct {
 
 	// time of the previous ebpf failure, or zero if it didn't fail
 	ebpfLastFailureTime time.Time
+	ebpfHealth          *probe.HealthRecorder
 }
 
 func newConnectionTracker(conf connectionTrackerConfig) connectionTracker {
 	ct := connectionTracker{
 		conf:            conf,
 		reverseResolver: newReverseResolver(),
+		ebpfHealth:      probe.NewHealthRecorder("ebpf"),
 	}
 	if conf.UseEbpfConn {
 		et, err := newEbpfTracker()
@@ -48,6 +52,7 @@ func newConnectionTracker(conf connectionTrackerConfig) connectionTracker {
 			return ct
 		}
 		log.Warnf("Error setting up the eBPF tracker, falling back to proc scanning: %v", err)
+		ct.ebpfHealth.Record(fmt.Errorf("could not set up eBPF tracker, using proc scanning: %v", err))
 	}
 	ct.useProcfs()
 	return ct
@@ -89,6 +94,7 @@ func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 	if t.ebpfTracker != nil {
 		if !t.ebpfTracker.isDead() {
 			t.performEbpfTrack(rpt, hostNodeID)
+			t.ebpfHealth.Record(nil)
 			return
 		}
 
@@ -101,6 +107,7 @@ func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 		if ebpfLastFailureTime.After(time.Now().Add(-5 * time.Minute)) {
 			// Multiple failures in the last 5 minutes, fall back to proc parsing
 			log.Warnf("ebpf tracker died again, gently falling back to proc scanning")
+			t.ebpfHealth.Record(fmt.Errorf("eBPF tracker died repeatedly, using proc scanning"))
 			t.useProcfs()
 		} else {
 			// Tolerable failure rate, restart the tracker
@@ -112,6 +119,7 @@ func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 				return
 			}
 			log.Warnf("could not restart ebpf tracker, falling back to proc scanning: %v", err)
+			t.ebpfHealth.Record(fmt.Errorf("could not restart eBPF tracker, using proc scanning: %v", err))
 			t.useProcfs()
 		}
 	}
@@ -169,7 +177,7 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 // once to initialize ebpfTracker
 func (t *connectionTracker) getInitialState() {
 	var processCache *process.CachingWalker
-	walker := process.NewWalker(t.conf.ProcRoot, true)
+	walker := process.NewWalker(t.conf.ProcRoot, true, 0)
 	processCache = process.NewCachingWalker(walker)
 	processCache.Tick()
 
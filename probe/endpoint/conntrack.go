@@ -16,6 +16,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 
 	"github.com/weaveworks/common/exec"
+	"github.com/weaveworks/scope/probe"
 )
 
 const (
@@ -69,7 +70,11 @@ type flowWalker interface {
 	stop()
 }
 
-type nilFlowWalker struct{}
+// nilFlowWalker is used when conntrack is disabled or not supported, in
+// which case err says why.
+type nilFlowWalker struct {
+	err error
+}
 
 func (n nilFlowWalker) stop()                        {}
 func (n nilFlowWalker) walkFlows(f func(flow, bool)) {}
@@ -84,6 +89,7 @@ type conntrackWalker struct {
 	bufferSize    int
 	args          []string
 	quit          chan struct{}
+	health        *probe.HealthRecorder
 }
 
 // newConntracker creates and starts a new conntracker.
@@ -92,18 +98,32 @@ func newConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int,
 		return nilFlowWalker{}
 	} else if err := IsConntrackSupported(procRoot); err != nil {
 		log.Warnf("Not using conntrack: not supported by the kernel: %s", err)
-		return nilFlowWalker{}
+		return nilFlowWalker{fmt.Errorf("not supported by the kernel: %v", err)}
 	}
 	result := &conntrackWalker{
 		activeFlows: map[int64]flow{},
 		bufferSize:  bufferSize,
 		args:        args,
 		quit:        make(chan struct{}),
+		health:      probe.NewHealthRecorder("conntrack"),
 	}
 	go result.loop()
 	return result
 }
 
+// flowWalkerHealth returns the health of the conntrack process behind fw.
+func flowWalkerHealth(fw flowWalker) probe.Health {
+	switch fw := fw.(type) {
+	case *conntrackWalker:
+		return fw.health.Health()
+	case nilFlowWalker:
+		h := probe.NewHealthRecorder("conntrack")
+		h.Record(fw.err)
+		return h.Health()
+	}
+	return probe.Health{Component: "conntrack", State: probe.HealthOK}
+}
+
 // IsConntrackSupported returns true if conntrack is suppported by the kernel
 var IsConntrackSupported = func(procRoot string) error {
 	// Make sure events are enabled, the conntrack CLI doesn't verify it
@@ -125,7 +145,10 @@ func (c *conntrackWalker) loop() {
 	// read the table before starting to handle events - basically degrading to
 	// polling.
 	for {
-		c.run()
+		if err := c.run(); err != nil {
+			log.Errorf("conntrack error: %v", err)
+			c.health.Record(err)
+		}
 		c.clearFlows()
 
 		select {
@@ -157,13 +180,12 @@ func logPipe(prefix string, reader io.Reader) {
 	}
 }
 
-func (c *conntrackWalker) run() {
+func (c *conntrackWalker) run() error {
 	// Fork another conntrack, just to capture existing connections
 	// for which we don't get events
 	existingFlows, err := existingConnections(c.args)
 	if err != nil {
-		log.Errorf("conntrack existingConnections error: %v", err)
-		return
+		return fmt.Errorf("existingConnections: %v", err)
 	}
 	for _, flow := range existingFlows {
 		c.handleFlow(flow, true)
@@ -176,20 +198,17 @@ func (c *conntrackWalker) run() {
 	cmd := exec.Command("conntrack", args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Errorf("conntrack error: %v", err)
-		return
+		return err
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		log.Errorf("conntrack error: %v", err)
-		return
+		return err
 	}
 	go logPipe("conntrack stderr:", stderr)
 
 	if err := cmd.Start(); err != nil {
-		log.Errorf("conntrack error: %v", err)
-		return
+		return err
 	}
 
 	defer func() {
@@ -205,10 +224,11 @@ func (c *conntrackWalker) run() {
 	select {
 	default:
 	case <-c.quit:
-		return
+		return nil
 	}
 	c.cmd = cmd
 	c.Unlock()
+	c.health.Record(nil)
 
 	scanner := bufio.NewScanner(bufio.NewReader(stdout))
 	defer log.Infof("conntrack exiting")
@@ -217,8 +237,7 @@ func (c *conntrackWalker) run() {
 	for {
 		f, err := decodeStreamedFlow(scanner)
 		if err != nil {
-			log.Errorf("conntrack error: %v", err)
-			return
+			return err
 		}
 		c.handleFlow(f, false)
 	}
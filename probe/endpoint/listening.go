@@ -0,0 +1,191 @@
+package endpoint
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/backoff"
+
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys of the listening sockets tables
+const (
+	ListeningTablePrefix = "listening_sockets_"
+	ListeningProtocol    = "listening_protocol"
+	ListeningAddress     = "listening_address"
+	ListeningPort        = "listening_port"
+	ListeningExposed     = "listening_exposed"
+	ListeningProcess     = "listening_process"
+)
+
+var (
+	listeningColumns = []report.Column{
+		{ID: ListeningProtocol, Label: "Protocol"},
+		{ID: ListeningAddress, Label: "Address"},
+		{ID: ListeningPort, Label: "Port", DataType: report.Number},
+		{ID: ListeningExposed, Label: "Exposed"},
+	}
+
+	processListeningTableTemplates = report.TableTemplates{
+		ListeningTablePrefix: {
+			ID:      ListeningTablePrefix,
+			Label:   "Listening Sockets",
+			Type:    report.MulticolumnTableType,
+			Prefix:  ListeningTablePrefix,
+			Columns: listeningColumns,
+		},
+	}
+
+	containerListeningTableTemplates = report.TableTemplates{
+		ListeningTablePrefix: {
+			ID:      ListeningTablePrefix,
+			Label:   "Listening Sockets",
+			Type:    report.MulticolumnTableType,
+			Prefix:  ListeningTablePrefix,
+			Columns: append([]report.Column{{ID: ListeningProcess, Label: "Process"}}, listeningColumns...),
+		},
+	}
+)
+
+// ListeningReporter reports the TCP and UDP sockets processes listen on, as
+// a table of their process nodes. It is also a Tagger, which copies the
+// tables to the containers of the processes.
+//
+// Finding the processes owning the sockets means looking at all their file
+// descriptors, so it's done in the background, every interval.
+type ListeningReporter struct {
+	hostID string
+	walker process.Walker
+
+	mtx           sync.RWMutex
+	sockets       []procspy.ListeningSocket
+	hostNamespace uint64
+
+	backoff backoff.Interface
+}
+
+// NewListeningReporter makes a new ListeningReporter of the processes
+// walked.
+func NewListeningReporter(hostID string, walker process.Walker, interval time.Duration) *ListeningReporter {
+	r := &ListeningReporter{
+		hostID: hostID,
+		walker: walker,
+	}
+	r.backoff = backoff.New(r.collect, "collecting listening sockets")
+	r.backoff.SetInitialBackoff(interval)
+	go r.backoff.Start()
+	return r
+}
+
+// Name of this reporter, for metrics gathering
+func (*ListeningReporter) Name() string { return "Listening" }
+
+// Stop collecting sockets.
+func (r *ListeningReporter) Stop() {
+	r.backoff.Stop()
+}
+
+func (r *ListeningReporter) collect() (bool, error) {
+	sockets, err := procspy.ListeningSockets(r.walker)
+	if err != nil {
+		return false, err
+	}
+	hostNamespace, err := procspy.ReadNetnsFromPID(1)
+	if err != nil {
+		return false, err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.sockets, r.hostNamespace = sockets, hostNamespace
+	return false, nil
+}
+
+// Report implements Reporter.
+func (r *ListeningReporter) Report() (report.Report, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	rpt := report.MakeReport()
+	rpt.Process = rpt.Process.WithTableTemplates(processListeningTableTemplates)
+
+	byPID := map[uint][]report.Row{}
+	for _, socket := range r.sockets {
+		for _, proc := range socket.Procs {
+			byPID[proc.PID] = append(byPID[proc.PID], report.Row{
+				ID:      listeningRowID(socket),
+				Entries: r.listeningEntries(socket),
+			})
+		}
+	}
+	for pid, rows := range byPID {
+		nodeID := report.MakeProcessNodeID(r.hostID, strconv.FormatUint(uint64(pid), 10))
+		rpt.Process.AddNode(report.MakeNode(nodeID).AddPrefixMulticolumnTable(ListeningTablePrefix, rows))
+	}
+	return rpt, nil
+}
+
+// Tag implements Tagger. It needs processes to have been tagged with their
+// containers.
+func (r *ListeningReporter) Tag(rpt report.Report) (report.Report, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	byContainer := map[string][]report.Row{}
+	for _, socket := range r.sockets {
+		for _, proc := range socket.Procs {
+			pid := strconv.FormatUint(uint64(proc.PID), 10)
+			node, ok := rpt.Process.Nodes[report.MakeProcessNodeID(r.hostID, pid)]
+			if !ok {
+				continue
+			}
+			containers, ok := node.Parents.Lookup(report.Container)
+			if !ok {
+				continue
+			}
+			entries := r.listeningEntries(socket)
+			entries[ListeningProcess] = fmt.Sprintf("%s (%s)", proc.Name, pid)
+			for _, containerID := range containers {
+				byContainer[containerID] = append(byContainer[containerID], report.Row{
+					ID:      listeningRowID(socket) + ":" + pid,
+					Entries: entries,
+				})
+			}
+		}
+	}
+
+	rpt.Container = rpt.Container.WithTableTemplates(containerListeningTableTemplates)
+	for containerID, rows := range byContainer {
+		if node, ok := rpt.Container.Nodes[containerID]; ok {
+			rpt.Container.Nodes[containerID] = node.AddPrefixMulticolumnTable(ListeningTablePrefix, rows)
+		}
+	}
+	return rpt, nil
+}
+
+// listeningRowID sorts sockets by protocol and port.
+func listeningRowID(socket procspy.ListeningSocket) string {
+	return fmt.Sprintf("%s:%05d:%s", socket.Transport, socket.Port, socket.Address)
+}
+
+// listeningEntries describes a socket. Sockets are exposed when bound to all
+// the addresses of the host's network namespace, and are therefore reachable
+// from outside the host unless firewalled. Those of containers are only
+// reachable through the ports published by their runtime.
+func (r *ListeningReporter) listeningEntries(socket procspy.ListeningSocket) map[string]string {
+	exposed := "no"
+	if socket.Address.IsUnspecified() && len(socket.Procs) > 0 && socket.Procs[0].NetNamespaceID == r.hostNamespace {
+		exposed = "yes"
+	}
+	return map[string]string{
+		ListeningProtocol: socket.Transport,
+		ListeningAddress:  socket.Address.String(),
+		ListeningPort:     strconv.Itoa(int(socket.Port)),
+		ListeningExposed:  exposed,
+	}
+}
@@ -0,0 +1,76 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestListeningReporter(t *testing.T) {
+	const (
+		hostID        = "host1"
+		hostNamespace = 4026531993
+		containerNS   = 4026532288
+	)
+	r := &ListeningReporter{
+		hostID:        hostID,
+		hostNamespace: hostNamespace,
+		sockets: []procspy.ListeningSocket{
+			{Transport: "tcp", Address: net.IPv4zero, Port: 22, Procs: []procspy.Proc{{PID: 1, Name: "sshd", NetNamespaceID: hostNamespace}}},
+			{Transport: "tcp", Address: net.IPv4(127, 0, 0, 1), Port: 3306, Procs: []procspy.Proc{{PID: 1, Name: "sshd", NetNamespaceID: hostNamespace}}},
+			{Transport: "udp", Address: net.IPv6zero, Port: 53, Procs: []procspy.Proc{{PID: 42, Name: "dnsmasq", NetNamespaceID: containerNS}, {PID: 43, Name: "dnsmasq", NetNamespaceID: containerNS}}},
+			{Transport: "tcp", Address: net.IPv4zero, Port: 8080},
+		},
+	}
+
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshd := report.MakeProcessNodeID(hostID, "1")
+	dnsmasq := report.MakeProcessNodeID(hostID, "42")
+	worker := report.MakeProcessNodeID(hostID, "43")
+	if want, have := 3, len(rpt.Process.Nodes); want != have {
+		t.Fatalf("Expected %d process nodes, got %d: %v", want, have, rpt.Process.Nodes)
+	}
+
+	have, _ := rpt.Process.Nodes[sshd].ExtractTable(processListeningTableTemplates[ListeningTablePrefix])
+	want := []report.Row{
+		{ID: "tcp:00022:0.0.0.0", Entries: map[string]string{
+			ListeningProtocol: "tcp", ListeningAddress: "0.0.0.0", ListeningPort: "22", ListeningExposed: "yes",
+		}},
+		{ID: "tcp:03306:127.0.0.1", Entries: map[string]string{
+			ListeningProtocol: "tcp", ListeningAddress: "127.0.0.1", ListeningPort: "3306", ListeningExposed: "no",
+		}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Unexpected rows of process:\n%v\n!=\n%v", have, want)
+	}
+
+	// Sockets of containers are copied to them
+	containerID := report.MakeContainerNodeID("abcdef")
+	rpt.Container.AddNode(report.MakeNode(containerID))
+	for _, id := range []string{dnsmasq, worker} {
+		rpt.Process.Nodes[id] = rpt.Process.Nodes[id].WithParents(
+			report.MakeSets().Add(report.Container, report.MakeStringSet(containerID)))
+	}
+	rpt, err = r.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, _ = rpt.Container.Nodes[containerID].ExtractTable(containerListeningTableTemplates[ListeningTablePrefix])
+	want = []report.Row{
+		{ID: "udp:00053::::42", Entries: map[string]string{
+			ListeningProcess: "dnsmasq (42)", ListeningProtocol: "udp", ListeningAddress: "::", ListeningPort: "53", ListeningExposed: "no",
+		}},
+		{ID: "udp:00053::::43", Entries: map[string]string{
+			ListeningProcess: "dnsmasq (43)", ListeningProtocol: "udp", ListeningAddress: "::", ListeningPort: "53", ListeningExposed: "no",
+		}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Unexpected rows of container:\n%v\n!=\n%v", have, want)
+	}
+}
@@ -0,0 +1,70 @@
+package procspy
+
+import (
+	"bytes"
+	"net"
+)
+
+// ListeningSocket is a TCP socket waiting for connections, or a UDP socket
+// waiting for datagrams from any peer. Procs are the processes holding the
+// socket, sorted by PID; there can be several, e.g. the workers a pre-forking
+// server shares its socket with, or none if they weren't found.
+type ListeningSocket struct {
+	Transport string
+	Address   net.IP
+	Port      uint16
+	Inode     uint64
+	Procs     []Proc
+}
+
+// ParseListeningSockets parses the listening sockets of the given transport
+// ("tcp" or "udp") in the contents of /proc/net/{tcp,udp}{,6}. UDP sockets
+// don't listen, but those not connected to a peer are in the TCP_CLOSE state
+// and receive datagrams from anyone.
+func ParseListeningSockets(transport string, b []byte) []ListeningSocket {
+	want := uint(tcpListen)
+	if transport == "udp" {
+		want = tcpClose
+	}
+	sockets := []ListeningSocket{}
+	for ; len(b) > 0; b = nextLine(b) {
+		var sl, local, remote, state, inode []byte
+		sl, b = nextField(b)
+		if sl == nil || bytes.Equal(sl, slHeader) {
+			continue
+		}
+		local, b = nextField(b)
+		remote, b = nextField(b)
+		state, b = nextField(b)
+		if parseHex(state) != want {
+			continue
+		}
+		for i := 0; i < 5; i++ {
+			_, b = nextField(b) // 'tx_queue:rx_queue', 'tr:tm->when', 'retrnsmt', 'uid' and 'timeout' columns
+		}
+		inode, b = nextField(b)
+
+		address, port := scanAddress(local)
+		_, remotePort := scanAddress(remote)
+		if port == 0 || remotePort != 0 {
+			// Unbound, or a connected UDP socket
+			continue
+		}
+		sockets = append(sockets, ListeningSocket{
+			Transport: transport,
+			Address:   address,
+			Port:      port,
+			Inode:     parseDec(inode),
+		})
+	}
+	return sockets
+}
+
+// scanAddress is like scanAddressNA, but allocates the address.
+func scanAddress(in []byte) (net.IP, uint16) {
+	col := bytes.IndexByte(in, ':')
+	if col == -1 {
+		return nil, 0
+	}
+	return net.IP(hexDecode32big(in[:col])), uint16(parseHex(in[col+1:]))
+}
@@ -0,0 +1,44 @@
+package procspy
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseListeningSockets(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 5084 1 ffff8800a6aaf740 100 0 0 10 0
+   1: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 10550 1 ffff8800a729b780 100 0 0 10 0
+   2: A12CF62E:E4D7 57FC1EC0:01BB 01 00000000:00000000 02:000006FA 00000000  1000        0 639474 2 ffff88007e75a740 48 4 26 10 -1
+`
+	udp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  12: 00000000:0044 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16324 2 ffff88003d3af3c0 0
+  40: 0100007F:0035 0100007F:A1B2 01 00000000:00000000 00:00000000 00000000     0        0 16325 2 ffff88003d3af3c0 0
+  41: 00000000:0000 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16326 2 ffff88003d3af3c0 0
+`
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4141 1 ffff88003e0a4800 100 0 0 10 0
+`
+
+	for _, c := range []struct {
+		transport, contents string
+		want                []ListeningSocket
+	}{
+		{"tcp", tcp, []ListeningSocket{
+			{Transport: "tcp", Address: net.IP{0, 0, 0, 0}, Port: 22, Inode: 5084},
+			{Transport: "tcp", Address: net.IP{127, 0, 0, 1}, Port: 3306, Inode: 10550},
+		}},
+		{"udp", udp, []ListeningSocket{
+			{Transport: "udp", Address: net.IP{0, 0, 0, 0}, Port: 68, Inode: 16324},
+		}},
+		{"tcp", tcp6, []ListeningSocket{
+			{Transport: "tcp", Address: net.IPv6zero, Port: 80, Inode: 4141},
+		}},
+	} {
+		have := ParseListeningSockets(c.transport, []byte(c.contents))
+		if !reflect.DeepEqual(c.want, have) {
+			t.Errorf("Unexpected listening sockets:\n%+v\n!=\n%+v", have, c.want)
+		}
+	}
+}
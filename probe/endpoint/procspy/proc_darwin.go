@@ -3,6 +3,8 @@ package procspy
 import (
 	"bytes"
 	"fmt"
+
+	"github.com/weaveworks/scope/probe/process"
 )
 
 // ReadTCPFiles reads the proc files tcp and tcp6 for a pid
@@ -14,3 +16,9 @@ func ReadTCPFiles(pid int, buf *bytes.Buffer) (int64, error) {
 func ReadNetnsFromPID(pid int) (uint64, error) {
 	return 0, fmt.Errorf("not supported on non-Linux systems")
 }
+
+// ListeningSockets returns the listening TCP and UDP sockets of the processes
+// walked.
+func ListeningSockets(walker process.Walker) ([]ListeningSocket, error) {
+	return nil, fmt.Errorf("not supported on non-Linux systems")
+}
@@ -2,6 +2,7 @@ package procspy
 
 import (
 	"bytes"
+	"net"
 	"reflect"
 	"syscall"
 	"testing"
@@ -62,7 +63,7 @@ func TestWalkProcPid(t *testing.T) {
 	defer fs_hook.Restore()
 
 	buf := bytes.Buffer{}
-	walker := process.NewWalker(procRoot, false)
+	walker := process.NewWalker(procRoot, false, 0)
 	ticker := time.NewTicker(time.Millisecond)
 	defer ticker.Stop()
 	pWalker := newPidWalker(walker, ticker.C, 1)
@@ -80,3 +81,115 @@ func TestWalkProcPid(t *testing.T) {
 		t.Fatalf("%+v", have)
 	}
 }
+
+func TestListeningSockets(t *testing.T) {
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("1",
+				fs.Dir("fd",
+					fs.File{
+						FName: "3",
+						FStat: syscall.Stat_t{
+							Ino:  4242,
+							Mode: syscall.S_IFSOCK,
+						},
+					},
+				),
+				fs.File{
+					FName:     "cmdline",
+					FContents: "foo",
+				},
+				fs.Dir("ns",
+					fs.File{
+						FName: "net",
+						FStat: syscall.Stat_t{Ino: 4026531993},
+					},
+				),
+				fs.Dir("net",
+					fs.File{
+						FName: "tcp",
+						FContents: `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4242 1 ffff8800a6aaf040 100 0 0 10 0
+   1: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4343 1 ffff8800a6aaf040 100 0 0 10 0
+`,
+					},
+					fs.File{FName: "udp"},
+					fs.File{FName: "tcp6"},
+					fs.File{FName: "udp6"},
+				),
+				fs.File{
+					FName:     "stat",
+					FContents: "1 na R 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0 0",
+				},
+				fs.File{
+					FName:     "limits",
+					FContents: "",
+				},
+			),
+			// A worker sharing the socket of its parent, twice
+			fs.Dir("2",
+				fs.Dir("fd",
+					fs.File{
+						FName: "3",
+						FStat: syscall.Stat_t{
+							Ino:  4242,
+							Mode: syscall.S_IFSOCK,
+						},
+					},
+					fs.File{
+						FName: "4",
+						FStat: syscall.Stat_t{
+							Ino:  4242,
+							Mode: syscall.S_IFSOCK,
+						},
+					},
+				),
+				fs.File{
+					FName:     "cmdline",
+					FContents: "bar",
+				},
+				fs.Dir("ns",
+					fs.File{
+						FName: "net",
+						FStat: syscall.Stat_t{Ino: 4026531993},
+					},
+				),
+				fs.File{
+					FName:     "stat",
+					FContents: "2 na R 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0 0",
+				},
+				fs.File{
+					FName:     "limits",
+					FContents: "",
+				},
+			),
+		),
+	))
+	defer fs_hook.Restore()
+
+	have, err := ListeningSockets(process.NewWalker(procRoot, false, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ListeningSocket{
+		{
+			Transport: "tcp",
+			Address:   net.IP{0, 0, 0, 0},
+			Port:      80,
+			Inode:     4242,
+			Procs: []Proc{
+				{PID: 1, Name: "foo", NetNamespaceID: 4026531993},
+				{PID: 2, Name: "bar", NetNamespaceID: 4026531993},
+			},
+		},
+		{
+			Transport: "tcp",
+			Address:   net.IP{127, 0, 0, 1},
+			Port:      3306,
+			Inode:     4343,
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatalf("%+v", have)
+	}
+}
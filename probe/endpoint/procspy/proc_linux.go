@@ -6,7 +6,9 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -281,3 +283,105 @@ func readFile(filename string, buf *bytes.Buffer) (int64, error) {
 	defer f.Close()
 	return buf.ReadFrom(f)
 }
+
+// ListeningSockets returns the listening TCP and UDP sockets of all the
+// network namespaces of the processes walked, with the processes they belong
+// to. Unlike the connection scanner, it doesn't rate limit reading
+// /proc/PID/fd/*, so it's meant to be run infrequently.
+func ListeningSockets(walker process.Walker) ([]ListeningSocket, error) {
+	namespaces := map[uint64][]process.Process{}
+	err := walker.Walk(func(p, _ process.Process) {
+		namespaceID, err := ReadNetnsFromPID(p.PID)
+		if err != nil {
+			return
+		}
+		namespaces[namespaceID] = append(namespaces[namespaceID], p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ListeningSocket{}
+	buf := bytes.Buffer{}
+	for namespaceID, procs := range namespaces {
+		var sockets []ListeningSocket
+		for _, p := range procs {
+			// The files are the same for all the processes of the namespace
+			var ok bool
+			if sockets, ok = readListeningSockets(p.PID, &buf); ok {
+				break
+			}
+		}
+		if len(sockets) == 0 {
+			continue
+		}
+		byInode := map[uint64]int{}
+		for i, s := range sockets {
+			byInode[s.Inode] = i
+		}
+
+		var statT syscall.Stat_t
+		for _, p := range procs {
+			fdBase := filepath.Join(procRoot, strconv.Itoa(p.PID), "fd")
+			fds, err := fs.ReadDirNames(fdBase)
+			if err != nil {
+				continue
+			}
+			for _, fd := range fds {
+				if err := fs.Stat(filepath.Join(fdBase, fd), &statT); err != nil {
+					continue
+				}
+				if statT.Mode&syscall.S_IFMT != syscall.S_IFSOCK {
+					continue
+				}
+				if i, ok := byInode[statT.Ino]; ok && !hasPID(sockets[i].Procs, p.PID) {
+					sockets[i].Procs = append(sockets[i].Procs, Proc{
+						PID:            uint(p.PID),
+						Name:           p.Name,
+						NetNamespaceID: namespaceID,
+					})
+				}
+			}
+		}
+		for _, s := range sockets {
+			sort.Sort(procsByPID(s.Procs))
+		}
+		result = append(result, sockets...)
+	}
+	return result, nil
+}
+
+// hasPID tells whether a process already holds a socket, through another of
+// its file descriptors.
+func hasPID(procs []Proc, pid int) bool {
+	for _, p := range procs {
+		if p.PID == uint(pid) {
+			return true
+		}
+	}
+	return false
+}
+
+type procsByPID []Proc
+
+func (p procsByPID) Len() int           { return len(p) }
+func (p procsByPID) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p procsByPID) Less(i, j int) bool { return p[i].PID < p[j].PID }
+
+// readListeningSockets reads the listening sockets of the network namespace
+// of a process.
+func readListeningSockets(pid int, buf *bytes.Buffer) ([]ListeningSocket, bool) {
+	sockets := []ListeningSocket{}
+	files := []string{"tcp", "udp"}
+	if ipv6IsSupported {
+		files = append(files, "tcp6", "udp6")
+	}
+	for _, file := range files {
+		buf.Reset()
+		if _, err := readFile(filepath.Join(procRoot, strconv.Itoa(pid), "net", file), buf); err != nil {
+			return nil, false
+		}
+		sockets = append(sockets, ParseListeningSockets(strings.TrimSuffix(file, "6"), buf.Bytes())...)
+	}
+	return sockets, true
+}
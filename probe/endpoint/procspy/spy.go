@@ -12,7 +12,9 @@ const (
 	tcpEstablished = 1
 	tcpFinWait1    = 4
 	tcpFinWait2    = 5
+	tcpClose       = 7
 	tcpCloseWait   = 8
+	tcpListen      = 10
 )
 
 // Connection is a (TCP) connection. The Proc struct might not be filled in.
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
@@ -78,6 +79,19 @@ func NewReporter(conf ReporterConfig) *Reporter {
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "Endpoint" }
 
+// Health implements probe.HealthChecker. Conntrack health is that of the
+// NAT mapper's conntrack process, which runs whenever conntrack is enabled.
+func (r *Reporter) Health() []probe.Health {
+	result := []probe.Health{}
+	if r.conf.UseEbpfConn {
+		result = append(result, r.connectionTracker.ebpfHealth.Health())
+	}
+	if r.conf.UseConntrack {
+		result = append(result, flowWalkerHealth(r.natMapper.flowWalker))
+	}
+	return result
+}
+
 // Stop stop stop
 func (r *Reporter) Stop() {
 	r.connectionTracker.Stop()
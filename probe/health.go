@@ -0,0 +1,259 @@
+package probe
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/scope/probe/appclient"
+	"github.com/weaveworks/scope/report"
+)
+
+// Health states
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// Keys for the probe health table, which is attached to the host node.
+const (
+	HealthTablePrefix = "probe_health_table_"
+	HealthState       = "probe_health_state"
+	HealthLastSuccess = "probe_health_last_success"
+	HealthLastError   = "probe_health_last_error"
+	HealthMessage     = "probe_health_message"
+	HealthLatency     = "probe_health_latency"
+)
+
+// HealthTableTemplates describe how the probe health table is shown in the UI.
+var HealthTableTemplates = report.TableTemplates{
+	HealthTablePrefix: {
+		ID:     HealthTablePrefix,
+		Label:  "Probe Health",
+		Type:   report.MulticolumnTableType,
+		Prefix: HealthTablePrefix,
+		Columns: []report.Column{
+			{ID: HealthState, Label: "State"},
+			{ID: HealthLastSuccess, Label: "Last Success"},
+			{ID: HealthLastError, Label: "Last Error"},
+			{ID: HealthMessage, Label: "Error"},
+			{ID: HealthLatency, Label: "Latency"},
+		},
+	},
+}
+
+// Health is the self-reported state of one component of the probe.
+type Health struct {
+	Component   string        `json:"component"`
+	State       string        `json:"state"`
+	LastSuccess time.Time     `json:"lastSuccess"`
+	LastError   time.Time     `json:"lastError"`
+	Error       string        `json:"error,omitempty"`
+	Latency     time.Duration `json:"latency"`
+}
+
+// HealthChecker is implemented by Reporters, Taggers and Tickers which know
+// more about their health than whether their last invocation failed, e.g. a
+// reporter which has quietly fallen back to a less capable data source. The
+// components returned are prefixed with the name of the HealthChecker.
+type HealthChecker interface {
+	Health() []Health
+}
+
+type publishStatuser interface {
+	PublishStatuses() []appclient.PublishStatus
+}
+
+func (h *Health) record(t time.Time, latency time.Duration, err error) {
+	h.Latency = latency
+	if err != nil {
+		h.LastError, h.Error = t, err.Error()
+	} else {
+		h.LastSuccess = t
+	}
+}
+
+func (h Health) withState() Health {
+	if h.Error != "" && !h.LastError.Before(h.LastSuccess) {
+		h.State = HealthFailing
+	} else {
+		h.State = HealthOK
+	}
+	return h
+}
+
+// HealthRecorder keeps track of the health of a single component, which
+// may be updated and checked from different goroutines.
+type HealthRecorder struct {
+	mtx    sync.Mutex
+	health Health
+}
+
+// NewHealthRecorder makes a new HealthRecorder for the named component.
+func NewHealthRecorder(component string) *HealthRecorder {
+	return &HealthRecorder{health: Health{Component: component}}
+}
+
+// Record the outcome of an operation of the component, nil meaning success.
+func (r *HealthRecorder) Record(err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.health.record(time.Now(), 0, err)
+}
+
+// Health returns the current health of the component.
+func (r *HealthRecorder) Health() Health {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.health.withState()
+}
+
+// healthTracker records the outcome of each invocation of a probe component.
+type healthTracker struct {
+	sync.Mutex
+	components map[string]*Health
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{components: map[string]*Health{}}
+}
+
+func (t *healthTracker) record(kind, name string, start time.Time, err error) {
+	component := kind + "/" + name
+	now := time.Now()
+	t.Lock()
+	defer t.Unlock()
+	h, ok := t.components[component]
+	if !ok {
+		h = &Health{Component: component}
+		t.components[component] = h
+	}
+	h.record(now, now.Sub(start), err)
+}
+
+func (t *healthTracker) health() []Health {
+	t.Lock()
+	defer t.Unlock()
+	result := make([]Health, 0, len(t.components))
+	for _, h := range t.components {
+		result = append(result, h.withState())
+	}
+	return result
+}
+
+// Health returns the state of all the components of the probe: its
+// reporters, taggers, tickers and publishers.
+func (p *Probe) Health() []Health {
+	result := p.health.health()
+
+	check := func(name string, c interface{}) {
+		if checker, ok := c.(HealthChecker); ok {
+			for _, h := range checker.Health() {
+				h.Component = name + "/" + h.Component
+				result = append(result, h.withState())
+			}
+		}
+	}
+	for _, r := range p.reporters {
+		check(r.Name(), r)
+	}
+	for _, t := range p.taggers {
+		check(t.Name(), t)
+	}
+	for _, t := range p.tickers {
+		check(t.Name(), t)
+	}
+
+	if p.appStatuses != nil {
+		for _, status := range p.appStatuses.PublishStatuses() {
+			result = append(result, Health{
+				Component:   "app/" + status.App,
+				LastSuccess: status.LastSuccess,
+				LastError:   status.LastError,
+				Error:       status.Error,
+				Latency:     status.Latency,
+			}.withState())
+		}
+	}
+
+	sort.Sort(healthByComponent(result))
+	return result
+}
+
+type healthByComponent []Health
+
+func (h healthByComponent) Len() int           { return len(h) }
+func (h healthByComponent) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h healthByComponent) Less(i, j int) bool { return h[i].Component < h[j].Component }
+
+// HealthReporter reports the health of a probe on its host node.
+type HealthReporter struct {
+	hostID string
+	probe  *Probe
+}
+
+// NewHealthReporter makes a new HealthReporter.
+func NewHealthReporter(hostID string, probe *Probe) *HealthReporter {
+	return &HealthReporter{hostID: hostID, probe: probe}
+}
+
+// Name of this reporter, for metrics gathering
+func (*HealthReporter) Name() string { return "Health" }
+
+// Report implements Reporter.
+func (r *HealthReporter) Report() (report.Report, error) {
+	rep := report.MakeReport()
+	rep.Host = rep.Host.WithTableTemplates(HealthTableTemplates)
+	rows := []report.Row{}
+	for _, h := range r.probe.Health() {
+		rows = append(rows, report.Row{
+			ID: h.Component,
+			Entries: map[string]string{
+				HealthState:       h.State,
+				HealthLastSuccess: formatHealthTime(h.LastSuccess),
+				HealthLastError:   formatHealthTime(h.LastError),
+				HealthMessage:     h.Error,
+				HealthLatency:     h.Latency.String(),
+			},
+		})
+	}
+	rep.Host.AddNode(
+		report.MakeNode(report.MakeHostNodeID(r.hostID)).
+			AddPrefixMulticolumnTable(HealthTablePrefix, rows),
+	)
+	return rep, nil
+}
+
+func formatHealthTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ExtractHealth returns the probe health reported on a host node.
+func ExtractHealth(n report.Node) []Health {
+	result := []Health{}
+	for _, row := range n.ExtractMulticolumnTable(HealthTableTemplates[HealthTablePrefix]) {
+		h := Health{
+			Component: row.ID,
+			State:     row.Entries[HealthState],
+			Error:     row.Entries[HealthMessage],
+		}
+		h.LastSuccess, _ = time.Parse(time.RFC3339Nano, row.Entries[HealthLastSuccess])
+		h.LastError, _ = time.Parse(time.RFC3339Nano, row.Entries[HealthLastError])
+		h.Latency, _ = time.ParseDuration(row.Entries[HealthLatency])
+		result = append(result, h)
+	}
+	return result
+}
+
+// Healthy returns true if none of the components are in error.
+func Healthy(hs []Health) bool {
+	for _, h := range hs {
+		if h.State == HealthFailing {
+			return false
+		}
+	}
+	return true
+}
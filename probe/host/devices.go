@@ -0,0 +1,267 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const sectorSize = 512 // /proc/diskstats counts in 512 bytes sectors, whatever the device
+
+// Mount is a filesystem mounted from a block device.
+type Mount struct {
+	Device     string
+	MountPoint string
+	Type       string
+}
+
+// FilesystemUsage is the used and total bytes of a filesystem.
+type FilesystemUsage struct {
+	Used, Total float64
+}
+
+// DiskStats are the cumulated bytes read from and written to a disk.
+type DiskStats struct {
+	ReadBytes, WrittenBytes uint64
+}
+
+// InterfaceStats are the cumulated counters of a network interface.
+type InterfaceStats struct {
+	RxBytes, RxErrors, RxDrops uint64
+	TxBytes, TxErrors, TxDrops uint64
+}
+
+// pseudoFilesystems are mounted from block devices, but are always full.
+var pseudoFilesystems = map[string]struct{}{
+	"squashfs": {},
+	"iso9660":  {},
+}
+
+// parseMounts parses /proc/PID/mounts, keeping the first mount point of each
+// block device: the others are bind mounts of the same filesystem.
+func parseMounts(contents []byte) []Mount {
+	mounts := []Mount{}
+	seen := map[string]struct{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		if _, ok := pseudoFilesystems[fields[2]]; ok {
+			continue
+		}
+		if _, ok := seen[fields[0]]; ok {
+			continue
+		}
+		seen[fields[0]] = struct{}{}
+		mounts = append(mounts, Mount{
+			Device:     fields[0],
+			MountPoint: unescapeMountPoint(fields[1]),
+			Type:       fields[2],
+		})
+	}
+	return mounts
+}
+
+// unescapeMountPoint undoes the octal escaping of spaces, tabs, newlines
+// and backslashes in /proc/PID/mounts.
+func unescapeMountPoint(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				buf.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// parseDiskStats parses /proc/diskstats, whose lines read like
+//
+//	8       0 sda 4242 0 1000 ... 2121 0 500 ...
+//
+// i.e. major, minor, name, then reads completed, reads merged, sectors read,
+// time spent reading, and the same for writes.
+func parseDiskStats(contents []byte) map[string]DiskStats {
+	disks := map[string]DiskStats{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		read, err1 := strconv.ParseUint(fields[5], 10, 64)
+		written, err2 := strconv.ParseUint(fields[9], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		disks[fields[2]] = DiskStats{
+			ReadBytes:    read * sectorSize,
+			WrittenBytes: written * sectorSize,
+		}
+	}
+	return disks
+}
+
+// parseNetDev parses /proc/net/dev, which after two lines of headers reads
+// like
+//
+//	eth0: 1234 10 0 0 0 0 0 0 5678 20 0 0 0 0 0 0
+//
+// i.e. the interface, then bytes, packets, errors, drops and four other
+// counters received, and the same transmitted.
+func parseNetDev(contents []byte) map[string]InterfaceStats {
+	interfaces := map[string]InterfaceStats{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 12 {
+			continue
+		}
+		counters := make([]uint64, 12)
+		var err error
+		for i := range counters {
+			if counters[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		interfaces[strings.TrimSpace(parts[0])] = InterfaceStats{
+			RxBytes:  counters[0],
+			RxErrors: counters[2],
+			RxDrops:  counters[3],
+			TxBytes:  counters[8],
+			TxErrors: counters[10],
+			TxDrops:  counters[11],
+		}
+	}
+	return interfaces
+}
+
+// deviceStats are the counters of the previous report, to compute rates.
+type deviceStats struct {
+	at         time.Time
+	disks      map[string]DiskStats
+	interfaces map[string]InterfaceStats
+}
+
+// deviceMetrics returns the metrics of the filesystems, disks and network
+// interfaces of the host, with their templates. Disk and network metrics are
+// rates since the previous report, so they are missing from the first one.
+func (r *Reporter) deviceMetrics(now time.Time) (report.Metrics, report.MetricTemplates) {
+	var (
+		metrics   = report.Metrics{}
+		templates = report.MetricTemplates{}
+	)
+	add := func(id, label, format string, priority float64, metric report.Metric) {
+		metrics[id] = metric
+		templates[id] = report.MetricTemplate{ID: id, Label: label, Format: format, Priority: priority}
+	}
+
+	filesystems := GetFilesystemUsage()
+	mountPoints := make([]string, 0, len(filesystems))
+	for mountPoint := range filesystems {
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	sort.Strings(mountPoints)
+	for i, mountPoint := range mountPoints {
+		usage := filesystems[mountPoint]
+		add(FilesystemUsagePrefix+mountPoint, "Disk "+mountPoint, report.FilesizeFormat, orderedPriority(21, i, len(mountPoints)),
+			report.MakeSingletonMetric(now, usage.Used).WithMax(usage.Total))
+	}
+
+	current := deviceStats{at: now, disks: GetDiskStats(), interfaces: GetInterfaceStats()}
+	r.Lock()
+	previous := r.previousDeviceStats
+	r.previousDeviceStats = current
+	r.Unlock()
+	seconds := current.at.Sub(previous.at).Seconds()
+	if previous.at.IsZero() || seconds <= 0 {
+		return metrics, templates
+	}
+	rate := func(current, previous uint64) (report.Metric, bool) {
+		if current < previous {
+			// The counter was reset
+			return report.Metric{}, false
+		}
+		return report.MakeSingletonMetric(now, float64(current-previous)/seconds), true
+	}
+
+	disks := make([]string, 0, len(current.disks))
+	for disk := range current.disks {
+		disks = append(disks, disk)
+	}
+	sort.Strings(disks)
+	for i, disk := range disks {
+		prev, ok := previous.disks[disk]
+		if !ok {
+			continue
+		}
+		var (
+			cur      = current.disks[disk]
+			priority = orderedPriority(31, i, len(disks))
+			step     = 0.1 / float64(len(disks))
+		)
+		if m, ok := rate(cur.ReadBytes, prev.ReadBytes); ok {
+			add(DiskReadPrefix+disk, disk+" read/s", report.FilesizeFormat, priority, m)
+		}
+		if m, ok := rate(cur.WrittenBytes, prev.WrittenBytes); ok {
+			add(DiskWritePrefix+disk, disk+" written/s", report.FilesizeFormat, priority+step, m)
+		}
+	}
+
+	interfaces := make([]string, 0, len(current.interfaces))
+	for iface := range current.interfaces {
+		interfaces = append(interfaces, iface)
+	}
+	sort.Strings(interfaces)
+	for i, iface := range interfaces {
+		prev, ok := previous.interfaces[iface]
+		if !ok {
+			continue
+		}
+		var (
+			cur      = current.interfaces[iface]
+			priority = orderedPriority(41, i, len(interfaces))
+			step     = 0.1 / float64(len(interfaces))
+		)
+		if m, ok := rate(cur.RxBytes, prev.RxBytes); ok {
+			add(NetRxPrefix+iface, iface+" received/s", report.FilesizeFormat, priority, m)
+		}
+		if m, ok := rate(cur.TxBytes, prev.TxBytes); ok {
+			add(NetTxPrefix+iface, iface+" sent/s", report.FilesizeFormat, priority+step, m)
+		}
+		if m, ok := rate(cur.RxErrors+cur.TxErrors, prev.RxErrors+prev.TxErrors); ok {
+			add(NetErrorsPrefix+iface, iface+" errors/s", report.DefaultFormat, priority+2*step, m)
+		}
+		if m, ok := rate(cur.RxDrops+cur.TxDrops, prev.RxDrops+prev.TxDrops); ok {
+			add(NetDropsPrefix+iface, iface+" drops/s", report.DefaultFormat, priority+3*step, m)
+		}
+	}
+	return metrics, templates
+}
+
+// orderedPriority spreads the priorities of n metrics of a kind between base
+// and base+1, so that they are shown together, in order.
+func orderedPriority(base float64, i, n int) float64 {
+	return base + float64(i)/float64(n)
+}
@@ -0,0 +1,121 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+const (
+	mountsFixture = `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime,errors=remount-ro 0 0
+/dev/sdb1 /var/lib/docker xfs rw,relatime 0 0
+/dev/loop0 /snap/core/1234 squashfs ro,nodev,relatime 0 0
+/dev/sda1 /var/lib/kubelet/pods/abc/volume ext4 rw,relatime 0 0
+/dev/sdc1 /mnt/my\040disk ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,noexec,relatime,size=817584k,mode=755 0 0
+`
+	diskStatsFixture = `   7       0 loop0 50 0 2000 12 0 0 0 0 0 16 12 0 0 0 0
+   8       0 sda 4242 12 1000 3000 2121 34 500 4000 0 5000 7000 0 0 0 0
+   8       1 sda1 4000 12 900 2900 2100 34 480 3900 0 4900 6800 0 0 0 0
+ 259       0 nvme0n1 100 0 8 10 200 0 16 20 0 30 30 0 0 0 0
+`
+	netDevFixture = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  123456     100    0    0    0     0          0         0   123456     100    0    0    0     0       0          0
+  eth0: 1000000    1000    2    3    0     0          0         0   500000     800    4    5    0     0       0          0
+`
+)
+
+func TestParseMounts(t *testing.T) {
+	want := []Mount{
+		{Device: "/dev/sda1", MountPoint: "/", Type: "ext4"},
+		{Device: "/dev/sdb1", MountPoint: "/var/lib/docker", Type: "xfs"},
+		{Device: "/dev/sdc1", MountPoint: "/mnt/my disk", Type: "ext4"},
+	}
+	if have := parseMounts([]byte(mountsFixture)); !reflect.DeepEqual(want, have) {
+		t.Errorf("Unexpected mounts:\n%v\n!=\n%v", have, want)
+	}
+}
+
+func TestParseDiskStats(t *testing.T) {
+	want := map[string]DiskStats{
+		"loop0":   {ReadBytes: 2000 * 512, WrittenBytes: 0},
+		"sda":     {ReadBytes: 1000 * 512, WrittenBytes: 500 * 512},
+		"sda1":    {ReadBytes: 900 * 512, WrittenBytes: 480 * 512},
+		"nvme0n1": {ReadBytes: 8 * 512, WrittenBytes: 16 * 512},
+	}
+	if have := parseDiskStats([]byte(diskStatsFixture)); !reflect.DeepEqual(want, have) {
+		t.Errorf("Unexpected disk stats:\n%v\n!=\n%v", have, want)
+	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	want := map[string]InterfaceStats{
+		"lo":   {RxBytes: 123456, TxBytes: 123456},
+		"eth0": {RxBytes: 1000000, RxErrors: 2, RxDrops: 3, TxBytes: 500000, TxErrors: 4, TxDrops: 5},
+	}
+	if have := parseNetDev([]byte(netDevFixture)); !reflect.DeepEqual(want, have) {
+		t.Errorf("Unexpected interface stats:\n%v\n!=\n%v", have, want)
+	}
+}
+
+func TestDeviceMetrics(t *testing.T) {
+	var (
+		oldGetFilesystemUsage = GetFilesystemUsage
+		oldGetDiskStats       = GetDiskStats
+		oldGetInterfaceStats  = GetInterfaceStats
+	)
+	defer func() {
+		GetFilesystemUsage = oldGetFilesystemUsage
+		GetDiskStats = oldGetDiskStats
+		GetInterfaceStats = oldGetInterfaceStats
+	}()
+
+	disks := map[string]DiskStats{"sda": {ReadBytes: 1000, WrittenBytes: 2000}}
+	interfaces := map[string]InterfaceStats{"eth0": {RxBytes: 1000, TxBytes: 1000, RxErrors: 1, TxDrops: 2}}
+	GetFilesystemUsage = func() map[string]FilesystemUsage {
+		return map[string]FilesystemUsage{"/": {Used: 90, Total: 100}}
+	}
+	GetDiskStats = func() map[string]DiskStats { return disks }
+	GetInterfaceStats = func() map[string]InterfaceStats { return interfaces }
+
+	r := &Reporter{}
+	start := time.Now()
+	metrics, templates := r.deviceMetrics(start)
+	if want := []string{FilesystemUsagePrefix + "/"}; len(metrics) != 1 || len(templates) != 1 {
+		t.Fatalf("Expected only %v before rates can be computed, got %v", want, metrics)
+	}
+	if sample, _ := metrics[FilesystemUsagePrefix+"/"].LastSample(); sample.Value != 90 {
+		t.Errorf("Expected 90 bytes used, got %v", sample.Value)
+	}
+
+	disks = map[string]DiskStats{"sda": {ReadBytes: 3000, WrittenBytes: 2000}}
+	interfaces = map[string]InterfaceStats{"eth0": {RxBytes: 5000, TxBytes: 500, RxErrors: 3, TxDrops: 4}}
+	metrics, templates = r.deviceMetrics(start.Add(2 * time.Second))
+	for id, want := range map[string]float64{
+		FilesystemUsagePrefix + "/": 90,
+		DiskReadPrefix + "sda":      1000,
+		DiskWritePrefix + "sda":     0,
+		NetRxPrefix + "eth0":        2000,
+		NetErrorsPrefix + "eth0":    1,
+		NetDropsPrefix + "eth0":     1,
+	} {
+		if sample, ok := metrics[id].LastSample(); !ok || sample.Value != want {
+			t.Errorf("Expected %s to be %v, got %v", id, want, sample.Value)
+		}
+		if _, ok := templates[id]; !ok {
+			t.Errorf("Expected template of %s", id)
+		}
+	}
+	// The transmit counter went backwards
+	if _, ok := metrics[NetTxPrefix+"eth0"]; ok {
+		t.Errorf("Expected no rate for a reset counter")
+	}
+	if want, have := report.FilesizeFormat, templates[DiskReadPrefix+"sda"].Format; want != have {
+		t.Errorf("Expected format %q, got %q", want, have)
+	}
+}
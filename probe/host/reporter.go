@@ -26,12 +26,31 @@ const (
 	ScopeVersion  = "host_scope_version"
 )
 
+// Prefixes of the keys of device metrics in Node.Metrics, followed by the
+// mount point, disk or network interface.
+const (
+	FilesystemUsagePrefix = "host_fs_usage_bytes_"
+	DiskReadPrefix        = "host_disk_read_bytes_per_second_"
+	DiskWritePrefix       = "host_disk_write_bytes_per_second_"
+	NetRxPrefix           = "host_net_rx_bytes_per_second_"
+	NetTxPrefix           = "host_net_tx_bytes_per_second_"
+	NetErrorsPrefix       = "host_net_errors_per_second_"
+	NetDropsPrefix        = "host_net_drops_per_second_"
+)
+
 // Exposed for testing.
 const (
 	ProcUptime  = "/proc/uptime"
 	ProcLoad    = "/proc/loadavg"
 	ProcStat    = "/proc/stat"
 	ProcMemInfo = "/proc/meminfo"
+
+	ProcMounts    = "/proc/1/mounts"
+	ProcRoot      = "/proc/1/root"
+	ProcDiskStats = "/proc/diskstats"
+	ProcNetDev    = "/proc/net/dev"
+	SysBlock      = "/sys/block"
+	SysVirtualNet = "/sys/devices/virtual/net"
 )
 
 // Exposed for testing.
@@ -63,6 +82,8 @@ type Reporter struct {
 	hostShellCmd    []string
 	handlerRegistry *controls.HandlerRegistry
 	pipeIDToTTY     map[string]uintptr
+
+	previousDeviceStats deviceStats
 }
 
 // NewReporter returns a Reporter which produces a report containing host
@@ -114,16 +135,19 @@ func (r *Reporter) Report() (report.Report, error) {
 	}
 	kernel := fmt.Sprintf("%s %s", kernelRelease, kernelVersion)
 
-	rep.Host = rep.Host.WithMetadataTemplates(MetadataTemplates)
-	rep.Host = rep.Host.WithMetricTemplates(MetricTemplates)
-
 	now := mtime.Now()
-	metrics := GetLoad(now)
+	metrics, deviceTemplates := r.deviceMetrics(now)
+	for id, metric := range GetLoad(now) {
+		metrics[id] = metric
+	}
 	cpuUsage, max := GetCPUUsagePercent()
 	metrics[CPUUsage] = report.MakeSingletonMetric(now, cpuUsage).WithMax(max)
 	memoryUsage, max := GetMemoryUsageBytes()
 	metrics[MemoryUsage] = report.MakeSingletonMetric(now, memoryUsage).WithMax(max)
 
+	rep.Host = rep.Host.WithMetadataTemplates(MetadataTemplates)
+	rep.Host = rep.Host.WithMetricTemplates(MetricTemplates.Merge(deviceTemplates))
+
 	rep.Host.AddNode(
 		report.MakeNodeWith(report.MakeHostNodeID(r.hostID), map[string]string{
 			report.ControlProbeID: r.probeID,
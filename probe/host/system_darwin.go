@@ -84,3 +84,20 @@ var GetCPUUsagePercent = func() (float64, float64) {
 var GetMemoryUsageBytes = func() (float64, float64) {
 	return 0.0, 0.0
 }
+
+// GetFilesystemUsage returns the used and total bytes of the filesystems of
+// the host, by mount point
+var GetFilesystemUsage = func() map[string]FilesystemUsage {
+	return nil
+}
+
+// GetDiskStats returns the cumulated IO of the disks of the host
+var GetDiskStats = func() map[string]DiskStats {
+	return nil
+}
+
+// GetInterfaceStats returns the cumulated counters of the network interfaces
+// of the host
+var GetInterfaceStats = func() map[string]InterfaceStats {
+	return nil
+}
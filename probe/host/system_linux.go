@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -108,3 +110,62 @@ var GetMemoryUsageBytes = func() (float64, float64) {
 	used := meminfo.MemTotal - meminfo.MemFree - meminfo.Buffers - meminfo.Cached
 	return float64(used * kb), float64(meminfo.MemTotal * kb)
 }
+
+// GetFilesystemUsage returns the used and total bytes of the filesystems
+// mounted from block devices on the host, by mount point. Mounts are read
+// from init's mount namespace, so that this works in a container sharing the
+// host's PID namespace.
+var GetFilesystemUsage = func() map[string]FilesystemUsage {
+	contents, err := ioutil.ReadFile(ProcMounts)
+	if err != nil {
+		return nil
+	}
+	usage := map[string]FilesystemUsage{}
+	for _, mount := range parseMounts(contents) {
+		var stat unix.Statfs_t
+		if err := unix.Statfs(filepath.Join(ProcRoot, mount.MountPoint), &stat); err != nil {
+			continue
+		}
+		total := stat.Blocks * uint64(stat.Bsize)
+		// Like df, count the blocks reserved to root as used
+		free := stat.Bavail * uint64(stat.Bsize)
+		if total == 0 {
+			continue
+		}
+		usage[mount.MountPoint] = FilesystemUsage{Used: float64(total - free), Total: float64(total)}
+	}
+	return usage
+}
+
+// GetDiskStats returns the cumulated IO of the physical disks of the host,
+// i.e. not of partitions, nor of loop, RAM or device mapper devices.
+var GetDiskStats = func() map[string]DiskStats {
+	contents, err := ioutil.ReadFile(ProcDiskStats)
+	if err != nil {
+		return nil
+	}
+	disks := parseDiskStats(contents)
+	for name := range disks {
+		if _, err := os.Stat(filepath.Join(SysBlock, name, "device")); err != nil {
+			delete(disks, name)
+		}
+	}
+	return disks
+}
+
+// GetInterfaceStats returns the cumulated counters of the network
+// interfaces of the host, except virtual ones like loopback, bridges and
+// veths.
+var GetInterfaceStats = func() map[string]InterfaceStats {
+	contents, err := ioutil.ReadFile(ProcNetDev)
+	if err != nil {
+		return nil
+	}
+	interfaces := parseNetDev(contents)
+	for name := range interfaces {
+		if _, err := os.Stat(filepath.Join(SysVirtualNet, name)); err == nil {
+			delete(interfaces, name)
+		}
+	}
+	return interfaces
+}
@@ -40,7 +40,7 @@ type Client interface {
 
 	WatchPods(f func(Event, Pod))
 
-	GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error)
+	GetLogs(namespaceID, podID string, containerNames []string, options LogOptions) (map[io.ReadCloser]string, error)
 	DeletePod(namespaceID, podID string) error
 	ScaleUp(resource, namespaceID, id string) error
 	ScaleDown(resource, namespaceID, id string) error
@@ -342,17 +342,28 @@ func (c *client) WalkNamespaces(f func(NamespaceResource) error) error {
 	return nil
 }
 
-func (c *client) GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error) {
+// GetLogs returns the log streams of the containers of a pod, with the names
+// of their containers.
+func (c *client) GetLogs(namespaceID, podID string, containerNames []string, options LogOptions) (map[io.ReadCloser]string, error) {
 	readClosersWithLabel := map[io.ReadCloser]string{}
 	for _, container := range containerNames {
-		req := c.client.CoreV1().Pods(namespaceID).GetLogs(
-			podID,
-			&apiv1.PodLogOptions{
-				Follow:     true,
-				Timestamps: true,
-				Container:  container,
-			},
-		)
+		podLogOptions := &apiv1.PodLogOptions{
+			Follow:     true,
+			Timestamps: options.Timestamps,
+			Container:  container,
+		}
+		if options.Since > 0 {
+			sinceSeconds := int64(options.Since / time.Second)
+			if sinceSeconds < 1 {
+				sinceSeconds = 1
+			}
+			podLogOptions.SinceSeconds = &sinceSeconds
+		}
+		if options.Tail >= 0 {
+			tailLines := options.Tail
+			podLogOptions.TailLines = &tailLines
+		}
+		req := c.client.CoreV1().Pods(namespaceID).GetLogs(podID, podLogOptions)
 		readCloser, err := req.Stream()
 		if err != nil {
 			for rc := range readClosersWithLabel {
@@ -363,7 +374,7 @@ func (c *client) GetLogs(namespaceID, podID string, containerNames []string) (io
 		readClosersWithLabel[readCloser] = container
 	}
 
-	return NewLogReadCloser(readClosersWithLabel), nil
+	return readClosersWithLabel, nil
 }
 
 func (c *client) DeletePod(namespaceID, podID string) error {
@@ -1,8 +1,14 @@
 package kubernetes
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"regexp"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/controls"
@@ -17,13 +23,109 @@ const (
 	ScaleDown = report.KubernetesScaleDown
 )
 
+// LogOptions are the options of the logs controls, parsed from the control
+// arguments of requests.
+type LogOptions struct {
+	Since      time.Duration  // only logs newer than this, if not zero
+	Tail       int64          // only this many of the latest lines, if not negative
+	Timestamps bool           // prefix lines with their timestamp
+	Grep       *regexp.Regexp // only lines matching this, if not nil
+}
+
+// ParseLogOptions parses the since, tail, grep and timestamps control
+// arguments.
+func ParseLogOptions(args map[string]string) (LogOptions, error) {
+	options := LogOptions{Tail: -1, Timestamps: true}
+	if s, ok := args["since"]; ok {
+		since, err := time.ParseDuration(s)
+		if err != nil || since < 0 {
+			return options, fmt.Errorf("Invalid since: %s", s)
+		}
+		options.Since = since
+	}
+	if s, ok := args["tail"]; ok {
+		tail, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || tail < 0 {
+			return options, fmt.Errorf("Invalid tail: %s", s)
+		}
+		options.Tail = tail
+	}
+	if s, ok := args["timestamps"]; ok {
+		timestamps, err := strconv.ParseBool(s)
+		if err != nil {
+			return options, fmt.Errorf("Invalid timestamps: %s", s)
+		}
+		options.Timestamps = timestamps
+	}
+	if s, ok := args["grep"]; ok && s != "" {
+		grep, err := regexp.Compile(s)
+		if err != nil {
+			return options, fmt.Errorf("Invalid grep: %v", err)
+		}
+		options.Grep = grep
+	}
+	return options, nil
+}
+
 // GetLogs is the control to get the logs for a kubernetes pod
 func (r *Reporter) GetLogs(req xfer.Request, namespaceID, podID string, containerNames []string) xfer.Response {
-	readCloser, err := r.client.GetLogs(namespaceID, podID, containerNames)
+	return r.streamLogs(req, []podLogs{{namespaceID, podID, containerNames}})
+}
+
+// GetPodsLogs is the control to get the interleaved logs of the pods of a
+// kubernetes service, deployment, daemonset or statefulset. Lines are
+// prefixed with the name of their pod when there are several.
+func (r *Reporter) GetPodsLogs(req xfer.Request, pods []Pod) xfer.Response {
+	if len(pods) == 0 {
+		return xfer.ResponseErrorf("No pods found: %s", req.NodeID)
+	}
+	logs := make([]podLogs, 0, len(pods))
+	for _, pod := range pods {
+		logs = append(logs, podLogs{pod.Namespace(), pod.Name(), pod.ContainerNames()})
+	}
+	return r.streamLogs(req, logs)
+}
+
+// podLogs identifies the logs of the containers of a pod
+type podLogs struct {
+	namespace, name string
+	containerNames  []string
+}
+
+// streamLogs pipes the logs of the containers of pods to the app, filtered
+// and merged. Lines are labelled with their container for a single pod, and
+// with their pod, and container if it has several, for several pods.
+func (r *Reporter) streamLogs(req xfer.Request, pods []podLogs) xfer.Response {
+	options, err := ParseLogOptions(req.ControlArgs)
 	if err != nil {
 		return xfer.ResponseError(err)
 	}
 
+	readClosersWithLabel := map[io.ReadCloser]string{}
+	for _, pod := range pods {
+		containers, err := r.client.GetLogs(pod.namespace, pod.name, pod.containerNames, options)
+		if err != nil {
+			for rc := range readClosersWithLabel {
+				rc.Close()
+			}
+			return xfer.ResponseError(err)
+		}
+		for readCloser, container := range containers {
+			label := container
+			if len(pods) > 1 {
+				label = pod.name
+				if len(containers) > 1 {
+					label += "/" + container
+				}
+			}
+			if options.Grep != nil {
+				readCloser = NewGrepReadCloser(readCloser, options.Grep)
+			}
+			readClosersWithLabel[readCloser] = label
+		}
+	}
+	readCloser := NewLogReadCloser(readClosersWithLabel)
+
 	readWriter := struct {
 		io.Reader
 		io.Writer
@@ -74,6 +176,84 @@ func (r *Reporter) CapturePod(f func(xfer.Request, string, string, []string) xfe
 	}
 }
 
+// CapturePods is exported for testing
+func (r *Reporter) CapturePods(f func(xfer.Request, []Pod) xfer.Response) func(xfer.Request) xfer.Response {
+	return func(req xfer.Request) xfer.Response {
+		namespace, selector, err := r.podSelector(req.NodeID)
+		if err != nil {
+			return xfer.ResponseError(err)
+		}
+		pods := []Pod{}
+		r.client.WalkPods(func(p Pod) error {
+			if p.Namespace() == namespace && selector.Matches(labels.Set(p.Labels())) {
+				pods = append(pods, p)
+			}
+			return nil
+		})
+		return f(req, pods)
+	}
+}
+
+// podSelector returns the namespace and selector of the pods of the service,
+// deployment, daemonset or statefulset with a node ID.
+func (r *Reporter) podSelector(nodeID string) (string, labels.Selector, error) {
+	var (
+		namespace string
+		selector  labels.Selector
+		err       error
+	)
+	if uid, ok := report.ParseServiceNodeID(nodeID); ok {
+		r.client.WalkServices(func(s Service) error {
+			if s.UID() == uid {
+				namespace, selector = s.Namespace(), s.Selector()
+			}
+			return nil
+		})
+	} else if uid, ok := report.ParseDeploymentNodeID(nodeID); ok {
+		r.client.WalkDeployments(func(d Deployment) error {
+			if d.UID() == uid {
+				namespace = d.Namespace()
+				selector, err = d.Selector()
+			}
+			return nil
+		})
+	} else if uid, ok := report.ParseDaemonSetNodeID(nodeID); ok {
+		r.client.WalkDaemonSets(func(d DaemonSet) error {
+			if d.UID() == uid {
+				namespace = d.Namespace()
+				selector, err = d.Selector()
+			}
+			return nil
+		})
+	} else if uid, ok := report.ParseStatefulSetNodeID(nodeID); ok {
+		r.client.WalkStatefulSets(func(s StatefulSet) error {
+			if s.UID() == uid {
+				namespace = s.Namespace()
+				selector, err = s.Selector()
+			}
+			return nil
+		})
+	} else {
+		return "", nil, fmt.Errorf("Invalid ID: %s", nodeID)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if selector == nil {
+		return "", nil, fmt.Errorf("Not found: %s", nodeID)
+	}
+	return namespace, selector, nil
+}
+
+// getLogs dispatches the logs control to pods, or to the pods of the other
+// resources.
+func (r *Reporter) getLogs(req xfer.Request) xfer.Response {
+	if _, ok := report.ParsePodNodeID(req.NodeID); ok {
+		return r.CapturePod(r.GetLogs)(req)
+	}
+	return r.CapturePods(r.GetPodsLogs)(req)
+}
+
 // CaptureDeployment is exported for testing
 func (r *Reporter) CaptureDeployment(f func(xfer.Request, string, string) xfer.Response) func(xfer.Request) xfer.Response {
 	return func(req xfer.Request) xfer.Response {
@@ -107,7 +287,7 @@ func (r *Reporter) ScaleDown(req xfer.Request, namespace, id string) xfer.Respon
 
 func (r *Reporter) registerControls() {
 	controls := map[string]xfer.ControlHandlerFunc{
-		GetLogs:   r.CapturePod(r.GetLogs),
+		GetLogs:   r.getLogs,
 		DeletePod: r.CapturePod(r.deletePod),
 		ScaleUp:   r.CaptureDeployment(r.ScaleUp),
 		ScaleDown: r.CaptureDeployment(r.ScaleDown),
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"regexp"
 	"sync"
 )
 
@@ -17,6 +18,8 @@ type logReadCloser struct {
 	buffer      bytes.Buffer
 	dataChannel chan []byte
 	eofChannel  chan int
+	done        chan struct{}
+	closeOnce   sync.Once
 	wg          sync.WaitGroup
 }
 
@@ -42,6 +45,7 @@ func NewLogReadCloser(readClosersWithLabel map[io.ReadCloser]string) io.ReadClos
 		labelLength: labelLength,
 		dataChannel: make(chan []byte),
 		eofChannel:  make(chan int),
+		done:        make(chan struct{}),
 		eof:         make([]bool, len(readClosers)),
 	}
 
@@ -78,6 +82,8 @@ func (l *logReadCloser) Read(p []byte) (int, error) {
 				received = true
 			case idx := <-l.eofChannel:
 				l.eof[idx] = true
+			case <-l.done:
+				return 0, io.EOF
 			}
 		}
 	}
@@ -98,19 +104,20 @@ func (l *logReadCloser) Read(p []byte) (int, error) {
 	return l.readInternalBuffer(p[byteCount:])
 }
 
+// Close stops reading, and closes all the readers. Readers still sending
+// lines give up once done is closed, so Close doesn't wait for a Read.
 func (l *logReadCloser) Close() error {
-	for _, rc := range l.readClosers {
-		err := rc.Close()
-		if err != nil {
-			return err
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		for _, rc := range l.readClosers {
+			if closeErr := rc.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
 		}
-	}
-
-	l.wg.Wait()
-
-	close(l.dataChannel)
-	close(l.eofChannel)
-	return nil
+		l.wg.Wait()
+	})
+	return err
 }
 
 func (l *logReadCloser) readInternalBuffer(p []byte) (int, error) {
@@ -128,7 +135,7 @@ func (l *logReadCloser) readInput(idx int) {
 		line, err := reader.ReadBytes('\n')
 		if err == io.EOF {
 			if len(line) > 0 {
-				l.dataChannel <- l.annotateLine(idx, line)
+				l.send(l.annotateLine(idx, line))
 			}
 			break
 		}
@@ -136,10 +143,25 @@ func (l *logReadCloser) readInput(idx int) {
 			// error, exit
 			break
 		}
-		l.dataChannel <- l.annotateLine(idx, line)
+		if !l.send(l.annotateLine(idx, line)) {
+			return
+		}
 	}
 
-	l.eofChannel <- idx
+	select {
+	case l.eofChannel <- idx:
+	case <-l.done:
+	}
+}
+
+// send passes a line on to Read, unless closed.
+func (l *logReadCloser) send(line []byte) bool {
+	select {
+	case l.dataChannel <- line:
+		return true
+	case <-l.done:
+		return false
+	}
 }
 
 func (l *logReadCloser) annotateLine(idx int, line []byte) []byte {
@@ -158,3 +180,39 @@ func (l *logReadCloser) isEOF() bool {
 	}
 	return true
 }
+
+type grepReadCloser struct {
+	pipeReader *io.PipeReader
+	readCloser io.ReadCloser
+}
+
+// NewGrepReadCloser reads the lines of an io.ReadCloser matching a pattern,
+// dropping the others
+func NewGrepReadCloser(readCloser io.ReadCloser, pattern *regexp.Regexp) io.ReadCloser {
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(readCloser)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 && pattern.Match(line) {
+				if _, err := pipeWriter.Write(line); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				pipeWriter.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return &grepReadCloser{pipeReader: pipeReader, readCloser: readCloser}
+}
+
+func (g *grepReadCloser) Read(p []byte) (int, error) {
+	return g.pipeReader.Read(p)
+}
+
+func (g *grepReadCloser) Close() error {
+	g.pipeReader.Close()
+	return g.readCloser.Close()
+}
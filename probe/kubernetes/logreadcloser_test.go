@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/probe/kubernetes"
 )
@@ -70,6 +71,46 @@ func TestLogReadCloser(t *testing.T) {
 	}
 }
 
+func TestLogReadCloserCloseWhileSending(t *testing.T) {
+	// Endless logs, which stop once closed
+	readClosersWithLabel := map[io.ReadCloser]string{}
+	for _, label := range []string{"zero", "one"} {
+		r, w := io.Pipe()
+		go func() {
+			for {
+				if _, err := w.Write([]byte("line\n")); err != nil {
+					return
+				}
+			}
+		}()
+		readClosersWithLabel[r] = label
+	}
+	l := kubernetes.NewLogReadCloser(readClosersWithLabel)
+	if _, err := l.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing doesn't wait for the lines still being sent to be read
+	closed := make(chan error)
+	go func() { closed <- l.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close must not return an error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close deadlocked")
+	}
+	// What was read already is still returned
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		_, err = l.Read(make([]byte, 10))
+	}
+	if err != io.EOF {
+		t.Errorf("Expected EOF once closed, got %v", err)
+	}
+}
+
 func lineCounter(counter map[string]int, pad int, label string, data []byte) {
 	for _, str := range strings.SplitAfter(string(data), "\n") {
 		if len(str) == 0 {
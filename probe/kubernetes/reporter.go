@@ -107,6 +107,15 @@ var (
 		},
 	}
 
+	// LogsControl is the control to get the logs of pods, or of the pods of
+	// services, deployments, daemonsets and statefulsets
+	LogsControl = report.Control{
+		ID:    GetLogs,
+		Human: "Get logs",
+		Icon:  "fa-desktop",
+		Rank:  0,
+	}
+
 	ScalingControls = []report.Control{
 		{
 			ID:    ScaleDown,
@@ -133,19 +142,21 @@ type Reporter struct {
 	handlerRegistry *controls.HandlerRegistry
 	nodeName        string
 	kubeletPort     uint
+	kubeletHealth   *probe.HealthRecorder
 }
 
 // NewReporter makes a new Reporter
-func NewReporter(client Client, pipes controls.PipeClient, probeID string, hostID string, probe *probe.Probe, handlerRegistry *controls.HandlerRegistry, nodeName string, kubeletPort uint) *Reporter {
+func NewReporter(client Client, pipes controls.PipeClient, probeID string, hostID string, p *probe.Probe, handlerRegistry *controls.HandlerRegistry, nodeName string, kubeletPort uint) *Reporter {
 	reporter := &Reporter{
 		client:          client,
 		pipes:           pipes,
 		probeID:         probeID,
-		probe:           probe,
+		probe:           p,
 		hostID:          hostID,
 		handlerRegistry: handlerRegistry,
 		nodeName:        nodeName,
 		kubeletPort:     kubeletPort,
+		kubeletHealth:   probe.NewHealthRecorder("kubelet"),
 	}
 	reporter.registerControls()
 	client.WatchPods(reporter.podEvent)
@@ -160,6 +171,15 @@ func (r *Reporter) Stop() {
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "K8s" }
 
+// Health implements probe.HealthChecker. Kubelet is only asked for the local
+// pods when the node name is unknown.
+func (r *Reporter) Health() []probe.Health {
+	if r.nodeName != "" {
+		return nil
+	}
+	return []probe.Health{r.kubeletHealth.Health()}
+}
+
 func (r *Reporter) podEvent(e Event, pod Pod) {
 	switch e {
 	case ADD:
@@ -278,6 +298,7 @@ func (r *Reporter) serviceTopology() (report.Topology, []Service, error) {
 			WithTableTemplates(TableTemplates)
 		services = []Service{}
 	)
+	result.Controls.AddControl(LogsControl)
 	err := r.client.WalkServices(func(s Service) error {
 		result.AddNode(s.GetNode(r.probeID))
 		services = append(services, s)
@@ -325,6 +346,7 @@ func (r *Reporter) deploymentTopology() (report.Topology, []Deployment, error) {
 		deployments = []Deployment{}
 	)
 	result.Controls.AddControls(ScalingControls)
+	result.Controls.AddControl(LogsControl)
 
 	err := r.client.WalkDeployments(func(d Deployment) error {
 		result.AddNode(d.GetNode(r.probeID))
@@ -340,6 +362,7 @@ func (r *Reporter) daemonSetTopology() (report.Topology, []DaemonSet, error) {
 		WithMetadataTemplates(DaemonSetMetadataTemplates).
 		WithMetricTemplates(DaemonSetMetricTemplates).
 		WithTableTemplates(TableTemplates)
+	result.Controls.AddControl(LogsControl)
 	err := r.client.WalkDaemonSets(func(d DaemonSet) error {
 		result.AddNode(d.GetNode(r.probeID))
 		daemonSets = append(daemonSets, d)
@@ -354,6 +377,7 @@ func (r *Reporter) statefulSetTopology() (report.Topology, []StatefulSet, error)
 		WithMetadataTemplates(StatefulSetMetadataTemplates).
 		WithMetricTemplates(StatefulSetMetricTemplates).
 		WithTableTemplates(TableTemplates)
+	result.Controls.AddControl(LogsControl)
 	err := r.client.WalkStatefulSets(func(s StatefulSet) error {
 		result.AddNode(s.GetNode(r.probeID))
 		statefulSets = append(statefulSets, s)
@@ -399,12 +423,7 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 			WithTableTemplates(TableTemplates)
 		selectors = []func(labelledChild){}
 	)
-	pods.Controls.AddControl(report.Control{
-		ID:    GetLogs,
-		Human: "Get logs",
-		Icon:  "fa-desktop",
-		Rank:  0,
-	})
+	pods.Controls.AddControl(LogsControl)
 	pods.Controls.AddControl(report.Control{
 		ID:    DeletePod,
 		Human: "Delete",
@@ -475,6 +494,7 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 		// We don't know the node name: fall back to obtaining the local pods from kubelet
 		var err error
 		localPodUIDs, err = GetLocalPodUIDs(fmt.Sprintf("127.0.0.1:%d", r.kubeletPort))
+		r.kubeletHealth.Record(err)
 		if err != nil {
 			log.Warnf("No node name and cannot obtain local pods, reporting all (which may impact performance): %v", err)
 		}
@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
@@ -111,9 +114,10 @@ func newMockClient() *mockClient {
 }
 
 type mockClient struct {
-	pods     []kubernetes.Pod
-	services []kubernetes.Service
-	logs     map[string]io.ReadCloser
+	pods       []kubernetes.Pod
+	services   []kubernetes.Service
+	logs       map[string]io.ReadCloser
+	logOptions kubernetes.LogOptions
 }
 
 func (c *mockClient) Stop() {}
@@ -149,12 +153,13 @@ func (c *mockClient) WalkNamespaces(f func(kubernetes.NamespaceResource) error)
 	return nil
 }
 func (*mockClient) WatchPods(func(kubernetes.Event, kubernetes.Pod)) {}
-func (c *mockClient) GetLogs(namespaceID, podName string, _ []string) (io.ReadCloser, error) {
+func (c *mockClient) GetLogs(namespaceID, podName string, _ []string, options kubernetes.LogOptions) (map[io.ReadCloser]string, error) {
+	c.logOptions = options
 	r, ok := c.logs[namespaceID+";"+podName]
 	if !ok {
 		return nil, fmt.Errorf("Not found")
 	}
-	return r, nil
+	return map[io.ReadCloser]string{r: "container"}, nil
 }
 func (c *mockClient) DeletePod(namespaceID, podID string) error {
 	return nil
@@ -273,6 +278,32 @@ func TestReporter(t *testing.T) {
 
 }
 
+func TestReporterKubeletHealth(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(newMockClient(), nil, "probe-id", "foo", nil, hr, "", 0)
+	defer reporter.Stop()
+	if _, err := reporter.Report(); err != nil {
+		t.Fatal(err)
+	}
+	health := reporter.Health()
+	if len(health) != 1 || health[0].Component != "kubelet" || health[0].State != probe.HealthFailing || health[0].Error != "connection refused" {
+		t.Errorf("Expected kubelet to be failing, got %v", health)
+	}
+
+	// With a node name, kubelet isn't asked for the local pods
+	reporter = kubernetes.NewReporter(newMockClient(), nil, "probe-id", "foo", nil, controls.NewDefaultHandlerRegistry(), "node", 0)
+	defer reporter.Stop()
+	if health := reporter.Health(); len(health) != 0 {
+		t.Errorf("Expected no kubelet health, got %v", health)
+	}
+}
+
 func TestTagger(t *testing.T) {
 	rpt := report.MakeReport()
 	rpt.Container.AddNode(report.MakeNodeWith("container1", map[string]string{
@@ -377,3 +408,72 @@ func TestReporterGetLogs(t *testing.T) {
 		t.Errorf("Expected pipe to close the underlying log stream")
 	}
 }
+
+func TestReporterGetPodsLogs(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return map[string]struct{}{}, nil
+	}
+
+	client := newMockClient()
+	pipes := mockPipeClient{}
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(client, pipes, "", "", nil, hr, "", 0)
+
+	// Should error on unknown resources
+	resp := reporter.CapturePods(reporter.GetPodsLogs)(xfer.Request{
+		NodeID:  report.MakeServiceNodeID("notfound"),
+		Control: kubernetes.GetLogs,
+	})
+	if want := "Not found: " + report.MakeServiceNodeID("notfound"); resp.Error != want {
+		t.Errorf("Expected error %q, got %q", want, resp.Error)
+	}
+
+	// Should merge the logs of the pods of a service, filtered
+	client.logs["ping;pong-a"] = ioutil.NopCloser(strings.NewReader("a: GET /\na: ERROR oops\n"))
+	client.logs["ping;pong-b"] = ioutil.NopCloser(strings.NewReader("b: ERROR boom\nb: GET /\n"))
+	resp = reporter.CapturePods(reporter.GetPodsLogs)(xfer.Request{
+		AppID:       "appID",
+		NodeID:      report.MakeServiceNodeID(serviceUID),
+		Control:     kubernetes.GetLogs,
+		ControlArgs: map[string]string{"grep": "ERROR", "tail": "10", "since": "1h", "timestamps": "false"},
+	})
+	pipe, ok := pipes[resp.Pipe]
+	if !ok {
+		t.Fatalf("Expected a pipe to have been created, got %#v", resp)
+	}
+	want := kubernetes.LogOptions{Since: time.Hour, Tail: 10, Timestamps: false}
+	if have := client.logOptions; have.Since != want.Since || have.Tail != want.Tail || have.Timestamps != want.Timestamps {
+		t.Errorf("Expected log options %v, got %v", want, have)
+	}
+	_, readWriter := pipe.Ends()
+	contents, err := ioutil.ReadAll(readWriter)
+	if err != nil {
+		t.Error(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	sort.Strings(lines)
+	if want := []string{"[pong-a] a: ERROR oops", "[pong-b] b: ERROR boom"}; !reflect.DeepEqual(want, lines) {
+		t.Errorf("Expected pipe to contain %q, but got %q", want, lines)
+	}
+	pipe.Close()
+}
+
+func TestParseLogOptions(t *testing.T) {
+	options, err := kubernetes.ParseLogOptions(map[string]string{})
+	if err != nil || options.Tail != -1 || !options.Timestamps || options.Since != 0 || options.Grep != nil {
+		t.Errorf("Unexpected default log options %v, %v", options, err)
+	}
+	for _, invalid := range []map[string]string{
+		{"since": "yesterday"},
+		{"since": "-1h"},
+		{"tail": "-1"},
+		{"timestamps": "maybe"},
+		{"grep": "("},
+	} {
+		if _, err := kubernetes.ParseLogOptions(invalid); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
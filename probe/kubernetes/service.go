@@ -63,7 +63,8 @@ func (s *service) GetNode(probeID string) report.Node {
 		}
 		latest[Ports] = portStr[:len(portStr)-1]
 	}
-	return s.MetaNode(report.MakeServiceNodeID(s.UID())).WithLatests(latest)
+	return s.MetaNode(report.MakeServiceNodeID(s.UID())).WithLatests(latest).
+		WithLatestActiveControls(GetLogs)
 }
 
 func (s *service) ClusterIP() string {
@@ -0,0 +1,9 @@
+// +build linux,!go1.10
+
+package netdev
+
+// Reading the devices of other network namespaces switches threads to them.
+// Before Go 1.10, the runtime clones new threads from the current one, so
+// they would inherit its namespace, and reuses threads whose namespace could
+// not be restored. Build with Go 1.10 or later.
+var _ = netdevRequiresGo110
@@ -0,0 +1,226 @@
+package netdev
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Not defined by package syscall
+const iflaInfoKind = 1
+
+// readDevices dumps the devices of a network namespace over netlink. The
+// netlink socket is bound to the namespace of the thread opening it, so the
+// dump is done by a goroutine of its own, on a thread switched to the
+// namespace.
+func readDevices(netNamespacePath string) ([]Device, error) {
+	type result struct {
+		links, addrs []byte
+		err          error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		res.links, res.addrs, res.err = dumpInNamespace(netNamespacePath)
+		done <- res
+	}()
+	res := <-done
+	if res.err != nil {
+		return nil, res.err
+	}
+	return parseDevices(res.links, res.addrs)
+}
+
+// dumpInNamespace dumps the links and addresses of a network namespace. It
+// must be called by a goroutine which exits once it returns: if the thread
+// can't be switched back to its namespace, it is left locked, so the
+// runtime terminates it along with the goroutine rather than reusing it.
+// This, and new threads not being cloned from the switched one, need Go 1.10
+// or later (see netlink_go19.go).
+func dumpInNamespace(netNamespacePath string) ([]byte, []byte, error) {
+	runtime.LockOSThread()
+
+	self, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, nil, err
+	}
+	defer self.Close()
+	target, err := os.Open(netNamespacePath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, nil, err
+	}
+	defer target.Close()
+
+	if err := unix.Setns(int(target.Fd()), syscall.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return nil, nil, err
+	}
+	links, linksErr := syscall.NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+	addrs, addrsErr := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+	if err := unix.Setns(int(self.Fd()), syscall.CLONE_NEWNET); err != nil {
+		log.Errorf("NetDevice: failed to restore the network namespace of thread: %v", err)
+		return nil, nil, err
+	}
+	runtime.UnlockOSThread()
+
+	if linksErr != nil {
+		return nil, nil, linksErr
+	}
+	if addrsErr != nil {
+		return nil, nil, addrsErr
+	}
+	return links, addrs, nil
+}
+
+// parseDevices parses the replies to RTM_GETLINK and RTM_GETADDR dumps.
+func parseDevices(links, addrs []byte) ([]Device, error) {
+	linkMsgs, err := syscall.ParseNetlinkMessage(links)
+	if err != nil {
+		return nil, err
+	}
+	devices := []Device{}
+	byIndex := map[int]int{}
+	for _, msg := range linkMsgs {
+		if msg.Header.Type != syscall.RTM_NEWLINK || len(msg.Data) < syscall.SizeofIfInfomsg {
+			continue
+		}
+		device, err := parseLink(msg)
+		if err != nil {
+			return nil, err
+		}
+		byIndex[device.Index] = len(devices)
+		devices = append(devices, device)
+	}
+
+	addrMsgs, err := syscall.ParseNetlinkMessage(addrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range addrMsgs {
+		if msg.Header.Type != syscall.RTM_NEWADDR || len(msg.Data) < syscall.SizeofIfAddrmsg {
+			continue
+		}
+		index, addr, ok := parseAddr(msg)
+		if !ok {
+			continue
+		}
+		if i, ok := byIndex[index]; ok {
+			devices[i].Addresses = append(devices[i].Addresses, addr)
+		}
+	}
+	return devices, nil
+}
+
+func parseLink(msg syscall.NetlinkMessage) (Device, error) {
+	info := (*syscall.IfInfomsg)(unsafe.Pointer(&msg.Data[0]))
+	device := Device{
+		Index: int(info.Index),
+		Up:    info.Flags&syscall.IFF_UP != 0,
+		Kind:  kindDevice,
+	}
+	if info.Flags&syscall.IFF_LOOPBACK != 0 {
+		device.Kind = kindLoopback
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+	if err != nil {
+		return device, err
+	}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case syscall.IFLA_IFNAME:
+			device.Name = cString(attr.Value)
+		case syscall.IFLA_ADDRESS:
+			device.MAC = formatMAC(attr.Value)
+		case syscall.IFLA_MTU:
+			device.MTU = int(nativeUint32(attr.Value))
+		case syscall.IFLA_MASTER:
+			device.MasterIndex = int(nativeUint32(attr.Value))
+		case syscall.IFLA_LINK:
+			device.LinkIndex = int(nativeUint32(attr.Value))
+		case syscall.IFLA_LINKINFO:
+			for _, info := range parseNestedAttrs(attr.Value) {
+				if info.Attr.Type == iflaInfoKind {
+					device.Kind = cString(info.Value)
+				}
+			}
+		}
+	}
+	return device, nil
+}
+
+func parseAddr(msg syscall.NetlinkMessage) (int, string, bool) {
+	info := (*syscall.IfAddrmsg)(unsafe.Pointer(&msg.Data[0]))
+	attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+	if err != nil {
+		return 0, "", false
+	}
+	var ip net.IP
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case syscall.IFA_LOCAL:
+			// The local address of point-to-point devices, for which
+			// IFA_ADDRESS is the address of the other end.
+			ip = net.IP(attr.Value)
+		case syscall.IFA_ADDRESS:
+			if ip == nil {
+				ip = net.IP(attr.Value)
+			}
+		}
+	}
+	if ip == nil {
+		return 0, "", false
+	}
+	return int(info.Index), fmt.Sprintf("%s/%d", ip, info.Prefixlen), true
+}
+
+// parseNestedAttrs parses attributes nested in another, which
+// syscall.ParseNetlinkRouteAttr doesn't.
+func parseNestedAttrs(b []byte) []syscall.NetlinkRouteAttr {
+	attrs := []syscall.NetlinkRouteAttr{}
+	for len(b) >= syscall.SizeofRtAttr {
+		length := int(nativeUint16(b[0:2]))
+		if length < syscall.SizeofRtAttr || length > len(b) {
+			break
+		}
+		attrs = append(attrs, syscall.NetlinkRouteAttr{
+			Attr:  syscall.RtAttr{Len: uint16(length), Type: nativeUint16(b[2:4])},
+			Value: b[syscall.SizeofRtAttr:length],
+		})
+		aligned := (length + syscall.NLMSG_ALIGNTO - 1) &^ (syscall.NLMSG_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	return attrs
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// Netlink speaks the byte order of the host.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+func nativeUint16(b []byte) uint16 { return nativeEndian.Uint16(b) }
+func nativeUint32(b []byte) uint32 { return nativeEndian.Uint32(b) }
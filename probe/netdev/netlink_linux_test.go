@@ -0,0 +1,98 @@
+package netdev
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func rtattr(typ uint16, value []byte) []byte {
+	length := syscall.SizeofRtAttr + len(value)
+	b := make([]byte, (length+syscall.NLMSG_ALIGNTO-1)&^(syscall.NLMSG_ALIGNTO-1))
+	nativeEndian.PutUint16(b[0:2], uint16(length))
+	nativeEndian.PutUint16(b[2:4], typ)
+	copy(b[syscall.SizeofRtAttr:], value)
+	return b
+}
+
+func uint32Attr(typ uint16, v uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+	return rtattr(typ, b)
+}
+
+func nlmsg(typ uint16, body []byte, attrs ...[]byte) []byte {
+	payload := append([]byte{}, body...)
+	for _, attr := range attrs {
+		payload = append(payload, attr...)
+	}
+	b := make([]byte, syscall.NLMSG_HDRLEN+len(payload))
+	nativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	nativeEndian.PutUint16(b[4:6], typ)
+	copy(b[syscall.NLMSG_HDRLEN:], payload)
+	return b
+}
+
+func linkMsg(index int32, flags uint32, attrs ...[]byte) []byte {
+	body := make([]byte, syscall.SizeofIfInfomsg)
+	nativeEndian.PutUint32(body[4:8], uint32(index))
+	nativeEndian.PutUint32(body[8:12], flags)
+	return nlmsg(syscall.RTM_NEWLINK, body, attrs...)
+}
+
+func addrMsg(index uint32, prefixLen uint8, attrs ...[]byte) []byte {
+	body := make([]byte, syscall.SizeofIfAddrmsg)
+	body[0] = syscall.AF_INET
+	body[1] = prefixLen
+	nativeEndian.PutUint32(body[4:8], index)
+	return nlmsg(syscall.RTM_NEWADDR, body, attrs...)
+}
+
+func TestParseDevices(t *testing.T) {
+	done := nlmsg(syscall.NLMSG_DONE, make([]byte, 4))
+	links := bytes.Join([][]byte{
+		linkMsg(1, syscall.IFF_UP|syscall.IFF_LOOPBACK,
+			rtattr(syscall.IFLA_IFNAME, []byte("lo\x00")),
+			uint32Attr(syscall.IFLA_MTU, 65536),
+		),
+		linkMsg(3, syscall.IFF_UP,
+			rtattr(syscall.IFLA_IFNAME, []byte("docker0\x00")),
+			rtattr(syscall.IFLA_ADDRESS, []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x01}),
+			uint32Attr(syscall.IFLA_MTU, 1500),
+			rtattr(syscall.IFLA_LINKINFO, rtattr(iflaInfoKind, []byte("bridge\x00"))),
+		),
+		linkMsg(7, 0,
+			rtattr(syscall.IFLA_IFNAME, []byte("veth1a2b3c\x00")),
+			uint32Attr(syscall.IFLA_MTU, 1500),
+			uint32Attr(syscall.IFLA_MASTER, 3),
+			uint32Attr(syscall.IFLA_LINK, 6),
+			rtattr(syscall.IFLA_LINKINFO, rtattr(iflaInfoKind, []byte("veth"))),
+		),
+		done,
+	}, nil)
+	addrs := bytes.Join([][]byte{
+		addrMsg(1, 8, rtattr(syscall.IFA_ADDRESS, net.IPv4(127, 0, 0, 1).To4())),
+		addrMsg(3, 16,
+			rtattr(syscall.IFA_ADDRESS, net.IPv4(172, 17, 0, 1).To4()),
+			rtattr(syscall.IFA_LOCAL, net.IPv4(172, 17, 0, 1).To4()),
+		),
+		addrMsg(42, 24, rtattr(syscall.IFA_ADDRESS, net.IPv4(10, 0, 0, 1).To4())),
+		done,
+	}, nil)
+
+	have, err := parseDevices(links, addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Device{
+		{Index: 1, Name: "lo", Kind: kindLoopback, MTU: 65536, Up: true, Addresses: []string{"127.0.0.1/8"}},
+		{Index: 3, Name: "docker0", Kind: "bridge", MAC: "02:42:ac:11:00:01", MTU: 1500, Up: true, Addresses: []string{"172.17.0.1/16"}},
+		{Index: 7, Name: "veth1a2b3c", Kind: kindVeth, MTU: 1500, MasterIndex: 3, LinkIndex: 6},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Unexpected devices:\n%#v\n!=\n%#v", have, want)
+	}
+}
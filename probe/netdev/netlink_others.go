@@ -0,0 +1,9 @@
+// +build !linux
+
+package netdev
+
+import "fmt"
+
+func readDevices(netNamespacePath string) ([]Device, error) {
+	return nil, fmt.Errorf("reading network devices is only supported on Linux")
+}
@@ -0,0 +1,362 @@
+package netdev
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node
+const (
+	Name      = report.NetDeviceName
+	Kind      = report.NetDeviceKind
+	MAC       = report.NetDeviceMAC
+	MTU       = report.NetDeviceMTU
+	State     = report.NetDeviceState
+	Namespace = report.NetDeviceNamespace
+	Owner     = report.NetDeviceOwner
+	Master    = report.NetDeviceMaster
+	Peer      = report.NetDevicePeer
+	Addresses = report.NetDeviceAddresses
+)
+
+const (
+	hostOwner = "host"
+
+	stateUp   = "up"
+	stateDown = "down"
+
+	kindVeth     = "veth"
+	kindDevice   = "device"
+	kindLoopback = "loopback"
+
+	// Namespaces, and their devices, are only read this often, as it means
+	// reading the namespace of every process, and switching to every
+	// namespace.
+	refreshInterval = 10 * time.Second
+)
+
+var (
+	// MetadataTemplates of network devices
+	MetadataTemplates = report.MetadataTemplates{
+		Kind:      {ID: Kind, Label: "Kind", From: report.FromLatest, Priority: 1},
+		State:     {ID: State, Label: "State", From: report.FromLatest, Priority: 2},
+		Owner:     {ID: Owner, Label: "Namespace Of", From: report.FromLatest, Priority: 3},
+		Namespace: {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 4},
+		MAC:       {ID: MAC, Label: "MAC", From: report.FromLatest, Priority: 5},
+		MTU:       {ID: MTU, Label: "MTU", From: report.FromLatest, Priority: 6, Datatype: report.Number},
+		Master:    {ID: Master, Label: "Attached To", From: report.FromLatest, Priority: 7},
+		Peer:      {ID: Peer, Label: "Peer", From: report.FromLatest, Priority: 8},
+		Addresses: {ID: Addresses, Label: "Addresses", From: report.FromSets, Priority: 9},
+	}
+)
+
+// Device is a network device of a network namespace.
+type Device struct {
+	Index int
+	Name  string
+	Kind  string // e.g. veth, bridge, vxlan, device (physical) or loopback
+	MAC   string
+	MTU   int
+	Up    bool
+	// MasterIndex is the index of the bridge the device is attached to, if
+	// any, in the same namespace.
+	MasterIndex int
+	// LinkIndex is the index of the device this one is bound to. For veths,
+	// this is the other end of the pair, which is usually in another
+	// namespace.
+	LinkIndex int
+	Addresses []string
+}
+
+// Reporter reports the network devices of all the network namespaces of
+// the host, i.e. those of its processes. It is also a Tagger, attaching the
+// devices to the containers owning their namespaces.
+type Reporter struct {
+	hostID   string
+	procRoot string
+
+	mtx         sync.Mutex
+	namespaces  map[string]*netNamespace
+	lastRefresh time.Time
+
+	// Exported for testing
+	ReadDevices func(netNamespacePath string) ([]Device, error)
+}
+
+// NewReporter makes a new Reporter.
+func NewReporter(hostID, procRoot string) *Reporter {
+	return &Reporter{
+		hostID:      hostID,
+		procRoot:    procRoot,
+		ReadDevices: readDevices,
+	}
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "NetDevice" }
+
+// netNamespace is a network namespace with the processes in it.
+type netNamespace struct {
+	id      string // inode number
+	pids    []int
+	devices []Device
+}
+
+// readNetNamespaces groups the processes of the host by network namespace,
+// identified by the inode number the /proc/PID/ns/net link points to.
+func readNetNamespaces(procRoot string) (map[string]*netNamespace, error) {
+	dirs, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := map[string]*netNamespace{}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+		// The link reads like net:[4026531993]
+		link, err := os.Readlink(filepath.Join(procRoot, dir.Name(), "ns", "net"))
+		if err != nil || !strings.HasPrefix(link, "net:[") || !strings.HasSuffix(link, "]") {
+			continue
+		}
+		id := link[len("net:[") : len(link)-1]
+		ns, ok := namespaces[id]
+		if !ok {
+			ns = &netNamespace{id: id}
+			namespaces[id] = ns
+		}
+		ns.pids = append(ns.pids, pid)
+	}
+	for _, ns := range namespaces {
+		sort.Ints(ns.pids)
+	}
+	return namespaces, nil
+}
+
+// netNamespaces returns the network namespaces of the host, with their
+// devices, reading them again if they are older than refreshInterval. They
+// must not be modified.
+func (r *Reporter) netNamespaces() (map[string]*netNamespace, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	now := mtime.Now()
+	if r.namespaces != nil && now.Sub(r.lastRefresh) < refreshInterval {
+		return r.namespaces, nil
+	}
+	namespaces, err := readNetNamespaces(r.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range namespaces {
+		path := filepath.Join(r.procRoot, strconv.Itoa(ns.pids[0]), "ns", "net")
+		if ns.devices, err = r.ReadDevices(path); err != nil {
+			// The process may have exited since
+			log.Debugf("NetDevice: failed to read devices of namespace %s: %v", ns.id, err)
+		}
+	}
+	r.namespaces, r.lastRefresh = namespaces, now
+	return namespaces, nil
+}
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	rpt := report.MakeReport()
+	rpt.NetDevice = rpt.NetDevice.WithMetadataTemplates(MetadataTemplates)
+
+	namespaces, err := r.netNamespaces()
+	if err != nil {
+		return rpt, err
+	}
+	// The host's namespace is the one of init
+	hostNamespace := ""
+	for _, ns := range namespaces {
+		if ns.pids[0] == 1 {
+			hostNamespace = ns.id
+		}
+	}
+
+	type deviceKey struct {
+		namespace string
+		index     int
+	}
+	nodeIDs := map[deviceKey]string{}
+	names := map[deviceKey]string{}
+	for _, ns := range namespaces {
+		for _, dev := range ns.devices {
+			key := deviceKey{ns.id, dev.Index}
+			nodeIDs[key] = report.MakeNetDeviceNodeID(r.hostID, ns.id, dev.Name)
+			names[key] = dev.Name
+		}
+	}
+
+	for _, ns := range namespaces {
+		for _, dev := range ns.devices {
+			latests := map[string]string{
+				Name:              dev.Name,
+				Kind:              dev.Kind,
+				MTU:               strconv.Itoa(dev.MTU),
+				State:             stateDown,
+				Namespace:         ns.id,
+				report.HostNodeID: r.hostID,
+			}
+			if dev.MAC != "" {
+				latests[MAC] = dev.MAC
+			}
+			if dev.Up {
+				latests[State] = stateUp
+			}
+			if ns.id == hostNamespace {
+				latests[Owner] = hostOwner
+			}
+
+			node := report.MakeNode(nodeIDs[deviceKey{ns.id, dev.Index}]).
+				WithSet(Addresses, report.MakeStringSet(dev.Addresses...)).
+				WithParents(report.MakeSets().Add(report.Host, report.MakeStringSet(report.MakeHostNodeID(r.hostID))))
+
+			if dev.MasterIndex != 0 {
+				master := deviceKey{ns.id, dev.MasterIndex}
+				if id, ok := nodeIDs[master]; ok {
+					latests[Master] = names[master]
+					node = node.WithAdjacent(id)
+				}
+			}
+			if dev.Kind == kindVeth {
+				if peer, ok := findVethPeer(namespaces, ns, dev); ok {
+					key := deviceKey{peer.namespace, peer.index}
+					latests[Peer] = names[key]
+					node = node.WithAdjacent(nodeIDs[key])
+				}
+			}
+
+			rpt.NetDevice.AddNode(node.WithLatests(latests))
+		}
+	}
+	return rpt, nil
+}
+
+type vethEnd struct {
+	namespace string
+	index     int
+}
+
+// findVethPeer finds the other end of a veth pair. Device indexes are only
+// unique within a namespace, but the ends of a pair are bound to each other's
+// index, so we look for the device whose link is this one, and is linked to.
+// When both ends are in the same namespace, the peer is in that namespace.
+func findVethPeer(namespaces map[string]*netNamespace, ns *netNamespace, dev Device) (vethEnd, bool) {
+	isPeer := func(other Device) bool {
+		return other.Kind == kindVeth && other.Index == dev.LinkIndex && other.LinkIndex == dev.Index
+	}
+	for _, other := range ns.devices {
+		if other.Index != dev.Index && isPeer(other) {
+			return vethEnd{ns.id, other.Index}, true
+		}
+	}
+	// Look at the other namespaces in a stable order, in case of collisions
+	ids := make([]string, 0, len(namespaces))
+	for id := range namespaces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if id == ns.id {
+			continue
+		}
+		for _, other := range namespaces[id].devices {
+			if isPeer(other) {
+				return vethEnd{id, other.Index}, true
+			}
+		}
+	}
+	return vethEnd{}, false
+}
+
+// Tag implements Tagger. It attaches the devices of a namespace to the
+// containers whose processes are in that namespace, according to the
+// container parents of the processes.
+func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+	if len(rpt.NetDevice.Nodes) == 0 {
+		return rpt, nil
+	}
+	namespaces, err := r.netNamespaces()
+	if err != nil {
+		return rpt, err
+	}
+	namespaceByPID := map[string]string{}
+	for _, ns := range namespaces {
+		for _, pid := range ns.pids {
+			namespaceByPID[strconv.Itoa(pid)] = ns.id
+		}
+	}
+
+	containersByNamespace := map[string]report.StringSet{}
+	for _, node := range rpt.Process.Nodes {
+		pid, ok := node.Latest.Lookup(process.PID)
+		if !ok {
+			continue
+		}
+		containers, ok := node.Parents.Lookup(report.Container)
+		if !ok {
+			continue
+		}
+		if ns, ok := namespaceByPID[pid]; ok {
+			containersByNamespace[ns] = containersByNamespace[ns].Merge(containers)
+		}
+	}
+
+	for id, node := range rpt.NetDevice.Nodes {
+		ns, _ := node.Latest.Lookup(Namespace)
+		containers, ok := containersByNamespace[ns]
+		if !ok {
+			continue
+		}
+		if _, ok := node.Latest.Lookup(Owner); !ok {
+			node = node.WithLatests(map[string]string{Owner: containerNames(rpt, containers)})
+		}
+		rpt.NetDevice.Nodes[id] = node.WithParents(node.Parents.Add(report.Container, containers))
+	}
+	return rpt, nil
+}
+
+// containerNames names the containers sharing a namespace, e.g. the
+// containers of a pod.
+func containerNames(rpt report.Report, containers report.StringSet) string {
+	names := []string{}
+	for _, id := range containers {
+		name, ok := rpt.Container.Nodes[id].Latest.Lookup(report.DockerContainerName)
+		if !ok {
+			name, _ = report.ParseContainerNodeID(id)
+			if len(name) > 12 {
+				name = name[:12]
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func formatMAC(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	parts := make([]string, len(b))
+	for i, octet := range b {
+		parts[i] = fmt.Sprintf("%02x", octet)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,185 @@
+package netdev_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/netdev"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	hostID         = "host1"
+	hostNamespace  = "4026531993"
+	containerNS    = "4026532288"
+	containerID    = "abcdef0123456789"
+	containerName  = "frontend"
+	containerPID   = "4242"
+	unrelatedPID   = "5000"
+	noNamespacePID = "6000"
+)
+
+// makeProc makes a fake /proc, with the network namespace links of
+// processes.
+func makeProc(t *testing.T, namespaces map[string]string) string {
+	dir, err := ioutil.TempDir("", "netdev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pid, ns := range namespaces {
+		if err := os.MkdirAll(filepath.Join(dir, pid, "ns"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if ns == "" {
+			continue
+		}
+		if err := os.Symlink(fmt.Sprintf("net:[%s]", ns), filepath.Join(dir, pid, "ns", "net")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReporter(t *testing.T) {
+	procRoot := makeProc(t, map[string]string{
+		"1":            hostNamespace,
+		"100":          hostNamespace,
+		containerPID:   containerNS,
+		noNamespacePID: "",
+		"self":         hostNamespace,
+	})
+	defer os.RemoveAll(procRoot)
+
+	devices := map[string][]netdev.Device{
+		hostNamespace: {
+			{Index: 1, Name: "lo", Kind: "loopback", MTU: 65536, Up: true, Addresses: []string{"127.0.0.1/8"}},
+			{Index: 3, Name: "docker0", Kind: "bridge", MAC: "02:42:ac:11:00:01", MTU: 1500, Up: true, Addresses: []string{"172.17.0.1/16"}},
+			{Index: 7, Name: "veth1a2b3c", Kind: "veth", MTU: 1500, Up: true, MasterIndex: 3, LinkIndex: 6},
+		},
+		containerNS: {
+			{Index: 1, Name: "lo", Kind: "loopback", MTU: 65536},
+			{Index: 6, Name: "eth0", Kind: "veth", MAC: "02:42:ac:11:00:02", MTU: 1500, Up: true, LinkIndex: 7, Addresses: []string{"172.17.0.2/16"}},
+		},
+	}
+	reporter := netdev.NewReporter(hostID, procRoot)
+	reporter.ReadDevices = func(path string) ([]netdev.Device, error) {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		var ns string
+		fmt.Sscanf(link, "net:[%s", &ns)
+		return devices[ns[:len(ns)-1]], nil
+	}
+
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 5, len(rpt.NetDevice.Nodes); want != have {
+		t.Fatalf("Expected %d devices, got %d: %v", want, have, rpt.NetDevice.Nodes)
+	}
+
+	bridgeID := report.MakeNetDeviceNodeID(hostID, hostNamespace, "docker0")
+	hostVethID := report.MakeNetDeviceNodeID(hostID, hostNamespace, "veth1a2b3c")
+	containerVethID := report.MakeNetDeviceNodeID(hostID, containerNS, "eth0")
+
+	hostVeth := rpt.NetDevice.Nodes[hostVethID]
+	for key, want := range map[string]string{
+		netdev.Name:   "veth1a2b3c",
+		netdev.Kind:   "veth",
+		netdev.State:  "up",
+		netdev.Owner:  "host",
+		netdev.Master: "docker0",
+		netdev.Peer:   "eth0",
+	} {
+		if have, _ := hostVeth.Latest.Lookup(key); want != have {
+			t.Errorf("Expected %s of host veth to be %q, got %q", key, want, have)
+		}
+	}
+	if !hostVeth.Adjacency.Contains(bridgeID) || !hostVeth.Adjacency.Contains(containerVethID) {
+		t.Errorf("Expected host veth to be adjacent to the bridge and its peer, got %v", hostVeth.Adjacency)
+	}
+	if hosts, _ := hostVeth.Parents.Lookup(report.Host); !hosts.Contains(report.MakeHostNodeID(hostID)) {
+		t.Errorf("Expected host veth to have host parent, got %v", hostVeth.Parents)
+	}
+
+	containerVeth := rpt.NetDevice.Nodes[containerVethID]
+	if have, _ := containerVeth.Latest.Lookup(netdev.Peer); have != "veth1a2b3c" {
+		t.Errorf("Expected peer of container veth to be veth1a2b3c, got %q", have)
+	}
+	if _, ok := containerVeth.Latest.Lookup(netdev.Owner); ok {
+		t.Errorf("Expected container veth not to have an owner before tagging")
+	}
+	if have, _ := containerVeth.Sets.Lookup(netdev.Addresses); !have.Contains("172.17.0.2/16") {
+		t.Errorf("Expected addresses of container veth, got %v", have)
+	}
+
+	// Tag the devices of the container's namespace with the container
+	containerNodeID := report.MakeContainerNodeID(containerID)
+	rpt.Container.AddNode(report.MakeNodeWith(containerNodeID, map[string]string{
+		report.DockerContainerName: containerName,
+	}))
+	rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID(hostID, containerPID), map[string]string{
+		process.PID: containerPID,
+	}).WithParents(report.MakeSets().Add(report.Container, report.MakeStringSet(containerNodeID))))
+	rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID(hostID, unrelatedPID), map[string]string{
+		process.PID: unrelatedPID,
+	}).WithParents(report.MakeSets().Add(report.Container, report.MakeStringSet(report.MakeContainerNodeID("other")))))
+
+	rpt, err = reporter.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerVeth = rpt.NetDevice.Nodes[containerVethID]
+	if have, _ := containerVeth.Latest.Lookup(netdev.Owner); have != containerName {
+		t.Errorf("Expected container veth to be owned by %q, got %q", containerName, have)
+	}
+	if have, _ := containerVeth.Parents.Lookup(report.Container); !have.Contains(containerNodeID) || len(have) != 1 {
+		t.Errorf("Expected container veth to have container parent, got %v", have)
+	}
+	if _, ok := rpt.NetDevice.Nodes[hostVethID].Parents.Lookup(report.Container); ok {
+		t.Errorf("Expected host veth not to have container parents")
+	}
+}
+
+func TestReporterCachesNamespaces(t *testing.T) {
+	procRoot := makeProc(t, map[string]string{"1": hostNamespace})
+	defer os.RemoveAll(procRoot)
+	defer mtime.NowReset()
+	now := time.Now()
+	mtime.NowForce(now)
+
+	reads := 0
+	reporter := netdev.NewReporter(hostID, procRoot)
+	reporter.ReadDevices = func(string) ([]netdev.Device, error) {
+		reads++
+		return []netdev.Device{{Index: 1, Name: "lo", Kind: "loopback"}}, nil
+	}
+	for i := 0; i < 2; i++ {
+		rpt, err := reporter.Report()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reporter.Tag(rpt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reads != 1 {
+		t.Errorf("Expected the devices to be read once, got %d reads", reads)
+	}
+
+	mtime.NowForce(now.Add(time.Minute))
+	if _, err := reporter.Report(); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 2 {
+		t.Errorf("Expected the devices to be read again, got %d reads", reads)
+	}
+}
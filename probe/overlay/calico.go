@@ -0,0 +1,244 @@
+package overlay
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const birdTimeout = 5 * time.Second
+
+// Calico reads the state of a Calico network from BIRD, the BGP daemon
+// calico-node runs on every host. Each host is a BGP peer, named after its
+// router ID (i.e. its IP address), and advertises the address blocks
+// allocated to it.
+//
+// Calico networks routing with VXLAN only don't run BIRD, and are not
+// supported.
+type Calico struct {
+	birdSocket string
+	procRoot   string
+}
+
+// NewCalico returns a new Calico Source. The socket is usually
+// /var/run/calico/bird.ctl.
+func NewCalico(birdSocket, procRoot string) *Calico {
+	return &Calico{birdSocket: birdSocket, procRoot: procRoot}
+}
+
+// Name implements Source.
+func (*Calico) Name() string { return "Calico" }
+
+// PeerPrefix implements Source.
+func (*Calico) PeerPrefix() string { return report.CalicoOverlayPeerPrefix }
+
+// Peers implements Source.
+func (c *Calico) Peers() ([]Peer, error) {
+	status, err := c.birdCommand("show status")
+	if err != nil {
+		return nil, err
+	}
+	protocols, err := c.birdCommand("show protocols")
+	if err != nil {
+		return nil, err
+	}
+	routes, err := c.birdCommand("show route")
+	if err != nil {
+		return nil, err
+	}
+	routerID, err := parseBIRDRouterID(status)
+	if err != nil {
+		return nil, err
+	}
+	tunnelType := c.tunnelType()
+
+	local := &Peer{Name: routerID, Local: true, TunnelEndpoint: routerID, TunnelType: tunnelType}
+	peers := map[string]*Peer{routerID: local}
+	peer := func(name string) *Peer {
+		if _, ok := peers[name]; !ok {
+			peers[name] = &Peer{Name: name, TunnelEndpoint: name, TunnelType: tunnelType}
+		}
+		return peers[name]
+	}
+
+	for _, session := range parseBIRDProtocols(protocols) {
+		peer(session.Peer)
+		local.Connections = append(local.Connections, session)
+	}
+	for subnet, via := range parseBIRDRoutes(routes) {
+		p := local
+		if via != "" {
+			p = peer(via)
+		}
+		p.Subnets = append(p.Subnets, subnet)
+	}
+
+	names := make([]string, 0, len(peers))
+	for name := range peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]Peer, 0, len(peers))
+	for _, name := range names {
+		p := peers[name]
+		sort.Strings(p.Subnets)
+		result = append(result, *p)
+	}
+	return result, nil
+}
+
+// tunnelType tells from the interfaces of the host how Calico encapsulates
+// traffic between hosts, if at all.
+func (c *Calico) tunnelType() string {
+	dev, err := ioutil.ReadFile(filepath.Join(c.procRoot, "net", "dev"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(dev), "\n") {
+		switch strings.TrimSpace(strings.SplitN(line, ":", 2)[0]) {
+		case "tunl0":
+			return "ipip"
+		case "vxlan.calico":
+			return "vxlan"
+		}
+	}
+	return "none"
+}
+
+// birdCommand runs a command of the BIRD CLI and returns the lines of its
+// reply, stripped of their reply codes.
+func (c *Calico) birdCommand(command string) ([]string, error) {
+	conn, err := net.DialTimeout("unix", c.birdSocket, birdTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(birdTimeout))
+
+	scanner := bufio.NewScanner(conn)
+	// BIRD greets us with "0001 BIRD x.y.z ready."
+	if _, err := readBIRDReply(scanner); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", command); err != nil {
+		return nil, err
+	}
+	return readBIRDReply(scanner)
+}
+
+// readBIRDReply reads the lines of a reply, which are either prefixed with a
+// four digit code and '-', or a space when continuing the previous code. The
+// last line is prefixed with a code and a space. Codes 8xxx and 9xxx are
+// errors.
+func readBIRDReply(scanner *bufio.Scanner) ([]string, error) {
+	lines := []string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, " ") {
+			lines = append(lines, line[1:])
+			continue
+		}
+		if len(line) < 5 {
+			return nil, fmt.Errorf("bad reply from BIRD: %q", line)
+		}
+		code, sep, text := line[:4], line[4], line[5:]
+		if code[0] == '8' || code[0] == '9' {
+			return nil, fmt.Errorf("BIRD: %s", text)
+		}
+		if text != "" {
+			lines = append(lines, text)
+		}
+		if sep == ' ' {
+			return lines, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unexpected end of reply from BIRD")
+}
+
+func parseBIRDRouterID(status []string) (string, error) {
+	const prefix = "Router ID is "
+	for _, line := range status {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSpace(line[len(prefix):]), nil
+		}
+	}
+	return "", fmt.Errorf("no router ID in BIRD status")
+}
+
+// birdPeerAddress returns the address of the peer of a BGP protocol named by
+// Calico, e.g. Mesh_10_0_0_2 (node-to-node mesh), Node_10_0_0_2 (per node
+// peers) or Global_10_0_0_2 (global peers).
+func birdPeerAddress(protocol string) (string, bool) {
+	parts := strings.SplitN(protocol, "_", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	addr := strings.Replace(parts[1], "_", ".", -1)
+	if net.ParseIP(addr) == nil {
+		return "", false
+	}
+	return addr, true
+}
+
+// parseBIRDProtocols returns the BGP sessions of the local peer, with the
+// state of the session (e.g. Established) if up, or of the protocol.
+//
+//	name          proto    table    state  since       info
+//	Mesh_10_0_0_2 BGP      master   up     12:00:00    Established
+func parseBIRDProtocols(protocols []string) []Connection {
+	sessions := []Connection{}
+	for _, line := range protocols {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "BGP" {
+			continue
+		}
+		addr, ok := birdPeerAddress(fields[0])
+		if !ok {
+			continue
+		}
+		state := fields[3]
+		if len(fields) > 5 {
+			state = fields[5]
+		}
+		sessions = append(sessions, Connection{Peer: addr, State: state})
+	}
+	return sessions
+}
+
+// parseBIRDRoutes maps the address blocks of the network to the peer
+// advertising them. Blocks of the local peer have blackhole routes, so that
+// traffic to unallocated addresses of the block doesn't leave the host.
+//
+//	10.244.0.0/26      blackhole [static1 12:00:00] * (200)
+//	10.244.1.0/26      via 10.0.0.2 on eth0 [Mesh_10_0_0_2 12:00:00] * (100/0) [i]
+func parseBIRDRoutes(routes []string) map[string]string {
+	result := map[string]string{}
+	for _, line := range routes {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(fields[0]); err != nil {
+			continue
+		}
+		switch {
+		case fields[1] == "blackhole":
+			result[fields[0]] = ""
+		case fields[1] == "via" && len(fields) > 5 && strings.HasPrefix(fields[5], "["):
+			if addr, ok := birdPeerAddress(fields[5][1:]); ok {
+				result[fields[0]] = addr
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package overlay_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/overlay"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+var birdReplies = map[string]string{
+	"show status": `1000-BIRD 1.6.8
+1011-Router ID is 10.0.0.1
+ Current server time is 2018-01-01 12:00:00
+ Last reboot on 2018-01-01 11:00:00
+0013 Daemon is up and running
+`,
+	"show protocols": `2002-name     proto    table    state  since       info
+1002-static1  Static   master   up     11:00:00
+ kernel1  Kernel   master   up     11:00:00
+ device1  Device   master   up     11:00:00
+ direct1  Direct   master   up     11:00:00
+ Mesh_10_0_0_2 BGP      master   up     11:00:01    Established
+ Mesh_10_0_0_3 BGP      master   start  11:00:01    Connect
+0000 
+`,
+	"show route": `1007-0.0.0.0/0          via 10.0.0.254 on eth0 [kernel1 11:00:00] * (10)
+ 10.0.0.0/24        dev eth0 [direct1 11:00:00] * (240)
+ 192.168.10.0/26    blackhole [static1 11:00:00] * (200)
+ 192.168.10.64/26   blackhole [static1 11:00:00] * (200)
+ 192.168.20.0/26    via 10.0.0.2 on eth0 [Mesh_10_0_0_2 11:00:01] * (100/0) [i]
+0000 
+`,
+}
+
+// serveBIRD speaks enough of the BIRD CLI protocol to reply to the commands
+// above.
+func serveBIRD(t *testing.T, socket string) net.Listener {
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprintf(conn, "0001 BIRD 1.6.8 ready.\n")
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					reply, ok := birdReplies[scanner.Text()]
+					if !ok {
+						reply = "9001 syntax error\n"
+					}
+					fmt.Fprint(conn, reply)
+				}
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+func TestCalico(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"proc/net/dev": `Inter-|   Receive                            |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:       0       0    0    0    0     0          0         0        0       0    0    0    0     0       0          0
+  eth0:       0       0    0    0    0     0          0         0        0       0    0    0    0     0       0          0
+ tunl0:       0       0    0    0    0     0          0         0        0       0    0    0    0     0       0          0
+`,
+	})
+	defer os.RemoveAll(dir)
+	listener := serveBIRD(t, filepath.Join(dir, "bird.ctl"))
+	defer listener.Close()
+
+	source := overlay.NewCalico(filepath.Join(dir, "bird.ctl"), filepath.Join(dir, "proc"))
+	peers, err := source.Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []overlay.Peer{
+		{
+			Name: "10.0.0.1", Local: true, TunnelEndpoint: "10.0.0.1", TunnelType: "ipip",
+			Subnets: []string{"192.168.10.0/26", "192.168.10.64/26"},
+			Connections: []overlay.Connection{
+				{Peer: "10.0.0.2", State: "Established"},
+				{Peer: "10.0.0.3", State: "Connect"},
+			},
+		},
+		{Name: "10.0.0.2", TunnelEndpoint: "10.0.0.2", TunnelType: "ipip", Subnets: []string{"192.168.20.0/26"}},
+		{Name: "10.0.0.3", TunnelEndpoint: "10.0.0.3", TunnelType: "ipip"},
+	}
+	if !reflect.DeepEqual(peers, want) {
+		t.Errorf("Unexpected peers:\n%#v\n!=\n%#v", peers, want)
+	}
+}
+
+func TestCalicoWithoutBIRD(t *testing.T) {
+	source := overlay.NewCalico("/non/existent/bird.ctl", "/proc")
+	if _, err := source.Peers(); err == nil {
+		t.Error("Expected an error without BIRD")
+	}
+}
@@ -0,0 +1,120 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const ciliumTimeout = 5 * time.Second
+
+// Cilium reads the state of a Cilium network from the API of the local
+// cilium-agent. Peers are named after the node names Cilium uses, which are
+// prefixed with the name of the cluster.
+type Cilium struct {
+	client *http.Client
+}
+
+// NewCilium returns a new Cilium Source talking to the agent on the given
+// socket, usually /var/run/cilium/cilium.sock.
+func NewCilium(socket string) *Cilium {
+	return &Cilium{
+		client: &http.Client{
+			Timeout: ciliumTimeout,
+			Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.DialTimeout("unix", socket, ciliumTimeout)
+				},
+			},
+		},
+	}
+}
+
+// Name implements Source.
+func (*Cilium) Name() string { return "Cilium" }
+
+// PeerPrefix implements Source.
+func (*Cilium) PeerPrefix() string { return report.CiliumOverlayPeerPrefix }
+
+type ciliumAddress struct {
+	IP         string `json:"ip"`
+	AllocRange string `json:"alloc-range"`
+}
+
+type ciliumNode struct {
+	Name           string `json:"name"`
+	PrimaryAddress struct {
+		IPv4 *ciliumAddress `json:"ipv4"`
+		IPv6 *ciliumAddress `json:"ipv6"`
+	} `json:"primary-address"`
+}
+
+type ciliumClusterStatus struct {
+	Self  string       `json:"self"`
+	Nodes []ciliumNode `json:"nodes"`
+}
+
+type ciliumConfig struct {
+	Status struct {
+		DaemonConfigurationMap map[string]interface{} `json:"daemonConfigurationMap"`
+	} `json:"status"`
+}
+
+func (c *Cilium) get(path string, result interface{}) error {
+	resp, err := c.client.Get("http://cilium/v1" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cilium %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Peers implements Source.
+func (c *Cilium) Peers() ([]Peer, error) {
+	var status ciliumClusterStatus
+	if err := c.get("/cluster/nodes", &status); err != nil {
+		return nil, err
+	}
+	// The tunnel mode is only informational, older agents don't report it.
+	var config ciliumConfig
+	tunnelType := ""
+	if err := c.get("/config", &config); err == nil {
+		tunnelType, _ = config.Status.DaemonConfigurationMap["Tunnel"].(string)
+	}
+
+	peers := make([]Peer, 0, len(status.Nodes))
+	for _, node := range status.Nodes {
+		peer := Peer{
+			Name:       node.Name,
+			Hostname:   node.Name[strings.LastIndex(node.Name, "/")+1:],
+			Local:      node.Name == status.Self,
+			TunnelType: tunnelType,
+		}
+		for _, addr := range []*ciliumAddress{node.PrimaryAddress.IPv4, node.PrimaryAddress.IPv6} {
+			if addr == nil {
+				continue
+			}
+			if peer.TunnelEndpoint == "" {
+				peer.TunnelEndpoint = addr.IP
+			}
+			if addr.AllocRange != "" {
+				peer.Subnets = append(peer.Subnets, addr.AllocRange)
+			}
+		}
+		peers = append(peers, peer)
+	}
+	state := "tunnel"
+	if tunnelType == "" || tunnelType == "disabled" {
+		state = "routed"
+	}
+	meshConnections(peers, state)
+	return peers, nil
+}
@@ -0,0 +1,70 @@
+package overlay_test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/overlay"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+const (
+	ciliumNodes = `{
+  "self": "default/node1",
+  "nodes": [
+    {
+      "name": "default/node1",
+      "primary-address": {"ipv4": {"ip": "172.18.0.2", "alloc-range": "10.0.0.0/24"}}
+    },
+    {
+      "name": "default/node2",
+      "primary-address": {
+        "ipv4": {"ip": "172.18.0.3", "alloc-range": "10.0.1.0/24"},
+        "ipv6": {"ip": "fc00::3", "alloc-range": "f00d::a00:0:0:0/96"}
+      }
+    }
+  ]
+}`
+	ciliumConfig = `{"status": {"daemonConfigurationMap": {"Tunnel": "vxlan"}}}`
+)
+
+func TestCilium(t *testing.T) {
+	dir := writeFiles(t, nil)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "cilium.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/cluster/nodes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ciliumNodes))
+	})
+	mux.HandleFunc("/v1/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ciliumConfig))
+	})
+	go http.Serve(listener, mux)
+
+	peers, err := overlay.NewCilium(socket).Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []overlay.Peer{
+		{
+			Name: "default/node1", Hostname: "node1", Local: true,
+			Subnets: []string{"10.0.0.0/24"}, TunnelEndpoint: "172.18.0.2", TunnelType: "vxlan",
+			Connections: []overlay.Connection{{Peer: "default/node2", State: "tunnel"}},
+		},
+		{
+			Name: "default/node2", Hostname: "node2",
+			Subnets: []string{"10.0.1.0/24", "f00d::a00:0:0:0/96"}, TunnelEndpoint: "172.18.0.3", TunnelType: "vxlan",
+		},
+	}
+	if !reflect.DeepEqual(peers, want) {
+		t.Errorf("Unexpected peers:\n%#v\n!=\n%#v", peers, want)
+	}
+}
@@ -0,0 +1,160 @@
+package overlay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Flannel reads the state of a Flannel network from the subnet file flanneld
+// writes for the CNI plugin, and the routes it installs to the subnets of the
+// other hosts.
+type Flannel struct {
+	subnetFile string
+	procRoot   string
+}
+
+// NewFlannel returns a new Flannel Source. The subnet file is usually
+// /run/flannel/subnet.env.
+func NewFlannel(subnetFile, procRoot string) *Flannel {
+	return &Flannel{subnetFile: subnetFile, procRoot: procRoot}
+}
+
+// Name implements Source.
+func (*Flannel) Name() string { return "Flannel" }
+
+// PeerPrefix implements Source.
+func (*Flannel) PeerPrefix() string { return report.FlannelOverlayPeerPrefix }
+
+// Peers implements Source. Each host leases a single subnet of the network,
+// so peers are named after the network address of their subnet.
+func (f *Flannel) Peers() ([]Peer, error) {
+	contents, err := ioutil.ReadFile(f.subnetFile)
+	if err != nil {
+		return nil, err
+	}
+	env := parseEnv(string(contents))
+	_, network, err := net.ParseCIDR(env["FLANNEL_NETWORK"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: bad FLANNEL_NETWORK: %v", f.subnetFile, err)
+	}
+	_, local, err := net.ParseCIDR(env["FLANNEL_SUBNET"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: bad FLANNEL_SUBNET: %v", f.subnetFile, err)
+	}
+
+	routes, err := readRoutes(filepath.Join(f.procRoot, "net", "route"))
+	if err != nil {
+		return nil, err
+	}
+
+	localPeer := Peer{
+		Name:    local.IP.String(),
+		Local:   true,
+		Subnets: []string{local.String()},
+	}
+	peers := []Peer{}
+	for _, route := range routes {
+		if route.gateway.Equal(net.IPv4zero) || !network.Contains(route.destination.IP) ||
+			route.destination.IP.Equal(local.IP) {
+			continue
+		}
+		tunnelType := flannelTunnelType(route.iface)
+		localPeer.TunnelType = tunnelType
+		peers = append(peers, Peer{
+			Name:           route.destination.IP.String(),
+			Subnets:        []string{route.destination.String()},
+			TunnelEndpoint: route.gateway.String(),
+			TunnelType:     tunnelType,
+		})
+	}
+	peers = append(peers, localPeer)
+	meshConnections(peers, "routed")
+	return peers, nil
+}
+
+// flannelTunnelType guesses the backend of Flannel from the interface its
+// routes go through.
+func flannelTunnelType(iface string) string {
+	switch {
+	case strings.HasPrefix(iface, "flannel."):
+		return "vxlan"
+	case iface == "flannel0":
+		return "udp"
+	default:
+		return "host-gw"
+	}
+}
+
+// parseEnv parses the KEY=VALUE files left behind by CNI plugins.
+func parseEnv(contents string) map[string]string {
+	env := map[string]string{}
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		env[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+	}
+	return env
+}
+
+type route struct {
+	iface       string
+	destination *net.IPNet
+	gateway     net.IP
+}
+
+// readRoutes reads the IPv4 routing table of the host from /proc/net/route,
+// where addresses are in host byte order, i.e. little endian.
+func readRoutes(path string) ([]route, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	routes := []route{}
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // skip the header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		destination, err1 := parseProcIP(fields[1])
+		gateway, err2 := parseProcIP(fields[2])
+		mask, err3 := parseProcIP(fields[7])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		routes = append(routes, route{
+			iface:       fields[0],
+			destination: &net.IPNet{IP: destination, Mask: net.IPMask(mask)},
+			gateway:     gateway,
+		})
+	}
+	return routes, scanner.Err()
+}
+
+func parseProcIP(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != net.IPv4len {
+		return nil, fmt.Errorf("bad address %q", s)
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+	return ip, nil
+}
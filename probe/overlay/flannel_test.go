@@ -0,0 +1,114 @@
+package overlay_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/overlay"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+const (
+	flannelSubnetEnv = `FLANNEL_NETWORK=10.244.0.0/16
+FLANNEL_SUBNET=10.244.0.1/24
+FLANNEL_MTU=1450
+FLANNEL_IPMASQ=true
+`
+	flannelRoutes = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0100000A	0003	0	0	0	00000000	0	0	0
+eth0	0000000A	00000000	0001	0	0	0	00FFFFFF	0	0	0
+flannel.1	0001F40A	0001F40A	0003	0	0	0	00FFFFFF	0	0	0
+flannel.1	0002F40A	0002F40A	0003	0	0	0	00FFFFFF	0	0	0
+cni0	0000F40A	00000000	0001	0	0	0	00FFFFFF	0	0	0
+`
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFlannel(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"subnet.env":     flannelSubnetEnv,
+		"proc/net/route": flannelRoutes,
+	})
+	defer os.RemoveAll(dir)
+
+	source := overlay.NewFlannel(filepath.Join(dir, "subnet.env"), filepath.Join(dir, "proc"))
+	peers, err := source.Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []overlay.Peer{
+		{Name: "10.244.1.0", Subnets: []string{"10.244.1.0/24"}, TunnelEndpoint: "10.244.1.0", TunnelType: "vxlan"},
+		{Name: "10.244.2.0", Subnets: []string{"10.244.2.0/24"}, TunnelEndpoint: "10.244.2.0", TunnelType: "vxlan"},
+		{
+			Name: "10.244.0.0", Local: true, Subnets: []string{"10.244.0.0/24"}, TunnelType: "vxlan",
+			Connections: []overlay.Connection{{Peer: "10.244.1.0", State: "routed"}, {Peer: "10.244.2.0", State: "routed"}},
+		},
+	}
+	if !reflect.DeepEqual(peers, want) {
+		t.Errorf("Unexpected peers:\n%#v\n!=\n%#v", peers, want)
+	}
+}
+
+func TestOverlayReporter(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"subnet.env":     flannelSubnetEnv,
+		"proc/net/route": flannelRoutes,
+	})
+	defer os.RemoveAll(dir)
+
+	r := overlay.NewReporter(mockHostID, overlay.NewFlannel(filepath.Join(dir, "subnet.env"), filepath.Join(dir, "proc")))
+	defer r.Stop()
+	test.Poll(t, 300*time.Millisecond, 3, func() interface{} {
+		rpt, _ := r.Report()
+		return len(rpt.Overlay.Nodes)
+	})
+
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	localID := report.MakeOverlayNodeID(report.FlannelOverlayPeerPrefix, "10.244.0.0")
+	local := rpt.Overlay.Nodes[localID]
+	if hostID, _ := local.Latest.Lookup(report.HostNodeID); hostID != mockHostID {
+		t.Errorf("Expected the local peer to be on host %q, got %q", mockHostID, hostID)
+	}
+	if localNetworks, _ := local.Sets.Lookup(host.LocalNetworks); !localNetworks.Contains("10.244.0.0/24") {
+		t.Errorf("Expected the local peer's subnet to be a local network, got %v", localNetworks)
+	}
+	if !local.Adjacency.Contains(report.MakeOverlayNodeID(report.FlannelOverlayPeerPrefix, "10.244.1.0")) {
+		t.Errorf("Expected the local peer to be adjacent to the others, got %v", local.Adjacency)
+	}
+
+	remote := rpt.Overlay.Nodes[report.MakeOverlayNodeID(report.FlannelOverlayPeerPrefix, "10.244.2.0")]
+	if _, ok := remote.Latest.Lookup(report.HostNodeID); ok {
+		t.Error("Expected remote peers not to be on this host")
+	}
+	if endpoint, _ := remote.Latest.Lookup(overlay.TunnelEndpoint); endpoint != "10.244.2.0" {
+		t.Errorf("Unexpected tunnel endpoint %q", endpoint)
+	}
+	if prefix, name := report.ParseOverlayNodeID(remote.ID); prefix != report.FlannelOverlayPeerPrefix || name != "10.244.2.0" {
+		t.Errorf("Unexpected overlay node ID %q", remote.ID)
+	}
+}
@@ -0,0 +1,227 @@
+package overlay
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/backoff"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node
+const (
+	PeerName                              = "overlay_peer_name"
+	PeerHostname                          = "overlay_peer_hostname"
+	PeerNetwork                           = "overlay_network"
+	TunnelType                            = "overlay_tunnel_type"
+	TunnelEndpoint                        = "overlay_tunnel_endpoint"
+	PeerSubnets                           = "overlay_subnets"
+	PeerCount                             = "overlay_peer_count"
+	PeerConnectionsPeer                   = "overlay_connection_peer"
+	PeerConnectionsState                  = "overlay_connection_state"
+	PeerConnectionsMulticolumnTablePrefix = "overlay_connections_multicolumn_table_"
+)
+
+var (
+	overlayMetadata = report.MetadataTemplates{
+		PeerNetwork:    {ID: PeerNetwork, Label: "Network", From: report.FromLatest, Priority: 1},
+		PeerName:       {ID: PeerName, Label: "Name", From: report.FromLatest, Priority: 3},
+		PeerHostname:   {ID: PeerHostname, Label: "Hostname", From: report.FromLatest, Priority: 4},
+		TunnelType:     {ID: TunnelType, Label: "Tunnel", From: report.FromLatest, Priority: 5},
+		TunnelEndpoint: {ID: TunnelEndpoint, Label: "Tunnel Endpoint", From: report.FromLatest, Priority: 6},
+		PeerCount:      {ID: PeerCount, Label: "Peers", From: report.FromLatest, Priority: 7},
+		PeerSubnets:    {ID: PeerSubnets, Label: "Subnets", From: report.FromSets, Priority: 9},
+	}
+
+	overlayTableTemplates = report.TableTemplates{
+		PeerConnectionsMulticolumnTablePrefix: {
+			ID:     PeerConnectionsMulticolumnTablePrefix,
+			Type:   report.MulticolumnTableType,
+			Prefix: PeerConnectionsMulticolumnTablePrefix,
+			Columns: []report.Column{
+				{
+					ID:    PeerConnectionsPeer,
+					Label: "Connections",
+				},
+				{
+					ID:    PeerConnectionsState,
+					Label: "State",
+				},
+			},
+		},
+	}
+)
+
+// Peer is a node of an overlay network, as seen by the local agent of the
+// network.
+type Peer struct {
+	// Name identifies the peer in the overlay network. Probes on different
+	// hosts must agree on it.
+	Name     string
+	Hostname string
+	// Local is set for the peer on the same host as the probe.
+	Local bool
+	// Subnets are the address ranges (usually of pods) allocated to the peer.
+	Subnets []string
+	// TunnelEndpoint is the address other peers send encapsulated traffic
+	// for the subnets of this peer to.
+	TunnelEndpoint string
+	TunnelType     string
+	// Connections are the peers this peer has tunnels or routing sessions to.
+	Connections []Connection
+}
+
+// Connection is a connection between two peers of an overlay network.
+type Connection struct {
+	Peer  string // Name of the other peer
+	State string
+}
+
+// Source reads the state of an overlay network from its local agent, e.g.
+// its configuration files or API. Sources are polled by Reporters.
+type Source interface {
+	// Name of the overlay network, e.g. "Calico"
+	Name() string
+	// PeerPrefix is the prefix of the overlay node IDs of the peers.
+	PeerPrefix() string
+	// Peers returns all peers known to the local agent, including the
+	// local one.
+	Peers() ([]Peer, error)
+}
+
+// Reporter reports the peers of an overlay network other than Weave Net,
+// which has a richer reporter of its own. It produces an Overlay topology
+// where peers are adjacent to those they have connections to.
+type Reporter struct {
+	source Source
+	hostID string
+
+	mtx   sync.RWMutex
+	peers []Peer
+
+	backoff backoff.Interface
+}
+
+// NewReporter returns a new Reporter of the overlay network read by source.
+func NewReporter(hostID string, source Source) *Reporter {
+	r := &Reporter{
+		source: source,
+		hostID: hostID,
+	}
+
+	r.backoff = backoff.New(r.collect, fmt.Sprintf("collecting %s peers", source.Name()))
+	r.backoff.SetInitialBackoff(5 * time.Second)
+	go r.backoff.Start()
+
+	return r
+}
+
+// Name of this reporter, for metrics gathering
+func (r *Reporter) Name() string { return r.source.Name() }
+
+// Stop polling the source.
+func (r *Reporter) Stop() {
+	r.backoff.Stop()
+}
+
+func (r *Reporter) collect() (bool, error) {
+	peers, err := r.source.Peers()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err != nil {
+		r.peers = nil
+	} else {
+		r.peers = peers
+	}
+	return false, err
+}
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	rpt := report.MakeReport()
+	rpt.Overlay = rpt.Overlay.WithMetadataTemplates(overlayMetadata).WithTableTemplates(overlayTableTemplates)
+
+	// Like Weave Net, we report nodes for all peers to highlight those not
+	// monitored by Scope.
+	for _, peer := range r.peers {
+		rpt.Overlay.AddNode(r.peerNode(peer))
+	}
+	return rpt, nil
+}
+
+func (r *Reporter) peerNode(peer Peer) report.Node {
+	prefix := r.source.PeerPrefix()
+	latests := map[string]string{
+		PeerName:    peer.Name,
+		PeerNetwork: r.source.Name(),
+	}
+	if peer.Hostname != "" {
+		latests[PeerHostname] = peer.Hostname
+	}
+	if peer.TunnelType != "" {
+		latests[TunnelType] = peer.TunnelType
+	}
+	if peer.TunnelEndpoint != "" {
+		latests[TunnelEndpoint] = peer.TunnelEndpoint
+	}
+
+	node := report.MakeNode(report.MakeOverlayNodeID(prefix, peer.Name)).
+		WithSet(PeerSubnets, report.MakeStringSet(peer.Subnets...))
+	for _, conn := range peer.Connections {
+		node = node.WithAdjacent(report.MakeOverlayNodeID(prefix, conn.Peer))
+	}
+
+	if peer.Local {
+		latests[report.HostNodeID] = r.hostID
+		latests[PeerCount] = fmt.Sprintf("%d", len(r.peers))
+		node = node.
+			WithSet(host.LocalNetworks, report.MakeStringSet(peer.Subnets...)).
+			AddPrefixMulticolumnTable(PeerConnectionsMulticolumnTablePrefix, connectionsTable(peer.Connections)).
+			WithParents(report.MakeSets().Add(report.Host, report.MakeStringSet(r.hostID)))
+	}
+
+	return node.WithLatests(latests)
+}
+
+func connectionsTable(connections []Connection) []report.Row {
+	table := make([]report.Row, 0, len(connections))
+	for _, conn := range connections {
+		table = append(table, report.Row{
+			ID: conn.Peer,
+			Entries: map[string]string{
+				PeerConnectionsPeer:  conn.Peer,
+				PeerConnectionsState: conn.State,
+			},
+		})
+	}
+	return table
+}
+
+// meshConnections connects the local peer to all the others, as overlay
+// networks which tunnel traffic between all hosts do.
+func meshConnections(peers []Peer, state string) {
+	var local *Peer
+	names := []string{}
+	for i := range peers {
+		if peers[i].Local {
+			local = &peers[i]
+		} else {
+			names = append(names, peers[i].Name)
+		}
+	}
+	if local == nil {
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		local.Connections = append(local.Connections, Connection{Peer: name, State: state})
+	}
+}
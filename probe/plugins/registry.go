@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 	"golang.org/x/net/context/ctxhttp"
@@ -22,6 +24,7 @@ import (
 	"github.com/weaveworks/common/backoff"
 	"github.com/weaveworks/common/fs"
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 )
@@ -29,6 +32,7 @@ import (
 // Exposed for testing
 var (
 	transport                 = makeUnixRoundTripper
+	dialPipe                  = dialUnixSocket
 	maxResponseBytes    int64 = 50 * 1024 * 1024
 	errResponseTooLarge       = fmt.Errorf("response must be shorter than 50MB")
 	validPluginName           = regexp.MustCompile("^[A-Za-z0-9]+([-][A-Za-z0-9]+)*$")
@@ -37,6 +41,11 @@ var (
 const (
 	pluginTimeout    = 500 * time.Millisecond
 	scanningInterval = 5 * time.Second
+
+	// Streaming plugins must send something (a report, or an empty
+	// heartbeat message) at least this often, or the probe reconnects.
+	streamHeartbeatTimeout = 10 * time.Second
+	streamInitialBackoff   = 1 * time.Second
 )
 
 // ReportPublisher is an interface for publishing reports immediately
@@ -53,15 +62,17 @@ type Registry struct {
 	lock              sync.RWMutex
 	context           context.Context
 	cancel            context.CancelFunc
+	controlsLock      sync.Mutex // protects controlsByPlugin, and is never held while waiting for a plugin
 	controlsByPlugin  map[string]report.StringSet
 	pluginsByID       map[string]*Plugin
 	handlerRegistry   *controls.HandlerRegistry
 	publisher         ReportPublisher
+	pipes             controls.PipeClient
 }
 
 // NewRegistry creates a new registry which watches the given dir root for new
 // plugins, and adds them.
-func NewRegistry(rootPath, apiVersion string, handshakeMetadata map[string]string, handlerRegistry *controls.HandlerRegistry, publisher ReportPublisher) (*Registry, error) {
+func NewRegistry(rootPath, apiVersion string, handshakeMetadata map[string]string, handlerRegistry *controls.HandlerRegistry, publisher ReportPublisher, pipes controls.PipeClient) (*Registry, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	r := &Registry{
 		rootPath:          rootPath,
@@ -74,6 +85,7 @@ func NewRegistry(rootPath, apiVersion string, handshakeMetadata map[string]strin
 		pluginsByID:       map[string]*Plugin{},
 		handlerRegistry:   handlerRegistry,
 		publisher:         publisher,
+		pipes:             pipes,
 	}
 	if err := r.scan(); err != nil {
 		r.Close()
@@ -222,11 +234,23 @@ func (r *Registry) Report() (report.Report, error) {
 		if plugin.Implements("controller") {
 			r.updateAndRegisterControlsInReport(&pluginReport)
 		}
+		if plugin.Implements("streamer") {
+			r.startStreaming(plugin)
+		}
 		rpt = rpt.Merge(pluginReport)
 	})
 	return rpt, nil
 }
 
+// Health implements the probe.HealthChecker interface
+func (r *Registry) Health() []probe.Health {
+	result := []probe.Health{}
+	r.ForEach(func(plugin *Plugin) {
+		result = append(result, plugin.health.Health())
+	})
+	return result
+}
+
 func (r *Registry) updateAndRegisterControlsInReport(rpt *report.Report) {
 	key := rpt.Plugins.Keys()[0]
 	spec, _ := rpt.Plugins.Lookup(key)
@@ -274,6 +298,8 @@ func (r *Registry) updateAndGetControlsInTopology(pluginID string, topology *rep
 }
 
 func (r *Registry) updatePluginControls(pluginID string, newPluginControls report.StringSet) {
+	r.controlsLock.Lock()
+	defer r.controlsLock.Unlock()
 	oldFakePluginControls := r.fakePluginControls(pluginID)
 	newFakePluginControls := map[string]xfer.ControlHandlerFunc{}
 	for _, controlID := range newPluginControls {
@@ -283,6 +309,62 @@ func (r *Registry) updatePluginControls(pluginID string, newPluginControls repor
 	r.controlsByPlugin[pluginID] = newPluginControls
 }
 
+// startStreaming connects to the plugin's /stream endpoint, in the
+// background, reconnecting with backoff if the stream fails. It is only
+// done once per plugin.
+func (r *Registry) startStreaming(plugin *Plugin) {
+	plugin.streamOnce.Do(func() {
+		pluginID := plugin.ID
+		plugin.backoff = backoff.New(func() (bool, error) {
+			return plugin.stream(func(rpt report.Report) {
+				r.publishStreamedShortcut(pluginID, rpt)
+			})
+		}, fmt.Sprintf("streaming reports from plugin %s", pluginID))
+		plugin.backoff.SetInitialBackoff(streamInitialBackoff)
+		go plugin.backoff.Start()
+	})
+}
+
+// publishStreamedShortcut publishes a shortcut report pushed by a plugin.
+// Its controls are rewritten, and added to those registered with the last
+// full report. It must not take the registry lock, as closing the plugin
+// waits for the stream while holding it.
+func (r *Registry) publishStreamedShortcut(pluginID string, rpt report.Report) {
+	if r.publisher == nil {
+		return
+	}
+	var streamedControls []string
+	rpt.WalkTopologies(func(topology *report.Topology) {
+		streamedControls = append(streamedControls, r.updateAndGetControlsInTopology(pluginID, topology)...)
+	})
+	r.addPluginControls(pluginID, report.MakeStringSet(streamedControls...))
+	rpt.Shortcut = true
+	r.publisher.Publish(rpt)
+}
+
+// addPluginControls registers the given controls of a plugin in addition to
+// its current ones. Plugins without registered controls, i.e. those which
+// have not reported yet or have been closed, are left alone.
+func (r *Registry) addPluginControls(pluginID string, pluginControls report.StringSet) {
+	r.controlsLock.Lock()
+	defer r.controlsLock.Unlock()
+	oldPluginControls, ok := r.controlsByPlugin[pluginID]
+	if !ok {
+		return
+	}
+	newFakePluginControls := map[string]xfer.ControlHandlerFunc{}
+	for _, controlID := range pluginControls {
+		if !oldPluginControls.Contains(controlID) {
+			newFakePluginControls[fakeControlID(pluginID, controlID)] = r.pluginControlHandler
+		}
+	}
+	if len(newFakePluginControls) == 0 {
+		return
+	}
+	r.handlerRegistry.Batch(nil, newFakePluginControls)
+	r.controlsByPlugin[pluginID] = oldPluginControls.Merge(pluginControls)
+}
+
 // PluginResponse is an extension of xfer.Response that allows plugins
 // to send the shortcut reports
 type PluginResponse struct {
@@ -296,17 +378,75 @@ func (r *Registry) pluginControlHandler(req xfer.Request) xfer.Response {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 	if plugin, found := r.pluginsByID[pluginID]; found {
+		req.ControlArgs = plugin.translatePipeID(req.ControlArgs)
 		response := plugin.Control(req)
 		if response.ShortcutReport != nil {
 			r.updateAndRegisterControlsInReport(response.ShortcutReport)
 			response.ShortcutReport.Shortcut = true
 			r.publisher.Publish(*response.ShortcutReport)
 		}
+		if response.Pipe != "" && response.Error == "" {
+			return r.openPipe(plugin, req.AppID, response.Response)
+		}
 		return response.Response
 	}
 	return xfer.ResponseErrorf("plugin %s not found", pluginID)
 }
 
+// openPipe connects a pipe the plugin asked for in its control response
+// to the app. The plugin's pipe ID and resize control are replaced with
+// the ones the app should use.
+func (r *Registry) openPipe(plugin *Plugin, appID string, res xfer.Response) xfer.Response {
+	if r.pipes == nil {
+		return xfer.ResponseErrorf("pipes are not supported by this probe")
+	}
+	conn, err := plugin.dialPipe(res.Pipe)
+	if err != nil {
+		return xfer.ResponseErrorf("connecting to pipe %s of plugin %s: %v", res.Pipe, plugin.ID, err)
+	}
+	id, pipe, err := controls.NewPipe(r.pipes, appID)
+	if err != nil {
+		conn.Close()
+		return xfer.ResponseError(err)
+	}
+
+	if res.ResizeTTYControl != "" {
+		res.ResizeTTYControl = fakeControlID(plugin.ID, res.ResizeTTYControl)
+		r.handlerRegistry.Register(res.ResizeTTYControl, r.pluginControlHandler)
+	}
+	plugin.addPipe(id, res.Pipe, res.ResizeTTYControl)
+	pipe.OnClose(func() {
+		conn.Close()
+		r.removePipe(plugin, id)
+	})
+	go func() {
+		local, _ := pipe.Ends()
+		if err := pipe.CopyToWebsocket(local, conn); err != nil && !xfer.IsExpectedWSCloseError(err) {
+			log.Errorf("plugins: pipe %s of plugin %s: %v", res.Pipe, plugin.ID, err)
+		}
+		pipe.Close()
+	}()
+
+	res.Pipe = id
+	return res
+}
+
+// removePipe forgets a closed pipe of a plugin, deregistering its resize
+// control unless another pipe, or the plugin's report, still uses it.
+func (r *Registry) removePipe(plugin *Plugin, id string) {
+	resizeControl, unused := plugin.removePipe(id)
+	if !unused {
+		return
+	}
+	r.controlsLock.Lock()
+	defer r.controlsLock.Unlock()
+	_, controlID := realPluginAndControlID(resizeControl)
+	if r.controlsByPlugin[plugin.ID].Contains(controlID) {
+		return
+	}
+	r.handlerRegistry.Rm(resizeControl)
+}
+
 func realPluginAndControlID(fakeID string) (string, string) {
 	parts := strings.SplitN(fakeID, "~", 2)
 	if len(parts) != 2 {
@@ -326,9 +466,14 @@ func (r *Registry) Close() {
 
 func (r *Registry) closePlugins(plugins map[string]*Plugin) {
 	var toRemove []string
+	r.controlsLock.Lock()
 	for pluginID, plugin := range plugins {
 		toRemove = append(toRemove, r.fakePluginControls(pluginID)...)
+		toRemove = append(toRemove, plugin.resizeControls()...)
 		delete(r.controlsByPlugin, pluginID)
+	}
+	r.controlsLock.Unlock()
+	for _, plugin := range plugins {
 		plugin.Close()
 	}
 	r.handlerRegistry.Batch(toRemove, nil)
@@ -343,6 +488,12 @@ func (r *Registry) fakePluginControls(pluginID string) []string {
 	return oldFakePluginControls
 }
 
+// ControlID returns the ID under which the probe exposes the control of a
+// plugin to the app.
+func ControlID(pluginID, controlID string) string {
+	return fakeControlID(pluginID, controlID)
+}
+
 func fakeControlID(pluginID, controlID string) string {
 	return fmt.Sprintf("%s~%s", pluginID, controlID)
 }
@@ -358,6 +509,26 @@ type Plugin struct {
 	client             *http.Client
 	cancel             context.CancelFunc
 	backoff            backoff.Interface
+	health             *probe.HealthRecorder
+
+	// For streaming plugins
+	streamOnce    sync.Once
+	streamMtx     sync.Mutex
+	streamed      *report.Report // latest full report pushed, nil when not streaming
+	lastHeartbeat time.Time
+
+	// For plugins opening pipes
+	pipeMtx          sync.Mutex
+	pipes            map[string]string // probe pipe ID -> plugin pipe ID
+	resizeControlIDs map[string]string // probe pipe ID -> fake ID of its resize control
+}
+
+// streamMessage is what plugins implementing the "streamer" interface push
+// over their /stream endpoint, as a sequence of JSON objects. A message
+// without a report is a heartbeat.
+type streamMessage struct {
+	Report   *report.Report `json:"report,omitempty"`
+	Shortcut bool           `json:"shortcut,omitempty"`
 }
 
 // NewPlugin loads and initializes a new plugin. If client is nil,
@@ -382,6 +553,9 @@ func NewPlugin(ctx context.Context, socket string, client *http.Client, expected
 		handshakeMetadata:  params,
 		client:             client,
 		cancel:             cancel,
+		health:             probe.NewHealthRecorder(id),
+		pipes:              map[string]string{},
+		resizeControlIDs:   map[string]string{},
 	}
 	return plugin, nil
 }
@@ -398,6 +572,10 @@ func (p *Plugin) Report() (result report.Report, err error) {
 		}
 	}()
 
+	if streamed, ok, err := p.streamedReport(); ok {
+		return streamed, err
+	}
+
 	if err := p.get("/report", p.handshakeMetadata, &result); err != nil {
 		return result, err
 	}
@@ -424,6 +602,93 @@ func (p *Plugin) Report() (result report.Report, err error) {
 	return result, err
 }
 
+// streamedReport returns the latest report pushed by the plugin, if it is
+// streaming. The plugin is in error if it has missed its heartbeat.
+func (p *Plugin) streamedReport() (report.Report, bool, error) {
+	p.streamMtx.Lock()
+	defer p.streamMtx.Unlock()
+	if p.streamed == nil {
+		return report.Report{}, false, nil
+	}
+	rpt := p.streamed.Copy()
+	rpt.Plugins = xfer.MakePluginSpecs()
+	if since := time.Since(p.lastHeartbeat); since > streamHeartbeatTimeout {
+		return rpt, true, fmt.Errorf("no heartbeat for %v", since)
+	}
+	return rpt, true, nil
+}
+
+// stream consumes the reports pushed by the plugin over its /stream
+// endpoint, until the connection fails, the plugin misses its heartbeat, or
+// the plugin is closed. While the plugin is streaming, its /report endpoint
+// is not polled.
+func (p *Plugin) stream(shortcut func(report.Report)) (bool, error) {
+	ctx, cancel := context.WithCancel(p.context)
+	defer cancel()
+	client := http.DefaultClient
+	if p.client != nil {
+		client = &http.Client{Transport: p.client.Transport}
+	}
+	resp, err := ctxhttp.Get(ctx, client, fmt.Sprintf("http://plugin/stream?%s", p.handshakeMetadata.Encode()))
+	if err != nil {
+		return p.context.Err() != nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("plugin returned non-200 status code: %s", resp.Status)
+	}
+	// ctxhttp only cancels requests until the response arrives
+	go func() {
+		<-ctx.Done()
+		resp.Body.Close()
+	}()
+
+	// Drop the connection if the plugin goes quiet
+	watchdog := time.AfterFunc(streamHeartbeatTimeout, cancel)
+	defer watchdog.Stop()
+	defer p.received(nil)
+
+	// Each message may be as large as a polled response
+	body := &maxBytesReader{ReadCloser: resp.Body, err: errResponseTooLarge}
+	decoder := codec.NewDecoder(body, &codec.JsonHandle{})
+	for {
+		var msg streamMessage
+		body.bytesRemaining = maxResponseBytes
+		if err := decoder.Decode(&msg); err != nil {
+			if p.context.Err() != nil {
+				return true, nil
+			}
+			if err == errResponseTooLarge {
+				return false, err
+			}
+			return false, fmt.Errorf("decoding error: %s", err)
+		}
+		watchdog.Reset(streamHeartbeatTimeout)
+		if msg.Report != nil && msg.Shortcut {
+			p.received(&streamMessage{})
+			shortcut(*msg.Report)
+		} else {
+			p.received(&msg)
+		}
+	}
+}
+
+// received records a message pushed by the plugin; nil means the stream
+// has ended.
+func (p *Plugin) received(msg *streamMessage) {
+	p.streamMtx.Lock()
+	defer p.streamMtx.Unlock()
+	switch {
+	case msg == nil:
+		p.streamed = nil
+	case msg.Report != nil:
+		p.streamed = msg.Report
+		p.lastHeartbeat = time.Now()
+	default:
+		p.lastHeartbeat = time.Now()
+	}
+}
+
 // Control sends a control message to a plugin
 func (p *Plugin) Control(request xfer.Request) (res PluginResponse) {
 	var err error
@@ -442,6 +707,83 @@ func (p *Plugin) Control(request xfer.Request) (res PluginResponse) {
 	return res
 }
 
+// dialPipe opens the websocket over which the plugin streams the data of
+// the given pipe.
+func (p *Plugin) dialPipe(pipeID string) (xfer.Websocket, error) {
+	params := url.Values{}
+	for k, v := range p.handshakeMetadata {
+		params[k] = v
+	}
+	params.Set("id", pipeID)
+	dialer := websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) {
+			return dialPipe(p.socket, pluginTimeout)
+		},
+		HandshakeTimeout: pluginTimeout,
+	}
+	conn, _, err := xfer.DialWS(&dialer, fmt.Sprintf("ws://plugin/pipe?%s", params.Encode()), nil)
+	return conn, err
+}
+
+func (p *Plugin) addPipe(id, pluginPipeID, resizeControl string) {
+	p.pipeMtx.Lock()
+	defer p.pipeMtx.Unlock()
+	p.pipes[id] = pluginPipeID
+	if resizeControl != "" {
+		p.resizeControlIDs[id] = resizeControl
+	}
+}
+
+// removePipe forgets a pipe, returning its resize control, and whether no
+// other pipe uses that control.
+func (p *Plugin) removePipe(id string) (string, bool) {
+	p.pipeMtx.Lock()
+	defer p.pipeMtx.Unlock()
+	delete(p.pipes, id)
+	resizeControl, ok := p.resizeControlIDs[id]
+	if !ok {
+		return "", false
+	}
+	delete(p.resizeControlIDs, id)
+	for _, other := range p.resizeControlIDs {
+		if other == resizeControl {
+			return resizeControl, false
+		}
+	}
+	return resizeControl, true
+}
+
+func (p *Plugin) resizeControls() []string {
+	p.pipeMtx.Lock()
+	defer p.pipeMtx.Unlock()
+	ids := report.MakeStringSet()
+	for _, id := range p.resizeControlIDs {
+		ids = ids.Add(id)
+	}
+	return ids
+}
+
+// translatePipeID replaces the pipe ID known to the app in control
+// arguments (e.g. of resize controls) with the one known to the plugin.
+func (p *Plugin) translatePipeID(args map[string]string) map[string]string {
+	pipeID, ok := args["pipeID"]
+	if !ok {
+		return args
+	}
+	p.pipeMtx.Lock()
+	pluginPipeID, ok := p.pipes[pipeID]
+	p.pipeMtx.Unlock()
+	if !ok {
+		return args
+	}
+	result := make(map[string]string, len(args))
+	for k, v := range args {
+		result[k] = v
+	}
+	result["pipeID"] = pluginPipeID
+	return result
+}
+
 // Implements checks if the plugin implements the given interface
 func (p *Plugin) Implements(iface string) bool {
 	for _, i := range p.PluginSpec.Interfaces {
@@ -453,6 +795,7 @@ func (p *Plugin) Implements(iface string) bool {
 }
 
 func (p *Plugin) setStatus(err error) {
+	p.health.Record(err)
 	if err == nil {
 		p.Status = "ok"
 	} else {
@@ -507,8 +850,8 @@ func getResult(body io.ReadCloser, result interface{}) error {
 
 // Close closes the client
 func (p *Plugin) Close() {
+	p.cancel()
 	if p.backoff != nil {
 		p.backoff.Stop()
 	}
-	p.cancel()
 }
@@ -11,10 +11,12 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/paypal/ionet"
 	"github.com/ugorji/go/codec"
 
@@ -30,7 +32,7 @@ import (
 func testRegistry(t *testing.T, apiVersion string) *Registry {
 	handlerRegistry := controls.NewDefaultHandlerRegistry()
 	root := "/plugins"
-	r, err := NewRegistry(root, apiVersion, nil, handlerRegistry, nil)
+	r, err := NewRegistry(root, apiVersion, nil, handlerRegistry, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,7 +42,10 @@ func testRegistry(t *testing.T, apiVersion string) *Registry {
 func stubTransport(fn func(socket string, timeout time.Duration) (http.RoundTripper, error)) {
 	transport = fn
 }
-func restoreTransport() { transport = makeUnixRoundTripper }
+func restoreTransport() {
+	transport = makeUnixRoundTripper
+	dialPipe = dialUnixSocket
+}
 
 type readWriteCloseRoundTripper struct {
 	io.ReadWriteCloser
@@ -782,7 +787,7 @@ func TestRegistryRegistersHandlers(t *testing.T) {
 	testBackend := newTestHandlerRegistryBackend(t)
 	handlerRegistry := controls.NewHandlerRegistry(testBackend)
 	root := "/plugins"
-	r, err := NewRegistry(root, "1", nil, handlerRegistry, nil)
+	r, err := NewRegistry(root, "1", nil, handlerRegistry, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -832,7 +837,7 @@ func TestRegistryHandlersCallPlugins(t *testing.T) {
 
 	handlerRegistry := controls.NewDefaultHandlerRegistry()
 	root := "/plugins"
-	r, err := NewRegistry(root, "1", nil, handlerRegistry, nil)
+	r, err := NewRegistry(root, "1", nil, handlerRegistry, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -846,3 +851,366 @@ func TestRegistryHandlersCallPlugins(t *testing.T) {
 		t.Fatalf("Got unexpected response: %#v", res)
 	}
 }
+
+type chanPublisher chan report.Report
+
+func (p chanPublisher) Publish(rpt report.Report) {
+	p <- rpt
+}
+
+func TestRegistryStreamsFromPlugins(t *testing.T) {
+	spec := pluginSpec("testPlugin", "reporter", "streamer")
+	streamed := report.MakeReport()
+	streamed.Host.AddNode(report.MakeNode("host1;<host>"))
+	shortcut := report.MakeReport()
+	shortcut.Container.AddNode(report.MakeNode("container1;<container>"))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report":
+			fmt.Fprint(w, mustMarshal(testReport(report.MakeTopology().WithLabel("host", "hosts"), spec)))
+		case "/stream":
+			fmt.Fprint(w, mustMarshal(streamMessage{Report: &streamed}))
+			fmt.Fprint(w, mustMarshal(streamMessage{Report: &shortcut, Shortcut: true}))
+			for {
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+					fmt.Fprint(w, mustMarshal(streamMessage{}))
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	mockFS := fs.Dir("", fs.Dir("plugins", fs.File{
+		FName: "testPlugin.sock",
+		FStat: syscall.Stat_t{Mode: syscall.S_IFSOCK},
+	}))
+	fs_hook.Mock(mockFS)
+	stubTransport(func(socket string, timeout time.Duration) (http.RoundTripper, error) {
+		return &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("tcp", ts.Listener.Addr().String())
+			},
+		}, nil
+	})
+	defer restore(t)
+
+	publisher := make(chanPublisher, 1)
+	r, err := NewRegistry("/plugins", "1", nil, controls.NewDefaultHandlerRegistry(), publisher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The first report is polled, and tells the probe to start streaming
+	if rpt, _ := r.Report(); len(rpt.Host.Nodes) != 0 {
+		t.Fatalf("Unexpected polled report: %v", rpt.Host.Nodes)
+	}
+
+	select {
+	case rpt := <-publisher:
+		if !rpt.Shortcut || len(rpt.Container.Nodes) != 1 {
+			t.Errorf("Unexpected shortcut report: %v", rpt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shortcut report not published")
+	}
+
+	rpt, err := r.Report()
+	if err != nil || len(rpt.Host.Nodes) != 1 {
+		t.Fatalf("Expected the streamed report, got %v (%v)", rpt.Host.Nodes, err)
+	}
+	checkLoadedPlugins(t, r.ForEach, []xfer.PluginSpec{
+		{
+			ID:         "testPlugin",
+			Label:      "testPlugin",
+			Interfaces: []string{"reporter", "streamer"},
+			APIVersion: "1",
+			Status:     "ok",
+		},
+	})
+}
+
+func TestRegistryLimitsStreamedMessages(t *testing.T) {
+	spec := pluginSpec("testPlugin", "reporter", "streamer")
+	small := report.MakeReport()
+	small.Host.AddNode(report.MakeNode("host1;<host>"))
+	large := report.MakeReport()
+	for i := 0; i < 100; i++ {
+		large.Host.AddNode(report.MakeNode(fmt.Sprintf("host%d;<host>", i)))
+	}
+	oldMaxResponseBytes := maxResponseBytes
+	maxResponseBytes = int64(len(mustMarshal(streamMessage{Report: &small}))) + 64
+	defer func() { maxResponseBytes = oldMaxResponseBytes }()
+
+	sendLarge := make(chan struct{})
+	var streams int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report":
+			fmt.Fprint(w, mustMarshal(testReport(report.MakeTopology().WithLabel("host", "hosts"), spec)))
+		case "/stream":
+			if atomic.AddInt32(&streams, 1) > 1 {
+				<-r.Context().Done()
+				return
+			}
+			// Together, the messages are larger than the limit
+			for i := 0; i < 10; i++ {
+				fmt.Fprint(w, mustMarshal(streamMessage{Report: &small}))
+			}
+			fmt.Fprint(w, mustMarshal(streamMessage{Report: &small, Shortcut: true}))
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-sendLarge:
+			}
+			fmt.Fprint(w, mustMarshal(streamMessage{Report: &large}))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	mockFS := fs.Dir("", fs.Dir("plugins", fs.File{
+		FName: "testPlugin.sock",
+		FStat: syscall.Stat_t{Mode: syscall.S_IFSOCK},
+	}))
+	fs_hook.Mock(mockFS)
+	stubTransport(func(socket string, timeout time.Duration) (http.RoundTripper, error) {
+		return &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("tcp", ts.Listener.Addr().String())
+			},
+		}, nil
+	})
+	defer restore(t)
+
+	publisher := make(chanPublisher, 1)
+	r, err := NewRegistry("/plugins", "1", nil, controls.NewDefaultHandlerRegistry(), publisher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Report()
+	select {
+	case <-publisher:
+	case <-time.After(time.Second):
+		t.Fatal("Shortcut report not published")
+	}
+	if rpt, err := r.Report(); err != nil || len(rpt.Host.Nodes) != 1 {
+		t.Fatalf("Expected the streamed report, got %v (%v)", rpt.Host.Nodes, err)
+	}
+
+	// The stream ends with the large message, and reports are polled again
+	close(sendLarge)
+	deadline := time.Now().Add(time.Second)
+	for {
+		rpt, err := r.Report()
+		if err == nil && len(rpt.Host.Nodes) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the polled report, got %d nodes (%v)", len(rpt.Host.Nodes), err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type chanPipeClient chan xfer.Pipe
+
+func (c chanPipeClient) PipeConnection(_, _ string, pipe xfer.Pipe) error {
+	c <- pipe
+	return nil
+}
+
+func (c chanPipeClient) PipeClose(_, _ string) error { return nil }
+
+func TestRegistryOpensPipesForPlugins(t *testing.T) {
+	spec := xfer.PluginSpec{
+		ID:         "testPlugin",
+		Label:      "testPlugin",
+		Interfaces: []string{"reporter", "controller"},
+		APIVersion: "1",
+	}
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report":
+			fmt.Fprint(w, mustMarshal(testReport(report.MakeTopology().WithLabel("host", "hosts"), spec)))
+		case "/control":
+			var req xfer.Request
+			mustUnmarshal(r.Body, &req)
+			switch req.Control {
+			case "console":
+				fmt.Fprint(w, mustMarshal(xfer.Response{Pipe: "console-1", RawTTY: true, ResizeTTYControl: "resize"}))
+			case "resize":
+				if req.ControlArgs["pipeID"] != "console-1" {
+					fmt.Fprint(w, mustMarshal(xfer.ResponseErrorf("unknown pipe %q", req.ControlArgs["pipeID"])))
+					return
+				}
+				fmt.Fprint(w, "{}")
+			}
+		case "/pipe":
+			if id := r.URL.Query().Get("id"); id != "console-1" {
+				http.Error(w, "unknown pipe "+id, http.StatusNotFound)
+				return
+			}
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				_, buf, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err := conn.WriteMessage(websocket.BinaryMessage, append([]byte("echo: "), buf...)); err != nil {
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	mockFS := fs.Dir("", fs.Dir("plugins", fs.File{
+		FName: "testPlugin.sock",
+		FStat: syscall.Stat_t{Mode: syscall.S_IFSOCK},
+	}))
+	fs_hook.Mock(mockFS)
+	dial := func(_, _ string) (net.Conn, error) {
+		return net.Dial("tcp", ts.Listener.Addr().String())
+	}
+	stubTransport(func(socket string, timeout time.Duration) (http.RoundTripper, error) {
+		return &http.Transport{Dial: dial}, nil
+	})
+	dialPipe = func(socket string, timeout time.Duration) (net.Conn, error) {
+		return dial("tcp", socket)
+	}
+	defer restore(t)
+
+	pipes := make(chanPipeClient, 1)
+	handlerRegistry := controls.NewDefaultHandlerRegistry()
+	r, err := NewRegistry("/plugins", "1", nil, handlerRegistry, nil, pipes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Report()
+
+	res := r.pluginControlHandler(xfer.Request{AppID: "app1", Control: fakeControlID("testPlugin", "console")})
+	if res.Error != "" || res.Pipe == "" || res.Pipe == "console-1" || !res.RawTTY {
+		t.Fatalf("Unexpected response: %#v", res)
+	}
+	if res.ResizeTTYControl != fakeControlID("testPlugin", "resize") {
+		t.Fatalf("Unexpected resize control: %q", res.ResizeTTYControl)
+	}
+
+	var pipe xfer.Pipe
+	select {
+	case pipe = <-pipes:
+	case <-time.After(time.Second):
+		t.Fatal("Pipe not connected to the app")
+	}
+	defer pipe.Close()
+	_, remote := pipe.Ends()
+	if _, err := remote.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := remote.Read(buf)
+	if err != nil || string(buf[:n]) != "echo: hello" {
+		t.Fatalf("Unexpected pipe output: %q (%v)", buf[:n], err)
+	}
+
+	// Resize requests are routed to the plugin, with its own pipe ID
+	res = handlerRegistry.HandleControlRequest(xfer.Request{
+		AppID:       "app1",
+		Control:     res.ResizeTTYControl,
+		ControlArgs: map[string]string{"pipeID": res.Pipe, "height": "24", "width": "80"},
+	})
+	if res.Error != "" {
+		t.Fatalf("Unexpected resize error: %s", res.Error)
+	}
+
+	// Closing the pipe deregisters its resize control
+	resizeControl := fakeControlID("testPlugin", "resize")
+	pipe.Close()
+	res = handlerRegistry.HandleControlRequest(xfer.Request{AppID: "app1", Control: resizeControl})
+	if res.Error == "" {
+		t.Errorf("Expected resize control %s to be deregistered", resizeControl)
+	}
+}
+
+func TestRegistryRegistersStreamedControls(t *testing.T) {
+	spec := pluginSpec("testPlugin", "reporter", "controller", "streamer")
+	shortcut := report.MakeReport()
+	shortcut.Container.AddNode(report.MakeNode("container1;<container>"))
+	shortcut.Container.Controls.AddControl(report.Control{ID: "restart"})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report":
+			fmt.Fprint(w, mustMarshal(testReport(report.MakeTopology().WithLabel("host", "hosts"), spec)))
+		case "/stream":
+			fmt.Fprint(w, mustMarshal(streamMessage{Report: &shortcut, Shortcut: true}))
+			for {
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+					fmt.Fprint(w, mustMarshal(streamMessage{}))
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	mockFS := fs.Dir("", fs.Dir("plugins", fs.File{
+		FName: "testPlugin.sock",
+		FStat: syscall.Stat_t{Mode: syscall.S_IFSOCK},
+	}))
+	fs_hook.Mock(mockFS)
+	stubTransport(func(socket string, timeout time.Duration) (http.RoundTripper, error) {
+		return &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("tcp", ts.Listener.Addr().String())
+			},
+		}, nil
+	})
+	defer restore(t)
+
+	publisher := make(chanPublisher, 1)
+	r, err := NewRegistry("/plugins", "1", nil, controls.NewDefaultHandlerRegistry(), publisher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Report()
+	select {
+	case <-publisher:
+	case <-time.After(time.Second):
+		t.Fatal("Shortcut report not published")
+	}
+	r.controlsLock.Lock()
+	defer r.controlsLock.Unlock()
+	if !r.controlsByPlugin["testPlugin"].Contains("restart") {
+		t.Errorf("Expected the streamed control to be registered, got %v", r.controlsByPlugin["testPlugin"])
+	}
+}
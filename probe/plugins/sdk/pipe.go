@@ -0,0 +1,140 @@
+package sdk
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// ResizeTTYControl is the control the probe sends to the plugin when the UI
+// terminal of one of its pipes is resized. It is handled by the SDK.
+const ResizeTTYControl = "sdk_resize_tty"
+
+// Pipes which the probe hasn't connected to by then are dropped.
+const pipeConnectTimeout = 10 * time.Second
+
+// PipeHandler serves a pipe opened by a control. It reads what the user
+// types from rw, and writes what the UI shows to it. It should return once
+// reading fails, which means the pipe was closed in the UI; the pipe is
+// closed in the UI once it returns.
+type PipeHandler func(rw io.ReadWriter)
+
+// ResizeFunc is called when the UI terminal of a pipe is resized.
+type ResizeFunc func(width, height uint)
+
+type pipe struct {
+	handler   PipeHandler
+	resize    ResizeFunc
+	connected bool
+}
+
+// OpenPipe sets up a pipe served by handler, and returns the response to
+// the control request which makes the probe connect it to the UI. If
+// resize is not nil, the pipe is a terminal and resize is called whenever
+// it is resized.
+func (p *Plugin) OpenPipe(handler PipeHandler, resize ResizeFunc) Response {
+	p.pipeMtx.Lock()
+	p.lastPipeID++
+	id := fmt.Sprintf("pipe-%d", p.lastPipeID)
+	p.pipes[id] = &pipe{handler: handler, resize: resize}
+	p.pipeMtx.Unlock()
+
+	time.AfterFunc(pipeConnectTimeout, func() {
+		p.pipeMtx.Lock()
+		defer p.pipeMtx.Unlock()
+		if pipe, ok := p.pipes[id]; ok && !pipe.connected {
+			delete(p.pipes, id)
+		}
+	})
+
+	res := Response{Response: xfer.Response{Pipe: id}}
+	if resize != nil {
+		res.RawTTY = true
+		res.ResizeTTYControl = ResizeTTYControl
+	}
+	return res
+}
+
+func (p *Plugin) resizePipe(req xfer.Request) Response {
+	p.pipeMtx.Lock()
+	pipe, ok := p.pipes[req.ControlArgs["pipeID"]]
+	p.pipeMtx.Unlock()
+	if !ok || pipe.resize == nil {
+		return Response{Response: xfer.ResponseErrorf("unknown pipe %q", req.ControlArgs["pipeID"])}
+	}
+	width, err := strconv.ParseUint(req.ControlArgs["width"], 10, 32)
+	if err != nil {
+		return Response{Response: xfer.ResponseErrorf("invalid width: %v", err)}
+	}
+	height, err := strconv.ParseUint(req.ControlArgs["height"], 10, 32)
+	if err != nil {
+		return Response{Response: xfer.ResponseErrorf("invalid height: %v", err)}
+	}
+	pipe.resize(uint(width), uint(height))
+	return Response{}
+}
+
+// servePipe connects the websocket of the probe to the handler of a pipe.
+// Each pipe can only be connected once.
+func (p *Plugin) servePipe(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	p.pipeMtx.Lock()
+	pipe, ok := p.pipes[id]
+	if ok && !pipe.connected {
+		pipe.connected = true
+	} else {
+		ok = false
+	}
+	p.pipeMtx.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	defer func() {
+		p.pipeMtx.Lock()
+		delete(p.pipes, id)
+		p.pipeMtx.Unlock()
+	}()
+
+	conn, err := xfer.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	pipe.handler(&websocketReadWriter{conn: conn})
+}
+
+// websocketReadWriter reads and writes the binary messages of a websocket
+// as a stream of bytes.
+type websocketReadWriter struct {
+	conn xfer.Websocket
+	buf  []byte
+}
+
+func (rw *websocketReadWriter) Read(b []byte) (int, error) {
+	for len(rw.buf) == 0 {
+		_, buf, err := rw.conn.ReadMessage()
+		if xfer.IsExpectedWSCloseError(err) {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, err
+		}
+		rw.buf = buf
+	}
+	n := copy(b, rw.buf)
+	rw.buf = rw.buf[n:]
+	return n, nil
+}
+
+func (rw *websocketReadWriter) Write(b []byte) (int, error) {
+	if err := rw.conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,235 @@
+/*
+Package sdk helps writing Scope probe plugins in Go.
+
+A plugin builds reports with NewReport, serves them, along with its
+controls, on a UNIX socket in the probe's plugins directory:
+
+	p := sdk.New("my-plugin", "My Plugin", "Does things", sdk.ReporterFunc(report))
+	p.RegisterControl("my-control", handleMyControl)
+	if err := p.ListenAndServe(sdk.DefaultPluginsRoot); err != nil {
+		log.Fatal(err)
+	}
+
+Plugins which react to events can push their reports with Publish instead
+of waiting to be polled, and controls can open pipes to the UI with
+OpenPipe.
+
+Package sdktest runs plugins against a probe's plugin registry in tests.
+*/
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// APIVersion is the version of the plugin protocol spoken by the SDK.
+	APIVersion = "1"
+
+	// DefaultPluginsRoot is where probes look for plugin sockets by default.
+	DefaultPluginsRoot = "/var/run/scope/plugins"
+)
+
+// Reporter produces the reports of a plugin.
+type Reporter interface {
+	Report() (*Report, error)
+}
+
+// ReporterFunc is an adapter to use ordinary functions as Reporters.
+type ReporterFunc func() (*Report, error)
+
+// Report implements Reporter.
+func (f ReporterFunc) Report() (*Report, error) {
+	return f()
+}
+
+// Response is the response of a plugin to a control request. The shortcut
+// report, if any, is published straight away, e.g. to hide a control which
+// no longer applies.
+type Response struct {
+	xfer.Response
+	ShortcutReport *report.Report `json:"shortcutReport,omitempty"`
+}
+
+// ControlHandler handles the requests for a control of a plugin.
+type ControlHandler func(xfer.Request) Response
+
+// Plugin serves reports and controls to a Scope probe. It implements
+// http.Handler.
+type Plugin struct {
+	spec     xfer.PluginSpec
+	reporter Reporter
+
+	mtx       sync.Mutex
+	controls  map[string]ControlHandler
+	streaming bool
+
+	streamMtx sync.Mutex
+	streamed  *report.Report // latest report published, nil until the plugin streams
+	streams   map[chan streamMessage]struct{}
+
+	pipeMtx    sync.Mutex
+	pipes      map[string]*pipe
+	lastPipeID int
+}
+
+// New makes a new Plugin. The id must be unique among the plugins of a
+// probe, and may only contain alphanumeric sequences separated by dashes.
+func New(id, label, description string, reporter Reporter) *Plugin {
+	return &Plugin{
+		spec: xfer.PluginSpec{
+			ID:          id,
+			Label:       label,
+			Description: description,
+			Interfaces:  []string{"reporter"},
+			APIVersion:  APIVersion,
+		},
+		reporter: reporter,
+		controls: map[string]ControlHandler{},
+		streams:  map[chan streamMessage]struct{}{},
+		pipes:    map[string]*pipe{},
+	}
+}
+
+// ID returns the ID of the plugin.
+func (p *Plugin) ID() string {
+	return p.spec.ID
+}
+
+// RegisterControl makes the plugin handle requests for the given control,
+// which should be added to the topologies reported with Topology.Control.
+func (p *Plugin) RegisterControl(id string, handler ControlHandler) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.controls[id] = handler
+}
+
+func (p *Plugin) pluginSpec() xfer.PluginSpec {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	spec := p.spec
+	spec.Interfaces = append([]string{}, spec.Interfaces...)
+	if len(p.controls) > 0 {
+		spec.Interfaces = append(spec.Interfaces, "controller")
+	}
+	if p.streaming {
+		spec.Interfaces = append(spec.Interfaces, "streamer")
+	}
+	return spec
+}
+
+// Report returns the report the plugin sends to the probe.
+func (p *Plugin) Report() (report.Report, error) {
+	r, err := p.reporter.Report()
+	if err != nil {
+		return report.MakeReport(), err
+	}
+	rpt := r.Build()
+	rpt.Plugins = xfer.MakePluginSpecs(p.pluginSpec())
+	return rpt, nil
+}
+
+// Control handles a control request of the probe.
+func (p *Plugin) Control(req xfer.Request) Response {
+	if req.Control == ResizeTTYControl {
+		return p.resizePipe(req)
+	}
+	p.mtx.Lock()
+	handler, ok := p.controls[req.Control]
+	p.mtx.Unlock()
+	if !ok {
+		return Response{Response: xfer.ResponseErrorf("unknown control %q", req.Control)}
+	}
+	res := handler(req)
+	if res.ShortcutReport != nil {
+		res.ShortcutReport.Plugins = xfer.MakePluginSpecs(p.pluginSpec())
+	}
+	return res
+}
+
+// ServeHTTP implements http.Handler, speaking the plugin protocol.
+func (p *Plugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/report":
+		rpt, err := p.Report()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondWith(w, rpt)
+	case "/control":
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req xfer.Request
+		if err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+		respondWith(w, p.Control(req))
+	case "/stream":
+		p.serveStream(w, r)
+	case "/pipe":
+		p.servePipe(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// respondWith encodes the value before writing anything, so encoding
+// errors can still be reported with an error status.
+func respondWith(w http.ResponseWriter, value interface{}) {
+	buf, err := encodeJSON(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+func encodeJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Listen creates the socket of the plugin in a sub-directory of the given
+// plugins directory named after the plugin, removing any left behind by a
+// previous run.
+func (p *Plugin) Listen(root string) (net.Listener, error) {
+	dir := filepath.Join(root, p.spec.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, p.spec.ID+".sock")
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", socket)
+}
+
+// ListenAndServe serves the plugin on its socket in the given plugins
+// directory. It blocks until serving fails.
+func (p *Plugin) ListenAndServe(root string) error {
+	listener, err := p.Listen(root)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	return http.Serve(listener, p)
+}
@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"sort"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Report builds the report a plugin sends to the probe. Plugins describe
+// what they know about nodes in the probe's topologies (e.g. report.Host),
+// or in topologies of their own.
+type Report struct {
+	topologies map[string]*Topology
+}
+
+// NewReport makes a new, empty Report.
+func NewReport() *Report {
+	return &Report{topologies: map[string]*Topology{}}
+}
+
+// Topology returns the builder for one of the probe's topologies, e.g.
+// report.Host or report.Container.
+func (r *Report) Topology(name string) *Topology {
+	if t, ok := r.topologies[name]; ok {
+		return t
+	}
+	topology, ok := report.MakeReport().Topology(name)
+	if !ok {
+		topology = report.MakeTopology().WithLabel(name, name+"s")
+	}
+	t := &Topology{topology: topology, nodes: map[string]*Node{}}
+	r.topologies[name] = t
+	return t
+}
+
+// PluginTopology returns the builder for a topology defined by the plugin,
+// which the UI shows as a view of its own. The labels are those of a single
+// node and of several nodes, e.g. "queue" and "queues".
+func (r *Report) PluginTopology(name, label, labelPlural string) *Topology {
+	t := r.Topology(name)
+	t.topology = t.topology.WithLabel(label, labelPlural)
+	return t
+}
+
+// Build returns the report.Report described so far.
+func (r *Report) Build() report.Report {
+	rpt := report.MakeReport()
+	for name, t := range r.topologies {
+		topology := t.build()
+		if report.IsPluginTopology(name) {
+			rpt.PluginTopologies[name] = topology
+			continue
+		}
+		rpt.WalkNamedTopologies(func(n string, t *report.Topology) {
+			if n == name {
+				*t = topology
+			}
+		})
+	}
+	return rpt
+}
+
+// Topology builds a topology of a plugin's report: the templates telling
+// the UI how to show the data of its nodes, its controls, and the nodes
+// themselves.
+type Topology struct {
+	topology report.Topology
+	nodes    map[string]*Node
+}
+
+// Metadata adds a template for showing the metadata key id of the nodes.
+// Rows of higher priority are shown further down.
+func (t *Topology) Metadata(id, label string, priority float64) *Topology {
+	t.topology = t.topology.WithMetadataTemplates(report.MetadataTemplates{
+		id: {ID: id, Label: label, Priority: priority, From: report.FromLatest},
+	})
+	return t
+}
+
+// Metric adds a template for showing the metric id of the nodes. The
+// format is one of those understood by the UI, e.g. "percent" or
+// "filesize"; the empty format shows plain numbers.
+func (t *Topology) Metric(id, label, format string, priority float64) *Topology {
+	t.topology = t.topology.WithMetricTemplates(report.MetricTemplates{
+		id: {ID: id, Label: label, Format: format, Priority: priority},
+	})
+	return t
+}
+
+// PropertyList adds a template for a table of key-value pairs, filled in
+// with Node.Property.
+func (t *Topology) PropertyList(prefix, label string) *Topology {
+	t.topology = t.topology.WithTableTemplates(report.TableTemplates{
+		prefix: {ID: prefix, Label: label, Prefix: prefix, Type: report.PropertyListType},
+	})
+	return t
+}
+
+// MulticolumnTable adds a template for a table with the given columns,
+// filled in with Node.Row.
+func (t *Topology) MulticolumnTable(prefix, label string, columns ...report.Column) *Topology {
+	t.topology = t.topology.WithTableTemplates(report.TableTemplates{
+		prefix: {ID: prefix, Label: label, Prefix: prefix, Type: report.MulticolumnTableType, Columns: columns},
+	})
+	return t
+}
+
+// Control adds a control which nodes may enable with Node.Control. The icon
+// is taken from http://fontawesome.io/cheatsheet/, e.g. "fa-stop".
+func (t *Topology) Control(id, human, icon string, rank int) *Topology {
+	t.topology.Controls.AddControl(report.Control{ID: id, Human: human, Icon: icon, Rank: rank})
+	return t
+}
+
+// Node returns the builder for the node with the given ID, which must match
+// the ID the probe gives the node if the plugin adds to a node the probe
+// knows about, e.g. report.MakeHostNodeID(hostname).
+func (t *Topology) Node(id string) *Node {
+	if n, ok := t.nodes[id]; ok {
+		return n
+	}
+	n := &Node{
+		node: report.MakeNode(id),
+		rows: map[string][]report.Row{},
+		now:  time.Now(),
+	}
+	t.nodes[id] = n
+	return n
+}
+
+func (t *Topology) build() report.Topology {
+	result := t.topology.Copy()
+	ids := make([]string, 0, len(t.nodes))
+	for id := range t.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		result.AddNode(t.nodes[id].build())
+	}
+	return result
+}
+
+// Node builds a node of a plugin's report.
+type Node struct {
+	node report.Node
+	rows map[string][]report.Row
+	now  time.Time
+}
+
+// Set sets the metadata key of the node.
+func (n *Node) Set(key, value string) *Node {
+	n.node = n.node.WithLatest(key, n.now, value)
+	return n
+}
+
+// Metric adds a sample of the metric key of the node, taken now. Metrics
+// with a maximum (e.g. percentages) are drawn relative to it by the UI; a
+// zero max means there is none.
+func (n *Node) Metric(key string, value, max float64) *Node {
+	metric := report.MakeSingletonMetric(n.now, value)
+	if max != 0 {
+		metric = metric.WithMax(max)
+	}
+	n.node = n.node.WithMetric(key, metric)
+	return n
+}
+
+// Property sets a key of the property list with the given prefix.
+func (n *Node) Property(prefix, key, value string) *Node {
+	n.node = n.node.AddPrefixPropertyList(prefix, map[string]string{key: value})
+	return n
+}
+
+// Row adds a row to the multicolumn table with the given prefix. The
+// entries are keyed by column ID.
+func (n *Node) Row(prefix, id string, entries map[string]string) *Node {
+	n.rows[prefix] = append(n.rows[prefix], report.Row{ID: id, Entries: entries})
+	return n
+}
+
+// Parent adds the node with the given ID in another topology, e.g.
+// report.Host, as a parent of the node.
+func (n *Node) Parent(topology, id string) *Node {
+	n.node = n.node.WithParents(report.MakeSets().Add(topology, report.MakeStringSet(id)))
+	return n
+}
+
+// Control enables the controls with the given IDs on the node.
+func (n *Node) Control(ids ...string) *Node {
+	n.node = n.node.WithLatestActiveControls(ids...)
+	return n
+}
+
+func (n *Node) build() report.Node {
+	result := n.node
+	for prefix, rows := range n.rows {
+		result = result.AddPrefixMulticolumnTable(prefix, rows)
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package sdk_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/plugins/sdk"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestReportBuild(t *testing.T) {
+	hostID := report.MakeHostNodeID("host1")
+	queueID := report.MakeProcessNodeID("host1", "queue1")
+
+	r := sdk.NewReport()
+	r.Topology(report.Host).
+		PropertyList("queues_", "Queues").
+		Node(hostID).Property("queues_", "count", "1")
+	r.PluginTopology("queues", "queue", "queues").
+		MulticolumnTable("consumers_", "Consumers", report.Column{ID: "addr", Label: "Address"}).
+		Node(queueID).
+		Row("consumers_", "c1", map[string]string{"addr": "10.0.0.1"}).
+		Row("consumers_", "c2", map[string]string{"addr": "10.0.0.2"})
+	rpt := r.Build()
+
+	if err := rpt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if rpt.Host.Label != "host" {
+		t.Errorf("Expected the host topology to keep its label, got %q", rpt.Host.Label)
+	}
+	host := rpt.Host.Nodes[hostID]
+	if rows := host.ExtractPropertyList(rpt.Host.TableTemplates["queues_"]); len(rows) != 1 {
+		t.Errorf("Unexpected property list: %v", rows)
+	}
+
+	queues, ok := rpt.PluginTopologies["queues"]
+	if !ok || queues.LabelPlural != "queues" {
+		t.Fatalf("Unexpected plugin topologies: %v", rpt.PluginTopologies)
+	}
+	queue := queues.Nodes[queueID]
+	if rows := queue.ExtractMulticolumnTable(queues.TableTemplates["consumers_"]); len(rows) != 2 {
+		t.Errorf("Unexpected multicolumn table: %v", rows)
+	}
+}
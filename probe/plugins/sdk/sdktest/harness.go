@@ -0,0 +1,139 @@
+// Package sdktest runs plugins written with the sdk package in-process,
+// against a real probe plugin registry, for use in tests.
+package sdktest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/plugins"
+	"github.com/weaveworks/scope/probe/plugins/sdk"
+	"github.com/weaveworks/scope/report"
+)
+
+// Harness serves a plugin on a socket in a temporary plugins directory,
+// which is watched by a probe plugin registry. Reports and control
+// requests go through the registry, as they would in a probe.
+type Harness struct {
+	Registry *plugins.Registry
+
+	// Shortcuts receives the shortcut reports published by the plugin.
+	Shortcuts chan report.Report
+
+	// Pipes receives the pipes opened by the plugin's controls, as they
+	// would be connected to the app.
+	Pipes chan xfer.Pipe
+
+	plugin   *sdk.Plugin
+	root     string
+	controls *controls.HandlerRegistry
+	server   *http.Server
+}
+
+// New starts serving the plugin, and makes a registry which loads it.
+func New(plugin *sdk.Plugin) (*Harness, error) {
+	root, err := ioutil.TempDir("", "sdktest")
+	if err != nil {
+		return nil, err
+	}
+	listener, err := plugin.Listen(root)
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	h := &Harness{
+		Shortcuts: make(chan report.Report, 16),
+		Pipes:     make(chan xfer.Pipe, 16),
+		plugin:    plugin,
+		root:      root,
+		controls:  controls.NewDefaultHandlerRegistry(),
+		server:    &http.Server{Handler: plugin},
+	}
+	go h.server.Serve(listener)
+
+	h.Registry, err = plugins.NewRegistry(root, sdk.APIVersion, map[string]string{
+		"probe_id":    "sdktest",
+		"api_version": sdk.APIVersion,
+	}, h.controls, h, h)
+	if err != nil {
+		h.server.Close()
+		os.RemoveAll(root)
+		return nil, err
+	}
+	return h, nil
+}
+
+// Publish implements plugins.ReportPublisher.
+func (h *Harness) Publish(rpt report.Report) {
+	select {
+	case h.Shortcuts <- rpt:
+	default:
+	}
+}
+
+// PipeConnection implements controls.PipeClient.
+func (h *Harness) PipeConnection(_, _ string, pipe xfer.Pipe) error {
+	h.Pipes <- pipe
+	return nil
+}
+
+// PipeClose implements controls.PipeClient.
+func (h *Harness) PipeClose(_, _ string) error {
+	return nil
+}
+
+// Report returns the report of the plugin, as merged into the probe's
+// report. It fails if the plugin reports an error.
+func (h *Harness) Report() (report.Report, error) {
+	rpt, err := h.Registry.Report()
+	if err != nil {
+		return rpt, err
+	}
+	var pluginErr error
+	h.Registry.ForEach(func(p *plugins.Plugin) {
+		if p.ID == h.plugin.ID() && p.Status != "ok" {
+			pluginErr = fmt.Errorf("plugin %s: %s", p.ID, p.Status)
+		}
+	})
+	if pluginErr != nil {
+		return rpt, pluginErr
+	}
+	return rpt, rpt.Validate()
+}
+
+// Control sends a request for the plugin's control to the node, as the
+// app would. The plugin must have reported the control first.
+func (h *Harness) Control(nodeID, control string, args map[string]string) xfer.Response {
+	return h.controls.HandleControlRequest(xfer.Request{
+		AppID:       "sdktest",
+		NodeID:      nodeID,
+		Control:     plugins.ControlID(h.plugin.ID(), control),
+		ControlArgs: args,
+	})
+}
+
+// ResizeTTY sends the resize control of a pipe, from the response to the
+// control which opened it, as the app would when its terminal is resized.
+func (h *Harness) ResizeTTY(res xfer.Response, width, height uint) xfer.Response {
+	return h.controls.HandleControlRequest(xfer.Request{
+		AppID:   "sdktest",
+		Control: res.ResizeTTYControl,
+		ControlArgs: map[string]string{
+			"pipeID": res.Pipe,
+			"width":  fmt.Sprint(width),
+			"height": fmt.Sprint(height),
+		},
+	})
+}
+
+// Close stops the registry and the plugin.
+func (h *Harness) Close() error {
+	h.Registry.Close()
+	err := h.server.Close()
+	os.RemoveAll(h.root)
+	return err
+}
@@ -0,0 +1,206 @@
+package sdktest_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/plugins"
+	"github.com/weaveworks/scope/probe/plugins/sdk"
+	"github.com/weaveworks/scope/probe/plugins/sdk/sdktest"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	queueDepth = "queue_depth"
+	purge      = "queue_purge"
+)
+
+func TestHarness(t *testing.T) {
+	hostID := report.MakeHostNodeID("host1")
+	queueID := report.MakeProcessNodeID("host1", "queue1")
+	depth := 3.0
+
+	buildReport := func() *sdk.Report {
+		r := sdk.NewReport()
+		r.Topology(report.Host).
+			Metric(queueDepth, "Queue depth", "", 1).
+			Node(hostID).Metric(queueDepth, depth, 0)
+		queues := r.PluginTopology("queues", "queue", "queues").
+			Metadata("name", "Name", 1).
+			Control(purge, "Purge", "fa-trash", 1)
+		node := queues.Node(queueID).Set("name", "queue1").Parent(report.Host, hostID)
+		if depth > 0 {
+			node.Control(purge)
+		}
+		return r
+	}
+	p := sdk.New("queues", "Queues", "Reports queues", sdk.ReporterFunc(func() (*sdk.Report, error) {
+		return buildReport(), nil
+	}))
+	p.RegisterControl(purge, func(req xfer.Request) sdk.Response {
+		if req.NodeID != queueID {
+			return sdk.Response{Response: xfer.ResponseErrorf("unknown queue %s", req.NodeID)}
+		}
+		depth = 0
+		rpt := buildReport().Build()
+		return sdk.Response{ShortcutReport: &rpt}
+	})
+
+	h, err := sdktest.New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	rpt, err := h.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric, ok := rpt.Host.Nodes[hostID].Metrics.Lookup(queueDepth); !ok || metric.Len() != 1 {
+		t.Errorf("Expected the queue depth of the host, got %v", rpt.Host.Nodes[hostID].Metrics)
+	}
+	queues, ok := rpt.PluginTopologies["queues"]
+	if !ok {
+		t.Fatal("Expected the queues topology")
+	}
+	if _, ok := queues.Controls[plugins.ControlID("queues", purge)]; !ok {
+		t.Errorf("Expected the purge control, got %v", queues.Controls)
+	}
+	var specs []xfer.PluginSpec
+	h.Registry.ForEach(func(p *plugins.Plugin) { specs = append(specs, p.PluginSpec) })
+	if len(specs) != 1 || len(specs[0].Interfaces) != 2 {
+		t.Errorf("Unexpected plugins: %v", specs)
+	}
+
+	if res := h.Control("nonexistent", purge, nil); res.Error == "" {
+		t.Error("Expected an error for an unknown node")
+	}
+	if res := h.Control(queueID, purge, nil); res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	select {
+	case shortcut := <-h.Shortcuts:
+		node := shortcut.PluginTopologies["queues"].Nodes[queueID]
+		if node.LatestControls.Size() != 0 {
+			t.Errorf("Expected the purge control to be gone, got %v", node.LatestControls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shortcut report not published")
+	}
+}
+
+func TestHarnessStreams(t *testing.T) {
+	hostID := report.MakeHostNodeID("host1")
+	p := sdk.New("streamer", "Streamer", "Pushes reports", sdk.ReporterFunc(func() (*sdk.Report, error) {
+		return sdk.NewReport(), nil
+	}))
+	r := sdk.NewReport()
+	r.Topology(report.Host).Node(hostID).Set("queues", "1")
+	p.Publish(r)
+
+	h, err := sdktest.New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// The first report is polled, and tells the probe to start streaming
+	if _, err := h.Report(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); ; {
+		rpt, err := h.Report()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := rpt.Host.Nodes[hostID]; ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Published report not streamed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	shortcut := sdk.NewReport()
+	shortcut.Topology(report.Host).Node(hostID).Set("queues", "2")
+	p.PublishShortcut(shortcut)
+	select {
+	case rpt := <-h.Shortcuts:
+		if queues, _ := rpt.Host.Nodes[hostID].Latest.Lookup("queues"); queues != "2" {
+			t.Errorf("Unexpected shortcut report: %v", rpt.Host.Nodes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shortcut report not published")
+	}
+}
+
+func TestHarnessPipes(t *testing.T) {
+	const console = "console"
+	hostID := report.MakeHostNodeID("host1")
+	resized := make(chan [2]uint, 1)
+	var p *sdk.Plugin
+	p = sdk.New("console", "Console", "Opens a console", sdk.ReporterFunc(func() (*sdk.Report, error) {
+		r := sdk.NewReport()
+		r.Topology(report.Host).Control(console, "Console", "fa-terminal", 1).Node(hostID).Control(console)
+		return r, nil
+	}))
+	p.RegisterControl(console, func(req xfer.Request) sdk.Response {
+		return p.OpenPipe(func(rw io.ReadWriter) {
+			buf := make([]byte, 64)
+			for {
+				n, err := rw.Read(buf)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(rw, "echo: %s", buf[:n])
+			}
+		}, func(width, height uint) {
+			resized <- [2]uint{width, height}
+		})
+	})
+
+	h, err := sdktest.New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if _, err := h.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	res := h.Control(hostID, console, nil)
+	if res.Error != "" || res.Pipe == "" || !res.RawTTY {
+		t.Fatalf("Unexpected response: %#v", res)
+	}
+	var pipe xfer.Pipe
+	select {
+	case pipe = <-h.Pipes:
+	case <-time.After(time.Second):
+		t.Fatal("Pipe not connected")
+	}
+	defer pipe.Close()
+	_, remote := pipe.Ends()
+	if _, err := remote.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	if n, err := remote.Read(buf); err != nil || string(buf[:n]) != "echo: hello" {
+		t.Fatalf("Unexpected pipe output: %q (%v)", buf[:n], err)
+	}
+
+	if res := h.ResizeTTY(res, 80, 24); res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	select {
+	case size := <-resized:
+		if size != [2]uint{80, 24} {
+			t.Errorf("Unexpected size: %v", size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pipe not resized")
+	}
+}
@@ -0,0 +1,106 @@
+package sdk
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// The probe reconnects to plugins which are quiet for 10 seconds.
+	streamHeartbeatPeriod = 5 * time.Second
+	streamBufferSize      = 16
+)
+
+// streamMessage is what the plugin pushes to the probe over its /stream
+// endpoint. A message without a report is a heartbeat.
+type streamMessage struct {
+	Report   *report.Report `json:"report,omitempty"`
+	Shortcut bool           `json:"shortcut,omitempty"`
+}
+
+// Publish pushes a report to the probe, which uses it instead of polling
+// the plugin, until the next report is published. Once a report has been
+// published, the plugin implements the streamer interface.
+func (p *Plugin) Publish(r *Report) {
+	p.publish(r, false)
+}
+
+// PublishShortcut pushes a shortcut report to the probe, which publishes it
+// to the app straight away. It is dropped if the probe is not streaming
+// from the plugin, i.e. until a report has been published with Publish.
+func (p *Plugin) PublishShortcut(r *Report) {
+	p.publish(r, true)
+}
+
+func (p *Plugin) publish(r *Report, shortcut bool) {
+	if !shortcut {
+		p.mtx.Lock()
+		p.streaming = true
+		p.mtx.Unlock()
+	}
+	rpt := r.Build()
+	rpt.Plugins = xfer.MakePluginSpecs(p.pluginSpec())
+	msg := streamMessage{Report: &rpt, Shortcut: shortcut}
+
+	p.streamMtx.Lock()
+	defer p.streamMtx.Unlock()
+	if !shortcut {
+		p.streamed = &rpt
+	}
+	for messages := range p.streams {
+		select {
+		case messages <- msg:
+		default:
+			// The probe is falling behind; it gets the next report instead
+		}
+	}
+}
+
+// serveStream writes the latest report, and then the reports and shortcut
+// reports published, to the probe, with heartbeats in between.
+func (p *Plugin) serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	messages := make(chan streamMessage, streamBufferSize)
+	p.streamMtx.Lock()
+	if p.streamed == nil {
+		p.streamMtx.Unlock()
+		http.Error(w, "no report published", http.StatusNotFound)
+		return
+	}
+	messages <- streamMessage{Report: p.streamed}
+	p.streams[messages] = struct{}{}
+	p.streamMtx.Unlock()
+	defer func() {
+		p.streamMtx.Lock()
+		delete(p.streams, messages)
+		p.streamMtx.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	heartbeat := time.NewTicker(streamHeartbeatPeriod)
+	defer heartbeat.Stop()
+	for {
+		var msg streamMessage
+		select {
+		case msg = <-messages:
+		case <-heartbeat.C:
+		case <-r.Context().Done():
+			return
+		}
+		buf, err := encodeJSON(msg)
+		if err != nil {
+			return
+		}
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -14,3 +14,7 @@ func makeUnixRoundTripper(address string, timeout time.Duration) (http.RoundTrip
 	}
 	return rt, nil
 }
+
+func dialUnixSocket(address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", address, timeout)
+}
@@ -24,6 +24,9 @@ type Probe struct {
 	reporters []Reporter
 	taggers   []Tagger
 
+	health      *healthTracker
+	appStatuses publishStatuser
+
 	quit chan struct{}
 	done sync.WaitGroup
 
@@ -74,10 +77,12 @@ func New(
 		spyInterval:     spyInterval,
 		publishInterval: publishInterval,
 		publisher:       appclient.NewReportPublisher(publisher, noControls),
+		health:          newHealthTracker(),
 		quit:            make(chan struct{}),
 		spiedReports:    make(chan report.Report, reportBufferSize),
 		shortcutReports: make(chan report.Report, reportBufferSize),
 	}
+	result.appStatuses, _ = publisher.(publishStatuser)
 	return result
 }
 
@@ -140,6 +145,7 @@ func (p *Probe) tick() {
 		t := time.Now()
 		err := ticker.Tick()
 		metrics.MeasureSince([]string{ticker.Name(), "ticker"}, t)
+		p.health.record("ticker", ticker.Name(), t, err)
 		if err != nil {
 			log.Errorf("error doing ticker: %v", err)
 		}
@@ -157,6 +163,7 @@ func (p *Probe) report() report.Report {
 				log.Warningf("%v reporter took %v (longer than %v)", rep.Name(), time.Now().Sub(t), p.spyInterval)
 			}
 			metrics.MeasureSince([]string{rep.Name(), "reporter"}, t)
+			p.health.record("reporter", rep.Name(), t, err)
 			if err != nil {
 				log.Errorf("error generating report: %v", err)
 				newReport = report.MakeReport() // empty is OK to merge
@@ -182,6 +189,7 @@ func (p *Probe) tag(r report.Report) report.Report {
 			log.Warningf("%v tagger took %v (longer than %v)", tagger.Name(), time.Now().Sub(t), p.spyInterval)
 		}
 		metrics.MeasureSince([]string{tagger.Name(), "tagger"}, t)
+		p.health.record("tagger", tagger.Name(), t, err)
 		if err != nil {
 			log.Errorf("error applying tagger: %v", err)
 		}
@@ -200,7 +208,10 @@ ForLoop:
 		}
 	}
 
-	if err := p.publisher.Publish(rpt.BackwardCompatible()); err != nil {
+	t := time.Now()
+	err := p.publisher.Publish(rpt.BackwardCompatible())
+	p.health.record("publisher", "publish", t, err)
+	if err != nil {
 		log.Infof("publish: %v", err)
 	}
 }
@@ -2,6 +2,7 @@ package probe
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
 	"testing"
 	"time"
@@ -97,3 +98,48 @@ func TestProbe(t *testing.T) {
 		return <-pub.have
 	})
 }
+
+type failingReporter struct{}
+
+func (failingReporter) Report() (report.Report, error) {
+	return report.MakeReport(), fmt.Errorf("docker unreachable")
+}
+
+func (failingReporter) Name() string { return "Failing" }
+
+func (failingReporter) Health() []Health {
+	return []Health{{Component: "ebpf", Error: "tracker died", LastError: time.Now()}}
+}
+
+func TestHealth(t *testing.T) {
+	p := New(0, 0, nil, false)
+	p.AddReporter(mockReporter{report.MakeReport()}, failingReporter{})
+	p.report()
+
+	rpt, err := NewHealthReporter("host1", p).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := ExtractHealth(rpt.Host.Nodes[report.MakeHostNodeID("host1")])
+
+	states := map[string]string{}
+	for _, h := range health {
+		states[h.Component] = h.State
+	}
+	want := map[string]string{
+		"Failing/ebpf":     HealthFailing,
+		"reporter/Failing": HealthFailing,
+		"reporter/Mock":    HealthOK,
+	}
+	if !reflect.DeepEqual(want, states) {
+		t.Errorf("want %v, have %v", want, states)
+	}
+	if Healthy(health) {
+		t.Errorf("probe with failing reporters should not be healthy")
+	}
+	for _, h := range health {
+		if h.Component == "reporter/Failing" && h.Error != "docker unreachable" {
+			t.Errorf("unexpected error %q", h.Error)
+		}
+	}
+}
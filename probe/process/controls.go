@@ -0,0 +1,308 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/report"
+)
+
+// Control IDs used by the process integration.
+const (
+	ViewOpenFiles   = "process_view_open_files"
+	ViewEnvironment = "process_view_environment"
+	DumpStack       = "process_dump_stack"
+	Renice          = "process_renice"
+	SignalTerm      = "process_signal_term"
+	SignalKill      = "process_signal_kill"
+	SignalHup       = "process_signal_hup"
+	SignalUsr1      = "process_signal_usr1"
+)
+
+var (
+	viewControls = []report.Control{
+		{ID: ViewOpenFiles, Human: "View open files", Icon: "fa-folder-open-o", Rank: 0},
+		{ID: ViewEnvironment, Human: "View environment", Icon: "fa-list", Rank: 1},
+		{ID: DumpStack, Human: "Dump stack", Icon: "fa-align-left", Rank: 2},
+	}
+	reniceControl  = report.Control{ID: Renice, Human: "Lower priority (renice)", Icon: "fa-arrow-down", Rank: 3}
+	signalControls = []report.Control{
+		{ID: SignalHup, Human: "Send SIGHUP", Icon: "fa-refresh", Rank: 4},
+		{ID: SignalUsr1, Human: "Send SIGUSR1", Icon: "fa-bolt", Rank: 5},
+		{ID: SignalTerm, Human: "Terminate (SIGTERM)", Icon: "fa-stop", Rank: 6},
+		{ID: SignalKill, Human: "Kill (SIGKILL)", Icon: "fa-times", Rank: 7},
+	}
+	signals = map[string]syscall.Signal{
+		SignalTerm: syscall.SIGTERM,
+		SignalKill: syscall.SIGKILL,
+		SignalHup:  syscall.SIGHUP,
+		SignalUsr1: syscall.SIGUSR1,
+	}
+
+	// stackDumpSignals are the signals making the runtimes of some processes,
+	// by name, write the stacks of their threads to their standard output.
+	stackDumpSignals = map[string]syscall.Signal{
+		"java": syscall.SIGQUIT,
+	}
+
+	// Readlink, Kill and Setpriority are exposed for testing
+	Readlink    = os.Readlink
+	Kill        = syscall.Kill
+	Setpriority = syscall.Setpriority
+)
+
+// defaultNice is the niceness renicing sets when the request has no "nice"
+// argument, as the UI sends none.
+const defaultNice = 10
+
+// controls returns the controls of processes, leaving out viewing their
+// environment if environment variables are omitted.
+func (r *Reporter) controls() []report.Control {
+	cs := []report.Control{}
+	for _, c := range viewControls {
+		if c.ID == ViewEnvironment && r.conf.NoEnvironmentVariables {
+			continue
+		}
+		cs = append(cs, c)
+	}
+	cs = append(cs, reniceControl)
+	return append(cs, signalControls...)
+}
+
+func (r *Reporter) activeControls() []string {
+	ids := []string{}
+	for _, c := range r.controls() {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func (r *Reporter) registerControls() {
+	handlers := map[string]xfer.ControlHandlerFunc{
+		ViewOpenFiles: r.capturePID(r.viewOpenFiles),
+		DumpStack:     r.capturePID(r.dumpStack),
+		Renice:        r.capturePID(r.renice),
+	}
+	if !r.conf.NoEnvironmentVariables {
+		handlers[ViewEnvironment] = r.capturePID(r.viewEnvironment)
+	}
+	for id := range signals {
+		handlers[id] = r.capturePID(r.signal)
+	}
+	r.conf.HandlerRegistry.Batch(nil, handlers)
+}
+
+func (r *Reporter) deregisterControls() {
+	ids := []string{ViewOpenFiles, ViewEnvironment, DumpStack, Renice}
+	for id := range signals {
+		ids = append(ids, id)
+	}
+	r.conf.HandlerRegistry.Batch(ids, nil)
+}
+
+// capturePID checks the node of a request is a process of this host, and
+// passes its PID to f.
+func (r *Reporter) capturePID(f func(xfer.Request, int) xfer.Response) func(xfer.Request) xfer.Response {
+	return func(req xfer.Request) xfer.Response {
+		hostID, pidstr, ok := report.ParseProcessNodeID(req.NodeID)
+		if !ok || hostID != r.conf.Scope {
+			return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+		}
+		pid, err := strconv.Atoi(pidstr)
+		if err != nil || pid <= 0 {
+			return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+		}
+		return f(req, pid)
+	}
+}
+
+// checkStartTime checks a process is still the one of the last report, and
+// not another process which has since reused its PID.
+func (r *Reporter) checkStartTime(pid int) error {
+	r.startTimesLock.RLock()
+	want, ok := r.startTimes[pid]
+	r.startTimesLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("process %d is not in the last report", pid)
+	}
+	have, err := readStartTime(path.Join(r.conf.ProcRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return err
+	}
+	if have != want {
+		return fmt.Errorf("process %d has been replaced since the last report", pid)
+	}
+	return nil
+}
+
+// readStartTime reads the start time of a process from its
+// '/proc/<pid>/stat' file. Its name, in parentheses, may contain spaces.
+func readStartTime(path string) (uint64, error) {
+	const procStatFieldStartTime = 21 // counting from zero, see "man 5 proc"
+	buf, err := fs.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	end := bytes.LastIndexByte(buf, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("invalid stat file %s", path)
+	}
+	// The fields after the name start with the state, at position 2
+	fields := strings.Fields(string(buf[end+1:]))
+	if len(fields) <= procStatFieldStartTime-2 {
+		return 0, fmt.Errorf("invalid stat file %s", path)
+	}
+	return strconv.ParseUint(fields[procStatFieldStartTime-2], 10, 64)
+}
+
+func (r *Reporter) signal(req xfer.Request, pid int) xfer.Response {
+	if pid == 1 || pid == os.Getpid() {
+		return xfer.ResponseErrorf("Refusing to signal process %d", pid)
+	}
+	if err := r.checkStartTime(pid); err != nil {
+		return xfer.ResponseErrorf("Refusing to signal process %d: %v", pid, err)
+	}
+	if err := Kill(pid, signals[req.Control]); err != nil {
+		return xfer.ResponseErrorf("Error signalling process %d: %v", pid, err)
+	}
+	return xfer.Response{}
+}
+
+// renice sets the niceness of all the threads of a process, as Linux sets
+// the one of a single thread.
+func (r *Reporter) renice(req xfer.Request, pid int) xfer.Response {
+	nice := defaultNice
+	if arg, ok := req.ControlArgs["nice"]; ok {
+		var err error
+		if nice, err = strconv.Atoi(arg); err != nil || nice < -20 || nice > 19 {
+			return xfer.ResponseErrorf("Invalid niceness: %s", arg)
+		}
+	}
+	if err := r.checkStartTime(pid); err != nil {
+		return xfer.ResponseErrorf("Refusing to renice process %d: %v", pid, err)
+	}
+	tids, err := fs.ReadDirNames(path.Join(r.conf.ProcRoot, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return xfer.ResponseErrorf("Error reading threads of process %d: %v", pid, err)
+	}
+	for _, tidstr := range tids {
+		tid, err := strconv.Atoi(tidstr)
+		if err != nil {
+			continue
+		}
+		if err := Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+			return xfer.ResponseErrorf("Error renicing process %d: %v", pid, err)
+		}
+	}
+	return xfer.Response{}
+}
+
+func (r *Reporter) viewOpenFiles(req xfer.Request, pid int) xfer.Response {
+	dir := path.Join(r.conf.ProcRoot, strconv.Itoa(pid), "fd")
+	fds, err := fs.ReadDirNames(dir)
+	if err != nil {
+		return xfer.ResponseErrorf("Error reading open files of process %d: %v", pid, err)
+	}
+	sort.Sort(byNumber(fds))
+	var buf bytes.Buffer
+	for _, fd := range fds {
+		target, err := Readlink(path.Join(dir, fd))
+		if err != nil {
+			target = "?"
+		}
+		fmt.Fprintf(&buf, "%s\t%s\n", fd, target)
+	}
+	return r.pipeText(req, buf.Bytes())
+}
+
+func (r *Reporter) viewEnvironment(req xfer.Request, pid int) xfer.Response {
+	environ, err := fs.ReadFile(path.Join(r.conf.ProcRoot, strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return xfer.ResponseErrorf("Error reading environment of process %d: %v", pid, err)
+	}
+	var buf bytes.Buffer
+	for _, v := range strings.Split(string(environ), "\x00") {
+		if v != "" {
+			fmt.Fprintf(&buf, "%s\n", v)
+		}
+	}
+	return r.pipeText(req, buf.Bytes())
+}
+
+// dumpStack shows the kernel stacks of the threads of a process. For
+// processes whose runtime dumps the stacks of its threads on a signal, it
+// sends that signal too.
+func (r *Reporter) dumpStack(req xfer.Request, pid int) xfer.Response {
+	dir := path.Join(r.conf.ProcRoot, strconv.Itoa(pid))
+	tids, err := fs.ReadDirNames(path.Join(dir, "task"))
+	if err != nil {
+		return xfer.ResponseErrorf("Error reading threads of process %d: %v", pid, err)
+	}
+	sort.Sort(byNumber(tids))
+	var buf bytes.Buffer
+	for _, tid := range tids {
+		comm, _ := fs.ReadFile(path.Join(dir, "task", tid, "comm"))
+		fmt.Fprintf(&buf, "thread %s (%s):\n", tid, strings.TrimSpace(string(comm)))
+		stack, err := fs.ReadFile(path.Join(dir, "task", tid, "stack"))
+		if err != nil {
+			fmt.Fprintf(&buf, "  error reading stack: %v\n", err)
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(stack)), "\n") {
+			fmt.Fprintf(&buf, "  %s\n", line)
+		}
+	}
+
+	comm, _ := fs.ReadFile(path.Join(dir, "comm"))
+	name := strings.TrimSpace(string(comm))
+	if sig, ok := stackDumpSignals[name]; ok {
+		if err := r.checkStartTime(pid); err != nil {
+			fmt.Fprintf(&buf, "\nNot sending %v to %s: %v\n", sig, name, err)
+		} else if err := Kill(pid, sig); err != nil {
+			fmt.Fprintf(&buf, "\nError sending %v to %s: %v\n", sig, name, err)
+		} else {
+			fmt.Fprintf(&buf, "\nSent %v to %s, which writes the stacks of its threads to its standard output.\n", sig, name)
+		}
+	}
+	return r.pipeText(req, buf.Bytes())
+}
+
+// pipeText opens a pipe to the app which shows some text, and closes once it
+// has been read.
+func (r *Reporter) pipeText(req xfer.Request, text []byte) xfer.Response {
+	readWriter := struct {
+		io.Reader
+		io.Writer
+	}{
+		bytes.NewReader(text),
+		ioutil.Discard,
+	}
+	id, _, err := controls.NewPipeFromEnds(nil, readWriter, r.conf.Pipes, req.AppID)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	return xfer.Response{
+		Pipe: id,
+	}
+}
+
+type byNumber []string
+
+func (s byNumber) Len() int      { return len(s) }
+func (s byNumber) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byNumber) Less(i, j int) bool {
+	a, _ := strconv.Atoi(s[i])
+	b, _ := strconv.Atoi(s[j])
+	return a < b
+}
@@ -0,0 +1,209 @@
+package process_test
+
+import (
+	"io/ioutil"
+	"reflect"
+	"syscall"
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockPipeClient map[string]xfer.Pipe
+
+func (c mockPipeClient) PipeConnection(appID, id string, pipe xfer.Pipe) error {
+	c[id] = pipe
+	return nil
+}
+
+func (c mockPipeClient) PipeClose(appID, id string) error {
+	return nil
+}
+
+var controlsFS = fs.Dir("",
+	fs.Dir("proc",
+		fs.Dir("42",
+			fs.File{FName: "comm", FContents: "java\n"},
+			fs.File{FName: "stat", FContents: "42 (java) S 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 5000 0 0 0\n"},
+			fs.File{FName: "environ", FContents: "HOME=/root\000PATH=/bin\000"},
+			fs.Dir("fd", fs.File{FName: "10"}, fs.File{FName: "2"}),
+			fs.Dir("task",
+				fs.Dir("42",
+					fs.File{FName: "comm", FContents: "java\n"},
+					fs.File{FName: "stack", FContents: "[<0>] futex_wait_queue_me+0xc4/0x120\n[<0>] futex_wait+0xda/0x200\n"},
+				),
+			),
+		),
+	),
+)
+
+func TestControls(t *testing.T) {
+	fs_hook.Mock(controlsFS)
+	defer fs_hook.Restore()
+	oldReadlink, oldKill, oldSetpriority := process.Readlink, process.Kill, process.Setpriority
+	defer func() { process.Readlink, process.Kill, process.Setpriority = oldReadlink, oldKill, oldSetpriority }()
+	process.Readlink = func(name string) (string, error) { return "target of " + name, nil }
+	signals := []syscall.Signal{}
+	process.Kill = func(pid int, sig syscall.Signal) error {
+		if pid != 42 {
+			t.Errorf("Expected to signal process 42, got %d", pid)
+		}
+		signals = append(signals, sig)
+		return nil
+	}
+	priorities := map[int]int{}
+	process.Setpriority = func(which, who, prio int) error {
+		if which != syscall.PRIO_PROCESS {
+			t.Errorf("Expected to renice a process, got %d", which)
+		}
+		priorities[who] = prio
+		return nil
+	}
+
+	pipes := mockPipeClient{}
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := process.NewReporter(process.ReporterConfig{
+		Scope:           "host",
+		ProbeID:         "probe",
+		Walker:          &mockWalker{processes: []process.Process{{PID: 42, Name: "java", StartTime: 5000}}},
+		Jiffies:         func() (uint64, float64, error) { return 0, 0., nil },
+		ProcRoot:        "/proc",
+		Pipes:           pipes,
+		HandlerRegistry: registry,
+	})
+	defer reporter.Stop()
+
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeID := report.MakeProcessNodeID("host", "42")
+	node := rpt.Process.Nodes[nodeID]
+	if probeID, _ := node.Latest.Lookup(report.ControlProbeID); probeID != "probe" {
+		t.Errorf("Expected the process to have a control probe ID, got %q", probeID)
+	}
+	for _, id := range []string{process.ViewOpenFiles, process.ViewEnvironment, process.DumpStack, process.Renice, process.SignalTerm, process.SignalKill} {
+		if _, ok := rpt.Process.Controls[id]; !ok {
+			t.Errorf("Expected control %s", id)
+		}
+		if _, ok := node.LatestControls.Lookup(id); !ok {
+			t.Errorf("Expected control %s to be active", id)
+		}
+	}
+
+	control := func(id string) string {
+		resp := registry.HandleControlRequest(xfer.Request{AppID: "app", NodeID: nodeID, Control: id, ControlArgs: map[string]string{}})
+		if resp.Error != "" {
+			t.Fatalf("%s: %s", id, resp.Error)
+		}
+		if resp.Pipe == "" {
+			return ""
+		}
+		_, end := pipes[resp.Pipe].Ends()
+		contents, err := ioutil.ReadAll(end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(contents)
+	}
+
+	if have, want := control(process.ViewOpenFiles), "2\ttarget of /proc/42/fd/2\n10\ttarget of /proc/42/fd/10\n"; have != want {
+		t.Errorf("Expected open files %q, got %q", want, have)
+	}
+	if have, want := control(process.ViewEnvironment), "HOME=/root\nPATH=/bin\n"; have != want {
+		t.Errorf("Expected environment %q, got %q", want, have)
+	}
+	want := "thread 42 (java):\n  [<0>] futex_wait_queue_me+0xc4/0x120\n  [<0>] futex_wait+0xda/0x200\n" +
+		"\nSent quit to java, which writes the stacks of its threads to its standard output.\n"
+	if have := control(process.DumpStack); have != want {
+		t.Errorf("Expected stack %q, got %q", want, have)
+	}
+	control(process.SignalTerm)
+	control(process.SignalKill)
+	if want := []syscall.Signal{syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGKILL}; len(signals) != len(want) || signals[0] != want[0] || signals[1] != want[1] || signals[2] != want[2] {
+		t.Errorf("Expected signals %v, got %v", want, signals)
+	}
+	control(process.Renice)
+	if want := map[int]int{42: 10}; !reflect.DeepEqual(priorities, want) {
+		t.Errorf("Expected priorities %v, got %v", want, priorities)
+	}
+	resp := registry.HandleControlRequest(xfer.Request{NodeID: nodeID, Control: process.Renice, ControlArgs: map[string]string{"nice": "5"}})
+	if resp.Error != "" || priorities[42] != 5 {
+		t.Errorf("Expected niceness 5, got %v (%s)", priorities, resp.Error)
+	}
+
+	// Processes of other hosts, and the init process, are refused
+	for _, req := range []xfer.Request{
+		{NodeID: report.MakeProcessNodeID("other", "42"), Control: process.SignalKill},
+		{NodeID: report.MakeProcessNodeID("host", "1"), Control: process.SignalKill},
+		{NodeID: nodeID, Control: process.Renice, ControlArgs: map[string]string{"nice": "20"}},
+	} {
+		if resp := registry.HandleControlRequest(req); resp.Error == "" {
+			t.Errorf("Expected an error for %v", req)
+		}
+	}
+}
+
+func TestControlsWithoutEnvironment(t *testing.T) {
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := process.NewReporter(process.ReporterConfig{
+		Scope:                  "host",
+		Walker:                 &mockWalker{processes: []process.Process{{PID: 42}}},
+		Jiffies:                func() (uint64, float64, error) { return 0, 0., nil },
+		NoEnvironmentVariables: true,
+		HandlerRegistry:        registry,
+	})
+	defer reporter.Stop()
+
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rpt.Process.Controls[process.ViewEnvironment]; ok {
+		t.Errorf("Expected no control to view the environment")
+	}
+	resp := registry.HandleControlRequest(xfer.Request{NodeID: report.MakeProcessNodeID("host", "42"), Control: process.ViewEnvironment})
+	if resp.Error == "" {
+		t.Errorf("Expected viewing the environment to fail")
+	}
+}
+
+func TestControlsRefuseReplacedProcesses(t *testing.T) {
+	fs_hook.Mock(controlsFS)
+	defer fs_hook.Restore()
+	oldKill := process.Kill
+	defer func() { process.Kill = oldKill }()
+	process.Kill = func(pid int, sig syscall.Signal) error {
+		t.Errorf("Expected no signal, got %v to %d", sig, pid)
+		return nil
+	}
+
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := process.NewReporter(process.ReporterConfig{
+		Scope: "host",
+		// The process 42 of the report started before the one of /proc
+		Walker:          &mockWalker{processes: []process.Process{{PID: 42, StartTime: 4000}}},
+		Jiffies:         func() (uint64, float64, error) { return 0, 0., nil },
+		ProcRoot:        "/proc",
+		HandlerRegistry: registry,
+	})
+	defer reporter.Stop()
+
+	if _, err := reporter.Report(); err != nil {
+		t.Fatal(err)
+	}
+	for _, nodeID := range []string{
+		report.MakeProcessNodeID("host", "42"),
+		report.MakeProcessNodeID("host", "43"), // not in the report
+	} {
+		resp := registry.HandleControlRequest(xfer.Request{NodeID: nodeID, Control: process.SignalTerm})
+		if resp.Error == "" {
+			t.Errorf("Expected signalling %s to fail", nodeID)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package process
 import (
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -18,6 +20,12 @@ const (
 	CPUUsage       = "process_cpu_usage_percent"
 	MemoryUsage    = "process_memory_usage_bytes"
 	OpenFilesCount = "open_files_count"
+
+	DiskReadRate       = "process_disk_read_bytes_per_second"
+	DiskWriteRate      = "process_disk_write_bytes_per_second"
+	SocketsEstablished = "process_sockets_established"
+	SocketsListening   = "process_sockets_listening"
+	SocketsCloseWait   = "process_sockets_close_wait"
 )
 
 // Exposed for testing
@@ -33,32 +41,62 @@ var (
 		CPUUsage:       {ID: CPUUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage:    {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
 		OpenFilesCount: {ID: OpenFilesCount, Label: "Open Files", Format: report.IntegerFormat, Priority: 3},
+	}.Merge(AggregateMetricTemplates)
+
+	// AggregateMetricTemplates are the templates of the metrics of processes
+	// which are summed up into the containers and process names they belong
+	// to.
+	AggregateMetricTemplates = report.MetricTemplates{
+		DiskReadRate:       {ID: DiskReadRate, Label: "Disk Read/s", Format: report.FilesizeFormat, Priority: 4},
+		DiskWriteRate:      {ID: DiskWriteRate, Label: "Disk Write/s", Format: report.FilesizeFormat, Priority: 5},
+		SocketsEstablished: {ID: SocketsEstablished, Label: "Established Sockets", Format: report.IntegerFormat, Priority: 6},
+		SocketsListening:   {ID: SocketsListening, Label: "Listening Sockets", Format: report.IntegerFormat, Priority: 7},
+		SocketsCloseWait:   {ID: SocketsCloseWait, Label: "CLOSE_WAIT Sockets", Format: report.IntegerFormat, Priority: 8},
 	}
 )
 
+// ReporterConfig are the config options for the process reporter.
+type ReporterConfig struct {
+	Scope                  string
+	ProbeID                string
+	Walker                 Walker
+	Jiffies                Jiffies
+	ProcRoot               string
+	NoCommandLineArguments bool
+	NoEnvironmentVariables bool
+	Pipes                  controls.PipeClient
+	HandlerRegistry        *controls.HandlerRegistry
+}
+
 // Reporter generates Reports containing the Process topology.
 type Reporter struct {
-	scope                  string
-	walker                 Walker
-	jiffies                Jiffies
-	noCommandLineArguments bool
+	conf ReporterConfig
+
+	// startTimes are the start times of the processes of the last report, by
+	// PID, so controls only act on those processes.
+	startTimesLock sync.RWMutex
+	startTimes     map[int]uint64
 }
 
 // Jiffies is the type for the function used to fetch the elapsed jiffies.
 type Jiffies func() (uint64, float64, error)
 
-// NewReporter makes a new Reporter.
-func NewReporter(walker Walker, scope string, jiffies Jiffies, noCommandLineArguments bool) *Reporter {
-	return &Reporter{
-		scope:                  scope,
-		walker:                 walker,
-		jiffies:                jiffies,
-		noCommandLineArguments: noCommandLineArguments,
+// NewReporter makes a new Reporter, and registers the controls of processes.
+func NewReporter(conf ReporterConfig) *Reporter {
+	r := &Reporter{
+		conf: conf,
 	}
+	r.registerControls()
+	return r
+}
+
+// Stop stops the reporter, deregistering the controls of processes.
+func (r *Reporter) Stop() {
+	r.deregisterControls()
 }
 
 // Name of this reporter, for metrics gathering
-func (Reporter) Name() string { return "Process" }
+func (*Reporter) Name() string { return "Process" }
 
 // Report implements Reporter.
 func (r *Reporter) Report() (report.Report, error) {
@@ -75,24 +113,30 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 	t := report.MakeTopology().
 		WithMetadataTemplates(MetadataTemplates).
 		WithMetricTemplates(MetricTemplates)
+	t.Controls.AddControls(r.controls())
+	activeControls := r.activeControls()
 	now := mtime.Now()
-	deltaTotal, maxCPU, err := r.jiffies()
+	deltaTotal, maxCPU, err := r.conf.Jiffies()
 	if err != nil {
 		return t, err
 	}
 
-	err = r.walker.Walk(func(p, prev Process) {
+	startTimes := map[int]uint64{}
+	err = r.conf.Walker.Walk(func(p, prev Process) {
+		startTimes[p.PID] = p.StartTime
 		pidstr := strconv.Itoa(p.PID)
-		nodeID := report.MakeProcessNodeID(r.scope, pidstr)
+		nodeID := report.MakeProcessNodeID(r.conf.Scope, pidstr)
 		node := report.MakeNode(nodeID)
 		node = node.WithLatest(PID, now, pidstr)
 		node = node.WithLatest(Threads, now, strconv.Itoa(p.Threads))
+		node = node.WithLatest(report.ControlProbeID, now, r.conf.ProbeID)
+		node = node.WithLatestActiveControls(activeControls...)
 		if p.Name != "" {
 			node = node.WithLatest(Name, now, p.Name)
 		}
 
 		if p.Cmdline != "" {
-			if r.noCommandLineArguments {
+			if r.conf.NoCommandLineArguments {
 				node = node.WithLatest(Cmdline, now, strings.Split(p.Cmdline, " ")[0])
 			} else {
 				node = node.WithLatest(Cmdline, now, p.Cmdline)
@@ -111,11 +155,26 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 			cpuUsage := float64(p.Jiffies-prev.Jiffies) / float64(deltaTotal) * 100.
 			metrics[CPUUsage] = report.MakeSingletonMetric(now, cpuUsage).WithMax(maxCPU)
 		}
+		// Rates need the counters of the same process in the previous walk,
+		// over the time between the two walks rather than between reports
+		elapsed := p.SampleTime.Sub(prev.SampleTime).Seconds()
+		if p.HasIOCounters && prev.HasIOCounters && p.IOReadBytes >= prev.IOReadBytes && p.IOWriteBytes >= prev.IOWriteBytes && elapsed > 0 {
+			metrics[DiskReadRate] = report.MakeSingletonMetric(now, float64(p.IOReadBytes-prev.IOReadBytes)/elapsed)
+			metrics[DiskWriteRate] = report.MakeSingletonMetric(now, float64(p.IOWriteBytes-prev.IOWriteBytes)/elapsed)
+		}
+		if p.HasSocketCounts {
+			metrics[SocketsEstablished] = report.MakeSingletonMetric(now, float64(p.Sockets.Established))
+			metrics[SocketsListening] = report.MakeSingletonMetric(now, float64(p.Sockets.Listening))
+			metrics[SocketsCloseWait] = report.MakeSingletonMetric(now, float64(p.Sockets.CloseWait))
+		}
 
 		node = node.WithMetrics(metrics)
 
 		t.AddNode(node)
 	})
 
+	r.startTimesLock.Lock()
+	r.startTimes = startTimes
+	r.startTimesLock.Unlock()
 	return t, err
 }
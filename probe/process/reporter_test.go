@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
@@ -36,7 +37,12 @@ func testReporter(t *testing.T, noCommandLineArguments bool, test func(report.Re
 	mtime.NowForce(now)
 	defer mtime.NowReset()
 
-	rpt, err := process.NewReporter(walker, "", getDeltaTotalJiffies, noCommandLineArguments).Report()
+	rpt, err := process.NewReporter(process.ReporterConfig{
+		Walker:                 walker,
+		Jiffies:                getDeltaTotalJiffies,
+		NoCommandLineArguments: noCommandLineArguments,
+		HandlerRegistry:        controls.NewDefaultHandlerRegistry(),
+	}).Report()
 	if err != nil {
 		t.Error(err)
 	}
@@ -138,7 +144,11 @@ func TestCmdlineRemoval(t *testing.T) {
 func BenchmarkReporter(t *testing.B) {
 	walker := &mockWalker{processes: processes}
 	getDeltaTotalJiffies := func() (uint64, float64, error) { return 0, 0., nil }
-	reporter := process.NewReporter(walker, "", getDeltaTotalJiffies, false)
+	reporter := process.NewReporter(process.ReporterConfig{
+		Walker:          walker,
+		Jiffies:         getDeltaTotalJiffies,
+		HandlerRegistry: controls.NewDefaultHandlerRegistry(),
+	})
 	t.ResetTimer()
 
 	for i := 0; i < t.N; i++ {
@@ -148,3 +158,63 @@ func BenchmarkReporter(t *testing.B) {
 		}
 	}
 }
+
+type mockPreviousWalker struct {
+	processes, previous map[int]process.Process
+}
+
+func (m *mockPreviousWalker) Walk(f func(process.Process, process.Process)) error {
+	for pid, p := range m.processes {
+		f(p, m.previous[pid])
+	}
+	return nil
+}
+
+func TestIOAndSocketMetrics(t *testing.T) {
+	start := time.Unix(1000, 0)
+	mtime.NowForce(start)
+	defer mtime.NowReset()
+
+	walker := &mockPreviousWalker{processes: map[int]process.Process{
+		1: {PID: 1, HasIOCounters: true, IOReadBytes: 1000, IOWriteBytes: 2000, SampleTime: start},
+	}}
+	reporter := process.NewReporter(process.ReporterConfig{
+		Walker:          walker,
+		Jiffies:         func() (uint64, float64, error) { return 0, 0., nil },
+		HandlerRegistry: controls.NewDefaultHandlerRegistry(),
+	})
+	if _, err := reporter.Report(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rates are over the time between the walks, not between the reports
+	mtime.NowForce(start.Add(5 * time.Second))
+	walker.processes, walker.previous = map[int]process.Process{
+		1: {PID: 1, HasIOCounters: true, IOReadBytes: 5000, IOWriteBytes: 2000, HasSocketCounts: true, Sockets: process.SocketCounts{Established: 3, Listening: 1}, SampleTime: start.Add(2 * time.Second)},
+		2: {PID: 2, HasIOCounters: true, IOReadBytes: 5000, SampleTime: start.Add(2 * time.Second)},
+	}, walker.processes
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpt.Process.Nodes[report.MakeProcessNodeID("", "1")]
+	for id, want := range map[string]float64{
+		process.DiskReadRate:       2000,
+		process.DiskWriteRate:      0,
+		process.SocketsEstablished: 3,
+		process.SocketsListening:   1,
+		process.SocketsCloseWait:   0,
+	} {
+		if sample, ok := node.Metrics[id].LastSample(); !ok || sample.Value != want {
+			t.Errorf("Expected %s to be %v, got %v", id, want, node.Metrics[id])
+		}
+	}
+	// Without previous counters, there is no rate
+	node = rpt.Process.Nodes[report.MakeProcessNodeID("", "2")]
+	if _, ok := node.Metrics[process.DiskReadRate]; ok {
+		t.Errorf("Expected no disk read rate for a new process")
+	}
+	if _, ok := node.Metrics[process.SocketsEstablished]; ok {
+		t.Errorf("Expected no socket counts if sockets are not counted")
+	}
+}
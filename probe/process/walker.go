@@ -1,6 +1,9 @@
 package process
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // Process represents a single process.
 type Process struct {
@@ -9,11 +12,23 @@ type Process struct {
 	Cmdline           string
 	Threads           int
 	Jiffies           uint64
+	StartTime         uint64 // in clock ticks after boot
 	RSSBytes          uint64
 	RSSBytesLimit     uint64
 	OpenFilesCount    int
 	OpenFilesLimit    uint64
 	IsWaitingInAccept bool
+	HasIOCounters     bool
+	IOReadBytes       uint64
+	IOWriteBytes      uint64
+	HasSocketCounts   bool
+	Sockets           SocketCounts
+	SampleTime        time.Time // when the walker read the process
+}
+
+// SocketCounts are the numbers of TCP sockets of a process in some states.
+type SocketCounts struct {
+	Established, Listening, CloseWait int
 }
 
 // Walker is something that walks the /proc directory
@@ -5,10 +5,11 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // NewWalker returns a Darwin (lsof-based) walker.
-func NewWalker(_ string, _ bool) Walker {
+func NewWalker(_ string, _ bool, _ time.Duration) Walker {
 	return &walker{}
 }
 
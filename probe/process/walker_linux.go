@@ -8,17 +8,32 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	linuxproc "github.com/c9s/goprocinfo/linux"
 	"github.com/coocood/freecache"
 
 	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/probe/host"
 )
 
 type walker struct {
 	procRoot                 string
 	gatheringWaitingInAccept bool
+	socketsInterval          time.Duration
+
+	// The socket counts of processes are only refreshed every
+	// socketsInterval; the walks in between reuse those of lastSockets.
+	lastSockets  time.Time
+	socketCounts map[socketCountKey]SocketCounts
+}
+
+// socketCountKey identifies a process whose sockets were counted, so the
+// counts of a process are not reused for another with the same PID.
+type socketCountKey struct {
+	pid       int
+	startTime uint64
 }
 
 var (
@@ -38,11 +53,14 @@ const (
 	cmdlineCacheTimeout = 60
 )
 
-// NewWalker creates a new process Walker.
-func NewWalker(procRoot string, gatheringWaitingInAccept bool) Walker {
+// NewWalker creates a new process Walker. Counting the sockets of processes
+// by state means reading the links of all their file descriptors, so it is
+// only done every socketsInterval, and not at all if that is zero.
+func NewWalker(procRoot string, gatheringWaitingInAccept bool, socketsInterval time.Duration) Walker {
 	return &walker{
 		procRoot:                 procRoot,
 		gatheringWaitingInAccept: gatheringWaitingInAccept,
+		socketsInterval:          socketsInterval,
 	}
 }
 
@@ -71,7 +89,7 @@ func parseIntWithSpaces(buf *[]byte, pos *int) (ret int) {
 }
 
 // readStats reads and parses '/proc/<pid>/stat' files
-func readStats(path string) (ppid, threads int, jiffies, rss, rssLimit uint64, err error) {
+func readStats(path string) (ppid, threads int, jiffies, startTime, rss, rssLimit uint64, err error) {
 	const (
 		// /proc/<pid>/stat field positions, counting from zero
 		// see "man 5 proc"
@@ -79,6 +97,7 @@ func readStats(path string) (ppid, threads int, jiffies, rss, rssLimit uint64, e
 		procStatFieldUserJiffies int = 13
 		procStatFieldSysJiffies  int = 14
 		procStatFieldThreads     int = 19
+		procStatFieldStartTime   int = 21
 		procStatFieldRssPages    int = 23
 		procStatFieldRssLimit    int = 24
 	)
@@ -106,7 +125,10 @@ func readStats(path string) (ppid, threads int, jiffies, rss, rssLimit uint64, e
 	skipNSpaces(&buf, &pos, procStatFieldThreads-procStatFieldSysJiffies)
 	threads = parseIntWithSpaces(&buf, &pos)
 
-	skipNSpaces(&buf, &pos, procStatFieldRssPages-procStatFieldThreads)
+	skipNSpaces(&buf, &pos, procStatFieldStartTime-procStatFieldThreads)
+	startTime = parseUint64WithSpaces(&buf, &pos)
+
+	skipNSpaces(&buf, &pos, procStatFieldRssPages-procStatFieldStartTime)
 	rssPages = parseUint64WithSpaces(&buf, &pos)
 
 	pos++ // 1 space between rssPages and rssLimit
@@ -149,6 +171,92 @@ func readLimits(path string) (openFilesLimit uint64, err error) {
 	return softLimit, nil
 }
 
+// readIO reads the bytes read from and written to storage by a process from
+// '/proc/<pid>/io'
+func readIO(path string) (readBytes, writeBytes uint64, err error) {
+	buf, err := fs.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "read_bytes:":
+			readBytes, err = strconv.ParseUint(fields[1], 10, 64)
+		case "write_bytes:":
+			writeBytes, err = strconv.ParseUint(fields[1], 10, 64)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return readBytes, writeBytes, nil
+}
+
+// TCP states of sockets in '/proc/net/tcp', see include/net/tcp_states.h
+const (
+	tcpEstablished = "01"
+	tcpCloseWait   = "08"
+	tcpListen      = "0A"
+)
+
+// readSocketStates reads the states of the TCP sockets of the network
+// namespace of a process, by inode.
+func readSocketStates(procRoot, pid string) map[string]string {
+	states := map[string]string{}
+	for _, filename := range []string{"tcp", "tcp6"} {
+		buf, err := fs.ReadFile(path.Join(procRoot, pid, "net", filename))
+		if err != nil {
+			continue
+		}
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when
+		// retrnsmt uid timeout inode ...
+		for _, line := range strings.Split(string(buf), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			states[fields[9]] = fields[3]
+		}
+	}
+	return states
+}
+
+// countSockets counts the TCP sockets of a process by state. socketStates
+// caches the states of sockets by network namespace.
+func (w *walker) countSockets(pid string, fds []string, socketStates map[string]map[string]string) SocketCounts {
+	netNamespace, err := Readlink(path.Join(w.procRoot, pid, "ns", "net"))
+	if err != nil {
+		// no namespaces, the sockets of all processes are in the same file
+		netNamespace = ""
+	}
+	states, ok := socketStates[netNamespace]
+	if !ok {
+		states = readSocketStates(w.procRoot, pid)
+		socketStates[netNamespace] = states
+	}
+
+	var counts SocketCounts
+	for _, fd := range fds {
+		link, err := Readlink(path.Join(w.procRoot, pid, "fd", fd))
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		switch states[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+		case tcpEstablished:
+			counts.Established++
+		case tcpListen:
+			counts.Listening++
+		case tcpCloseWait:
+			counts.CloseWait++
+		}
+	}
+	return counts
+}
+
 func (w *walker) readCmdline(filename string) (cmdline, name string) {
 	if cmdlineBuf, err := fs.ReadFile(path.Join(w.procRoot, filename, "cmdline")); err == nil {
 		// like proc, treat name as the first element of command line
@@ -203,6 +311,17 @@ func (w *walker) Walk(f func(Process, Process)) error {
 	if err != nil {
 		return err
 	}
+	var (
+		now            = mtime.Now()
+		countSockets   = w.socketsInterval > 0 && now.Sub(w.lastSockets) >= w.socketsInterval
+		socketStates   = map[string]map[string]string{}
+		socketCounts   = w.socketCounts
+		hasSocketCount bool
+	)
+	if countSockets {
+		w.lastSockets = now
+		socketCounts = map[socketCountKey]SocketCounts{}
+	}
 
 	for _, filename := range dirEntries {
 		pid, err := strconv.Atoi(filename)
@@ -210,14 +329,31 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			continue
 		}
 
-		ppid, threads, jiffies, rss, rssLimit, err := readStats(path.Join(w.procRoot, filename, "stat"))
+		ppid, threads, jiffies, startTime, rss, rssLimit, err := readStats(path.Join(w.procRoot, filename, "stat"))
 		if err != nil {
 			continue
 		}
 
-		openFilesCount, err := fs.ReadDirCount(path.Join(w.procRoot, filename, "fd"))
-		if err != nil {
-			continue
+		var (
+			openFilesCount int
+			sockets        SocketCounts
+			key            = socketCountKey{pid, startTime}
+		)
+		if countSockets {
+			fds, err := fs.ReadDirNames(path.Join(w.procRoot, filename, "fd"))
+			if err != nil {
+				continue
+			}
+			openFilesCount = len(fds)
+			sockets = w.countSockets(filename, fds, socketStates)
+			socketCounts[key] = sockets
+			hasSocketCount = true
+		} else {
+			openFilesCount, err = fs.ReadDirCount(path.Join(w.procRoot, filename, "fd"))
+			if err != nil {
+				continue
+			}
+			sockets, hasSocketCount = socketCounts[key]
 		}
 
 		var openFilesLimit uint64
@@ -248,6 +384,9 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			isWaitingInAccept = IsProcInAccept(w.procRoot, filename)
 		}
 
+		ioReadBytes, ioWriteBytes, err := readIO(path.Join(w.procRoot, filename, "io"))
+		hasIOCounters := err == nil
+
 		f(Process{
 			PID:               pid,
 			PPID:              ppid,
@@ -255,14 +394,22 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			Cmdline:           cmdline,
 			Threads:           threads,
 			Jiffies:           jiffies,
+			StartTime:         startTime,
 			RSSBytes:          rss,
 			RSSBytesLimit:     rssLimit,
 			OpenFilesCount:    openFilesCount,
 			OpenFilesLimit:    openFilesLimit,
 			IsWaitingInAccept: isWaitingInAccept,
+			HasIOCounters:     hasIOCounters,
+			IOReadBytes:       ioReadBytes,
+			IOWriteBytes:      ioWriteBytes,
+			HasSocketCounts:   hasSocketCount,
+			Sockets:           sockets,
+			SampleTime:        now,
 		}, Process{})
 	}
 
+	w.socketCounts = socketCounts
 	return nil
 }
 
@@ -3,8 +3,10 @@ package process_test
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/common/test/fs"
 	"github.com/weaveworks/scope/probe/process"
@@ -19,7 +21,7 @@ var mockFS = fs.Dir("",
 			},
 			fs.File{
 				FName:     "stat",
-				FContents: "3 na R 2 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 2 2048",
+				FContents: "3 na R 2 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 1234 0 2 2048",
 			},
 			fs.File{
 				FName:     "limits",
@@ -79,16 +81,19 @@ var mockFS = fs.Dir("",
 func TestWalker(t *testing.T) {
 	fs_hook.Mock(mockFS)
 	defer fs_hook.Restore()
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
 
 	want := map[int]process.Process{
-		3: {PID: 3, PPID: 2, Name: "curl", Cmdline: "curl google.com", Threads: 1, RSSBytes: 8192, RSSBytesLimit: 2048, OpenFilesCount: 3, OpenFilesLimit: 32768},
-		2: {PID: 2, PPID: 1, Name: "bash", Cmdline: "bash", Threads: 1, OpenFilesCount: 2},
-		4: {PID: 4, PPID: 3, Name: "apache", Cmdline: "apache", Threads: 1, OpenFilesCount: 1},
-		1: {PID: 1, PPID: 0, Name: "init", Cmdline: "init", Threads: 1, OpenFilesCount: 0},
+		3: {PID: 3, PPID: 2, Name: "curl", Cmdline: "curl google.com", Threads: 1, StartTime: 1234, RSSBytes: 8192, RSSBytesLimit: 2048, OpenFilesCount: 3, OpenFilesLimit: 32768, SampleTime: now},
+		2: {PID: 2, PPID: 1, Name: "bash", Cmdline: "bash", Threads: 1, OpenFilesCount: 2, SampleTime: now},
+		4: {PID: 4, PPID: 3, Name: "apache", Cmdline: "apache", Threads: 1, OpenFilesCount: 1, SampleTime: now},
+		1: {PID: 1, PPID: 0, Name: "init", Cmdline: "init", Threads: 1, OpenFilesCount: 0, SampleTime: now},
 	}
 
 	have := map[int]process.Process{}
-	walker := process.NewWalker("/proc", false)
+	walker := process.NewWalker("/proc", false, 0)
 	err := walker.Walk(func(p, _ process.Process) {
 		have[p.PID] = p
 	})
@@ -97,3 +102,83 @@ func TestWalker(t *testing.T) {
 		t.Errorf("%v (%v)", test.Diff(want, have), err)
 	}
 }
+
+var ioAndSocketsFS = fs.Dir("",
+	fs.Dir("proc",
+		fs.Dir("33",
+			fs.File{
+				FName:     "cmdline",
+				FContents: "nginx",
+			},
+			fs.File{
+				FName:     "stat",
+				FContents: "33 na R 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0 0",
+			},
+			fs.File{
+				FName:     "limits",
+				FContents: ``,
+			},
+			fs.File{
+				FName:     "io",
+				FContents: "rchar: 100\nwchar: 200\nsyscr: 3\nsyscw: 4\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n",
+			},
+			fs.Dir("fd", fs.File{FName: "0"}, fs.File{FName: "3"}, fs.File{FName: "4"}, fs.File{FName: "5"}, fs.File{FName: "6"}),
+			fs.Dir("net",
+				fs.File{
+					FName: "tcp",
+					FContents: "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+						"   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0\n" +
+						"   1: 0100007F:0050 0100007F:9C40 01 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 20 4 30 10 -1\n" +
+						"   2: 0100007F:0050 0100007F:9C41 08 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 20 4 30 10 -1\n",
+				},
+			),
+		),
+	),
+)
+
+func TestWalkerIOAndSockets(t *testing.T) {
+	fs_hook.Mock(ioAndSocketsFS)
+	defer fs_hook.Restore()
+	oldReadlink := process.Readlink
+	defer func() { process.Readlink = oldReadlink }()
+	links := map[string]string{
+		"/proc/33/ns/net": "net:[4026531993]",
+		"/proc/33/fd/0":   "/dev/null",
+		"/proc/33/fd/3":   "socket:[1001]",
+		"/proc/33/fd/4":   "socket:[1002]",
+		"/proc/33/fd/5":   "socket:[1003]",
+		"/proc/33/fd/6":   "socket:[9999]",
+	}
+	process.Readlink = func(name string) (string, error) {
+		return links[name], nil
+	}
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	want := process.Process{
+		PID: 33, PPID: 1, Name: "nginx", Cmdline: "nginx", Threads: 1, OpenFilesCount: 5,
+		HasIOCounters: true, IOReadBytes: 4096, IOWriteBytes: 8192,
+		HasSocketCounts: true, Sockets: process.SocketCounts{Established: 1, Listening: 1, CloseWait: 1},
+	}
+	var have process.Process
+	walker := process.NewWalker("/proc", false, time.Minute)
+	walk := func() {
+		want.SampleTime = mtime.Now()
+		err := walker.Walk(func(p, _ process.Process) {
+			have = p
+		})
+		if err != nil || !reflect.DeepEqual(want, have) {
+			t.Errorf("%v (%v)", test.Diff(want, have), err)
+		}
+	}
+	walk()
+
+	// The sockets are only counted again once the interval has passed
+	links["/proc/33/fd/6"] = "socket:[1001]"
+	mtime.NowForce(now.Add(30 * time.Second))
+	walk()
+	mtime.NowForce(now.Add(time.Minute))
+	want.Sockets.Listening = 2
+	walk()
+}
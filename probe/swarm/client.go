@@ -0,0 +1,224 @@
+package swarm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types"
+	docker_swarm "github.com/docker/engine-api/types/swarm"
+)
+
+const (
+	// The Swarm API appeared in this version of the Docker API
+	apiVersion      = "v1.24"
+	defaultEndpoint = "unix:///var/run/docker.sock"
+	clientTimeout   = 10 * time.Second
+)
+
+// Client is the subset of the Docker Swarm API used by the reporter. We
+// create an interface so we can mock for testing.
+type Client interface {
+	// Info returns the Swarm state of the local Docker engine.
+	Info() (docker_swarm.Info, error)
+	// Cluster returns the Swarm the local Docker engine manages.
+	Cluster() (docker_swarm.Swarm, error)
+	ListNodes() ([]docker_swarm.Node, error)
+	ListServices() ([]docker_swarm.Service, error)
+	ListTasks() ([]docker_swarm.Task, error)
+	// ListNetworks returns the Swarm-scoped networks.
+	ListNetworks() ([]types.NetworkResource, error)
+	// ScaleService changes the number of replicas of a service by amount.
+	ScaleService(serviceID string, amount int) error
+}
+
+type client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// NewClient makes a new Client talking to the Docker engine at the given
+// endpoint, e.g. "unix:///var/run/docker.sock" or "tcp://10.0.0.1:2375".
+// An empty endpoint means $DOCKER_HOST, or the local engine's socket. Like
+// the Docker client, TCP endpoints are reached over TLS when
+// $DOCKER_TLS_VERIFY is set, with the certificates in $DOCKER_CERT_PATH.
+func NewClient(endpoint string) (Client, error) {
+	if endpoint == "" {
+		endpoint = os.Getenv("DOCKER_HOST")
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{http: &http.Client{Timeout: clientTimeout}}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		c.http.Transport = &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.DialTimeout("unix", socket, clientTimeout)
+			},
+		}
+		c.baseURL = "http://docker/" + apiVersion
+	case "tcp", "http":
+		c.baseURL = "http://" + u.Host + "/" + apiVersion
+		if os.Getenv("DOCKER_TLS_VERIFY") == "" {
+			break
+		}
+		config, err := tlsConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		c.http.Transport = &http.Transport{TLSClientConfig: config}
+		c.baseURL = "https://" + u.Host + "/" + apiVersion
+	default:
+		return nil, fmt.Errorf("unsupported Docker endpoint %q", endpoint)
+	}
+	return c, nil
+}
+
+// tlsConfigFromEnv loads the client certificate and the CA certificate from
+// $DOCKER_CERT_PATH, which defaults to ~/.docker.
+func tlsConfigFromEnv() (*tls.Config, error) {
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return nil, fmt.Errorf("HOME must be set if DOCKER_CERT_PATH is not")
+		}
+		certPath = filepath.Join(home, ".docker")
+	}
+	cert, err := tls.LoadX509KeyPair(filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(certPath, "ca.pem"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", filepath.Join(certPath, "ca.pem"))
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
+
+func (c *client) get(path string, result interface{}) error {
+	resp, err := c.http.Get(c.baseURL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(path, resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func apiError(path string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (c *client) Info() (docker_swarm.Info, error) {
+	var info struct{ Swarm docker_swarm.Info }
+	err := c.get("/info", &info)
+	return info.Swarm, err
+}
+
+func (c *client) Cluster() (docker_swarm.Swarm, error) {
+	var cluster docker_swarm.Swarm
+	err := c.get("/swarm", &cluster)
+	return cluster, err
+}
+
+func (c *client) ListNodes() ([]docker_swarm.Node, error) {
+	var nodes []docker_swarm.Node
+	err := c.get("/nodes", &nodes)
+	return nodes, err
+}
+
+func (c *client) ListServices() ([]docker_swarm.Service, error) {
+	var services []docker_swarm.Service
+	err := c.get("/services", &services)
+	return services, err
+}
+
+func (c *client) ListTasks() ([]docker_swarm.Task, error) {
+	var tasks []docker_swarm.Task
+	err := c.get("/tasks", &tasks)
+	return tasks, err
+}
+
+func (c *client) ListNetworks() ([]types.NetworkResource, error) {
+	var networks []types.NetworkResource
+	if err := c.get("/networks", &networks); err != nil {
+		return nil, err
+	}
+	result := networks[:0]
+	for _, network := range networks {
+		if network.Scope == "swarm" {
+			result = append(result, network)
+		}
+	}
+	return result, nil
+}
+
+// ScaleService updates the service spec as sent by the engine, rather than
+// as decoded into docker_swarm.ServiceSpec, so that fields the vendored types don't
+// know about are preserved.
+func (c *client) ScaleService(serviceID string, amount int) error {
+	var service struct {
+		Version docker_swarm.Version
+		Spec    map[string]interface{}
+	}
+	path := "/services/" + url.PathEscape(serviceID)
+	if err := c.get(path, &service); err != nil {
+		return err
+	}
+
+	mode, _ := service.Spec["Mode"].(map[string]interface{})
+	replicated, ok := mode["Replicated"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("service %s is not replicated", serviceID)
+	}
+	replicas, _ := replicated["Replicas"].(float64)
+	replicas += float64(amount)
+	if replicas < 0 {
+		replicas = 0
+	}
+	replicated["Replicas"] = replicas
+
+	body, err := json.Marshal(service.Spec)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(
+		fmt.Sprintf("%s%s/update?version=%d", c.baseURL, path, service.Version.Index),
+		"application/json", bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(path+"/update", resp)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package swarm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types/network"
+	docker_swarm "github.com/docker/engine-api/types/swarm"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for the metadata of Swarm nodes, services, tasks and networks.
+const (
+	Cluster            = report.SwarmCluster
+	CreatedAt          = report.SwarmCreatedAt
+	NodeHostname       = report.SwarmNodeHostname
+	NodeRole           = report.SwarmNodeRole
+	NodeAvailability   = report.SwarmNodeAvailability
+	NodeState          = report.SwarmNodeState
+	NodeManagerStatus  = report.SwarmNodeManagerStatus
+	ServiceName        = docker.ServiceName
+	StackNamespace     = docker.StackNamespace
+	ServiceMode        = report.SwarmServiceMode
+	ServiceImage       = report.SwarmServiceImage
+	ServiceDesiredTask = report.SwarmServiceDesiredTasks
+	ServiceRunningTask = report.SwarmServiceRunningTasks
+	TaskName           = report.SwarmTaskName
+	TaskState          = report.SwarmTaskState
+	TaskDesiredState   = report.SwarmTaskDesiredState
+	TaskMessage        = report.SwarmTaskMessage
+	NetworkName        = report.SwarmNetworkName
+	NetworkDriver      = report.SwarmNetworkDriver
+	NetworkSubnets     = report.SwarmNetworkSubnets
+	ScaleUp            = report.SwarmScaleUp
+	ScaleDown          = report.SwarmScaleDown
+)
+
+// Service modes
+const (
+	ModeReplicated = "replicated"
+	ModeGlobal     = "global"
+)
+
+var (
+	nodeMetadata = report.MetadataTemplates{
+		NodeHostname:      {ID: NodeHostname, Label: "Hostname", From: report.FromLatest, Priority: 0},
+		NodeRole:          {ID: NodeRole, Label: "Role", From: report.FromLatest, Priority: 1},
+		NodeManagerStatus: {ID: NodeManagerStatus, Label: "Manager Status", From: report.FromLatest, Priority: 2},
+		NodeAvailability:  {ID: NodeAvailability, Label: "Availability", From: report.FromLatest, Priority: 3},
+		NodeState:         {ID: NodeState, Label: "State", From: report.FromLatest, Priority: 4},
+		Cluster:           {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 5, Truncate: 12},
+	}
+	serviceMetadata = report.MetadataTemplates{
+		ServiceName:        {ID: ServiceName, Label: "Service Name", From: report.FromLatest, Priority: 0},
+		StackNamespace:     {ID: StackNamespace, Label: "Stack Namespace", From: report.FromLatest, Priority: 1},
+		ServiceMode:        {ID: ServiceMode, Label: "Mode", From: report.FromLatest, Priority: 2},
+		ServiceImage:       {ID: ServiceImage, Label: "Image", From: report.FromLatest, Priority: 3},
+		ServiceDesiredTask: {ID: ServiceDesiredTask, Label: "Desired Tasks", From: report.FromLatest, Priority: 4, Datatype: report.Number},
+		ServiceRunningTask: {ID: ServiceRunningTask, Label: "Running Tasks", From: report.FromLatest, Priority: 5, Datatype: report.Number},
+		CreatedAt:          {ID: CreatedAt, Label: "Created At", From: report.FromLatest, Priority: 6, Datatype: report.DateTime},
+		Cluster:            {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 7, Truncate: 12},
+	}
+	taskMetadata = report.MetadataTemplates{
+		TaskState:        {ID: TaskState, Label: "State", From: report.FromLatest, Priority: 0},
+		TaskDesiredState: {ID: TaskDesiredState, Label: "Desired State", From: report.FromLatest, Priority: 1},
+		TaskMessage:      {ID: TaskMessage, Label: "Message", From: report.FromLatest, Priority: 2},
+		ServiceImage:     {ID: ServiceImage, Label: "Image", From: report.FromLatest, Priority: 3},
+		CreatedAt:        {ID: CreatedAt, Label: "Created At", From: report.FromLatest, Priority: 4, Datatype: report.DateTime},
+	}
+	networkMetadata = report.MetadataTemplates{
+		NetworkName:    {ID: NetworkName, Label: "Name", From: report.FromLatest, Priority: 0},
+		NetworkDriver:  {ID: NetworkDriver, Label: "Driver", From: report.FromLatest, Priority: 1},
+		NetworkSubnets: {ID: NetworkSubnets, Label: "Subnets", From: report.FromLatest, Priority: 2},
+		Cluster:        {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 3, Truncate: 12},
+	}
+)
+
+// Reporter reports the nodes, services, tasks and networks of the Swarm
+// cluster managed by the local Docker engine. On engines which are not
+// Swarm managers it reports nothing, as only managers can query the cluster.
+type Reporter struct {
+	client          Client
+	handlerRegistry *controls.HandlerRegistry
+	probeID         string
+}
+
+// NewReporter makes a new Reporter, and registers its controls.
+func NewReporter(client Client, handlerRegistry *controls.HandlerRegistry, probeID string) *Reporter {
+	r := &Reporter{
+		client:          client,
+		handlerRegistry: handlerRegistry,
+		probeID:         probeID,
+	}
+	handlerRegistry.Batch(nil, map[string]xfer.ControlHandlerFunc{
+		ScaleUp:   r.controlScaleUp,
+		ScaleDown: r.controlScaleDown,
+	})
+	return r
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "Swarm" }
+
+// Stop unregisters controls.
+func (r *Reporter) Stop() {
+	r.handlerRegistry.Batch([]string{
+		ScaleUp,
+		ScaleDown,
+	}, nil)
+}
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	result.SwarmNode = result.SwarmNode.Merge(report.MakeTopology().WithMetadataTemplates(nodeMetadata))
+	serviceTopology := report.MakeTopology().WithMetadataTemplates(serviceMetadata)
+	serviceTopology.Controls.AddControls([]report.Control{
+		{
+			ID:    ScaleDown,
+			Human: "Scale Down",
+			Icon:  "fa-minus",
+			Rank:  0,
+		},
+		{
+			ID:    ScaleUp,
+			Human: "Scale Up",
+			Icon:  "fa-plus",
+			Rank:  1,
+		},
+	})
+	result.SwarmService = result.SwarmService.Merge(serviceTopology)
+	result.SwarmTask = result.SwarmTask.Merge(report.MakeTopology().WithMetadataTemplates(taskMetadata))
+	result.SwarmNetwork = result.SwarmNetwork.Merge(report.MakeTopology().WithMetadataTemplates(networkMetadata))
+
+	info, err := r.client.Info()
+	if err != nil {
+		return result, err
+	}
+	if !info.ControlAvailable {
+		return result, nil
+	}
+	cluster, err := r.client.Cluster()
+	if err != nil {
+		return result, err
+	}
+	nodes, err := r.client.ListNodes()
+	if err != nil {
+		return result, err
+	}
+	services, err := r.client.ListServices()
+	if err != nil {
+		return result, err
+	}
+	tasks, err := r.client.ListTasks()
+	if err != nil {
+		return result, err
+	}
+	networks, err := r.client.ListNetworks()
+	if err != nil {
+		return result, err
+	}
+
+	for _, node := range nodes {
+		result.SwarmNode.AddNode(r.nodeNode(cluster.ID, node))
+	}
+	for _, n := range networks {
+		result.SwarmNetwork.AddNode(report.MakeNodeWith(report.MakeSwarmNetworkNodeID(n.ID), map[string]string{
+			NetworkName:    n.Name,
+			NetworkDriver:  n.Driver,
+			NetworkSubnets: networkSubnets(n.IPAM.Config),
+			Cluster:        cluster.ID,
+		}))
+	}
+
+	servicesByID := map[string]docker_swarm.Service{}
+	for _, service := range services {
+		servicesByID[service.ID] = service
+	}
+	running := map[string]int{}
+	for _, task := range tasks {
+		if task.Status.State == docker_swarm.TaskStateRunning {
+			running[task.ServiceID]++
+		}
+		if task.DesiredState != docker_swarm.TaskStateRunning && task.Status.State != docker_swarm.TaskStateRunning {
+			// Swarm keeps a history of tasks which have finished; skip those.
+			continue
+		}
+		result.SwarmTask.AddNode(taskNode(task, servicesByID[task.ServiceID]))
+	}
+	for _, service := range services {
+		result.SwarmService.AddNode(r.serviceNode(cluster.ID, service, running[service.ID]))
+	}
+	return result, nil
+}
+
+func (r *Reporter) nodeNode(clusterID string, node docker_swarm.Node) report.Node {
+	managerStatus := ""
+	if node.ManagerStatus != nil {
+		managerStatus = string(node.ManagerStatus.Reachability)
+		if node.ManagerStatus.Leader {
+			managerStatus = "leader"
+		}
+	}
+	return report.MakeNodeWith(report.MakeSwarmNodeNodeID(node.ID), map[string]string{
+		NodeHostname:      node.Description.Hostname,
+		NodeRole:          string(node.Spec.Role),
+		NodeManagerStatus: managerStatus,
+		NodeAvailability:  string(node.Spec.Availability),
+		NodeState:         string(node.Status.State),
+		Cluster:           clusterID,
+	}).WithParents(report.MakeSets().
+		Add(report.Host, report.MakeStringSet(report.MakeHostNodeID(node.Description.Hostname))),
+	)
+}
+
+func (r *Reporter) serviceNode(clusterID string, service docker_swarm.Service, running int) report.Node {
+	name, namespace := docker.StackServiceName(service.Spec.Name, service.Spec.Labels["com.docker.stack.namespace"])
+	latests := map[string]string{
+		ServiceName:           name,
+		StackNamespace:        namespace,
+		ServiceImage:          service.Spec.TaskTemplate.ContainerSpec.Image,
+		ServiceRunningTask:    strconv.Itoa(running),
+		CreatedAt:             service.CreatedAt.Format(time.RFC3339Nano),
+		Cluster:               clusterID,
+		report.ControlProbeID: r.probeID,
+	}
+	node := report.MakeNode(report.MakeSwarmServiceNodeID(service.ID))
+	if replicated := service.Spec.Mode.Replicated; replicated != nil {
+		desired := uint64(0)
+		if replicated.Replicas != nil {
+			desired = *replicated.Replicas
+		}
+		latests[ServiceMode] = ModeReplicated
+		latests[ServiceDesiredTask] = strconv.FormatUint(desired, 10)
+		node = node.WithLatestControls(map[string]report.NodeControlData{
+			ScaleUp:   {Dead: false},
+			ScaleDown: {Dead: desired == 0},
+		})
+	} else {
+		latests[ServiceMode] = ModeGlobal
+	}
+	return node.WithLatests(latests)
+}
+
+func taskNode(task docker_swarm.Task, service docker_swarm.Service) report.Node {
+	parents := report.MakeSets().
+		Add(report.SwarmService, report.MakeStringSet(report.MakeSwarmServiceNodeID(task.ServiceID)))
+	if task.NodeID != "" {
+		parents = parents.Add(report.SwarmNode, report.MakeStringSet(report.MakeSwarmNodeNodeID(task.NodeID)))
+	}
+	networks := report.MakeStringSet()
+	for _, attachment := range task.NetworksAttachments {
+		networks = networks.Add(report.MakeSwarmNetworkNodeID(attachment.Network.ID))
+	}
+	if len(networks) > 0 {
+		parents = parents.Add(report.SwarmNetwork, networks)
+	}
+
+	message := task.Status.Err
+	if message == "" {
+		message = task.Status.Message
+	}
+	return report.MakeNodeWith(report.MakeSwarmTaskNodeID(task.ID), map[string]string{
+		TaskName:         taskName(task, service),
+		TaskState:        string(task.Status.State),
+		TaskDesiredState: string(task.DesiredState),
+		TaskMessage:      message,
+		ServiceImage:     task.Spec.ContainerSpec.Image,
+		CreatedAt:        task.CreatedAt.Format(time.RFC3339Nano),
+	}).WithParents(parents)
+}
+
+// taskName names tasks the way the docker CLI does: after their service, and
+// their slot for replicated services or their node for global ones.
+func taskName(task docker_swarm.Task, service docker_swarm.Service) string {
+	name := service.Spec.Name
+	if name == "" {
+		name = task.ServiceID
+	}
+	if task.Slot != 0 {
+		return fmt.Sprintf("%s.%d", name, task.Slot)
+	}
+	return fmt.Sprintf("%s.%s", name, task.NodeID)
+}
+
+func networkSubnets(configs []network.IPAMConfig) string {
+	subnets := make([]string, 0, len(configs))
+	for _, config := range configs {
+		subnets = append(subnets, config.Subnet)
+	}
+	return strings.Join(subnets, ", ")
+}
+
+func (r *Reporter) controlScaleUp(req xfer.Request) xfer.Response {
+	return xfer.ResponseError(r.controlScale(req, 1))
+}
+
+func (r *Reporter) controlScaleDown(req xfer.Request) xfer.Response {
+	return xfer.ResponseError(r.controlScale(req, -1))
+}
+
+func (r *Reporter) controlScale(req xfer.Request, amount int) error {
+	serviceID, ok := report.ParseSwarmServiceNodeID(req.NodeID)
+	if !ok {
+		return fmt.Errorf("Bad node ID")
+	}
+	return r.client.ScaleService(serviceID, amount)
+}
@@ -0,0 +1,181 @@
+package swarm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/network"
+	docker_swarm "github.com/docker/engine-api/types/swarm"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/swarm"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockClient struct {
+	manager  bool
+	replicas map[string]int
+}
+
+func (c *mockClient) Info() (docker_swarm.Info, error) {
+	return docker_swarm.Info{NodeID: "node1", ControlAvailable: c.manager}, nil
+}
+
+func (c *mockClient) Cluster() (docker_swarm.Swarm, error) {
+	return docker_swarm.Swarm{ID: "cluster1"}, nil
+}
+
+func (c *mockClient) ListNodes() ([]docker_swarm.Node, error) {
+	return []docker_swarm.Node{
+		{
+			ID:            "node1",
+			Description:   docker_swarm.NodeDescription{Hostname: "host1"},
+			Spec:          docker_swarm.NodeSpec{Role: docker_swarm.NodeRoleManager},
+			ManagerStatus: &docker_swarm.ManagerStatus{Leader: true},
+		},
+		{
+			ID:          "node2",
+			Description: docker_swarm.NodeDescription{Hostname: "host2"},
+			Spec:        docker_swarm.NodeSpec{Role: docker_swarm.NodeRoleWorker},
+		},
+	}, nil
+}
+
+func (c *mockClient) ListServices() ([]docker_swarm.Service, error) {
+	replicas := uint64(c.replicas["web"])
+	web := docker_swarm.Service{ID: "web"}
+	web.Spec.Name = "shop_web"
+	web.Spec.Labels = map[string]string{"com.docker.stack.namespace": "shop"}
+	web.Spec.Mode.Replicated = &docker_swarm.ReplicatedService{Replicas: &replicas}
+	agent := docker_swarm.Service{ID: "agent"}
+	agent.Spec.Name = "agent"
+	agent.Spec.Mode.Global = &docker_swarm.GlobalService{}
+	return []docker_swarm.Service{web, agent}, nil
+}
+
+func (c *mockClient) ListTasks() ([]docker_swarm.Task, error) {
+	ingress := []docker_swarm.NetworkAttachment{{Network: docker_swarm.Network{ID: "ingress"}}}
+	return []docker_swarm.Task{
+		{
+			ID: "web1", ServiceID: "web", NodeID: "node1", Slot: 1,
+			DesiredState:        docker_swarm.TaskStateRunning,
+			Status:              docker_swarm.TaskStatus{State: docker_swarm.TaskStateRunning},
+			NetworksAttachments: ingress,
+		},
+		{
+			ID: "web2", ServiceID: "web", NodeID: "node2", Slot: 2,
+			DesiredState: docker_swarm.TaskStateRunning,
+			Status:       docker_swarm.TaskStatus{State: docker_swarm.TaskStatePreparing},
+		},
+		{
+			ID: "web0", ServiceID: "web", NodeID: "node2", Slot: 2,
+			DesiredState: docker_swarm.TaskStateShutdown,
+			Status:       docker_swarm.TaskStatus{State: docker_swarm.TaskStateFailed, Err: "oom"},
+		},
+		{
+			ID: "agent1", ServiceID: "agent", NodeID: "node1",
+			DesiredState: docker_swarm.TaskStateRunning,
+			Status:       docker_swarm.TaskStatus{State: docker_swarm.TaskStateRunning},
+		},
+	}, nil
+}
+
+func (c *mockClient) ListNetworks() ([]types.NetworkResource, error) {
+	return []types.NetworkResource{
+		{
+			ID: "ingress", Name: "ingress", Scope: "swarm", Driver: "overlay",
+			IPAM: network.IPAM{Config: []network.IPAMConfig{{Subnet: "10.255.0.0/16"}}},
+		},
+	}, nil
+}
+
+func (c *mockClient) ScaleService(serviceID string, amount int) error {
+	if _, ok := c.replicas[serviceID]; !ok {
+		return fmt.Errorf("service %s is not replicated", serviceID)
+	}
+	c.replicas[serviceID] += amount
+	return nil
+}
+
+func TestReporter(t *testing.T) {
+	client := &mockClient{manager: true, replicas: map[string]int{"web": 2}}
+	hr := controls.NewDefaultHandlerRegistry()
+	r := swarm.NewReporter(client, hr, "probe1")
+	defer r.Stop()
+
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rpt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rpt.SwarmNode.Nodes) != 2 || len(rpt.SwarmService.Nodes) != 2 || len(rpt.SwarmNetwork.Nodes) != 1 {
+		t.Fatalf("Unexpected report: %d nodes, %d services, %d networks",
+			len(rpt.SwarmNode.Nodes), len(rpt.SwarmService.Nodes), len(rpt.SwarmNetwork.Nodes))
+	}
+	if len(rpt.SwarmTask.Nodes) != 3 {
+		t.Errorf("Expected finished tasks to be skipped, got %v", rpt.SwarmTask.Nodes)
+	}
+
+	web := rpt.SwarmService.Nodes[report.MakeSwarmServiceNodeID("web")]
+	for key, want := range map[string]string{
+		swarm.ServiceName:        "web",
+		swarm.StackNamespace:     "shop",
+		swarm.ServiceMode:        swarm.ModeReplicated,
+		swarm.ServiceDesiredTask: "2",
+		swarm.ServiceRunningTask: "1",
+		swarm.Cluster:            "cluster1",
+	} {
+		if have, _ := web.Latest.Lookup(key); have != want {
+			t.Errorf("Expected %s of web to be %q, got %q", key, want, have)
+		}
+	}
+	if _, ok := web.LatestControls.Lookup(swarm.ScaleUp); !ok {
+		t.Error("Expected web to be scalable")
+	}
+	agent := rpt.SwarmService.Nodes[report.MakeSwarmServiceNodeID("agent")]
+	if agent.LatestControls.Size() != 0 {
+		t.Errorf("Expected global services not to be scalable, got %v", agent.LatestControls)
+	}
+
+	task := rpt.SwarmTask.Nodes[report.MakeSwarmTaskNodeID("web1")]
+	if name, _ := task.Latest.Lookup(swarm.TaskName); name != "shop_web.1" {
+		t.Errorf("Unexpected task name %q", name)
+	}
+	for topology, want := range map[string]string{
+		report.SwarmService: report.MakeSwarmServiceNodeID("web"),
+		report.SwarmNode:    report.MakeSwarmNodeNodeID("node1"),
+		report.SwarmNetwork: report.MakeSwarmNetworkNodeID("ingress"),
+	} {
+		if have, _ := task.Parents.Lookup(topology); !have.Contains(want) {
+			t.Errorf("Expected task to have %s parent %s, got %v", topology, want, have)
+		}
+	}
+	if name, _ := rpt.SwarmTask.Nodes[report.MakeSwarmTaskNodeID("agent1")].Latest.Lookup(swarm.TaskName); name != "agent.node1" {
+		t.Errorf("Unexpected global task name %q", name)
+	}
+
+	res := hr.HandleControlRequest(xfer.Request{NodeID: web.ID, Control: swarm.ScaleUp})
+	if res.Error != "" || client.replicas["web"] != 3 {
+		t.Errorf("Scaling up failed: %v, %d replicas", res.Error, client.replicas["web"])
+	}
+	res = hr.HandleControlRequest(xfer.Request{NodeID: agent.ID, Control: swarm.ScaleDown})
+	if res.Error == "" {
+		t.Error("Expected an error scaling a global service")
+	}
+}
+
+func TestReporterOnWorkers(t *testing.T) {
+	r := swarm.NewReporter(&mockClient{}, controls.NewDefaultHandlerRegistry(), "probe1")
+	defer r.Stop()
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpt.SwarmNode.Nodes)+len(rpt.SwarmService.Nodes)+len(rpt.SwarmTask.Nodes) != 0 {
+		t.Error("Expected workers to report nothing")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
@@ -23,10 +24,13 @@ import (
 	"github.com/weaveworks/common/network"
 	"github.com/weaveworks/go-checkpoint"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/app/federation"
 	"github.com/weaveworks/scope/app/multitenant"
 	"github.com/weaveworks/scope/common/weave"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/weave/common"
 )
 
@@ -50,9 +54,13 @@ func init() {
 }
 
 // Router creates the mux for all the various app components.
-func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, externalUI bool, capabilities map[string]bool, metricsGraphURL string) http.Handler {
+func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, authenticator *auth.Authenticator, externalUI bool, capabilities map[string]bool, metricsGraphURL string, metricQueries *detailed.MetricQueries, metricHistory *app.MetricHistory) http.Handler {
 	router := mux.NewRouter().SkipClean(true)
 
+	if authenticator != nil {
+		authenticator.RegisterRoutes(router)
+	}
+
 	// We pull in the http.DefaultServeMux to get the pprof routes
 	router.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
 	router.Path("/metrics").Handler(prometheus.Handler())
@@ -60,7 +68,10 @@ func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter
 	app.RegisterReportPostHandler(collector, router)
 	app.RegisterControlRoutes(router, controlRouter)
 	app.RegisterPipeRoutes(router, pipeRouter)
-	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: collector, MetricsGraphURL: metricsGraphURL}, capabilities)
+	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: collector, MetricsGraphURL: metricsGraphURL, MetricQueries: metricQueries}, capabilities)
+	if metricHistory != nil {
+		app.RegisterMetricHistoryRoutes(router, collector, metricHistory)
+	}
 
 	uiHandler := http.FileServer(GetFS(externalUI))
 	router.PathPrefix("/ui").Name("static").Handler(
@@ -68,11 +79,15 @@ func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter
 			uiHandler))
 	router.PathPrefix("/").Name("static").Handler(uiHandler)
 
+	var handler http.Handler = router
+	if authenticator != nil {
+		handler = authenticator.Wrap(handler)
+	}
 	instrument := middleware.Instrument{
 		RouteMatcher: router,
 		Duration:     requestDuration,
 	}
-	return instrument.Wrap(router)
+	return instrument.Wrap(handler)
 }
 
 func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHostname string,
@@ -212,23 +227,87 @@ func appMain(flags appFlags) {
 	if flags.userIDHeader != "" {
 		userIDer = multitenant.UserIDHeader(flags.userIDHeader)
 	}
+	if flags.userIDClientCert {
+		userIDer = multitenant.UserIDClientCertificate
+	}
 
-	collector, err := collectorFactory(
-		userIDer, flags.collectorURL, flags.s3URL, flags.natsHostname,
-		multitenant.MemcacheConfig{
-			Host:             flags.memcachedHostname,
-			Timeout:          flags.memcachedTimeout,
-			Expiration:       flags.memcachedExpiration,
-			UpdateInterval:   memcacheUpdateInterval,
-			Service:          flags.memcachedService,
-			CompressionLevel: flags.memcachedCompressionLevel,
-		},
-		flags.window, flags.awsCreateTables)
+	tlsConfig, err := appTLSConfig(flags)
 	if err != nil {
-		log.Fatalf("Error creating collector: %v", err)
+		log.Fatalf("Error loading TLS certificates: %v", err)
 		return
 	}
 
+	var metricQueries *detailed.MetricQueries
+	if flags.metricsGraphQueries != "" {
+		if metricQueries, err = detailed.LoadMetricQueries(flags.metricsGraphQueries); err != nil {
+			log.Fatalf("Error loading metrics graph queries: %v", err)
+			return
+		}
+	}
+
+	var (
+		collector     app.Collector
+		controlRouter app.ControlRouter
+		pipeRouter    app.PipeRouter
+	)
+	if len(flags.federatedClusters) > 0 {
+		client, err := apiClient(flags.federationCA)
+		if err != nil {
+			log.Fatalf("Error loading CA certificates of clusters: %v", err)
+			return
+		}
+		reporter := federation.NewReporter(federation.Config{
+			Clusters: flags.federatedClusters,
+			Client:   client,
+			Interval: flags.federationInterval,
+			Window:   flags.window,
+		})
+		defer reporter.Stop()
+		collector = reporter
+		controlRouter = federation.NewControlRouter(reporter)
+		pipeRouter = federation.NewPipeRouter(reporter)
+	} else {
+		collector, err = collectorFactory(
+			userIDer, flags.collectorURL, flags.s3URL, flags.natsHostname,
+			multitenant.MemcacheConfig{
+				Host:             flags.memcachedHostname,
+				Timeout:          flags.memcachedTimeout,
+				Expiration:       flags.memcachedExpiration,
+				UpdateInterval:   memcacheUpdateInterval,
+				Service:          flags.memcachedService,
+				CompressionLevel: flags.memcachedCompressionLevel,
+			},
+			flags.window, flags.awsCreateTables)
+		if err != nil {
+			log.Fatalf("Error creating collector: %v", err)
+			return
+		}
+
+		controlRouter, err = controlRouterFactory(userIDer, flags.controlRouterURL, flags.controlRPCTimeout)
+		if err != nil {
+			log.Fatalf("Error creating control router: %v", err)
+			return
+		}
+
+		pipeRouter, err = pipeRouterFactory(userIDer, flags.pipeRouterURL, flags.consulInf)
+		if err != nil {
+			log.Fatalf("Error creating pipe router: %v", err)
+			return
+		}
+	}
+
+	// The history of the metrics of other tenants' nodes can't be kept
+	var metricHistory *app.MetricHistory
+	if flags.metricsHistory != "" && flags.userIDHeader == "" && !flags.userIDClientCert {
+		resolutions, err := app.ParseMetricHistoryResolutions(flags.metricsHistory)
+		if err != nil {
+			log.Fatalf("Error parsing metrics history resolutions: %v", err)
+			return
+		}
+		metricHistory = app.NewMetricHistory(collector, resolutions, flags.metricsHistoryMaxSeries)
+		defer metricHistory.Stop()
+	}
+
 	if flags.BillingEmitterConfig.Enabled {
 		billingEmitter, err := emitterFactory(collector, flags.BillingClientConfig, userIDer, flags.BillingEmitterConfig)
 		if err != nil {
@@ -239,18 +318,6 @@ func appMain(flags appFlags) {
 		collector = billingEmitter
 	}
 
-	controlRouter, err := controlRouterFactory(userIDer, flags.controlRouterURL, flags.controlRPCTimeout)
-	if err != nil {
-		log.Fatalf("Error creating control router: %v", err)
-		return
-	}
-
-	pipeRouter, err := pipeRouterFactory(userIDer, flags.pipeRouterURL, flags.consulInf)
-	if err != nil {
-		log.Fatalf("Error creating pipe router: %v", err)
-		return
-	}
-
 	// Start background version checking
 	checkpoint.CheckInterval(&checkpoint.CheckParams{
 		Product: "scope-app",
@@ -278,10 +345,34 @@ func appMain(flags appFlags) {
 		}
 	}
 
+	// Federating apps don't accept reports, so don't offer to stream them
+	acceptsReports := len(flags.federatedClusters) == 0
 	capabilities := map[string]bool{
 		xfer.HistoricReportsCapability: collector.HasHistoricReports(),
+		xfer.ReportStreamCapability:    acceptsReports,
+		xfer.ReportDeltasCapability:    acceptsReports,
+	}
+	var authenticator *auth.Authenticator
+	if flags.authConfig.Enabled() {
+		if flags.authSessionKeyFile != "" {
+			if flags.authConfig.SessionKey, err = ioutil.ReadFile(flags.authSessionKeyFile); err != nil {
+				log.Fatalf("Error reading session key: %v", err)
+				return
+			}
+		}
+		flags.authConfig.NoProbes = !acceptsReports
+		if authenticator, err = auth.New(flags.authConfig); err != nil {
+			log.Fatalf("Error setting up authentication: %v", err)
+			return
+		}
+	}
+
+	handler := router(collector, controlRouter, pipeRouter, authenticator, flags.externalUI, capabilities, flags.metricsGraphURL, metricQueries, metricHistory)
+	if tlsConfig != nil && tlsConfig.ClientCAs != nil {
+		handler = app.ClientCertificateAuth{
+			VerifyProbeID: flags.tlsVerifyProbeID,
+		}.Wrap(handler)
 	}
-	handler := router(collector, controlRouter, pipeRouter, flags.externalUI, capabilities, flags.metricsGraphURL)
 	if flags.logHTTP {
 		handler = middleware.Log{
 			LogRequestHeaders: flags.logHTTPHeaders,
@@ -300,8 +391,15 @@ func appMain(flags appFlags) {
 		},
 	}
 	go func() {
-		log.Infof("listening on %s", flags.listen)
-		if err := server.ListenAndServe(); err != nil {
+		var err error
+		if tlsConfig != nil {
+			log.Infof("listening on %s (HTTPS)", flags.listen)
+			err = server.ListenAndServeTLSConfig(tlsConfig)
+		} else {
+			log.Infof("listening on %s", flags.listen)
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			log.Error(err)
 		}
 	}()
@@ -16,6 +16,8 @@ import (
 
 	billing "github.com/weaveworks/billing-client"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/app/federation"
 	"github.com/weaveworks/scope/app/multitenant"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/appclient"
@@ -33,11 +35,15 @@ var (
 	probeTokenFlag         = "probe.token"
 	kubernetesPasswordFlag = "probe.kubernetes.password"
 	kubernetesTokenFlag    = "probe.kubernetes.token"
+	oidcClientSecretFlag   = "app.auth.oidc.client-secret"
+	queryTokenFlag         = "query.token"
 	sensitiveFlags         = []string{
 		serviceTokenFlag,
 		probeTokenFlag,
 		kubernetesPasswordFlag,
 		kubernetesTokenFlag,
+		oidcClientSecretFlag,
+		queryTokenFlag,
 	}
 	colonFinder         = regexp.MustCompile(`[^\\](:)`)
 	unescapeBackslashes = regexp.MustCompile(`\\(.)`)
@@ -80,6 +86,7 @@ func setLogLevel(levelname string) {
 type flags struct {
 	probe probeFlags
 	app   appFlags
+	query queryFlags
 
 	mode                             string
 	debug                            bool
@@ -96,9 +103,15 @@ type probeFlags struct {
 	token                  string
 	httpListen             string
 	publishInterval        time.Duration
+	publishStream          bool
+	publishDeltas          bool
 	spyInterval            time.Duration
 	pluginsRoot            string
 	insecure               bool
+	tlsCert                string // Client certificate presented to the app
+	tlsKey                 string
+	tlsCA                  string // CA of the app's certificate
+	tlsIDFromCert          bool   // Use the common name of the certificate as probe ID
 	logPrefix              string
 	logLevel               string
 	resolver               string
@@ -115,9 +128,16 @@ type probeFlags struct {
 	useEbpfConn bool // Enable connection tracking with eBPF
 	procRoot    string
 
+	socketsEnabled    bool          // Count the TCP sockets of processes (must be root)
+	socketsInterval   time.Duration // How often to count them
+	listeningEnabled  bool          // Report listening sockets of processes (must be root)
+	listeningInterval time.Duration // How often to look for the processes owning them
+	netDevicesEnabled bool          // Produce network device topology (must be root)
+
 	dockerEnabled  bool
 	dockerInterval time.Duration
 	dockerBridge   string
+	swarmEnabled   bool
 
 	kubernetesEnabled      bool
 	kubernetesNodeName     string
@@ -132,6 +152,13 @@ type probeFlags struct {
 	weaveEnabled  bool
 	weaveAddr     string
 	weaveHostname string
+
+	calicoEnabled     bool
+	calicoBIRDSocket  string
+	ciliumEnabled     bool
+	ciliumSocket      string
+	flannelEnabled    bool
+	flannelSubnetFile string
 }
 
 type appFlags struct {
@@ -143,6 +170,11 @@ type appFlags struct {
 	logHTTP        bool
 	logHTTPHeaders bool
 
+	tlsCert          string
+	tlsKey           string
+	tlsClientCA      string // Verify client certificates against this CA
+	tlsVerifyProbeID bool   // Require probe IDs to be the common names of their certificates
+
 	weaveEnabled   bool
 	weaveAddr      string
 	weaveHostname  string
@@ -161,8 +193,17 @@ type appFlags struct {
 	memcachedExpiration       time.Duration
 	memcachedCompressionLevel int
 	userIDHeader              string
+	userIDClientCert          bool
+	authConfig                auth.Config
+	authSessionKeyFile        string
+	federatedClusters         clustersFlag
+	federationInterval        time.Duration
+	federationCA              string
 	externalUI                bool
 	metricsGraphURL           string
+	metricsGraphQueries       string
+	metricsHistory            string
+	metricsHistoryMaxSeries   int
 
 	blockProfileRate int
 
@@ -221,6 +262,50 @@ func (c *containerLabelFiltersFlag) toAPITopologyOption(flagValue string, filter
 	return app.MakeAPITopologyOption(filterID, containerFilterTitle, filterFunction(labelKeyValuePair[0], labelKeyValuePair[1]), false), nil
 }
 
+// clustersFlag is the list of clusters of a federating app.
+type clustersFlag []federation.Cluster
+
+func (c *clustersFlag) String() string {
+	clusters := []string{}
+	for _, cluster := range *c {
+		u := cluster.URL
+		u.User = nil // omit tokens
+		clusters = append(clusters, cluster.Name+"="+u.String())
+	}
+	return strings.Join(clusters, ",")
+}
+
+func (c *clustersFlag) Set(flagValue string) error {
+	cluster, err := federation.ParseCluster(flagValue)
+	if err != nil {
+		return err
+	}
+	for _, other := range *c {
+		if other.Name == cluster.Name {
+			return fmt.Errorf("duplicate cluster %s", cluster.Name)
+		}
+	}
+	*c = append(*c, cluster)
+	return nil
+}
+
+// listFlag is a list of comma-separated values, which may be given over
+// several flags.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(flagValue string) error {
+	for _, value := range strings.Split(flagValue, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			*l = append(*l, value)
+		}
+	}
+	return nil
+}
+
 func logCensoredArgs() {
 	var prettyPrintedArgs string
 	// We show the flags followed by the args. This may change the original
@@ -278,6 +363,8 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.token, probeTokenFlag, "", "Token to authenticate with cloud.weave.works")
 	flag.StringVar(&flags.probe.httpListen, "probe.http.listen", "", "listen address for HTTP profiling and instrumentation server")
 	flag.DurationVar(&flags.probe.publishInterval, "probe.publish.interval", 3*time.Second, "publish (output) interval")
+	flag.BoolVar(&flags.probe.publishStream, "probe.publish.stream", true, "stream reports over a websocket to apps which support it")
+	flag.BoolVar(&flags.probe.publishDeltas, "probe.publish.deltas", true, "send report deltas over the report stream to apps which support it")
 	flag.DurationVar(&flags.probe.spyInterval, "probe.spy.interval", time.Second, "spy (scan) interval")
 	flag.StringVar(&flags.probe.pluginsRoot, "probe.plugins.root", "/var/run/scope/plugins", "Root directory to search for plugins")
 	flag.BoolVar(&flags.probe.noControls, "probe.no-controls", false, "Disable controls (e.g. start/stop containers, terminals, logs ...)")
@@ -285,6 +372,10 @@ func setupFlags(flags *flags) {
 	flag.BoolVar(&flags.probe.noEnvironmentVariables, "probe.omit.env-vars", true, "Disable collection of environment variables")
 
 	flag.BoolVar(&flags.probe.insecure, "probe.insecure", false, "(SSL) explicitly allow \"insecure\" SSL connections and transfers")
+	flag.StringVar(&flags.probe.tlsCert, "probe.tls.cert", "", "Client certificate (PEM) to present to apps verifying the certificates of probes")
+	flag.StringVar(&flags.probe.tlsKey, "probe.tls.key", "", "Private key (PEM) of the client certificate")
+	flag.StringVar(&flags.probe.tlsCA, "probe.tls.ca", "", "CA certificates (PEM) to verify the certificate of apps with, instead of the well-known ones")
+	flag.BoolVar(&flags.probe.tlsIDFromCert, "probe.tls.id-from-cert", false, "Use the common name of the client certificate as probe ID")
 	flag.StringVar(&flags.probe.resolver, "probe.resolver", "", "IP address & port of resolver to use.  Default is to use system resolver.")
 	flag.StringVar(&flags.probe.logPrefix, "probe.log.prefix", "<probe>", "prefix for each log line")
 	flag.StringVar(&flags.probe.logLevel, "probe.log.level", "info", "logging threshold level: debug|info|warn|error|fatal|panic")
@@ -296,11 +387,17 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.procRoot, "probe.proc.root", "/proc", "location of the proc filesystem")
 	flag.BoolVar(&flags.probe.procEnabled, "probe.processes", true, "produce process topology & include procspied connections")
 	flag.BoolVar(&flags.probe.useEbpfConn, "probe.ebpf.connections", true, "enable connection tracking with eBPF")
+	flag.BoolVar(&flags.probe.socketsEnabled, "probe.proc.sockets", false, "count the TCP sockets of processes by state (needs root)")
+	flag.DurationVar(&flags.probe.socketsInterval, "probe.proc.sockets.interval", 30*time.Second, "how often to count the TCP sockets of processes")
+	flag.BoolVar(&flags.probe.listeningEnabled, "probe.listening", true, "report the sockets processes listen on (needs root)")
+	flag.DurationVar(&flags.probe.listeningInterval, "probe.listening.interval", 10*time.Second, "how often to look for the processes owning listening sockets")
+	flag.BoolVar(&flags.probe.netDevicesEnabled, "probe.net-devices", false, "produce network device topology of host and container namespaces (needs root)")
 
 	// Docker
 	flag.BoolVar(&flags.probe.dockerEnabled, "probe.docker", false, "collect Docker-related attributes for processes")
 	flag.DurationVar(&flags.probe.dockerInterval, "probe.docker.interval", 10*time.Second, "how often to update Docker attributes")
 	flag.StringVar(&flags.probe.dockerBridge, "probe.docker.bridge", "docker0", "the docker bridge name")
+	flag.BoolVar(&flags.probe.swarmEnabled, "probe.docker.swarm", true, "on Docker Swarm managers, collect Swarm nodes, services, tasks and networks")
 
 	// K8s
 	flag.BoolVar(&flags.probe.kubernetesEnabled, "probe.kubernetes", false, "collect kubernetes-related attributes for containers")
@@ -330,6 +427,14 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.weaveAddr, "probe.weave.addr", "127.0.0.1:6784", "IP address & port of the Weave router")
 	flag.StringVar(&flags.probe.weaveHostname, "probe.weave.hostname", "", "Hostname to lookup in WeaveDNS")
 
+	// Other overlay networks
+	flag.BoolVar(&flags.probe.calicoEnabled, "probe.calico", false, "Collect Calico peers from BIRD")
+	flag.StringVar(&flags.probe.calicoBIRDSocket, "probe.calico.bird-socket", "/var/run/calico/bird.ctl", "BIRD control socket of calico-node")
+	flag.BoolVar(&flags.probe.ciliumEnabled, "probe.cilium", false, "Collect Cilium peers from the cilium-agent")
+	flag.StringVar(&flags.probe.ciliumSocket, "probe.cilium.socket", "/var/run/cilium/cilium.sock", "API socket of the cilium-agent")
+	flag.BoolVar(&flags.probe.flannelEnabled, "probe.flannel", false, "Collect Flannel peers from the subnet file and routes")
+	flag.StringVar(&flags.probe.flannelSubnetFile, "probe.flannel.subnet-file", "/run/flannel/subnet.env", "Subnet file written by flanneld")
+
 	// App flags
 	flag.DurationVar(&flags.app.window, "app.window", 15*time.Second, "window")
 	flag.StringVar(&flags.app.listen, "app.http.address", ":"+strconv.Itoa(xfer.AppPort), "webserver listen address")
@@ -338,6 +443,10 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.app.logPrefix, "app.log.prefix", "<app>", "prefix for each log line")
 	flag.BoolVar(&flags.app.logHTTP, "app.log.http", false, "Log individual HTTP requests")
 	flag.BoolVar(&flags.app.logHTTPHeaders, "app.log.httpHeaders", false, "Log HTTP headers. Needs app.log.http to be enabled.")
+	flag.StringVar(&flags.app.tlsCert, "app.tls.cert", "", "Certificate (PEM) to serve HTTPS with. If empty, serve HTTP")
+	flag.StringVar(&flags.app.tlsKey, "app.tls.key", "", "Private key (PEM) of the certificate")
+	flag.StringVar(&flags.app.tlsClientCA, "app.tls.client-ca", "", "CA certificates (PEM) to verify client certificates with. If set, probes must present one")
+	flag.BoolVar(&flags.app.tlsVerifyProbeID, "app.tls.verify-probe-id", false, "Reject probes whose ID isn't the common name of their client certificate")
 
 	flag.StringVar(&flags.app.weaveAddr, "app.weave.addr", app.DefaultWeaveURL, "Address on which to contact WeaveDNS")
 	flag.StringVar(&flags.app.weaveHostname, "app.weave.hostname", "", "Hostname to advertise in WeaveDNS")
@@ -358,13 +467,38 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.app.memcachedService, "app.memcached.service", "memcached", "SRV service used to discover memcache servers.")
 	flag.IntVar(&flags.app.memcachedCompressionLevel, "app.memcached.compression", gzip.DefaultCompression, "How much to compress reports stored in memcached.")
 	flag.StringVar(&flags.app.userIDHeader, "app.userid.header", "", "HTTP header to use as userid")
+	flag.BoolVar(&flags.app.userIDClientCert, "app.userid.client-cert", false, "Use the common name of the client certificate as userid")
+	flag.StringVar(&flags.app.authConfig.HtpasswdFile, "app.auth.htpasswd", "", "Require users to log in with HTTP basic auth, with the users of this htpasswd file (MD5 or SHA1 hashes)")
+	flag.StringVar(&flags.app.authConfig.TokensFile, "app.auth.tokens", "", "Require API clients and probes to authenticate with one of the bearer tokens of this file of \"token name\" lines, ending with \"probe\" for tokens only probes may use")
+	flag.StringVar(&flags.app.authConfig.OIDC.Issuer, "app.auth.oidc.issuer", "", "Require users to log in with this OpenID Connect provider")
+	flag.StringVar(&flags.app.authConfig.OIDC.ClientID, "app.auth.oidc.client-id", "", "OAuth2 client ID of the app at the OpenID Connect provider")
+	flag.StringVar(&flags.app.authConfig.OIDC.ClientSecret, oidcClientSecretFlag, "", "OAuth2 client secret of the app at the OpenID Connect provider")
+	flag.StringVar(&flags.app.authConfig.OIDC.RedirectURL, "app.auth.oidc.redirect-url", "", "External URL of the app's "+auth.CallbackPath+" route, registered at the OpenID Connect provider")
+	flag.StringVar(&flags.app.authConfig.OIDC.UserClaim, "app.auth.oidc.user-claim", "email", "Claim of the ID token naming the user")
+	flag.Var((*listFlag)(&flags.app.authConfig.OIDC.AllowedEmails), "app.auth.oidc.allowed-emails", "Comma-separated verified emails of the users allowed to log in with OpenID Connect")
+	flag.Var((*listFlag)(&flags.app.authConfig.OIDC.AllowedDomains), "app.auth.oidc.allowed-domains", "Comma-separated domains of the verified emails of the users allowed to log in with OpenID Connect")
+	flag.Var((*listFlag)(&flags.app.authConfig.OIDC.AllowedGroups), "app.auth.oidc.allowed-groups", "Comma-separated groups (groups claim) of the users allowed to log in with OpenID Connect")
+	flag.DurationVar(&flags.app.authConfig.SessionTTL, "app.auth.session-ttl", 12*time.Hour, "How long OpenID Connect logins last")
+	flag.StringVar(&flags.app.authSessionKeyFile, "app.auth.session-key-file", "", "File of the key signing login sessions, for sessions to survive restarts. If empty, a random key is used")
+	flag.Var(&flags.app.federatedClusters, "app.federate", "Show the reports of the Scope app of another cluster, specified as name=URL, instead of accepting reports from probes. Multiple flags are accepted. Example: --app.federate=eu-west=https://token@scope.eu-west.example.com")
+	flag.DurationVar(&flags.app.federationInterval, "app.federate.interval", 3*time.Second, "How often to pull the reports of federated clusters")
+	flag.StringVar(&flags.app.federationCA, "app.federate.ca", "", "CA certificates (PEM) to verify the apps of federated clusters with, instead of the well-known ones")
 	flag.BoolVar(&flags.app.externalUI, "app.externalUI", false, "Point to externally hosted static UI assets")
 	flag.StringVar(&flags.app.metricsGraphURL, "app.metrics-graph", "", "Enable extended metrics graph by providing a templated URL (supports :orgID and :query). Example: --app.metric-graph=/prom/:orgID/notebook/new")
+	flag.StringVar(&flags.app.metricsHistory, "app.metrics-history", "", "Resolutions to keep the history of the metrics of nodes at, as comma-separated step:retention pairs (e.g. 15s:1h,2m:6h), empty to disable. Not available to multitenant apps")
+	flag.IntVar(&flags.app.metricsHistoryMaxSeries, "app.metrics-history.max-series", 10000, "Maximum number of metrics of nodes to keep the history of, forgetting the least recently reported ones beyond it")
+	flag.StringVar(&flags.app.metricsGraphQueries, "app.metrics-graph.queries", "", "YAML or JSON file of the queries of the metrics graph, replacing those of cAdvisor metrics")
 
 	flag.IntVar(&flags.app.blockProfileRate, "app.block.profile.rate", 0, "If more than 0, enable block profiling. The profiler aims to sample an average of one blocking event per rate nanoseconds spent blocked.")
 
 	flag.BoolVar(&flags.app.awsCreateTables, "app.aws.create.tables", false, "Create the tables in DynamoDB")
 	flag.StringVar(&flags.app.consulInf, "app.consul.inf", "", "The interface who's address I should advertise myself under in consul")
+
+	// Query flags
+	flag.StringVar(&flags.query.app, "query.app", "http://localhost:4040", "URL of the app to query, which may include the user and password of basic authentication")
+	flag.StringVar(&flags.query.token, queryTokenFlag, "", "Token to authenticate to the app with")
+	flag.StringVar(&flags.query.ca, "query.tls.ca", "", "CA certificates (PEM) to verify the app with, instead of the well-known ones")
+	flag.StringVar(&flags.query.output, "query.output", "table", "Output format (table or json)")
 }
 
 func main() {
@@ -443,6 +577,8 @@ func main() {
 		appMain(flags.app)
 	case "probe":
 		probeMain(flags.probe, targets)
+	case "query":
+		queryMain(flags.query, flag.Args())
 	case "version":
 		fmt.Println("Weave Scope version", version)
 	case "help":
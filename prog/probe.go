@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"math/rand"
 	"net"
 	"net/http"
@@ -28,9 +30,11 @@ import (
 	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/netdev"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/plugins"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/swarm"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/weave/common"
 )
@@ -109,6 +113,31 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		hostName = hostname.Get()
 		hostID   = hostName // TODO(pb): we should sanitize the hostname
 	)
+
+	var (
+		tlsCertificates []tls.Certificate
+		tlsRootCAs      *x509.CertPool
+	)
+	if flags.tlsCert != "" || flags.tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(flags.tlsCert, flags.tlsKey)
+		if err != nil {
+			log.Fatalf("Error loading client certificate: %v", err)
+		}
+		tlsCertificates = []tls.Certificate{cert}
+		if flags.tlsIDFromCert {
+			if probeID, err = certificateName(cert); err != nil {
+				log.Fatalf("Error reading probe ID from client certificate: %v", err)
+			}
+		}
+	} else if flags.tlsIDFromCert {
+		log.Fatal("--probe.tls.id-from-cert requires --probe.tls.cert and --probe.tls.key")
+	}
+	if flags.tlsCA != "" {
+		var err error
+		if tlsRootCAs, err = loadCertPool(flags.tlsCA); err != nil {
+			log.Fatalf("Error loading CA certificates: %v", err)
+		}
+	}
 	log.Infof("probe starting, version %s, ID %s", version, probeID)
 	checkNewScopeVersion(flags)
 
@@ -124,6 +153,11 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			ProbeVersion: version,
 			ProbeID:      probeID,
 			Insecure:     flags.insecure,
+			ReportStream: flags.publishStream,
+			ReportDeltas: flags.publishDeltas,
+
+			TLSCertificates: tlsCertificates,
+			TLSRootCAs:      tlsRootCAs,
 		}
 		return appclient.NewAppClient(
 			probeConfig, hostname, url,
@@ -152,14 +186,30 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 
 	hostReporter := host.NewReporter(hostID, hostName, probeID, version, clients, handlerRegistry)
 	defer hostReporter.Stop()
-	p.AddReporter(hostReporter)
+	p.AddReporter(hostReporter, probe.NewHealthReporter(hostID, p))
 	p.AddTagger(probe.NewTopologyTagger(), host.NewTagger(hostID))
 
 	var processCache *process.CachingWalker
 	if flags.procEnabled {
-		processCache = process.NewCachingWalker(process.NewWalker(flags.procRoot, false))
+		var socketsInterval time.Duration
+		if flags.socketsEnabled {
+			socketsInterval = flags.socketsInterval
+		}
+		processCache = process.NewCachingWalker(process.NewWalker(flags.procRoot, false, socketsInterval))
 		p.AddTicker(processCache)
-		p.AddReporter(process.NewReporter(processCache, hostID, process.GetDeltaTotalJiffies, flags.noCommandLineArguments))
+		processReporter := process.NewReporter(process.ReporterConfig{
+			Scope:                  hostID,
+			ProbeID:                probeID,
+			Walker:                 processCache,
+			Jiffies:                process.GetDeltaTotalJiffies,
+			ProcRoot:               flags.procRoot,
+			NoCommandLineArguments: flags.noCommandLineArguments,
+			NoEnvironmentVariables: flags.noEnvironmentVariables,
+			Pipes:                  clients,
+			HandlerRegistry:        handlerRegistry,
+		})
+		defer processReporter.Stop()
+		p.AddReporter(processReporter)
 	}
 
 	dnsSnooper, err := endpoint.NewDNSSnooper()
@@ -210,6 +260,15 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		} else {
 			log.Errorf("Docker: failed to start registry: %v", err)
 		}
+		if flags.swarmEnabled {
+			if client, err := swarm.NewClient(""); err == nil {
+				reporter := swarm.NewReporter(client, handlerRegistry, probeID)
+				defer reporter.Stop()
+				p.AddReporter(reporter)
+			} else {
+				log.Errorf("Swarm: failed to start client: %v", err)
+			}
+		}
 	}
 
 	if flags.kubernetesEnabled {
@@ -268,6 +327,36 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		}
 	}
 
+	overlaySources := []overlay.Source{}
+	if flags.calicoEnabled {
+		overlaySources = append(overlaySources, overlay.NewCalico(flags.calicoBIRDSocket, flags.procRoot))
+	}
+	if flags.ciliumEnabled {
+		overlaySources = append(overlaySources, overlay.NewCilium(flags.ciliumSocket))
+	}
+	if flags.flannelEnabled {
+		overlaySources = append(overlaySources, overlay.NewFlannel(flags.flannelSubnetFile, flags.procRoot))
+	}
+	for _, source := range overlaySources {
+		reporter := overlay.NewReporter(hostID, source)
+		defer reporter.Stop()
+		p.AddReporter(reporter)
+	}
+
+	// The following taggers need the docker tagger to have given processes
+	// their containers
+	if flags.procEnabled && flags.listeningEnabled {
+		listening := endpoint.NewListeningReporter(hostID, processCache, flags.listeningInterval)
+		defer listening.Stop()
+		p.AddReporter(listening)
+		p.AddTagger(listening)
+	}
+	if flags.netDevicesEnabled {
+		netDevices := netdev.NewReporter(hostID, flags.procRoot)
+		p.AddReporter(netDevices)
+		p.AddTagger(netDevices)
+	}
+
 	pluginRegistry, err := plugins.NewRegistry(
 		flags.pluginsRoot,
 		pluginAPIVersion,
@@ -277,6 +366,7 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		},
 		handlerRegistry,
 		p,
+		clients,
 	)
 	if err != nil {
 		log.Errorf("plugins: problem loading: %v", err)
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+const queryUsage = `Usage: scope query {OPTIONS} COMMAND
+
+Commands:
+  topologies                                  List the topologies
+  nodes TOPOLOGY {OPTION=VALUE}               List the nodes of a topology, with its options
+  node TOPOLOGY NODE                          Show the details of a node
+  connections TOPOLOGY FROM TO                List the connections between two nodes
+  control TOPOLOGY NODE CONTROL {ARG=VALUE}   Invoke a control of a node, e.g.
+                                              docker_restart_container, and attach
+                                              to the output of the pipe it opens
+
+Options:
+`
+
+type queryFlags struct {
+	app    string
+	token  string
+	ca     string
+	output string
+}
+
+// queryClient queries the API of a running app.
+type queryClient struct {
+	flags  queryFlags
+	base   *url.URL
+	client *http.Client
+	out    io.Writer
+}
+
+func queryMain(flags queryFlags, args []string) {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Fprint(os.Stderr, queryUsage)
+		flag.VisitAll(func(f *flag.Flag) {
+			if strings.HasPrefix(f.Name, "query.") {
+				fmt.Fprintf(os.Stderr, "  --%s=%s\n    \t%s\n", f.Name, f.DefValue, f.Usage)
+			}
+		})
+		return
+	}
+	if err := runQuery(flags, args, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runQuery(flags queryFlags, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command, see scope query help")
+	}
+	if flags.output != "table" && flags.output != "json" {
+		return fmt.Errorf("invalid output %q, expected table or json", flags.output)
+	}
+	base, err := url.Parse(flags.app)
+	if err != nil {
+		return err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return fmt.Errorf("invalid app URL %s", flags.app)
+	}
+	client, err := apiClient(flags.ca)
+	if err != nil {
+		return err
+	}
+	c := queryClient{flags: flags, base: base, client: client, out: out}
+
+	command, args := args[0], args[1:]
+	switch {
+	case command == "topologies" && len(args) == 0:
+		return c.topologies()
+	case command == "nodes" && len(args) >= 1:
+		return c.nodes(args[0], args[1:])
+	case command == "node" && len(args) == 2:
+		return c.node(args[0], args[1])
+	case command == "connections" && len(args) == 3:
+		return c.connections(args[0], args[1], args[2])
+	case command == "control" && len(args) >= 3:
+		return c.control(args[0], args[1], args[2], args[3:])
+	default:
+		return fmt.Errorf("invalid command %q, see scope query help", strings.Join(append([]string{command}, args...), " "))
+	}
+}
+
+// url returns the URL of an escaped path of the app.
+func (c queryClient) url(path string, query url.Values) string {
+	u := url.URL{Scheme: c.base.Scheme, Host: c.base.Host}
+	s := u.String() + strings.TrimSuffix(c.base.EscapedPath(), "/") + path
+	if len(query) > 0 {
+		s += "?" + query.Encode()
+	}
+	return s
+}
+
+// authorize adds the token, or the user of the URL of the app, to the
+// headers of a request.
+func (c queryClient) authorize(headers http.Header) {
+	if c.flags.token != "" {
+		headers.Set("Authorization", "Bearer "+c.flags.token)
+	} else if c.base.User != nil {
+		password, _ := c.base.User.Password()
+		req := http.Request{Header: headers}
+		req.SetBasicAuth(c.base.User.Username(), password)
+	}
+}
+
+// do makes a request to the app, and decodes its JSON response into
+// result, if any.
+func (c queryClient) do(method, path string, query url.Values, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		buf := &bytes.Buffer{}
+		if err := codec.NewEncoder(buf, &codec.JsonHandle{}).Encode(body); err != nil {
+			return err
+		}
+		reqBody = buf
+	}
+	req, err := http.NewRequest(method, c.url(path, query), reqBody)
+	if err != nil {
+		return err
+	}
+	c.authorize(req.Header)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		// Errors are JSON encoded strings
+		var message string
+		if err := codec.NewDecoderBytes(buf.Bytes(), &codec.JsonHandle{}).Decode(&message); err != nil {
+			message = strings.TrimSpace(buf.String())
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, message)
+	}
+	if result == nil || buf.Len() == 0 {
+		return nil
+	}
+	return codec.NewDecoderBytes(buf.Bytes(), &codec.JsonHandle{}).Decode(result)
+}
+
+// print prints the result of a command as JSON, or as a table.
+func (c queryClient) print(result interface{}, header []string, rows [][]string) error {
+	if c.flags.output == "json" {
+		buf := &bytes.Buffer{}
+		if err := codec.NewEncoder(buf, &codec.JsonHandle{HTMLCharsAsIs: true}).Encode(result); err != nil {
+			return err
+		}
+		indented := &bytes.Buffer{}
+		if err := json.Indent(indented, buf.Bytes(), "", "  "); err != nil {
+			return err
+		}
+		indented.WriteString("\n")
+		_, err := indented.WriteTo(c.out)
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func (c queryClient) topologies() error {
+	var topologies []app.APITopologyDesc
+	if err := c.do("GET", "/api/topology", nil, nil, &topologies); err != nil {
+		return err
+	}
+	rows := [][]string{}
+	var add func(indent string, topologies []app.APITopologyDesc)
+	add = func(indent string, topologies []app.APITopologyDesc) {
+		for _, t := range topologies {
+			rows = append(rows, []string{
+				indent + strings.TrimPrefix(t.URL, "/api/topology/"),
+				t.Name,
+				strconv.Itoa(t.Stats.NonpseudoNodeCount),
+				strconv.Itoa(t.Stats.EdgeCount),
+			})
+			add(indent+"  ", t.SubTopologies)
+		}
+	}
+	add("", topologies)
+	return c.print(topologies, []string{"TOPOLOGY", "NAME", "NODES", "EDGES"}, rows)
+}
+
+func (c queryClient) nodes(topology string, options []string) error {
+	query, err := parseQueryArgs(options)
+	if err != nil {
+		return err
+	}
+	if query.Get("pseudo") == "" {
+		query.Set("pseudo", "hide")
+	}
+	var topo app.APITopology
+	if err := c.do("GET", "/api/topology/"+url.PathEscape(topology), query, nil, &topo); err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, n := range topo.Nodes {
+		rows = append(rows, []string{n.ID, n.Label, n.LabelMinor})
+	}
+	sort.Sort(byColumns(rows))
+	return c.print(topo, []string{"ID", "LABEL", "DETAILS"}, rows)
+}
+
+func (c queryClient) getNode(topology, id string) (detailed.Node, error) {
+	var node app.APINode
+	err := c.do("GET", "/api/topology/"+url.PathEscape(topology)+"/"+url.PathEscape(id), nil, nil, &node)
+	return node.Node, err
+}
+
+func (c queryClient) node(topology, id string) error {
+	node, err := c.getNode(topology, id)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{
+		{"ID", node.ID},
+		{"Label", node.Label},
+	}
+	if node.LabelMinor != "" {
+		rows = append(rows, []string{"Details", node.LabelMinor})
+	}
+	for _, row := range node.Metadata {
+		rows = append(rows, []string{row.Label, row.Value})
+	}
+	for _, row := range node.Metrics {
+		if !row.ValueEmpty {
+			rows = append(rows, []string{row.Label, formatMetric(row)})
+		}
+	}
+	for _, parent := range node.Parents {
+		rows = append(rows, []string{"Parent " + parent.TopologyID, parent.Label + " (" + parent.ID + ")"})
+	}
+	for _, control := range node.Controls {
+		rows = append(rows, []string{"Control", control.Control.ID + " (" + control.Control.Human + ")"})
+	}
+	return c.print(node, nil, rows)
+}
+
+func formatMetric(row report.MetricRow) string {
+	switch row.Format {
+	case report.PercentFormat:
+		return fmt.Sprintf("%.2f%%", row.Value)
+	case report.IntegerFormat:
+		return fmt.Sprintf("%.0f", row.Value)
+	case report.FilesizeFormat:
+		units := []string{"B", "KB", "MB", "GB", "TB"}
+		v, i := row.Value, 0
+		for ; v >= 1024 && i < len(units)-1; i++ {
+			v /= 1024
+		}
+		return fmt.Sprintf("%.1f %s", v, units[i])
+	default:
+		return strconv.FormatFloat(row.Value, 'f', 2, 64)
+	}
+}
+
+func (c queryClient) connections(topology, from, to string) error {
+	node, err := c.getNode(topology, from)
+	if err != nil {
+		return err
+	}
+	summaries := []detailed.ConnectionsSummary{}
+	rows := [][]string{}
+	for _, summary := range node.Connections {
+		connections := []detailed.Connection{}
+		for _, connection := range summary.Connections {
+			if connection.NodeID != to {
+				continue
+			}
+			connections = append(connections, connection)
+			values := map[string]string{}
+			for _, row := range connection.Metadata {
+				values[row.ID] = row.Value
+			}
+			rows = append(rows, []string{summary.Label, connection.Label, values["port"], values["count"]})
+		}
+		summary.Connections = connections
+		summaries = append(summaries, summary)
+	}
+	return c.print(summaries, []string{"DIRECTION", "NODE", "PORT", "COUNT"}, rows)
+}
+
+func (c queryClient) control(topology, id, controlID string, args []string) error {
+	controlArgs, err := parseQueryArgs(args)
+	if err != nil {
+		return err
+	}
+	node, err := c.getNode(topology, id)
+	if err != nil {
+		return err
+	}
+	available := []string{}
+	var control *detailed.ControlInstance
+	for i := range node.Controls {
+		if node.Controls[i].Control.ID == controlID {
+			control = &node.Controls[i]
+		}
+		available = append(available, node.Controls[i].Control.ID)
+	}
+	if control == nil {
+		return fmt.Errorf("node %s has no control %s, only %s", id, controlID, strings.Join(available, ", "))
+	}
+
+	body := map[string]string{}
+	for k := range controlArgs {
+		body[k] = controlArgs.Get(k)
+	}
+	path := fmt.Sprintf("/api/control/%s/%s/%s", url.PathEscape(control.ProbeID), url.PathEscape(control.NodeID), url.PathEscape(controlID))
+	var res xfer.Response
+	if err := c.do("POST", path, nil, body, &res); err != nil {
+		return err
+	}
+	if res.Error != "" {
+		return fmt.Errorf("%s", res.Error)
+	}
+	if res.Value != nil {
+		if err := c.print(res, nil, [][]string{{fmt.Sprint(res.Value)}}); err != nil {
+			return err
+		}
+	}
+	if res.Pipe != "" {
+		return c.attach(res.Pipe)
+	}
+	return nil
+}
+
+// attach copies the output of a pipe until it's closed, or interrupted.
+func (c queryClient) attach(pipeID string) error {
+	dialer := &websocket.Dialer{}
+	if transport, ok := c.client.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+	headers := http.Header{}
+	c.authorize(headers)
+	path := "/api/pipe/" + url.PathEscape(pipeID)
+	conn, _, err := xfer.DialWS(dialer, strings.Replace(c.url(path, nil), "http", "ws", 1), headers)
+	if err != nil {
+		return err
+	}
+	defer c.do("DELETE", path, nil, nil, nil)
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+	interrupted := make(chan struct{})
+	go func() {
+		if _, ok := <-interrupts; ok {
+			close(interrupted)
+			conn.Close()
+		}
+	}()
+
+	defer conn.Close()
+	for {
+		_, buf, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-interrupted:
+				return nil
+			default:
+			}
+			if xfer.IsExpectedWSCloseError(err) {
+				return nil
+			}
+			return err
+		}
+		if _, err := c.out.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// parseQueryArgs parses key=value arguments.
+func parseQueryArgs(args []string) (url.Values, error) {
+	values := url.Values{}
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid argument %q, expected key=value", arg)
+		}
+		values.Set(kv[0], kv[1])
+	}
+	return values, nil
+}
+
+type byColumns [][]string
+
+func (s byColumns) Len() int      { return len(s) }
+func (s byColumns) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byColumns) Less(i, j int) bool {
+	for k := range s[i] {
+		if s[i][k] != s[j][k] {
+			return s[i][k] < s[j][k]
+		}
+	}
+	return false
+}
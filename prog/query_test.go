@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func queryServer(t *testing.T) *httptest.Server {
+	rpt := fixture.Report.Copy()
+	rpt.Host.Controls.AddControl(report.Control{ID: "host_exec", Human: "Exec shell"})
+	rpt.Host.Nodes[fixture.ServerHostNodeID] = rpt.Host.Nodes[fixture.ServerHostNodeID].
+		WithLatests(map[string]string{report.ControlProbeID: "probe"}).
+		WithLatestActiveControls("host_exec")
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt), nil)
+	router.PathPrefix("/api/control/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var args map[string]string
+		codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&args)
+		if r.RequestURI != "/api/control/probe/"+url.PathEscape(fixture.ServerHostNodeID)+"/host_exec" || args["shell"] != "sh" {
+			t.Errorf("Unexpected control %s %v", r.RequestURI, args)
+		}
+		codec.NewEncoder(w, &codec.JsonHandle{}).Encode(xfer.Response{Pipe: "pipe"})
+	})
+	router.Methods("GET").Path("/api/pipe/pipe").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := xfer.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.WriteMessage(websocket.BinaryMessage, []byte("hello\n"))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.Close()
+	})
+	router.Methods("DELETE").Path("/api/pipe/pipe").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return httptest.NewServer(router)
+}
+
+func TestQuery(t *testing.T) {
+	ts := queryServer(t)
+	defer ts.Close()
+
+	query := func(output string, args ...string) string {
+		out := &bytes.Buffer{}
+		if err := runQuery(queryFlags{app: ts.URL, output: output}, args, out); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	out := query("table", "topologies")
+	assert.Contains(t, out, "TOPOLOGY")
+	assert.Contains(t, out, "containers")
+
+	out = query("table", "nodes", "hosts")
+	assert.Contains(t, out, fixture.ServerHostNodeID)
+	assert.Contains(t, out, fixture.ClientHostNodeID)
+
+	out = query("json", "node", "hosts", fixture.ServerHostNodeID)
+	assert.Contains(t, out, `"id": "`+fixture.ServerHostNodeID+`"`)
+
+	out = query("table", "connections", "containers", fixture.ClientContainerNodeID, fixture.ServerContainerNodeID)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, 2, len(lines), out)
+	assert.Contains(t, lines[1], fixture.ServerPort)
+
+	out = query("table", "control", "hosts", fixture.ServerHostNodeID, "host_exec", "shell=sh")
+	assert.Equal(t, "hello\n", out)
+
+	for _, invalid := range [][]string{
+		{"frobnicate"},
+		{"node", "hosts"},
+		{"node", "hosts", "nonexistent"},
+		{"control", "hosts", fixture.ServerHostNodeID, "host_reboot"},
+		{"nodes", "hosts", "notkeyvalue"},
+	} {
+		if err := runQuery(queryFlags{app: ts.URL, output: "table"}, invalid, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
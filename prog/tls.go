@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// loadCertPool reads the PEM encoded CA certificates of a file.
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", filename)
+	}
+	return pool, nil
+}
+
+// certificateName returns the common name of the leaf of a certificate chain.
+func certificateName(cert tls.Certificate) (string, error) {
+	if len(cert.Certificate) == 0 {
+		return "", fmt.Errorf("empty certificate")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+	if leaf.Subject.CommonName == "" {
+		return "", fmt.Errorf("certificate has no common name")
+	}
+	return leaf.Subject.CommonName, nil
+}
+
+// appTLSConfig returns the TLS config the app serves HTTPS with, or nil to
+// serve HTTP.
+func appTLSConfig(flags appFlags) (*tls.Config, error) {
+	if flags.tlsCert == "" && flags.tlsKey == "" {
+		if flags.tlsClientCA != "" || flags.userIDClientCert {
+			return nil, fmt.Errorf("verifying client certificates requires a certificate and key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(flags.tlsCert, flags.tlsKey)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// Websockets need HTTP/1.1
+		NextProtos: []string{"http/1.1"},
+	}
+	if flags.tlsClientCA == "" && (flags.userIDClientCert || flags.tlsVerifyProbeID) {
+		return nil, fmt.Errorf("verifying client certificates requires a client CA")
+	}
+	if flags.tlsClientCA != "" {
+		if config.ClientCAs, err = loadCertPool(flags.tlsClientCA); err != nil {
+			return nil, err
+		}
+		// The UI doesn't need a certificate: ClientCertificateAuth only
+		// requires probes to present one.
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// apiClient returns a client of the API of Scope apps, e.g. those of
+// federated clusters.
+func apiClient(caFile string) (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{},
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return &http.Client{Transport: transport, Timeout: httpTimeout}, nil
+}
@@ -20,11 +20,12 @@ const (
 var UncontainedIDPrefix = MakePseudoNodeID(UncontainedID, "")
 
 // ContainerRenderer is a Renderer which produces a renderable container
-// graph by merging the process graph and the container topology.
+// graph by merging the process graph and the container topology, summing up
+// some metrics of the processes.
 // NB We only want processes in container _or_ processes with network connections
 // but we need to be careful to ensure we only include each edge once, by only
 // including the ProcessRenderer once.
-var ContainerRenderer = Memoise(MakeFilter(
+var ContainerRenderer = Memoise(SumChildMetrics(report.Process, aggregateProcessMetrics, MakeFilter(
 	func(n report.Node) bool {
 		// Drop deleted containers
 		state, ok := n.Latest.Lookup(docker.ContainerState)
@@ -37,7 +38,7 @@ var ContainerRenderer = Memoise(MakeFilter(
 		),
 		ConnectionJoin(MapContainer2IP, report.Container),
 	),
-))
+)))
 
 const originalNodeID = "original_node_id"
 
@@ -235,7 +236,7 @@ func MapContainer2IP(m report.Node) []string {
 	for _, portMapping := range ports {
 		if mapping := portMappingMatch.FindStringSubmatch(portMapping); mapping != nil {
 			ip, port := mapping[1], mapping[2]
-			id := report.MakeScopedEndpointNodeID("", ip, port)
+			id := report.MakeScopedEndpointNodeID(unscopedAddress(m, ip), ip, port)
 			result = append(result, id)
 		}
 	}
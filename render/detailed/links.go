@@ -2,7 +2,6 @@ package detailed
 
 import (
 	"bytes"
-	"fmt"
 	"net/url"
 	"strings"
 
@@ -21,87 +20,16 @@ const (
 	idTransmitBytes = "transmit_bytes"
 )
 
-var (
-	// Metadata for shown queries
-	shownQueries = []struct {
-		ID    string
-		Label string
-	}{
-		{
-			ID:    docker.CPUTotalUsage,
-			Label: "CPU",
-		},
-		{
-			ID:    docker.MemoryUsage,
-			Label: "Memory",
-		},
-		{
-			ID:    idReceiveBytes,
-			Label: "Rx/s",
-		},
-		{
-			ID:    idTransmitBytes,
-			Label: "Tx/s",
-		},
-	}
-
-	// Queries on pod names of the format `name-<id>-<hash>`
-	// See also:  https://kubernetes.io/docs/concepts/workloads/controllers/deployment/#pod-template-hash-label
-	podIDHashQueries = formatMetricQueries(`pod_name=~"^{{label}}-[^-]+-[^-]+$",namespace="{{namespace}}"`, []string{docker.MemoryUsage, docker.CPUTotalUsage})
-
-	// Prometheus queries for topologies
-	topologyQueries = map[string]map[string]string{
-		// Containers
-
-		report.Container:      formatMetricQueries(`name="{{containerName}}"`, []string{docker.MemoryUsage, docker.CPUTotalUsage}),
-		report.ContainerImage: formatMetricQueries(`image="{{label}}"`, []string{docker.MemoryUsage, docker.CPUTotalUsage}),
-
-		// Kubernetes topologies
-
-		report.Pod: formatMetricQueries(
-			`pod_name="{{label}}",namespace="{{namespace}}"`,
-			[]string{docker.MemoryUsage, docker.CPUTotalUsage},
-		),
-		report.DaemonSet:   formatMetricQueries(`pod_name=~"^{{label}}-[^-]+$",namespace="{{namespace}}"`, []string{docker.MemoryUsage, docker.CPUTotalUsage}),
-		report.Deployment:  podIDHashQueries,
-		report.StatefulSet: podIDHashQueries,
-		report.CronJob:     podIDHashQueries,
-		report.Service: {
-			docker.CPUTotalUsage: `sum(rate(container_cpu_usage_seconds_total{image!="",namespace="{{namespace}}",_weave_pod_name="{{label}}",job="cadvisor",container_name!="POD"}[5m]))`,
-			docker.MemoryUsage:   `sum(rate(container_memory_usage_bytes{image!="",namespace="{{namespace}}",_weave_pod_name="{{label}}",job="cadvisor",container_name!="POD"}[5m]))`,
-		},
-	}
-)
-
-func formatMetricQueries(filter string, ids []string) map[string]string {
-	queries := make(map[string]string)
-	for _, id := range ids {
-		// All  `container_*`metrics  are provided by cAdvisor in Kubelets
-		switch id {
-		case docker.MemoryUsage:
-			queries[id] = fmt.Sprintf("sum(container_memory_usage_bytes{%s})", filter)
-		case docker.CPUTotalUsage:
-			queries[id] = fmt.Sprintf(
-				"sum(rate(container_cpu_usage_seconds_total{%s}[1m]))/count(container_cpu_usage_seconds_total{%s})*100",
-				filter,
-				filter,
-			)
-		case idReceiveBytes:
-			queries[id] = fmt.Sprintf("sum(rate(container_network_receive_bytes_total{%s}[5m]))", filter)
-		case idTransmitBytes:
-			queries[id] = fmt.Sprintf("sum(rate(container_network_transmit_bytes_total{%s}[5m]))", filter)
-		}
-	}
-
-	return queries
-}
-
 // RenderMetricURLs sets respective URLs for metrics in a node summary. Missing metrics
 // where we have a query for will be appended as an empty metric (no values or samples).
-func RenderMetricURLs(summary NodeSummary, n report.Node, r report.Report, metricsGraphURL string) NodeSummary {
-	if metricsGraphURL == "" {
+func RenderMetricURLs(summary NodeSummary, n report.Node, rc RenderContext) NodeSummary {
+	if rc.MetricsGraphURL == "" {
 		return summary
 	}
+	queries := rc.MetricQueries
+	if queries == nil {
+		queries = DefaultMetricQueries
+	}
 
 	var maxprio float64
 	var ms []report.MetricRow
@@ -113,23 +41,23 @@ func RenderMetricURLs(summary NodeSummary, n report.Node, r report.Report, metri
 			maxprio = metric.Priority
 		}
 
-		query := metricQuery(summary, n, r, metric.ID)
+		query := metricQuery(summary, n, rc.Report, queries.query(n.Topology, metric.ID))
 		ms = append(ms, metric)
 
 		if query != "" {
-			ms[len(ms)-1].URL = metricURL(query, metricsGraphURL)
+			ms[len(ms)-1].URL = metricURL(query, rc.MetricsGraphURL)
 		}
 
 		found[metric.ID] = struct{}{}
 	}
 
 	// Append empty metrics for unattached queries
-	for _, metadata := range shownQueries {
+	for _, metadata := range queries.Metrics {
 		if _, ok := found[metadata.ID]; ok {
 			continue
 		}
 
-		query := metricQuery(summary, n, r, metadata.ID)
+		query := metricQuery(summary, n, rc.Report, queries.query(n.Topology, metadata.ID))
 		if query == "" {
 			continue
 		}
@@ -138,7 +66,7 @@ func RenderMetricURLs(summary NodeSummary, n report.Node, r report.Report, metri
 		ms = append(ms, report.MetricRow{
 			ID:         metadata.ID,
 			Label:      metadata.Label,
-			URL:        metricURL(query, metricsGraphURL),
+			URL:        metricURL(query, rc.MetricsGraphURL),
 			Metric:     &report.Metric{},
 			Priority:   maxprio,
 			ValueEmpty: true,
@@ -150,10 +78,9 @@ func RenderMetricURLs(summary NodeSummary, n report.Node, r report.Report, metri
 	return summary
 }
 
-// metricQuery returns the query for the given node and metric.
-func metricQuery(summary NodeSummary, n report.Node, r report.Report, metricID string) string {
-	queries := topologyQueries[n.Topology]
-	if len(queries) == 0 {
+// metricQuery fills in the query template of a metric for the given node.
+func metricQuery(summary NodeSummary, n report.Node, r report.Report, template string) string {
+	if template == "" {
 		return ""
 	}
 
@@ -164,7 +91,7 @@ func metricQuery(summary NodeSummary, n report.Node, r report.Report, metricID s
 		"{{namespace}}", namespace,
 		"{{containerName}}", name,
 	)
-	return replacer.Replace(queries[metricID])
+	return replacer.Replace(template)
 }
 
 func metricLabel(summary NodeSummary, n report.Node, r report.Report) string {
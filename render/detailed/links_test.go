@@ -31,6 +31,10 @@ var (
 	}
 )
 
+func renderContext(metricsGraphURL string) detailed.RenderContext {
+	return detailed.RenderContext{Report: report.MakeReport(), MetricsGraphURL: metricsGraphURL}
+}
+
 func nodeSummaryWithMetrics(label string, metrics []report.MetricRow) detailed.NodeSummary {
 	return detailed.NodeSummary{
 		BasicNodeSummary: detailed.BasicNodeSummary{
@@ -42,7 +46,7 @@ func nodeSummaryWithMetrics(label string, metrics []report.MetricRow) detailed.N
 
 func TestRenderMetricURLs_Disabled(t *testing.T) {
 	s := nodeSummaryWithMetrics("foo", sampleMetrics)
-	result := detailed.RenderMetricURLs(s, samplePodNode, report.MakeReport(), "")
+	result := detailed.RenderMetricURLs(s, samplePodNode, renderContext(""))
 
 	assert.Empty(t, result.Metrics[0].URL)
 	assert.Empty(t, result.Metrics[1].URL)
@@ -50,7 +54,7 @@ func TestRenderMetricURLs_Disabled(t *testing.T) {
 
 func TestRenderMetricURLs_UnknownTopology(t *testing.T) {
 	s := nodeSummaryWithMetrics("foo", sampleMetrics)
-	result := detailed.RenderMetricURLs(s, sampleUnknownNode, report.MakeReport(), sampleMetricsGraphURL)
+	result := detailed.RenderMetricURLs(s, sampleUnknownNode, renderContext(sampleMetricsGraphURL))
 
 	assert.Empty(t, result.Metrics[0].URL)
 	assert.Empty(t, result.Metrics[1].URL)
@@ -58,7 +62,7 @@ func TestRenderMetricURLs_UnknownTopology(t *testing.T) {
 
 func TestRenderMetricURLs_Pod(t *testing.T) {
 	s := nodeSummaryWithMetrics("foo", sampleMetrics)
-	result := detailed.RenderMetricURLs(s, samplePodNode, report.MakeReport(), sampleMetricsGraphURL)
+	result := detailed.RenderMetricURLs(s, samplePodNode, renderContext(sampleMetricsGraphURL))
 
 	checkURL(t, result.Metrics[0].URL, sampleMetricsGraphURL,
 		[]string{"container_memory_usage_bytes", `pod_name=\"foo\"`, `namespace=\"noospace\"`})
@@ -68,7 +72,7 @@ func TestRenderMetricURLs_Pod(t *testing.T) {
 
 func TestRenderMetricURLs_Container(t *testing.T) {
 	s := nodeSummaryWithMetrics("foo", sampleMetrics)
-	result := detailed.RenderMetricURLs(s, sampleContainerNode, report.MakeReport(), sampleMetricsGraphURL)
+	result := detailed.RenderMetricURLs(s, sampleContainerNode, renderContext(sampleMetricsGraphURL))
 
 	checkURL(t, result.Metrics[0].URL, sampleMetricsGraphURL,
 		[]string{"container_memory_usage_bytes", `name=\"cooname\"`})
@@ -77,7 +81,7 @@ func TestRenderMetricURLs_Container(t *testing.T) {
 }
 
 func TestRenderMetricURLs_EmptyMetrics(t *testing.T) {
-	result := detailed.RenderMetricURLs(detailed.NodeSummary{}, samplePodNode, report.MakeReport(), sampleMetricsGraphURL)
+	result := detailed.RenderMetricURLs(detailed.NodeSummary{}, samplePodNode, renderContext(sampleMetricsGraphURL))
 
 	m := result.Metrics[0]
 	assert.Equal(t, docker.CPUTotalUsage, m.ID)
@@ -94,7 +98,7 @@ func TestRenderMetricURLs_EmptyMetrics(t *testing.T) {
 
 func TestRenderMetricURLs_CombinedEmptyMetrics(t *testing.T) {
 	s := nodeSummaryWithMetrics("foo", []report.MetricRow{{ID: docker.MemoryUsage, Priority: 1}})
-	result := detailed.RenderMetricURLs(s, samplePodNode, report.MakeReport(), sampleMetricsGraphURL)
+	result := detailed.RenderMetricURLs(s, samplePodNode, renderContext(sampleMetricsGraphURL))
 
 	assert.NotEmpty(t, result.Metrics[0].URL)
 	assert.False(t, result.Metrics[0].ValueEmpty)
@@ -106,7 +110,7 @@ func TestRenderMetricURLs_CombinedEmptyMetrics(t *testing.T) {
 
 func TestRenderMetricURLs_QueryReplacement(t *testing.T) {
 	s := nodeSummaryWithMetrics("foo", sampleMetrics)
-	result := detailed.RenderMetricURLs(s, samplePodNode, report.MakeReport(), "http://example.test/?q=:query")
+	result := detailed.RenderMetricURLs(s, samplePodNode, renderContext("http://example.test/?q=:query"))
 
 	checkURL(t, result.Metrics[0].URL, "http://example.test/?q=",
 		[]string{"container_memory_usage_bytes", `pod_name="foo"`, `namespace="noospace"`})
@@ -114,6 +118,70 @@ func TestRenderMetricURLs_QueryReplacement(t *testing.T) {
 		[]string{"container_cpu_usage_seconds", `pod_name="foo"`, `namespace="noospace"`})
 }
 
+func TestRenderMetricURLs_CustomQueries(t *testing.T) {
+	queries, err := detailed.ParseMetricQueries([]byte(`
+metrics:
+- id: docker_memory_usage
+  label: Memory
+  query: 'sum(memory_bytes{{{selector}}})'
+- id: docker_cpu_total_usage
+  label: CPU
+- id: open_files
+  label: Open files
+  query: 'sum(open_files{{{selector}}})'
+topologies:
+  pod:
+    selector: 'pod="{{label}}",ns="{{namespace}}"'
+    metrics: [docker_memory_usage, open_files]
+    queries:
+      docker_cpu_total_usage: 'sum(cpu{pod="{{label}}"})'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := renderContext(sampleMetricsGraphURL)
+	rc.MetricQueries = queries
+
+	s := nodeSummaryWithMetrics("foo", sampleMetrics)
+	result := detailed.RenderMetricURLs(s, samplePodNode, rc)
+	checkURL(t, result.Metrics[0].URL, sampleMetricsGraphURL,
+		[]string{`sum(memory_bytes{pod=\"foo\",ns=\"noospace\"})`})
+	checkURL(t, result.Metrics[1].URL, sampleMetricsGraphURL,
+		[]string{`sum(cpu{pod=\"foo\"})`})
+	assert.Equal(t, "open_files", result.Metrics[2].ID)
+	assert.Equal(t, "Open files", result.Metrics[2].Label)
+	checkURL(t, result.Metrics[2].URL, sampleMetricsGraphURL,
+		[]string{`sum(open_files{pod=\"foo\",ns=\"noospace\"})`})
+
+	result = detailed.RenderMetricURLs(s, sampleContainerNode, rc)
+	assert.Empty(t, result.Metrics[0].URL)
+	assert.Empty(t, result.Metrics[1].URL)
+}
+
+func TestParseMetricQueries_Invalid(t *testing.T) {
+	for _, invalid := range []string{
+		// Unknown placeholder
+		"metrics: [{id: m, label: M, query: 'm{{{selector}}}'}]\ntopologies: {pod: {selector: 'pod=\"{{name}}\"', metrics: [m]}}",
+		"metrics: [{id: m, label: M, query: 'm{pod=\"{{pod}}\"}'}]",
+		// Unknown topology
+		"metrics: [{id: m, label: M, query: 'm{{{selector}}}'}]\ntopologies: {nodes: {selector: 'a=\"{{label}}\"', metrics: [m]}}",
+		// Unknown metric
+		"metrics: [{id: m, label: M, query: 'm{{{selector}}}'}]\ntopologies: {pod: {selector: 'a=\"{{label}}\"', metrics: [other]}}",
+		"topologies: {pod: {queries: {m: 'm'}}}",
+		// Missing queries and selectors
+		"metrics: [{id: m, label: M}]\ntopologies: {pod: {selector: 'a=\"{{label}}\"', metrics: [m]}}",
+		"metrics: [{id: m, label: M, query: 'm{{{selector}}}'}]\ntopologies: {pod: {metrics: [m]}}",
+		"metrics: [{id: m, label: M}]\ntopologies: {pod: {queries: {m: ''}}}",
+		// Invalid metrics
+		"metrics: [{id: m}]",
+		"metrics: [{id: m, label: M}, {id: m, label: N}]",
+	} {
+		if _, err := detailed.ParseMetricQueries([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
 func checkURL(t *testing.T, u string, prefix string, contains []string) {
 	assert.True(t, strings.HasPrefix(u, prefix))
 	for _, contain := range contains {
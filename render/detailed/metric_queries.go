@@ -0,0 +1,219 @@
+package detailed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+const selectorPlaceholder = "selector"
+
+var (
+	placeholderRegexp = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+	// Placeholders replaced with properties of nodes
+	nodePlaceholders = map[string]struct{}{
+		"label":         {},
+		"namespace":     {},
+		"containerName": {},
+	}
+
+	// DefaultMetricQueries are the queries of cAdvisor metrics of containers
+	// and Kubernetes topologies, as scraped by Weave Cloud.
+	DefaultMetricQueries = mustParseMetricQueries(defaultMetricQueries)
+)
+
+// All `container_*` metrics are provided by cAdvisor in Kubelets
+const defaultMetricQueries = `
+metrics:
+- id: ` + docker.CPUTotalUsage + `
+  label: CPU
+  query: 'sum(rate(container_cpu_usage_seconds_total{{{selector}}}[1m]))/count(container_cpu_usage_seconds_total{{{selector}}})*100'
+- id: ` + docker.MemoryUsage + `
+  label: Memory
+  query: 'sum(container_memory_usage_bytes{{{selector}}})'
+- id: ` + idReceiveBytes + `
+  label: Rx/s
+  query: 'sum(rate(container_network_receive_bytes_total{{{selector}}}[5m]))'
+- id: ` + idTransmitBytes + `
+  label: Tx/s
+  query: 'sum(rate(container_network_transmit_bytes_total{{{selector}}}[5m]))'
+
+topologies:
+  ` + report.Container + `:
+    selector: 'name="{{containerName}}"'
+    metrics: [` + docker.MemoryUsage + `, ` + docker.CPUTotalUsage + `]
+  ` + report.ContainerImage + `:
+    selector: 'image="{{label}}"'
+    metrics: [` + docker.MemoryUsage + `, ` + docker.CPUTotalUsage + `]
+  ` + report.Pod + `:
+    selector: 'pod_name="{{label}}",namespace="{{namespace}}"'
+    metrics: [` + docker.MemoryUsage + `, ` + docker.CPUTotalUsage + `]
+  ` + report.DaemonSet + `:
+    selector: 'pod_name=~"^{{label}}-[^-]+$",namespace="{{namespace}}"'
+    metrics: [` + docker.MemoryUsage + `, ` + docker.CPUTotalUsage + `]
+
+  # Pod names of the format name-<id>-<hash>, see also
+  # https://kubernetes.io/docs/concepts/workloads/controllers/deployment/#pod-template-hash-label
+  ` + report.Deployment + `: &podIDHash
+    selector: 'pod_name=~"^{{label}}-[^-]+-[^-]+$",namespace="{{namespace}}"'
+    metrics: [` + docker.MemoryUsage + `, ` + docker.CPUTotalUsage + `]
+  ` + report.StatefulSet + `: *podIDHash
+  ` + report.CronJob + `: *podIDHash
+
+  ` + report.Service + `:
+    queries:
+      ` + docker.CPUTotalUsage + `: 'sum(rate(container_cpu_usage_seconds_total{image!="",namespace="{{namespace}}",_weave_pod_name="{{label}}",job="cadvisor",container_name!="POD"}[5m]))'
+      ` + docker.MemoryUsage + `: 'sum(rate(container_memory_usage_bytes{image!="",namespace="{{namespace}}",_weave_pod_name="{{label}}",job="cadvisor",container_name!="POD"}[5m]))'
+`
+
+// MetricQueries configures the metrics graph queries linked from the metrics
+// of nodes. Queries are templates of the {{label}}, {{namespace}} and
+// {{containerName}} of nodes.
+type MetricQueries struct {
+	// Metrics are the metrics linked to graphs, in the order they're shown
+	// when nodes don't have them. Their queries may be templates of the
+	// {{selector}} of topologies.
+	Metrics []MetricQuery `json:"metrics"`
+	// Topologies are the queries of the metrics of the nodes of each
+	// topology.
+	Topologies map[string]TopologyMetricQueries `json:"topologies"`
+
+	queries map[string]map[string]string // Queries by topology and metric ID
+}
+
+// MetricQuery is a metric linked to graphs.
+type MetricQuery struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Query string `json:"query,omitempty"`
+}
+
+// TopologyMetricQueries are the metric queries of the nodes of a topology.
+type TopologyMetricQueries struct {
+	// Selector maps nodes to the labels of their series, e.g.
+	// pod="{{label}}",namespace="{{namespace}}"
+	Selector string `json:"selector,omitempty"`
+	// Metrics are the IDs of the metrics queried with the selector.
+	Metrics []string `json:"metrics,omitempty"`
+	// Queries are the queries of metrics by their ID, overriding those
+	// with the selector.
+	Queries map[string]string `json:"queries,omitempty"`
+}
+
+// LoadMetricQueries loads and validates metric queries from a YAML or JSON
+// file.
+func LoadMetricQueries(filename string) (*MetricQueries, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	q, err := ParseMetricQueries(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return q, nil
+}
+
+// ParseMetricQueries parses and validates YAML or JSON metric queries.
+func ParseMetricQueries(buf []byte) (*MetricQueries, error) {
+	var q MetricQueries
+	if err := yaml.Unmarshal(buf, &q); err != nil {
+		return nil, err
+	}
+	if err := q.compile(); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func mustParseMetricQueries(s string) *MetricQueries {
+	q, err := ParseMetricQueries([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// compile validates the metric queries, and expands the selectors of
+// topologies.
+func (q *MetricQueries) compile() error {
+	templates := map[string]string{}
+	for _, metric := range q.Metrics {
+		if metric.ID == "" || metric.Label == "" {
+			return fmt.Errorf("metric %q: missing ID or label", metric.ID)
+		}
+		if _, ok := templates[metric.ID]; ok {
+			return fmt.Errorf("metric %s: defined twice", metric.ID)
+		}
+		if err := checkPlaceholders(metric.Query, true); err != nil {
+			return fmt.Errorf("metric %s: %v", metric.ID, err)
+		}
+		templates[metric.ID] = metric.Query
+	}
+
+	knownTopologies := report.MakeReport()
+	q.queries = map[string]map[string]string{}
+	for topology, tq := range q.Topologies {
+		if _, ok := knownTopologies.Topology(topology); !ok {
+			return fmt.Errorf("unknown topology %s", topology)
+		}
+		if err := checkPlaceholders(tq.Selector, false); err != nil {
+			return fmt.Errorf("topology %s: selector: %v", topology, err)
+		}
+		queries := map[string]string{}
+		for _, id := range tq.Metrics {
+			template, ok := templates[id]
+			if !ok {
+				return fmt.Errorf("topology %s: unknown metric %s", topology, id)
+			}
+			if template == "" {
+				return fmt.Errorf("topology %s: metric %s has no query", topology, id)
+			}
+			if tq.Selector == "" && strings.Contains(template, "{{"+selectorPlaceholder+"}}") {
+				return fmt.Errorf("topology %s: metric %s needs a selector", topology, id)
+			}
+			queries[id] = strings.Replace(template, "{{"+selectorPlaceholder+"}}", tq.Selector, -1)
+		}
+		for id, query := range tq.Queries {
+			if _, ok := templates[id]; !ok {
+				return fmt.Errorf("topology %s: unknown metric %s", topology, id)
+			}
+			if query == "" {
+				return fmt.Errorf("topology %s: empty query of metric %s", topology, id)
+			}
+			if err := checkPlaceholders(query, false); err != nil {
+				return fmt.Errorf("topology %s: metric %s: %v", topology, id, err)
+			}
+			queries[id] = query
+		}
+		q.queries[topology] = queries
+	}
+	return nil
+}
+
+// checkPlaceholders checks that a template only has placeholders of
+// properties of nodes, and of the selector if allowed.
+func checkPlaceholders(template string, selector bool) error {
+	for _, match := range placeholderRegexp.FindAllStringSubmatch(template, -1) {
+		if _, ok := nodePlaceholders[match[1]]; ok {
+			continue
+		}
+		if selector && match[1] == selectorPlaceholder {
+			continue
+		}
+		return fmt.Errorf("unknown placeholder %s", match[0])
+	}
+	return nil
+}
+
+// query returns the query template of a metric of the nodes of a topology.
+func (q *MetricQueries) query(topology, metricID string) string {
+	return q.queries[topology][metricID]
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/swarm"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -82,6 +83,7 @@ func (c *ControlInstance) CodecDecodeSelf(decoder *codec.Decoder) {
 type RenderContext struct {
 	report.Report
 	MetricsGraphURL string
+	MetricQueries   *MetricQueries // DefaultMetricQueries if nil
 }
 
 // MakeNode transforms a renderable node to a detailed node. It uses
@@ -157,6 +159,16 @@ var nodeSummaryGroupSpecs = []struct {
 			},
 		},
 	},
+	{
+		topologyID: report.SwarmTask,
+		NodeSummaryGroup: NodeSummaryGroup{
+			Label: "Tasks",
+			Columns: []Column{
+				{ID: swarm.TaskState, Label: "State"},
+				{ID: swarm.TaskDesiredState, Label: "Desired State"},
+			},
+		},
+	},
 	{
 		topologyID: report.Container,
 		NodeSummaryGroup: NodeSummaryGroup{
@@ -226,7 +238,7 @@ func children(rc RenderContext, n report.Node) []NodeSummaryGroup {
 		if !ok {
 			continue
 		}
-		apiTopology, ok := primaryAPITopology[topologyID]
+		apiTopology, ok := primaryAPITopologyID(topologyID)
 		if !ok {
 			continue
 		}
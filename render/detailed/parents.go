@@ -23,7 +23,10 @@ var parentTopologies = []string{
 	report.Service,
 	report.ECSTask,
 	report.ECSService,
+	report.SwarmTask,
 	report.SwarmService,
+	report.SwarmNode,
+	report.SwarmNetwork,
 	report.Host,
 }
 
@@ -34,12 +37,12 @@ func Parents(r report.Report, n report.Node) []Parent {
 		return nil
 	}
 	result := make([]Parent, 0, n.Parents.Size())
-	for _, topologyID := range parentTopologies {
+	for _, topologyID := range append(parentTopologies, r.PluginTopologyNames()...) {
 		topology, ok := r.Topology(topologyID)
 		if !ok {
 			continue
 		}
-		apiTopologyID, ok := primaryAPITopology[topologyID]
+		apiTopologyID, ok := primaryAPITopologyID(topologyID)
 		if !ok {
 			continue
 		}
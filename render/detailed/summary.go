@@ -7,8 +7,10 @@ import (
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/netdev"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/swarm"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 )
@@ -77,8 +79,12 @@ var renderers = map[string]func(BasicNodeSummary, report.Node) BasicNodeSummary{
 	report.ECSTask:        ecsTaskNodeSummary,
 	report.ECSService:     ecsServiceNodeSummary,
 	report.SwarmService:   swarmServiceNodeSummary,
+	report.SwarmTask:      swarmTaskNodeSummary,
+	report.SwarmNode:      swarmNodeNodeSummary,
+	report.SwarmNetwork:   swarmNetworkNodeSummary,
 	report.Host:           hostNodeSummary,
-	report.Overlay:        weaveNodeSummary,
+	report.Overlay:        overlayNodeSummary,
+	report.NetDevice:      netDeviceNodeSummary,
 	report.Endpoint:       nil, // Do not render
 }
 
@@ -96,7 +102,11 @@ var primaryAPITopology = map[string]string{
 	report.ECSTask:        "ecs-tasks",
 	report.ECSService:     "ecs-services",
 	report.SwarmService:   "swarm-services",
+	report.SwarmTask:      "swarm-tasks",
+	report.SwarmNode:      "swarm-nodes",
+	report.SwarmNetwork:   "swarm-networks",
 	report.Host:           "hosts",
+	report.NetDevice:      "net-devices",
 }
 
 // MakeBasicNodeSummary returns a basic summary of a node, if
@@ -126,9 +136,9 @@ func MakeBasicNodeSummary(r report.Report, n report.Node) (BasicNodeSummary, boo
 
 	// Is it any known topology?
 	if _, ok := r.Topology(n.Topology); ok {
-		// We should never get here, since all known topologies are in
-		// 'renderers'.
-		return summary, true
+		// Built-in topologies are all in 'renderers', so this must be a
+		// plugin topology.
+		return pluginNodeSummary(summary, n), true
 	}
 
 	// We have no idea how to render this.
@@ -152,9 +162,14 @@ func MakeNodeSummary(rc RenderContext, n report.Node) (NodeSummary, bool) {
 			summary.Metadata = topology.MetadataTemplates.MetadataRows(n)
 			summary.Metrics = topology.MetricTemplates.MetricRows(n)
 			summary.Tables = topology.TableTemplates.Tables(n)
+		} else if original, _, ok := render.ParseGroupNodeTopology(n.Topology); ok {
+			// Group nodes only have the metrics summed up from their children
+			if topology, ok := rc.Topology(original); ok {
+				summary.Metrics = topology.MetricTemplates.MetricRows(n)
+			}
 		}
 	}
-	return RenderMetricURLs(summary, n, rc.Report, rc.MetricsGraphURL), true
+	return RenderMetricURLs(summary, n, rc), true
 }
 
 // SummarizeMetrics returns a copy of the NodeSummary where the metrics are
@@ -168,6 +183,29 @@ func (n NodeSummary) SummarizeMetrics() NodeSummary {
 	return n
 }
 
+// pluginNodeSummary summarises nodes of plugin topologies, which are labelled
+// by their name, if they have one.
+func pluginNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	if name, ok := n.Latest.Lookup(report.Name); ok {
+		base.Label = name
+	}
+	base.Rank = base.Label
+	return base
+}
+
+// primaryAPITopologyID returns the ID of the API topology to link to for
+// nodes of the given report topology. Plugin topologies are shown in API
+// topologies of the same name.
+func primaryAPITopologyID(topologyID string) (string, bool) {
+	if id, ok := primaryAPITopology[topologyID]; ok {
+		return id, true
+	}
+	if report.IsPluginTopology(topologyID) {
+		return topologyID, true
+	}
+	return "", false
+}
+
 func pseudoNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	pseudoID, _ := render.ParsePseudoNodeID(n.ID)
 	base.Pseudo = true
@@ -344,6 +382,33 @@ func swarmServiceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSumm
 	return base
 }
 
+func swarmTaskNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(swarm.TaskName)
+	if base.Label == "" {
+		base.Label, _ = report.ParseSwarmTaskNodeID(n.ID)
+	}
+	base.LabelMinor, _ = n.Latest.Lookup(swarm.TaskState)
+	return base
+}
+
+func swarmNodeNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(swarm.NodeHostname)
+	if base.Label == "" {
+		base.Label, _ = report.ParseSwarmNodeNodeID(n.ID)
+	}
+	base.LabelMinor, _ = n.Latest.Lookup(swarm.NodeRole)
+	return base
+}
+
+func swarmNetworkNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(swarm.NetworkName)
+	if base.Label == "" {
+		base.Label, _ = report.ParseSwarmNetworkNodeID(n.ID)
+	}
+	base.LabelMinor, _ = n.Latest.Lookup(swarm.NetworkSubnets)
+	return base
+}
+
 func hostNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	var (
 		hostname, _ = report.ParseHostNodeID(n.ID)
@@ -357,13 +422,16 @@ func hostNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	return base
 }
 
-func weaveNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+func overlayNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	var (
 		nickname, _ = n.Latest.Lookup(overlay.WeavePeerNickName)
+		hostname, _ = n.Latest.Lookup(overlay.PeerHostname)
 		_, peerName = report.ParseOverlayNodeID(n.ID)
 	)
 	if nickname != "" {
 		base.Label = nickname
+	} else if hostname != "" {
+		base.Label = hostname
 	} else {
 		base.Label = peerName
 	}
@@ -371,6 +439,18 @@ func weaveNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	return base
 }
 
+func netDeviceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(netdev.Name)
+	if base.Label == "" {
+		_, _, base.Label, _ = report.ParseNetDeviceNodeID(n.ID)
+	}
+	base.LabelMinor, _ = n.Latest.Lookup(netdev.Owner)
+	if base.LabelMinor == "" {
+		base.LabelMinor, _ = n.Latest.Lookup(netdev.Kind)
+	}
+	return base
+}
+
 // groupNodeSummary renders the summary for a group node. n.Topology is
 // expected to be of the form: group:container:hostname
 func groupNodeSummary(base BasicNodeSummary, r report.Report, n report.Node) BasicNodeSummary {
@@ -220,8 +220,12 @@ func TestMakeNodeSummaryNoMetadata(t *testing.T) {
 		report.ECSTask:        report.MakeECSTaskNodeID("arn:aws:ecs:us-east-1:012345678910:task/1dc5c17a-422b-4dc4-b493-371970c6c4d6"),
 		report.ECSService:     report.MakeECSServiceNodeID("cluster", "service"),
 		report.SwarmService:   report.MakeSwarmServiceNodeID("0001accbecc2c95e650fe641926fb923b7cc307a71101a1200af3759227b6d7d"),
+		report.SwarmTask:      report.MakeSwarmTaskNodeID("x1ozcvb9ic3pvcqaanbx6xz7e"),
+		report.SwarmNode:      report.MakeSwarmNodeNodeID("24ifsmvkjbyhk5w6d5w2ta4uh"),
+		report.SwarmNetwork:   report.MakeSwarmNetworkNodeID("7s7rlk3eft7sgl3l3dd8wn6oi"),
 		report.Host:           report.MakeHostNodeID("ip-123-45-6-100"),
 		report.Overlay:        report.MakeOverlayNodeID("", "3e:ca:14:ca:12:5c"),
+		report.NetDevice:      report.MakeNetDeviceNodeID("ip-123-45-6-100", "4026532288", "eth0"),
 		processNameTopology:   "/home/weave/scope",
 	} {
 		summary, b := detailed.MakeNodeSummary(detailed.RenderContext{}, report.MakeNode(id).WithTopology(topology))
@@ -273,6 +273,23 @@ func IsNotPseudo(n report.Node) bool {
 	return n.Topology != Pseudo || IsInternetNode(n) || strings.HasPrefix(n.ID, ServiceNodeIDPrefix)
 }
 
+// IsCluster checks if the node, or any of the nodes it groups, was reported
+// by the specified cluster of a federating app
+func IsCluster(cluster string) FilterFunc {
+	return func(n report.Node) bool {
+		if value, ok := n.Latest.Lookup(report.Cluster); ok {
+			return value == cluster
+		}
+		found := false
+		n.Children.ForEach(func(child report.Node) {
+			if value, ok := child.Latest.Lookup(report.Cluster); ok && value == cluster {
+				found = true
+			}
+		})
+		return found
+	}
+}
+
 // IsNamespace checks if the node is a pod/service in the specified namespace
 func IsNamespace(namespace string) FilterFunc {
 	return func(n report.Node) bool {
@@ -100,3 +100,13 @@ func externalNodeID(rpt report.Report, n report.Node, addr string, local report.
 	// The node is not external
 	return "", false
 }
+
+// unscopedAddress returns the scope of an address of a node which isn't
+// scoped by a host, i.e. none, or that of the node's cluster in federating
+// apps.
+func unscopedAddress(n report.Node, address string) string {
+	if cluster, ok := n.Latest.Lookup(report.Cluster); ok {
+		return report.ClusterScope(cluster, "", address)
+	}
+	return ""
+}
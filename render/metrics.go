@@ -40,3 +40,50 @@ func (p propagateSingleMetrics) Render(rpt report.Report) Nodes {
 	}
 	return Nodes{Nodes: outputs, Filtered: nodes.Filtered}
 }
+
+// SumChildMetrics creates a renderer which sets the given metrics of nodes
+// to the sum of the latest values of those metrics of their children of the
+// specified topology, if any of them have them.
+func SumChildMetrics(topology string, metrics []string, r Renderer) Renderer {
+	return sumChildMetrics{topology: topology, metrics: metrics, r: r}
+}
+
+type sumChildMetrics struct {
+	topology string
+	metrics  []string
+	r        Renderer
+}
+
+func (s sumChildMetrics) Render(rpt report.Report) Nodes {
+	nodes := s.r.Render(rpt)
+	outputs := make(report.Nodes, len(nodes.Nodes))
+	for id, n := range nodes.Nodes {
+		sums := map[string]report.Sample{}
+		n.Children.ForEach(func(child report.Node) {
+			if child.Topology != s.topology {
+				return
+			}
+			for _, metricID := range s.metrics {
+				sample, ok := child.Metrics[metricID].LastSample()
+				if !ok {
+					continue
+				}
+				sum := sums[metricID]
+				sum.Value += sample.Value
+				if sample.Timestamp.After(sum.Timestamp) {
+					sum.Timestamp = sample.Timestamp
+				}
+				sums[metricID] = sum
+			}
+		})
+		if len(sums) > 0 {
+			metrics := report.Metrics{}
+			for metricID, sum := range sums {
+				metrics[metricID] = report.MakeSingletonMetric(sum.Timestamp, sum.Value)
+			}
+			n = n.WithMetrics(metrics)
+		}
+		outputs[id] = n
+	}
+	return Nodes{Nodes: outputs, Filtered: nodes.Filtered}
+}
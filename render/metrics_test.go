@@ -165,3 +165,41 @@ func TestPropagateSingleMetrics(t *testing.T) {
 		}
 	}
 }
+
+func TestSumChildMetrics(t *testing.T) {
+	t1 := time.Unix(1000, 0).UTC()
+	t2 := t1.Add(time.Second)
+	input := report.MakeNode("a").WithChildren(
+		report.MakeNodeSet(
+			report.MakeNode("child1").
+				WithTopology(report.Process).
+				WithMetrics(report.Metrics{
+					"metric1": report.MakeSingletonMetric(t1, 1),
+					"metric2": report.MakeSingletonMetric(t1, 10),
+				}),
+			report.MakeNode("child2").
+				WithTopology(report.Process).
+				WithMetrics(report.Metrics{
+					"metric1": report.MakeSingletonMetric(t2, 2),
+				}),
+			report.MakeNode("child3").
+				WithTopology("otherTopology").
+				WithMetrics(report.Metrics{
+					"metric1": report.MakeSingletonMetric(t2, 100),
+				}),
+		),
+	)
+	want := report.Metrics{
+		"metric1": report.MakeSingletonMetric(t2, 3),
+		"metric2": report.MakeSingletonMetric(t1, 10),
+	}
+	got := render.SumChildMetrics(report.Process, []string{"metric1", "metric2", "metric3"}, mockRenderer{report.Nodes{"a": input}}).Render(report.Report{}).Nodes
+	if !reflect.DeepEqual(want, got["a"].Metrics) {
+		t.Errorf("Diff: %s", test.Diff(want, got["a"].Metrics))
+	}
+
+	got = render.SumChildMetrics(report.Container, []string{"metric1"}, mockRenderer{report.Nodes{"a": input}}).Render(report.Report{}).Nodes
+	if len(got["a"].Metrics) != 0 {
+		t.Errorf("Expected no metrics without children of the topology, got %v", got["a"].Metrics)
+	}
+}
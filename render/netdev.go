@@ -0,0 +1,8 @@
+package render
+
+// NetDeviceRenderer is a Renderer for the network devices of hosts and
+// containers, connected to the bridges they are attached to and to the other
+// end of their veth pair.
+//
+// not memoised
+var NetDeviceRenderer = SelectNetDevice
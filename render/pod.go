@@ -119,7 +119,7 @@ func MapPod2IP(m report.Node) []string {
 	if !ok {
 		return nil
 	}
-	return []string{report.MakeScopedEndpointNodeID("", ip, "")}
+	return []string{report.MakeScopedEndpointNodeID(unscopedAddress(m, ip), ip, "")}
 }
 
 // Map2Parent is a Renderer which maps Nodes to some parent grouping.
@@ -59,10 +59,22 @@ func (r processWithContainerNameRenderer) Render(rpt report.Report) Nodes {
 var ProcessWithContainerNameRenderer = processWithContainerNameRenderer{ProcessRenderer}
 
 // ProcessNameRenderer is a Renderer which produces a renderable process
-// name graph by munging the progess graph.
+// name graph by munging the progess graph, summing up some metrics of the
+// processes.
 //
 // not memoised
-var ProcessNameRenderer = CustomRenderer{RenderFunc: processes2Names, Renderer: ProcessRenderer}
+var ProcessNameRenderer = SumChildMetrics(report.Process, aggregateProcessMetrics,
+	CustomRenderer{RenderFunc: processes2Names, Renderer: ProcessRenderer})
+
+// aggregateProcessMetrics are the metrics of processes summed up into the
+// containers and process names they belong to
+var aggregateProcessMetrics = func() []string {
+	ids := []string{}
+	for id := range process.AggregateMetricTemplates {
+		ids = append(ids, id)
+	}
+	return ids
+}()
 
 // endpoints2Processes joins the endpoint topology to the process
 // topology, matching on hostID and pid.
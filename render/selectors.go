@@ -31,5 +31,9 @@ var (
 	SelectECSTask        = TopologySelector(report.ECSTask)
 	SelectECSService     = TopologySelector(report.ECSService)
 	SelectSwarmService   = TopologySelector(report.SwarmService)
+	SelectSwarmTask      = TopologySelector(report.SwarmTask)
+	SelectSwarmNode      = TopologySelector(report.SwarmNode)
+	SelectSwarmNetwork   = TopologySelector(report.SwarmNetwork)
 	SelectOverlay        = TopologySelector(report.Overlay)
+	SelectNetDevice      = TopologySelector(report.NetDevice)
 )
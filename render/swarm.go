@@ -17,6 +17,39 @@ var SwarmServiceRenderer = ConditionalRenderer(renderSwarmTopologies,
 	),
 )
 
+// SwarmTaskRenderer is a Renderer for Docker Swarm tasks.
+var SwarmTaskRenderer = Memoise(ConditionalRenderer(renderSwarmTopologies,
+	renderParents(
+		report.Container, []string{report.SwarmTask}, UnmanagedID,
+		MakeFilter(
+			IsRunning,
+			ContainerWithImageNameRenderer,
+		),
+	),
+))
+
+// SwarmNodeRenderer is a Renderer for Docker Swarm nodes, with the tasks
+// scheduled on them.
+//
+// not memoised
+var SwarmNodeRenderer = ConditionalRenderer(renderSwarmTopologies,
+	renderParents(
+		report.SwarmTask, []string{report.SwarmNode}, "",
+		SwarmTaskRenderer,
+	),
+)
+
+// SwarmNetworkRenderer is a Renderer for Docker Swarm overlay networks,
+// with the tasks attached to them.
+//
+// not memoised
+var SwarmNetworkRenderer = ConditionalRenderer(renderSwarmTopologies,
+	renderParents(
+		report.SwarmTask, []string{report.SwarmNetwork}, "",
+		SwarmTaskRenderer,
+	),
+)
+
 func renderSwarmTopologies(rpt report.Report) bool {
-	return len(rpt.SwarmService.Nodes) >= 1
+	return len(rpt.SwarmService.Nodes)+len(rpt.SwarmTask.Nodes)+len(rpt.SwarmNode.Nodes) >= 1
 }
@@ -8,30 +8,58 @@ import (
 // WeaveRenderer is a Renderer which produces a renderable weave topology.
 //
 // not memoised
-var WeaveRenderer = MakeMap(
-	MapWeaveIdentity,
-	SelectOverlay,
-)
+var WeaveRenderer = MakeOverlayRenderer(report.WeaveOverlayPeerPrefix)
 
-// MapWeaveIdentity maps an overlay topology node to a weave topology node.
-func MapWeaveIdentity(m report.Node) report.Node {
-	peerPrefix, _ := report.ParseOverlayNodeID(m.ID)
-	if peerPrefix != report.WeaveOverlayPeerPrefix {
-		return report.Node{}
-	}
+// CalicoRenderer is a Renderer which produces a renderable calico topology.
+//
+// not memoised
+var CalicoRenderer = MakeOverlayRenderer(report.CalicoOverlayPeerPrefix)
 
-	var (
-		node        = m
-		nickname, _ = m.Latest.Lookup(overlay.WeavePeerNickName)
+// CiliumRenderer is a Renderer which produces a renderable cilium topology.
+//
+// not memoised
+var CiliumRenderer = MakeOverlayRenderer(report.CiliumOverlayPeerPrefix)
+
+// FlannelRenderer is a Renderer which produces a renderable flannel topology.
+//
+// not memoised
+var FlannelRenderer = MakeOverlayRenderer(report.FlannelOverlayPeerPrefix)
+
+// MakeOverlayRenderer makes a Renderer of the peers of the overlay network
+// whose node IDs have the given prefix.
+func MakeOverlayRenderer(peerPrefix string) Renderer {
+	return MakeMap(
+		MapOverlayIdentity(peerPrefix),
+		SelectOverlay,
 	)
+}
 
-	// Nodes without a host id indicate they are not monitored by Scope
-	// (their info doesn't come from a probe monitoring that peer directly)
-	// , display them as pseudo nodes.
-	if _, ok := node.Latest.Lookup(report.HostNodeID); !ok {
-		id := MakePseudoNodeID(UnmanagedID, nickname)
-		node = NewDerivedPseudoNode(id, m)
-	}
+// MapOverlayIdentity returns a MapFunc which maps overlay topology nodes
+// with the given prefix to themselves, dropping the others.
+func MapOverlayIdentity(peerPrefix string) MapFunc {
+	return func(m report.Node) report.Node {
+		prefix, peerName := report.ParseOverlayNodeID(m.ID)
+		if prefix != peerPrefix {
+			return report.Node{}
+		}
 
-	return node
+		node := m
+
+		// Nodes without a host id indicate they are not monitored by Scope
+		// (their info doesn't come from a probe monitoring that peer directly)
+		// , display them as pseudo nodes.
+		if _, ok := node.Latest.Lookup(report.HostNodeID); !ok {
+			label, ok := m.Latest.Lookup(overlay.WeavePeerNickName)
+			if !ok {
+				label, ok = m.Latest.Lookup(overlay.PeerHostname)
+			}
+			if !ok {
+				label = peerName
+			}
+			id := MakePseudoNodeID(UnmanagedID, label)
+			node = NewDerivedPseudoNode(id, m)
+		}
+
+		return node
+	}
 }
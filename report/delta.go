@@ -0,0 +1,351 @@
+package report
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Delta describes the changes between a base report and a later report from
+// the same probe, such that applying the delta to the base yields the later
+// report. Probes stamp the Latest values of all their nodes, and add new
+// samples to all their metrics, in every report; so nodes whose only changes
+// are to Latest values and metrics are carried as NodeDeltas, and only other
+// nodes which are new or differ from the base are carried in full. Nodes
+// which have gone away are listed by ID. Everything else (topology templates,
+// DNS, sampling, window etc.) is carried as-is from the later report.
+type Delta struct {
+	// Report is the later report, minus any nodes unchanged since the base or
+	// carried in Updated.
+	Report Report `json:"report"`
+
+	// Removed holds the IDs of nodes present in the base but not in the
+	// later report, keyed by topology name.
+	Removed map[string][]string `json:"removed,omitempty"`
+
+	// Updated holds the changes to the Latest values and metrics of nodes,
+	// keyed by topology name and node ID.
+	Updated map[string]map[string]NodeDelta `json:"updated,omitempty"`
+}
+
+// NodeDelta holds the changes to the Latest values and metrics of a node
+// which is otherwise unchanged since the base.
+type NodeDelta struct {
+	// Latest and LatestControls hold the entries which are new, or whose value
+	// changed, or whose timestamp is neither the one of the base nor Stamp.
+	Latest         StringLatestMap          `json:"latest,omitempty"`
+	LatestControls NodeControlDataLatestMap `json:"latestControls,omitempty"`
+
+	// Stamp, unless zero, is the new timestamp of all the other entries, and
+	// of the sample of the other metrics which have a single one.
+	Stamp time.Time `json:"stamp,omitempty"`
+
+	// Metrics hold the changes to the other metrics of the node, by ID.
+	Metrics map[string]MetricDelta `json:"metrics,omitempty"`
+}
+
+// MetricDelta holds the changes to a metric: the samples it keeps from the
+// end of the base metric, and its new samples. Its first and last timestamps
+// are those of its samples.
+type MetricDelta struct {
+	Kept     int      `json:"kept,omitempty"`
+	Samples  []Sample `json:"samples,omitempty"`
+	Min, Max float64
+}
+
+// MakeDelta computes the delta between base and rpt.
+func MakeDelta(base, rpt Report) Delta {
+	d := Delta{
+		Report:  rpt,
+		Removed: map[string][]string{},
+		Updated: map[string]map[string]NodeDelta{},
+	}
+	d.Report.WalkNamedTopologies(func(name string, t *Topology) {
+		baseTopology, _ := base.Topology(name)
+		baseNodes := baseTopology.Nodes
+		changed := Nodes{}
+		for id, n := range t.Nodes {
+			old, ok := baseNodes[id]
+			if !ok {
+				changed[id] = n
+				continue
+			}
+			nd, ok := makeNodeDelta(old, n)
+			if !ok {
+				changed[id] = n
+				continue
+			}
+			if !nd.empty() {
+				if d.Updated[name] == nil {
+					d.Updated[name] = map[string]NodeDelta{}
+				}
+				d.Updated[name][id] = nd
+			}
+		}
+		for id := range baseNodes {
+			if _, ok := t.Nodes[id]; !ok {
+				d.Removed[name] = append(d.Removed[name], id)
+			}
+		}
+		t.Nodes = changed
+	})
+	return d
+}
+
+// Apply applies the delta to base, returning the resulting report. The base
+// is not modified.
+func (d Delta) Apply(base Report) Report {
+	result := d.Report
+	result.WalkNamedTopologies(func(name string, t *Topology) {
+		baseTopology, _ := base.Topology(name)
+		nodes := baseTopology.Nodes.Copy()
+		for _, id := range d.Removed[name] {
+			delete(nodes, id)
+		}
+		for id, nd := range d.Updated[name] {
+			if n, ok := nodes[id]; ok {
+				nodes[id] = nd.apply(n)
+			}
+		}
+		for id, n := range t.Nodes {
+			nodes[id] = n
+		}
+		t.Nodes = nodes
+	})
+	return result
+}
+
+// makeNodeDelta computes the changes between a node of the base and the same
+// node of the later report. It returns false if the node changed otherwise
+// than by Latest values and new samples of metrics, or lost any of them.
+func makeNodeDelta(base, n Node) (NodeDelta, bool) {
+	nd := NodeDelta{}
+	if !sameButLatestAndMetrics(base, n) || len(n.Latest) < len(base.Latest) ||
+		len(n.LatestControls) < len(base.LatestControls) || len(n.Metrics) < len(base.Metrics) {
+		return nd, false
+	}
+
+	// Find which timestamp, if any, the most unchanged entries were restamped
+	// with: probes usually stamp all the entries of a node alike.
+	var (
+		unstamped  int
+		stamps     = map[int64]int{}
+		stampTimes []time.Time
+	)
+	countStamp := func(unchanged bool, baseTimestamp, timestamp time.Time) {
+		switch {
+		case !unchanged:
+		case timestamp.Equal(baseTimestamp):
+			unstamped++
+		default:
+			if _, ok := stamps[timestamp.UnixNano()]; !ok {
+				stampTimes = append(stampTimes, timestamp)
+			}
+			stamps[timestamp.UnixNano()]++
+		}
+	}
+	for _, e := range n.Latest {
+		v, ts, ok := base.Latest.LookupEntry(e.key)
+		countStamp(ok && v == e.Value, ts, e.Timestamp)
+	}
+	for _, e := range n.LatestControls {
+		v, ts, ok := base.LatestControls.LookupEntry(e.key)
+		countStamp(ok && v == e.Value, ts, e.Timestamp)
+	}
+	for _, ts := range stampTimes {
+		if count := stamps[ts.UnixNano()]; count > unstamped && (nd.Stamp.IsZero() || count > stamps[nd.Stamp.UnixNano()]) {
+			nd.Stamp = ts
+		}
+	}
+
+	// Keep the entries which applying the stamp would not reproduce
+	reproduced := func(baseTimestamp, timestamp time.Time) bool {
+		if nd.Stamp.IsZero() {
+			return timestamp.Equal(baseTimestamp)
+		}
+		return timestamp.Equal(nd.Stamp)
+	}
+	for _, e := range n.Latest {
+		v, ts, ok := base.Latest.LookupEntry(e.key)
+		if !ok || v != e.Value || !reproduced(ts, e.Timestamp) {
+			nd.Latest = append(nd.Latest, e)
+		}
+	}
+	for _, e := range n.LatestControls {
+		v, ts, ok := base.LatestControls.LookupEntry(e.key)
+		if !ok || v != e.Value || !reproduced(ts, e.Timestamp) {
+			nd.LatestControls = append(nd.LatestControls, e)
+		}
+	}
+	for _, e := range base.Latest {
+		if _, ok := n.Latest.Lookup(e.key); !ok {
+			return nd, false
+		}
+	}
+	for _, e := range base.LatestControls {
+		if _, ok := n.LatestControls.Lookup(e.key); !ok {
+			return nd, false
+		}
+	}
+
+	for id, m := range n.Metrics {
+		old, ok := base.Metrics[id]
+		if ok && reflect.DeepEqual(nd.restamped(old), m) {
+			continue
+		}
+		md, ok := makeMetricDelta(old, m)
+		if !ok {
+			return nd, false
+		}
+		if nd.Metrics == nil {
+			nd.Metrics = map[string]MetricDelta{}
+		}
+		nd.Metrics[id] = md
+	}
+	for id := range base.Metrics {
+		if _, ok := n.Metrics[id]; !ok {
+			return nd, false
+		}
+	}
+	return nd, true
+}
+
+// restamped returns a metric of the base as it is when not in the delta.
+func (nd NodeDelta) restamped(m Metric) Metric {
+	if nd.Stamp.IsZero() || len(m.Samples) != 1 {
+		return m
+	}
+	return Metric{
+		Samples: []Sample{{Timestamp: nd.Stamp, Value: m.Samples[0].Value}},
+		Min:     m.Min,
+		Max:     m.Max,
+		First:   nd.Stamp,
+		Last:    nd.Stamp,
+	}
+}
+
+// sameButLatestAndMetrics returns true if two nodes are the same, but for
+// their Latest values and metrics.
+func sameButLatestAndMetrics(a, b Node) bool {
+	a.Latest, a.LatestControls, a.Metrics = nil, nil, nil
+	b.Latest, b.LatestControls, b.Metrics = nil, nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// makeMetricDelta returns the changes to a metric. It returns false if the
+// samples of the metric don't start with some of the last ones of the base,
+// or its first and last timestamps are not those of its samples.
+func makeMetricDelta(base, m Metric) (MetricDelta, bool) {
+	if len(m.Samples) == 0 || !m.First.Equal(m.Samples[0].Timestamp) || !m.Last.Equal(m.Samples[len(m.Samples)-1].Timestamp) {
+		return MetricDelta{}, false
+	}
+	i := sort.Search(len(base.Samples), func(i int) bool {
+		return !base.Samples[i].Timestamp.Before(m.First)
+	})
+	kept := base.Samples[i:]
+	if len(m.Samples) < len(kept) {
+		return MetricDelta{}, false
+	}
+	for i, s := range kept {
+		if !s.Timestamp.Equal(m.Samples[i].Timestamp) || s.Value != m.Samples[i].Value {
+			return MetricDelta{}, false
+		}
+	}
+	return MetricDelta{Kept: len(kept), Samples: m.Samples[len(kept):], Min: m.Min, Max: m.Max}, true
+}
+
+// apply applies the changes to a metric of the base.
+func (md MetricDelta) apply(base Metric) Metric {
+	samples := md.Samples
+	if md.Kept > len(base.Samples) {
+		md.Kept = len(base.Samples)
+	}
+	if md.Kept > 0 {
+		kept := base.Samples[len(base.Samples)-md.Kept:]
+		samples = append(append(make([]Sample, 0, len(kept)+len(md.Samples)), kept...), md.Samples...)
+	}
+	if len(samples) == 0 {
+		return Metric{Min: md.Min, Max: md.Max}
+	}
+	return Metric{
+		Samples: samples,
+		Min:     md.Min,
+		Max:     md.Max,
+		First:   samples[0].Timestamp,
+		Last:    samples[len(samples)-1].Timestamp,
+	}
+}
+
+func (nd NodeDelta) empty() bool {
+	return len(nd.Latest) == 0 && len(nd.LatestControls) == 0 && nd.Stamp.IsZero() && len(nd.Metrics) == 0
+}
+
+// apply applies the changes to a node of the base.
+func (nd NodeDelta) apply(n Node) Node {
+	latest := make(StringLatestMap, 0, len(n.Latest)+len(nd.Latest))
+	for _, e := range n.Latest {
+		if !nd.Stamp.IsZero() {
+			e.Timestamp = nd.Stamp
+		}
+		latest = append(latest, e)
+	}
+	for _, e := range nd.Latest {
+		latest = latest.Set(e.key, e.Timestamp, e.Value)
+	}
+	n.Latest = latest
+
+	controls := make(NodeControlDataLatestMap, 0, len(n.LatestControls)+len(nd.LatestControls))
+	for _, e := range n.LatestControls {
+		if !nd.Stamp.IsZero() {
+			e.Timestamp = nd.Stamp
+		}
+		controls = append(controls, e)
+	}
+	for _, e := range nd.LatestControls {
+		controls = controls.Set(e.key, e.Timestamp, e.Value)
+	}
+	n.LatestControls = controls
+
+	if len(nd.Metrics) > 0 || (len(n.Metrics) > 0 && !nd.Stamp.IsZero()) {
+		metrics := make(Metrics, len(n.Metrics)+len(nd.Metrics))
+		for id, m := range n.Metrics {
+			metrics[id] = nd.restamped(m)
+		}
+		for id, md := range nd.Metrics {
+			metrics[id] = md.apply(n.Metrics[id])
+		}
+		n.Metrics = metrics
+	}
+	return n
+}
+
+// WriteBinary writes a Delta as a gzipped msgpack.
+func (d Delta) WriteBinary(w io.Writer, compressionLevel int) error {
+	gzwriter, err := gzip.NewWriterLevel(w, compressionLevel)
+	if err != nil {
+		return err
+	}
+	if err = codec.NewEncoder(gzwriter, &codec.MsgpackHandle{}).Encode(&d); err != nil {
+		return err
+	}
+	gzwriter.Close() // otherwise the content won't get flushed to the output stream
+	return nil
+}
+
+// ReadBinary reads a gzipped msgpack into a Delta.
+func (d *Delta) ReadBinary(r io.Reader) error {
+	gzreader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	buf, err := ioutil.ReadAll(gzreader)
+	if err != nil {
+		return err
+	}
+	return codec.NewDecoderBytes(buf, &codec.MsgpackHandle{}).Decode(d)
+}
@@ -0,0 +1,173 @@
+package report_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+	s_reflect "github.com/weaveworks/scope/test/reflect"
+)
+
+func TestDelta(t *testing.T) {
+	base := report.MakeReport()
+	base.Container.AddNode(report.MakeNodeWith("a", map[string]string{"name": "a"}))
+	base.Container.AddNode(report.MakeNodeWith("b", map[string]string{"name": "b"}))
+	base.Container.AddNode(report.MakeNodeWith("c", map[string]string{"name": "c"}))
+
+	rpt := report.MakeReport()
+	rpt.Container.AddNode(base.Container.Nodes["a"])
+	rpt.Container.AddNode(report.MakeNodeWith("b", map[string]string{"name": "b2"}))
+	rpt.Container.AddNode(report.MakeNodeWith("d", map[string]string{"name": "d"}))
+
+	delta := report.MakeDelta(base, rpt)
+	if want, have := 1, len(delta.Report.Container.Nodes); want != have {
+		t.Errorf("want %d new nodes, have %d", want, have)
+	}
+	if _, ok := delta.Report.Container.Nodes["a"]; ok {
+		t.Errorf("unchanged node should not be in the delta")
+	}
+	if want, have := 1, len(delta.Updated[report.Container]); want != have {
+		t.Errorf("want %d updated nodes, have %d", want, have)
+	}
+	if want, have := []string{"c"}, delta.Removed[report.Container]; !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	// Round-trip the delta, to check it survives the wire
+	buf := &bytes.Buffer{}
+	if err := delta.WriteBinary(buf, gzip.DefaultCompression); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report.Delta
+	if err := decoded.ReadBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := rpt, decoded.Apply(base); !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if len(base.Container.Nodes) != 3 {
+		t.Errorf("Apply should not modify the base")
+	}
+}
+
+type processWalker []process.Process
+
+func (w processWalker) Walk(f func(process.Process, process.Process)) error {
+	for _, p := range w {
+		f(p, process.Process{})
+	}
+	return nil
+}
+
+// Consecutive reports of a probe stamp all the Latest values of their nodes
+// anew, and add new samples to their metrics, yet most of them are unchanged.
+func TestDeltaOfConsecutiveReports(t *testing.T) {
+	walker := processWalker{}
+	for pid := 1; pid <= 100; pid++ {
+		walker = append(walker, process.Process{
+			PID: pid, PPID: pid / 10, Name: fmt.Sprintf("worker-%d", pid),
+			Cmdline: fmt.Sprintf("/usr/bin/worker --id=%d --queue=q%d", pid, pid*7),
+			Threads: pid % 8, RSSBytes: uint64(pid) << 20, OpenFilesCount: pid * 3, OpenFilesLimit: 1024,
+		})
+	}
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := process.NewReporter(process.ReporterConfig{
+		Scope:           "host",
+		ProbeID:         "probe",
+		Walker:          walker,
+		Jiffies:         func() (uint64, float64, error) { return 0, 0., nil },
+		HandlerRegistry: registry,
+	})
+	defer reporter.Stop()
+
+	now := time.Unix(1000, 0).UTC()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+	base, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime.NowForce(now.Add(3 * time.Second))
+	walker[0].Threads = 5
+	walker[1].RSSBytes = 2 << 20
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delta := report.MakeDelta(base, rpt)
+	if have := len(delta.Report.Process.Nodes); have != 0 {
+		t.Errorf("want no nodes carried in full, have %d", have)
+	}
+	deltaBuf, rptBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := delta.WriteBinary(deltaBuf, gzip.DefaultCompression); err != nil {
+		t.Fatal(err)
+	}
+	if err := rpt.WriteBinary(rptBuf, gzip.DefaultCompression); err != nil {
+		t.Fatal(err)
+	}
+	if deltaBuf.Len()*2 > rptBuf.Len() {
+		t.Errorf("want the delta (%d bytes) to be less than half the report (%d bytes)", deltaBuf.Len(), rptBuf.Len())
+	}
+
+	var decoded report.Delta
+	if err := decoded.ReadBinary(deltaBuf); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := rpt, decoded.Apply(base); !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestDeltaOfLatestAndMetrics(t *testing.T) {
+	t1, t2, t3 := time.Unix(1, 0).UTC(), time.Unix(2, 0).UTC(), time.Unix(3, 0).UTC()
+	base := report.MakeReport()
+	base.Host.AddNode(report.MakeNode("a").
+		WithLatest("restamped", t1, "1").
+		WithLatest("restamped too", t1, "1").
+		WithLatest("kept", t1, "2").
+		WithLatest("changed", t1, "3").
+		WithMetric("history", report.MakeMetric([]report.Sample{{Timestamp: t1, Value: 1}, {Timestamp: t2, Value: 2}})).
+		WithMetric("singleton", report.MakeSingletonMetric(t2, 4)))
+
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode("a").
+		WithLatest("restamped", t3, "1").
+		WithLatest("restamped too", t3, "1").
+		WithLatest("kept", t1, "2").
+		WithLatest("changed", t3, "4").
+		WithLatest("added", t3, "5").
+		WithMetric("history", report.MakeMetric([]report.Sample{{Timestamp: t2, Value: 2}, {Timestamp: t3, Value: 3}})).
+		WithMetric("singleton", report.MakeSingletonMetric(t3, 4)))
+
+	delta := report.MakeDelta(base, rpt)
+	nd, ok := delta.Updated[report.Host]["a"]
+	if !ok || len(delta.Report.Host.Nodes) != 0 {
+		t.Fatalf("want node a to be updated, have %v", delta)
+	}
+	if !nd.Stamp.Equal(t3) {
+		t.Errorf("want stamp %v, have %v", t3, nd.Stamp)
+	}
+	keys := []string{}
+	nd.Latest.ForEach(func(k string, _ time.Time, _ string) { keys = append(keys, k) })
+	if want, have := []string{"added", "changed", "kept"}, keys; !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if want, have := (report.MetricDelta{Kept: 1, Samples: []report.Sample{{Timestamp: t3, Value: 3}}, Min: 2, Max: 3}), nd.Metrics["history"]; !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if _, ok := nd.Metrics["singleton"]; ok {
+		t.Errorf("want the restamped singleton metric not to be in the delta")
+	}
+	if want, have := rpt, delta.Apply(base); !s_reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
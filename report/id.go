@@ -25,6 +25,15 @@ const (
 
 	// DockerOverlayPeerPrefix is the prefix for docker peers in the overlay network
 	DockerOverlayPeerPrefix = "docker_peer_"
+
+	// CalicoOverlayPeerPrefix is the prefix for calico peers in the overlay network
+	CalicoOverlayPeerPrefix = "calico_peer_"
+
+	// CiliumOverlayPeerPrefix is the prefix for cilium peers in the overlay network
+	CiliumOverlayPeerPrefix = "cilium_peer_"
+
+	// FlannelOverlayPeerPrefix is the prefix for flannel peers in the overlay network
+	FlannelOverlayPeerPrefix = "flannel_peer_"
 )
 
 // MakeEndpointNodeID produces an endpoint node ID from its composite parts.
@@ -69,11 +78,28 @@ func MakeScopedAddressNodeID(scope, address string) string {
 	return scope + ScopeDelim + address
 }
 
+// ClusterScope returns the scope of an address of a cluster shown by a
+// federating app. Clusters may use the same private ranges, so addresses are
+// scoped by their cluster, except for unscoped public ones, which are unique
+// and can join the connections between clusters.
+func ClusterScope(cluster, scope, address string) string {
+	if ip := net.ParseIP(address); scope == "" && ip != nil && ip.IsGlobalUnicast() && !ip.IsPrivate() {
+		return scope
+	}
+	return cluster + "/" + scope
+}
+
 // MakeProcessNodeID produces a process node ID from its composite parts.
 func MakeProcessNodeID(hostID, pid string) string {
 	return hostID + ScopeDelim + pid
 }
 
+// MakeNetDeviceNodeID produces a network device node ID from its composite
+// parts: the network namespace is the inode number of the namespace.
+func MakeNetDeviceNodeID(hostID, netNamespace, name string) string {
+	return hostID + ScopeDelim + netNamespace + ScopeDelim + name
+}
+
 // MakeECSServiceNodeID produces an ECS Service node ID from its composite parts.
 func MakeECSServiceNodeID(cluster, serviceName string) string {
 	return cluster + ScopeDelim + serviceName
@@ -157,6 +183,24 @@ var (
 
 	// ParseSwarmServiceNodeID parses a Swarm service node ID
 	ParseSwarmServiceNodeID = parseSingleComponentID("swarm_service")
+
+	// MakeSwarmTaskNodeID produces a Swarm task node ID from its composite parts.
+	MakeSwarmTaskNodeID = makeSingleComponentID("swarm_task")
+
+	// ParseSwarmTaskNodeID parses a Swarm task node ID
+	ParseSwarmTaskNodeID = parseSingleComponentID("swarm_task")
+
+	// MakeSwarmNodeNodeID produces a Swarm node node ID from its composite parts.
+	MakeSwarmNodeNodeID = makeSingleComponentID("swarm_node")
+
+	// ParseSwarmNodeNodeID parses a Swarm node node ID
+	ParseSwarmNodeNodeID = parseSingleComponentID("swarm_node")
+
+	// MakeSwarmNetworkNodeID produces a Swarm network node ID from its composite parts.
+	MakeSwarmNetworkNodeID = makeSingleComponentID("swarm_network")
+
+	// ParseSwarmNetworkNodeID parses a Swarm network node ID
+	ParseSwarmNetworkNodeID = parseSingleComponentID("swarm_network")
 )
 
 // makeSingleComponentID makes a single-component node id encoder
@@ -193,8 +237,15 @@ func ParseOverlayNodeID(id string) (overlayPrefix string, peerName string) {
 
 	id = id[1:]
 
-	if strings.HasPrefix(id, DockerOverlayPeerPrefix) {
-		return DockerOverlayPeerPrefix, id[len(DockerOverlayPeerPrefix):]
+	for _, prefix := range []string{
+		DockerOverlayPeerPrefix,
+		CalicoOverlayPeerPrefix,
+		CiliumOverlayPeerPrefix,
+		FlannelOverlayPeerPrefix,
+	} {
+		if strings.HasPrefix(id, prefix) {
+			return prefix, id[len(prefix):]
+		}
 	}
 
 	return WeaveOverlayPeerPrefix, id
@@ -240,6 +291,16 @@ func ParseProcessNodeID(processNodeID string) (hostID, pid string, ok bool) {
 	return split2(processNodeID, ScopeDelim)
 }
 
+// ParseNetDeviceNodeID produces the host ID, network namespace and name of
+// the device from a network device node ID.
+func ParseNetDeviceNodeID(netDeviceNodeID string) (hostID, netNamespace, name string, ok bool) {
+	fields := strings.SplitN(netDeviceNodeID, ScopeDelim, 3)
+	if len(fields) != 3 {
+		return "", "", "", false
+	}
+	return fields[0], fields[1], fields[2], true
+}
+
 // ParseECSServiceNodeID produces the cluster, service name from an ECS Service node ID
 func ParseECSServiceNodeID(ecsServiceNodeID string) (cluster, serviceName string, ok bool) {
 	cluster, serviceName, ok = split2(ecsServiceNodeID, ScopeDelim)
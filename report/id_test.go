@@ -78,3 +78,19 @@ func TestECSServiceNodeIDCompat(t *testing.T) {
 		t.Errorf("Backwards-compatible id %q parsed name to %q, expected %q", testID, name, testName)
 	}
 }
+
+func TestClusterScope(t *testing.T) {
+	for _, c := range []struct{ scope, address, want string }{
+		{"", "8.8.8.8", ""},
+		{"", "2001:4860:4860::8888", ""},
+		{"", "10.0.0.1", "eu/"},
+		{"", "fd00::1", "eu/"},
+		{"", "127.0.0.1", "eu/"},
+		{"host1", "8.8.8.8", "eu/host1"},
+		{"host1", "172.17.0.2", "eu/host1"},
+	} {
+		if have := report.ClusterScope("eu", c.scope, c.address); have != c.want {
+			t.Errorf("%q %q: want %q, have %q", c.scope, c.address, c.want, have)
+		}
+	}
+}
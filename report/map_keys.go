@@ -72,13 +72,55 @@ const (
 	KubernetesType                 = "kubernetes_type"
 	KubernetesPorts                = "kubernetes_ports"
 	// probe/awsecs
-	ECSCluster             = "ecs_cluster"
-	ECSCreatedAt           = "ecs_created_at"
-	ECSTaskFamily          = "ecs_task_family"
-	ECSServiceDesiredCount = "ecs_service_desired_count"
-	ECSServiceRunningCount = "ecs_service_running_count"
-	ECSScaleUp             = "ecs_scale_up"
-	ECSScaleDown           = "ecs_scale_down"
+	ECSCluster                         = "ecs_cluster"
+	ECSCreatedAt                       = "ecs_created_at"
+	ECSTaskFamily                      = "ecs_task_family"
+	ECSServiceDesiredCount             = "ecs_service_desired_count"
+	ECSServiceRunningCount             = "ecs_service_running_count"
+	ECSScaleUp                         = "ecs_scale_up"
+	ECSScaleDown                       = "ecs_scale_down"
+	ECSTaskDefinition                  = "ecs_task_definition"
+	ECSTaskCPU                         = "ecs_task_cpu"
+	ECSTaskMemory                      = "ecs_task_memory"
+	ECSLaunchType                      = "ecs_launch_type"
+	ECSContainerInstanceEC2ID          = "ecs_container_instance_ec2_id"
+	ECSContainerInstanceStatus         = "ecs_container_instance_status"
+	ECSContainerInstanceAgentConnected = "ecs_container_instance_agent_connected"
+	ECSContainerInstanceRunningTasks   = "ecs_container_instance_running_tasks"
+	ECSContainerInstancePendingTasks   = "ecs_container_instance_pending_tasks"
+	ECSServicePendingCount             = "ecs_service_pending_count"
+	// probe/swarm
+	SwarmCluster             = "swarm_cluster"
+	SwarmCreatedAt           = "swarm_created_at"
+	SwarmNodeHostname        = "swarm_node_hostname"
+	SwarmNodeRole            = "swarm_node_role"
+	SwarmNodeAvailability    = "swarm_node_availability"
+	SwarmNodeState           = "swarm_node_state"
+	SwarmNodeManagerStatus   = "swarm_node_manager_status"
+	SwarmServiceMode         = "swarm_service_mode"
+	SwarmServiceImage        = "swarm_service_image"
+	SwarmServiceDesiredTasks = "swarm_service_desired_tasks"
+	SwarmServiceRunningTasks = "swarm_service_running_tasks"
+	SwarmTaskName            = "swarm_task_name"
+	SwarmTaskState           = "swarm_task_state"
+	SwarmTaskDesiredState    = "swarm_task_desired_state"
+	SwarmTaskMessage         = "swarm_task_message"
+	SwarmNetworkName         = "swarm_network_name"
+	SwarmNetworkDriver       = "swarm_network_driver"
+	SwarmNetworkSubnets      = "swarm_network_subnets"
+	SwarmScaleUp             = "swarm_scale_up"
+	SwarmScaleDown           = "swarm_scale_down"
+	// probe/netdev
+	NetDeviceName      = "net_device_name"
+	NetDeviceKind      = "net_device_kind"
+	NetDeviceMAC       = "net_device_mac"
+	NetDeviceMTU       = "net_device_mtu"
+	NetDeviceState     = "net_device_state"
+	NetDeviceNamespace = "net_device_namespace"
+	NetDeviceOwner     = "net_device_owner"
+	NetDeviceMaster    = "net_device_master"
+	NetDevicePeer      = "net_device_peer"
+	NetDeviceAddresses = "net_device_addresses"
 )
 
 /* Lookup table to allow msgpack/json decoder to avoid heap allocation
@@ -103,9 +145,14 @@ var commonKeys = map[string]string{
 	ECSService:     ECSService,
 	ECSTask:        ECSTask,
 	SwarmService:   SwarmService,
+	SwarmTask:      SwarmTask,
+	SwarmNode:      SwarmNode,
+	SwarmNetwork:   SwarmNetwork,
+	NetDevice:      NetDevice,
 
 	HostNodeID:             HostNodeID,
 	ControlProbeID:         ControlProbeID,
+	Cluster:                Cluster,
 	DoesNotMakeConnections: DoesNotMakeConnections,
 
 	ReverseDNSNames: ReverseDNSNames,
@@ -177,13 +224,55 @@ var commonKeys = map[string]string{
 	KubernetesType:                 KubernetesType,
 	KubernetesPorts:                KubernetesPorts,
 
-	ECSCluster:             ECSCluster,
-	ECSCreatedAt:           ECSCreatedAt,
-	ECSTaskFamily:          ECSTaskFamily,
-	ECSServiceDesiredCount: ECSServiceDesiredCount,
-	ECSServiceRunningCount: ECSServiceRunningCount,
-	ECSScaleUp:             ECSScaleUp,
-	ECSScaleDown:           ECSScaleDown,
+	ECSCluster:                         ECSCluster,
+	ECSCreatedAt:                       ECSCreatedAt,
+	ECSTaskFamily:                      ECSTaskFamily,
+	ECSServiceDesiredCount:             ECSServiceDesiredCount,
+	ECSServiceRunningCount:             ECSServiceRunningCount,
+	ECSScaleUp:                         ECSScaleUp,
+	ECSScaleDown:                       ECSScaleDown,
+	ECSTaskDefinition:                  ECSTaskDefinition,
+	ECSTaskCPU:                         ECSTaskCPU,
+	ECSTaskMemory:                      ECSTaskMemory,
+	ECSLaunchType:                      ECSLaunchType,
+	ECSContainerInstanceEC2ID:          ECSContainerInstanceEC2ID,
+	ECSContainerInstanceStatus:         ECSContainerInstanceStatus,
+	ECSContainerInstanceAgentConnected: ECSContainerInstanceAgentConnected,
+	ECSContainerInstanceRunningTasks:   ECSContainerInstanceRunningTasks,
+	ECSContainerInstancePendingTasks:   ECSContainerInstancePendingTasks,
+	ECSServicePendingCount:             ECSServicePendingCount,
+
+	SwarmCluster:             SwarmCluster,
+	SwarmCreatedAt:           SwarmCreatedAt,
+	SwarmNodeHostname:        SwarmNodeHostname,
+	SwarmNodeRole:            SwarmNodeRole,
+	SwarmNodeAvailability:    SwarmNodeAvailability,
+	SwarmNodeState:           SwarmNodeState,
+	SwarmNodeManagerStatus:   SwarmNodeManagerStatus,
+	SwarmServiceMode:         SwarmServiceMode,
+	SwarmServiceImage:        SwarmServiceImage,
+	SwarmServiceDesiredTasks: SwarmServiceDesiredTasks,
+	SwarmServiceRunningTasks: SwarmServiceRunningTasks,
+	SwarmTaskName:            SwarmTaskName,
+	SwarmTaskState:           SwarmTaskState,
+	SwarmTaskDesiredState:    SwarmTaskDesiredState,
+	SwarmTaskMessage:         SwarmTaskMessage,
+	SwarmNetworkName:         SwarmNetworkName,
+	SwarmNetworkDriver:       SwarmNetworkDriver,
+	SwarmNetworkSubnets:      SwarmNetworkSubnets,
+	SwarmScaleUp:             SwarmScaleUp,
+	SwarmScaleDown:           SwarmScaleDown,
+
+	NetDeviceName:      NetDeviceName,
+	NetDeviceKind:      NetDeviceKind,
+	NetDeviceMAC:       NetDeviceMAC,
+	NetDeviceMTU:       NetDeviceMTU,
+	NetDeviceState:     NetDeviceState,
+	NetDeviceNamespace: NetDeviceNamespace,
+	NetDeviceOwner:     NetDeviceOwner,
+	NetDeviceMaster:    NetDeviceMaster,
+	NetDevicePeer:      NetDevicePeer,
+	NetDeviceAddresses: NetDeviceAddresses,
 }
 
 func lookupCommonKey(b []byte) string {
@@ -3,6 +3,8 @@ package report
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -29,6 +31,10 @@ const (
 	ECSService     = "ecs_service"
 	ECSTask        = "ecs_task"
 	SwarmService   = "swarm_service"
+	SwarmTask      = "swarm_task"
+	SwarmNode      = "swarm_node"
+	SwarmNetwork   = "swarm_network"
+	NetDevice      = "net_device"
 
 	// Shapes used for different nodes
 	Circle   = "circle"
@@ -63,6 +69,10 @@ var topologyNames = []string{
 	ECSTask,
 	ECSService,
 	SwarmService,
+	SwarmTask,
+	SwarmNode,
+	SwarmNetwork,
+	NetDevice,
 }
 
 // Report is the core data type. It's produced by probes, and consumed and
@@ -146,11 +156,35 @@ type Report struct {
 	// Edges are not present.
 	SwarmService Topology
 
+	// Swarm Task nodes are Docker Swarm tasks, each of which runs a
+	// container of a service on a Swarm node. Edges are not present.
+	SwarmTask Topology
+
+	// Swarm Node nodes are the Docker engines making up a Swarm cluster.
+	// Edges are not present.
+	SwarmNode Topology
+
+	// Swarm Network nodes are the overlay networks of a Swarm cluster.
+	// Edges are not present.
+	SwarmNetwork Topology
+
+	// NetDevice nodes are the network interfaces (devices) of the network
+	// namespaces of a host, e.g. veths, bridges and physical interfaces.
+	// Edges join the ends of veth pairs, and interfaces to the bridges
+	// they are attached to.
+	NetDevice Topology
+
 	// Overlay nodes are active peers in any software-defined network that's
 	// overlaid on the infrastructure. The information is scraped by polling
 	// their status endpoints. Edges are present.
 	Overlay Topology
 
+	// PluginTopologies are extra topologies, keyed by name, which are not
+	// known to Scope itself. They are typically defined by plugins, to show
+	// things like message queues or databases as first-class nodes. Their
+	// names must not clash with the names of the topologies above.
+	PluginTopologies map[string]Topology `deepequal:"nil==empty"`
+
 	DNS DNSRecords
 
 	// Sampling data for this report.
@@ -244,6 +278,24 @@ func MakeReport() Report {
 			WithShape(Heptagon).
 			WithLabel("service", "services"),
 
+		SwarmTask: MakeTopology().
+			WithShape(Hexagon).
+			WithLabel("task", "tasks"),
+
+		SwarmNode: MakeTopology().
+			WithShape(Circle).
+			WithLabel("node", "nodes"),
+
+		SwarmNetwork: MakeTopology().
+			WithShape(Cloud).
+			WithLabel("network", "networks"),
+
+		NetDevice: MakeTopology().
+			WithShape(Square).
+			WithLabel("interface", "interfaces"),
+
+		PluginTopologies: map[string]Topology{},
+
 		DNS: DNSRecords{},
 
 		Sampling: Sampling{},
@@ -286,25 +338,106 @@ func (r Report) Merge(other Report) Report {
 // WalkTopologies iterates through the Topologies of the report,
 // potentially modifying them
 func (r *Report) WalkTopologies(f func(*Topology)) {
-	for _, name := range topologyNames {
-		f(r.topology(name))
-	}
+	r.WalkNamedTopologies(func(_ string, t *Topology) { f(t) })
 }
 
 // WalkNamedTopologies iterates through the Topologies of the report,
-// potentially modifying them.
+// potentially modifying them. The map of plugin topologies may be shared
+// with shallow copies of the report, so it is copied before modifying any of
+// them, and left alone by walks which don't.
 func (r *Report) WalkNamedTopologies(f func(string, *Topology)) {
 	for _, name := range topologyNames {
 		f(name, r.topology(name))
 	}
+	var topologies map[string]Topology
+	for name, t := range r.PluginTopologies {
+		walked := t
+		f(name, &walked)
+		if sameTopology(t, walked) {
+			continue
+		}
+		if topologies == nil {
+			topologies = r.ownPluginTopologies()
+		}
+		topologies[name] = walked
+	}
+	if topologies != nil {
+		r.PluginTopologies = topologies
+	}
 }
 
 // WalkPairedTopologies iterates through the Topologies of this and another report,
-// potentially modifying one or both.
+// potentially modifying the receiver. Plugin topologies missing from one of
+// the reports are added to the receiver. The other report is never modified:
+// changes f makes to its plugin topologies are dropped.
 func (r *Report) WalkPairedTopologies(o *Report, f func(*Topology, *Topology)) {
 	for _, name := range topologyNames {
 		f(r.topology(name), o.topology(name))
 	}
+	if len(r.PluginTopologies) == 0 && len(o.PluginTopologies) == 0 {
+		return
+	}
+	topologies := r.ownPluginTopologies()
+	for name := range o.PluginTopologies {
+		if _, ok := topologies[name]; !ok {
+			topologies[name] = MakeTopology()
+		}
+	}
+	for name, ours := range topologies {
+		theirs, ok := o.PluginTopologies[name]
+		if !ok {
+			theirs = MakeTopology()
+		}
+		f(&ours, &theirs)
+		topologies[name] = ours
+	}
+	r.PluginTopologies = topologies
+}
+
+// ownPluginTopologies returns a copy of the map of the report's plugin
+// topologies, which can be modified without modifying those of the report.
+func (r Report) ownPluginTopologies() map[string]Topology {
+	topologies := make(map[string]Topology, len(r.PluginTopologies))
+	for name, t := range r.PluginTopologies {
+		topologies[name] = t
+	}
+	return topologies
+}
+
+// sameTopology returns true if two topologies have the same fields, sharing
+// the same maps, as when a walk leaves a topology alone.
+func sameTopology(a, b Topology) bool {
+	return a.Shape == b.Shape && a.Label == b.Label && a.LabelPlural == b.LabelPlural &&
+		sameMap(a.Nodes, b.Nodes) && sameMap(a.Controls, b.Controls) &&
+		sameMap(a.MetadataTemplates, b.MetadataTemplates) &&
+		sameMap(a.MetricTemplates, b.MetricTemplates) &&
+		sameMap(a.TableTemplates, b.TableTemplates)
+}
+
+func sameMap(a, b interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// PluginTopologyNames returns the sorted names of the report's plugin
+// topologies.
+func (r Report) PluginTopologyNames() []string {
+	names := make([]string, 0, len(r.PluginTopologies))
+	for name := range r.PluginTopologies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsPluginTopology returns true if name is not one of the topologies known
+// to Scope itself.
+func IsPluginTopology(name string) bool {
+	for _, n := range topologyNames {
+		if n == name {
+			return false
+		}
+	}
+	return true
 }
 
 // topology returns a reference to one of the report's topologies,
@@ -345,6 +478,14 @@ func (r *Report) topology(name string) *Topology {
 		return &r.ECSService
 	case SwarmService:
 		return &r.SwarmService
+	case SwarmTask:
+		return &r.SwarmTask
+	case SwarmNode:
+		return &r.SwarmNode
+	case SwarmNetwork:
+		return &r.SwarmNetwork
+	case NetDevice:
+		return &r.NetDevice
 	}
 	return nil
 }
@@ -354,6 +495,9 @@ func (r Report) Topology(name string) (Topology, bool) {
 	if t := r.topology(name); t != nil {
 		return *t, true
 	}
+	if t, ok := r.PluginTopologies[name]; ok {
+		return t, true
+	}
 	return Topology{}, false
 }
 
@@ -365,6 +509,13 @@ func (r Report) Validate() error {
 			errs = append(errs, err.Error())
 		}
 	}
+	for _, name := range r.PluginTopologyNames() {
+		if !IsPluginTopology(name) {
+			errs = append(errs, fmt.Sprintf("plugin topology %q clashes with a built-in topology", name))
+		} else if err := r.PluginTopologies[name].Validate(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
 	if r.Sampling.Count > r.Sampling.Total {
 		errs = append(errs, fmt.Sprintf("sampling count (%d) bigger than total (%d)", r.Sampling.Count, r.Sampling.Total))
 	}
@@ -565,4 +716,7 @@ const (
 	HostNodeID = "host_node_id"
 	// ControlProbeID is the random ID of the probe which controls the specific node.
 	ControlProbeID = "control_probe_id"
+	// Cluster is the name of the cluster of a node, in federating apps
+	// showing the reports of several clusters.
+	Cluster = "cluster"
 )
@@ -46,6 +46,74 @@ func TestReportTopology(t *testing.T) {
 	}
 }
 
+func TestReportPluginTopologies(t *testing.T) {
+	a, b := report.MakeReport(), report.MakeReport()
+	a.PluginTopologies["queues"] = report.MakeTopology().WithLabel("queue", "queues")
+	a.PluginTopologies["queues"].AddNode(report.MakeNode("host1;q1"))
+	b.PluginTopologies["queues"] = report.MakeTopology()
+	b.PluginTopologies["queues"].AddNode(report.MakeNode("host1;q2"))
+	b.PluginTopologies["databases"] = report.MakeTopology()
+	b.PluginTopologies["databases"].AddNode(report.MakeNode("host1;db1"))
+
+	merged := a.Merge(b)
+	if have := merged.PluginTopologyNames(); !reflect.DeepEqual([]string{"databases", "queues"}, have) {
+		t.Errorf("unexpected plugin topologies: %v", have)
+	}
+	queues, ok := merged.Topology("queues")
+	if !ok || len(queues.Nodes) != 2 || queues.LabelPlural != "queues" {
+		t.Errorf("unexpected merged topology: %v", queues)
+	}
+	if len(a.PluginTopologies) != 1 || len(a.PluginTopologies["queues"].Nodes) != 1 {
+		t.Errorf("merge modified the receiver: %v", a.PluginTopologies)
+	}
+
+	if err := merged.Validate(); err != nil {
+		t.Error(err)
+	}
+	merged.PluginTopologies[report.Host] = report.MakeTopology()
+	if err := merged.Validate(); err == nil {
+		t.Error("expected a clash with a built-in topology to be invalid")
+	}
+}
+
+// Copying, merging and walking a report must not write to its plugin
+// topologies, which are shared by the readers of a cached report.
+func TestReportPluginTopologiesShared(t *testing.T) {
+	r := report.MakeReport()
+	r.PluginTopologies["queues"] = report.MakeTopology()
+	r.PluginTopologies["queues"].AddNode(report.MakeNode("host1;q1"))
+
+	done := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 100; j++ {
+				switch i {
+				case 0:
+					r.Copy()
+				case 1:
+					report.MakeReport().Merge(r)
+				case 2:
+					rpt := r
+					rpt.WalkTopologies(func(*report.Topology) {})
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	walked := r
+	walked.WalkTopologies(func(t *report.Topology) { *t = t.WithLabel("queue", "queues") })
+	if queues := r.PluginTopologies["queues"]; queues.Label != "" {
+		t.Errorf("walking a copy modified the original: %v", queues)
+	}
+	if queues := walked.PluginTopologies["queues"]; queues.Label != "queue" || len(queues.Nodes) != 1 {
+		t.Errorf("unexpected walked topology: %v", queues)
+	}
+}
+
 func TestNode(t *testing.T) {
 	{
 		node := report.MakeNodeWith("foo", map[string]string{